@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"udv/internal/config"
     "udv/internal/api"
+	"udv/internal/adapter"
+	"udv/internal/adapter/memory"
 	"udv/internal/adapter/postgres"
+	"udv/internal/adapter/sqlite"
 	"udv/internal/schema"
 )
 
@@ -40,9 +46,21 @@ func main() {
 	// Log registry initialization
 	fmt.Printf("Schema registry initialized with %d model(s)\n", len(registry.ListModels()))
 
-	// Initialize database connection (optional)
-	var db *postgres.Database
-	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+	// Initialize database connection (optional); a SQLite file path
+	// selects the SQLite adapter
+	var dbAdapter adapter.Adapter
+	if dbURL := os.Getenv("DATABASE_URL"); sqlite.IsDatabasePath(dbURL) {
+		db, err := sqlite.Open(dbURL)
+		if err != nil {
+			fmt.Printf("Warning: Could not open SQLite database: %v\n", err)
+			fmt.Println("Running in SQL-generation-only mode")
+		} else {
+			defer db.Close()
+			fmt.Println("SQLite database opened")
+		}
+		dbAdapter = sqlite.NewAdapter(db)
+	} else if dbURL != "" {
+		var db *postgres.Database
 		var err error
 		db, err = postgres.Connect(dbURL)
 		if err != nil {
@@ -51,9 +69,23 @@ func main() {
 		} else {
 			defer db.Close()
 			fmt.Println("Database connection established")
+			if ok, err := db.HasExtension(context.Background(), "pg_trgm"); err == nil && !ok {
+				fmt.Println("Note: pg_trgm extension not installed; the similar operator is unavailable")
+			}
 		}
+		dbAdapter = postgres.NewAdapter(db)
+	} else if memory.HasFiles(cfg) {
+		// Models declaring data files are queried in memory
+		db, err := memory.Load(cfg, filepath.Dir(configPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load data files: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("DATABASE_URL not set, querying model data files in memory")
+		dbAdapter = memory.NewAdapter(db)
 	} else {
 		fmt.Println("DATABASE_URL not set, running in SQL-generation-only mode")
+		dbAdapter = postgres.NewAdapter(nil)
 	}
 
 	// Health check endpoint
@@ -64,7 +96,15 @@ func main() {
 	})
 
 	// Register API routes
-	apiSrv := api.New(registry, db)
+	apiSrv := api.New(registry, dbAdapter)
+	if depthStr := os.Getenv("MAX_FILTER_DEPTH"); depthStr != "" {
+		depth, err := strconv.Atoi(depthStr)
+		if err != nil || depth < 1 {
+			fmt.Fprintf(os.Stderr, "Invalid MAX_FILTER_DEPTH: %q\n", depthStr)
+			os.Exit(1)
+		}
+		apiSrv.SetMaxFilterDepth(depth)
+	}
 	apiSrv.RegisterRoutes(mux)
 
 
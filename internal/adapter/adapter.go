@@ -1,3 +1,230 @@
 package adapter
 
-// Package adapter defines database adapter interfaces
+// Package adapter defines database adapter interfaces: a Dialect renders
+// query plans in a database's SQL, an Executor runs that SQL. The API only
+// talks to these interfaces, so each database lives in its own package
+// (adapter/postgres, ...).
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// Row is one result row, keyed by output column name
+type Row = map[string]interface{}
+
+// Adapter pairs the dialect of a database with the executor connected to
+// it. Executor returns nil when there is no connection; queries are then
+// only rendered, not run.
+type Adapter interface {
+	Dialect() Dialect
+	Executor() Executor
+}
+
+// Dialect renders query plans as parameterized SQL for one database.
+// Implementations must be safe for concurrent use.
+type Dialect interface {
+	// Name identifies the database in messages, e.g. "PostgreSQL"
+	Name() string
+
+	BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error)
+	BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error)
+
+	// QuoteIdentifier quotes a table, column or alias name
+	QuoteIdentifier(name string) string
+
+	// TypeCast wraps a parameter placeholder in the cast its field type
+	// needs, or returns it unchanged
+	TypeCast(placeholder string, fieldType planner.FieldType) string
+
+	Capabilities() Capabilities
+}
+
+// Capabilities lists the optional query features a dialect can render.
+// Plans using a missing one are rejected before any SQL is built.
+type Capabilities struct {
+	FullTextSearch    bool // search operator
+	Similarity        bool // similar operator
+	JSONPaths         bool // fields below a json column
+	WindowFunctions   bool // query windows
+	Percentiles       bool // median and percentile aggregates
+	StatisticalAggs   bool // stddev and variance aggregates
+	FilteredAggregate bool // per-aggregate filters
+	ArrayAggregates   bool // array_agg, bool_and and bool_or aggregates
+	TimeSeries        bool // bucketed time series with gap filling
+}
+
+// Executor runs SQL rendered by the dialect of the same adapter.
+// Implementations must be safe for concurrent use.
+type Executor interface {
+	// Fetch runs a query and returns all its rows
+	Fetch(ctx context.Context, sql string, args ...interface{}) ([]Row, error)
+
+	// Stream runs a query and calls fn for each row as it is read, stopping
+	// at the first error fn returns
+	Stream(ctx context.Context, sql string, args []interface{}, fn func(Row) error) error
+
+	Close() error
+}
+
+// ScanRows reads database/sql rows into Rows, calling fn for each as it is
+// scanned and stopping at the first error fn returns. Byte values become
+// strings, for JSON serialization. It does not close rows.
+func ScanRows(rows *sql.Rows, fn func(Row) error) error {
+	// Get column names
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	for rows.Next() {
+		// Create a slice of interface{} to hold the values
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+
+		// Scan the row
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		// Convert to map
+		entry := make(Row, len(columns))
+		for i, col := range columns {
+			val := values[i]
+			// Convert []byte to string for better JSON serialization
+			if b, ok := val.([]byte); ok {
+				entry[col] = string(b)
+			} else {
+				entry[col] = val
+			}
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
+
+// Counter is implemented by executors that can count the rows of a plan
+// across all pages, exactly or estimated
+type Counter interface {
+	CountRows(ctx context.Context, plan *planner.QueryPlan, estimate bool) (int64, error)
+}
+
+// PlanExecutor is implemented by executors that evaluate query plans
+// themselves rather than running SQL, such as the in-memory engine. Callers
+// hand them the plan instead of the dialect's SQL.
+type PlanExecutor interface {
+	FetchPlan(ctx context.Context, plan *planner.QueryPlan) ([]Row, error)
+}
+
+// PlanChecker is implemented by executors whose database may lack
+// something a plan needs (e.g. an extension). CheckPlan returns an
+// *UnsupportedError naming it.
+type PlanChecker interface {
+	CheckPlan(ctx context.Context, plan *planner.QueryPlan) error
+}
+
+// UnsupportedError reports a plan an adapter cannot run
+type UnsupportedError struct {
+	Feature string
+	Reason  string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Reason
+}
+
+// CheckCapabilities returns an *UnsupportedError for the first feature of
+// plan the dialect cannot render
+func CheckCapabilities(d Dialect, plan *planner.QueryPlan) error {
+	caps := d.Capabilities()
+	unsupported := func(feature string) error {
+		return &UnsupportedError{
+			Feature: feature,
+			Reason:  fmt.Sprintf("%s is not supported by the %s adapter", feature, d.Name()),
+		}
+	}
+
+	var err error
+	column := func(ref *planner.ColumnRef) {
+		if err == nil && ref != nil && len(ref.JSONPath) > 0 && !caps.JSONPaths {
+			err = unsupported("json path " + ref.ColumnName + "." + strings.Join(ref.JSONPath, "."))
+		}
+	}
+	var walk func(expr planner.FilterExpr)
+	walk = func(expr planner.FilterExpr) {
+		switch e := expr.(type) {
+		case *planner.ComparisonFilterIR:
+			column(&e.Left)
+			switch {
+			case err != nil:
+			case e.Operator == dsl.OpSearch && !caps.FullTextSearch:
+				err = unsupported("the search operator")
+			case e.Operator == dsl.OpSimilar && !caps.Similarity:
+				err = unsupported("the similar operator")
+			}
+		case *planner.LogicalFilterIR:
+			for _, node := range e.Nodes {
+				walk(node)
+			}
+		}
+	}
+
+	for i := range plan.Select {
+		column(&plan.Select[i].Column)
+	}
+	walk(plan.Filters)
+	for i := range plan.GroupBy {
+		column(&plan.GroupBy[i].Column)
+	}
+	for _, agg := range plan.Aggregates {
+		column(agg.Column)
+		if err != nil {
+			return err
+		}
+		switch agg.Function {
+		case planner.AggMedianFn, planner.AggPercentileFn:
+			if !caps.Percentiles {
+				return unsupported("the " + strings.ToLower(string(agg.Function)) + " aggregate")
+			}
+		case planner.AggStddevFn, planner.AggVarianceFn:
+			if !caps.StatisticalAggs {
+				return unsupported("the " + strings.ToLower(string(agg.Function)) + " aggregate")
+			}
+		case planner.AggArrayAggFn, planner.AggBoolAndFn, planner.AggBoolOrFn:
+			if !caps.ArrayAggregates {
+				return unsupported("the " + strings.ToLower(string(agg.Function)) + " aggregate")
+			}
+		}
+		if agg.Filter != nil {
+			if !caps.FilteredAggregate {
+				return unsupported("aggregate filters")
+			}
+			walk(agg.Filter)
+		}
+	}
+	walk(plan.Having)
+	if len(plan.Windows) > 0 && !caps.WindowFunctions {
+		return unsupported("window functions")
+	}
+	for _, s := range plan.Sort {
+		column(s.Column)
+		if s.Search != nil {
+			walk(s.Search)
+		}
+	}
+	return err
+}
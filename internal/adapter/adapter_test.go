@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// stubDialect renders nothing; it only reports capabilities
+type stubDialect struct {
+	caps Capabilities
+}
+
+func (stubDialect) Name() string { return "Stub" }
+
+func (stubDialect) BuildQuery(*planner.QueryPlan) (string, []interface{}, error) {
+	return "", nil, nil
+}
+
+func (stubDialect) BuildTimeSeriesQuery(*planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return "", nil, nil
+}
+
+func (stubDialect) QuoteIdentifier(name string) string { return name }
+
+func (stubDialect) TypeCast(placeholder string, _ planner.FieldType) string { return placeholder }
+
+func (d stubDialect) Capabilities() Capabilities { return d.caps }
+
+func TestCheckCapabilities(t *testing.T) {
+	status := planner.ColumnRef{TableAlias: "t0", ColumnName: "status", DataType: planner.TypeString}
+	amount := planner.ColumnRef{TableAlias: "t0", ColumnName: "amount", DataType: planner.TypeDecimal}
+	country := planner.ColumnRef{TableAlias: "t0", ColumnName: "metadata", DataType: planner.TypeString, JSONPath: []string{"address", "country"}}
+
+	tests := []struct {
+		name    string
+		plan    *planner.QueryPlan
+		feature string
+	}{
+		{
+			"plain query",
+			&planner.QueryPlan{
+				Select:  []planner.SelectExpr{{Column: status, Alias: "status"}},
+				Filters: &planner.ComparisonFilterIR{Left: amount, Operator: dsl.OpGT},
+			},
+			"",
+		},
+		{
+			"similar under or",
+			&planner.QueryPlan{
+				Filters: &planner.LogicalFilterIR{Op: "OR", Nodes: []planner.FilterExpr{
+					&planner.ComparisonFilterIR{Left: amount, Operator: dsl.OpGT},
+					&planner.ComparisonFilterIR{Left: status, Operator: dsl.OpSimilar},
+				}},
+			},
+			"the similar operator",
+		},
+		{
+			"json path in group by",
+			&planner.QueryPlan{GroupBy: []planner.GroupExpr{{Column: country, Alias: "metadata.address.country"}}},
+			"json path metadata.address.country",
+		},
+		{
+			"median",
+			&planner.QueryPlan{Aggregates: []planner.AggregateExpr{{Function: planner.AggMedianFn, Column: &amount, Alias: "m"}}},
+			"the median aggregate",
+		},
+		{
+			"aggregate filter",
+			&planner.QueryPlan{Aggregates: []planner.AggregateExpr{{
+				Function: planner.AggCountFn,
+				Alias:    "paid",
+				Filter:   &planner.ComparisonFilterIR{Left: status, Operator: dsl.OpEqual},
+			}}},
+			"aggregate filters",
+		},
+		{
+			"window",
+			&planner.QueryPlan{Windows: []planner.WindowExpr{{Function: planner.WinRowNumberFn, Alias: "n"}}},
+			"window functions",
+		},
+	}
+
+	d := stubDialect{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCapabilities(d, tt.plan)
+			if tt.feature == "" {
+				if err != nil {
+					t.Fatalf("CheckCapabilities() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var unsupported *UnsupportedError
+			if !errors.As(err, &unsupported) {
+				t.Fatalf("CheckCapabilities() error = %v, want *UnsupportedError", err)
+			}
+			if unsupported.Feature != tt.feature {
+				t.Errorf("Feature = %q, want %q", unsupported.Feature, tt.feature)
+			}
+			if !strings.Contains(err.Error(), "not supported by the Stub adapter") {
+				t.Errorf("error = %v, want to name the adapter", err)
+			}
+
+			// Every feature is fine for a dialect that has them all
+			all := stubDialect{caps: Capabilities{
+				FullTextSearch: true, Similarity: true, JSONPaths: true, WindowFunctions: true,
+				Percentiles: true, StatisticalAggs: true, FilteredAggregate: true, ArrayAggregates: true,
+			}}
+			if err := CheckCapabilities(all, tt.plan); err != nil {
+				t.Errorf("CheckCapabilities() with all capabilities error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package memory
+
+// Package memory evaluates query plans in Go over rows loaded from CSV,
+// JSON or NDJSON files, with no database. It follows PostgreSQL semantics
+// (three-valued logic, NULL ordering, percentile_cont, ...), so it doubles
+// as a reference engine for differential tests of the SQL dialects.
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"udv/internal/adapter"
+	"udv/internal/config"
+	"udv/internal/planner"
+)
+
+// Dialect describes plans instead of rendering SQL: the engine evaluates
+// the plan itself, so the query text is only shown to callers
+type Dialect struct{}
+
+var _ adapter.Dialect = Dialect{}
+
+// Name implements adapter.Dialect
+func (Dialect) Name() string {
+	return "in-memory"
+}
+
+// BuildQuery implements adapter.Dialect, returning a comment naming the
+// evaluated model and no parameters
+func (Dialect) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	if plan == nil || plan.RootModel == nil {
+		return "", nil, fmt.Errorf("query plan has no root model")
+	}
+	return fmt.Sprintf("-- evaluated in memory over %s", plan.RootModel.Table), []interface{}{}, nil
+}
+
+// BuildTimeSeriesQuery implements adapter.Dialect. Time series are not
+// supported (see Capabilities).
+func (Dialect) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("time series are not supported by the in-memory engine")
+}
+
+// QuoteIdentifier implements adapter.Dialect; names are map keys, so they
+// need no quoting
+func (Dialect) QuoteIdentifier(name string) string {
+	return name
+}
+
+// TypeCast implements adapter.Dialect; values are compared as typed Go
+// values, so placeholders need no casts
+func (Dialect) TypeCast(placeholder string, fieldType planner.FieldType) string {
+	return placeholder
+}
+
+// Capabilities implements adapter.Dialect. Text search, similarity and
+// window functions have no in-memory implementation.
+func (Dialect) Capabilities() adapter.Capabilities {
+	return adapter.Capabilities{
+		JSONPaths:         true,
+		Percentiles:       true,
+		StatisticalAggs:   true,
+		FilteredAggregate: true,
+		ArrayAggregates:   true,
+	}
+}
+
+// Adapter is the in-memory adapter.Adapter
+type Adapter struct {
+	db *Database
+}
+
+var (
+	_ adapter.Adapter      = (*Adapter)(nil)
+	_ adapter.Executor     = (*Database)(nil)
+	_ adapter.PlanExecutor = (*Database)(nil)
+	_ adapter.Counter      = (*Database)(nil)
+)
+
+// NewAdapter returns the in-memory adapter evaluating plans over db, or
+// only describing them when db is nil
+func NewAdapter(db *Database) *Adapter {
+	return &Adapter{db: db}
+}
+
+// Dialect implements adapter.Adapter
+func (a *Adapter) Dialect() adapter.Dialect {
+	return Dialect{}
+}
+
+// Executor implements adapter.Adapter
+func (a *Adapter) Executor() adapter.Executor {
+	if a.db == nil {
+		return nil
+	}
+	return a.db
+}
+
+// Database holds the rows of each table in memory. Tables are read-only
+// once loaded, so a Database is safe for concurrent use.
+type Database struct {
+	tables map[string][]adapter.Row
+}
+
+// NewDatabase wraps rows already in memory, keyed by table name. Values
+// must be typed as Load types them (int64, float64, bool, time.Time,
+// string, or decoded JSON for json fields).
+func NewDatabase(tables map[string][]adapter.Row) *Database {
+	return &Database{tables: tables}
+}
+
+// HasFiles reports whether any model of cfg declares a data file
+func HasFiles(cfg *config.Config) bool {
+	for _, model := range cfg.Models {
+		if model.File != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the data file of every model that declares one. Relative
+// paths are resolved against baseDir, the directory of the config file.
+func Load(cfg *config.Config, baseDir string) (*Database, error) {
+	tables := make(map[string][]adapter.Row)
+	for i := range cfg.Models {
+		model := &cfg.Models[i]
+		if model.File == "" {
+			continue
+		}
+		if _, loaded := tables[model.Table]; loaded {
+			return nil, fmt.Errorf("model %s: table %s already has a data file", model.Name, model.Table)
+		}
+
+		path := model.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		rows, err := loadFile(path, model)
+		if err != nil {
+			return nil, fmt.Errorf("model %s: %w", model.Name, err)
+		}
+		tables[model.Table] = rows
+	}
+	return &Database{tables: tables}, nil
+}
+
+// Close implements adapter.Executor; there is nothing to release
+func (d *Database) Close() error {
+	return nil
+}
+
+// Fetch implements adapter.Executor. The engine evaluates plans, not SQL:
+// use FetchPlan.
+func (d *Database) Fetch(ctx context.Context, sql string, args ...interface{}) ([]adapter.Row, error) {
+	return nil, fmt.Errorf("the in-memory engine evaluates query plans, not SQL")
+}
+
+// Stream implements adapter.Executor, see Fetch
+func (d *Database) Stream(ctx context.Context, sql string, args []interface{}, fn func(adapter.Row) error) error {
+	return fmt.Errorf("the in-memory engine evaluates query plans, not SQL")
+}
+
+// FetchPlan implements adapter.PlanExecutor: it evaluates the plan over the
+// loaded tables and returns one page of rows
+func (d *Database) FetchPlan(ctx context.Context, plan *planner.QueryPlan) ([]adapter.Row, error) {
+	e := &engine{db: d, plan: plan}
+	results, err := e.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results, err = e.paginate(results)
+	if err != nil {
+		return nil, err
+	}
+	return e.project(results)
+}
+
+// CountRows implements adapter.Counter. Counts are always exact: the rows
+// (or groups) matching the plan across all pages.
+func (d *Database) CountRows(ctx context.Context, plan *planner.QueryPlan, estimate bool) (int64, error) {
+	e := &engine{db: d, plan: plan}
+	results, err := e.run(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(results)), nil
+}
@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"udv/internal/planner"
+)
+
+// aggregate computes one aggregate over the tuples of a group that pass
+// its compiled filter. Like SQL aggregates, all but COUNT and ARRAY_AGG skip
+// NULLs and return NULL when no value is left.
+func aggregate(agg planner.AggregateExpr, filter predicate, tuples []tuple) (interface{}, error) {
+	var rows int64
+	var values []interface{} // non-NULL values
+	var all []interface{}    // every value, for ARRAY_AGG
+	for _, t := range tuples {
+		ok, err := filter(t, nil)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate %s filter: %w", agg.Alias, err)
+		}
+		if ok != truthTrue {
+			continue
+		}
+		rows++
+		if agg.Column == nil {
+			continue
+		}
+		v, err := value(t, *agg.Column)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, v)
+		if v != nil {
+			values = append(values, v)
+		}
+	}
+
+	if agg.Column == nil {
+		if agg.Function != planner.AggCountFn {
+			return nil, fmt.Errorf("aggregate %s requires a field", agg.Alias)
+		}
+		return rows, nil
+	}
+
+	switch agg.Function {
+	case planner.AggCountFn:
+		return int64(len(values)), nil
+
+	case planner.AggCountDistinctFn:
+		seen := make(map[string]bool, len(values))
+		for _, v := range values {
+			seen[key(v)] = true
+		}
+		return int64(len(seen)), nil
+
+	case planner.AggArrayAggFn:
+		if len(all) == 0 {
+			return nil, nil
+		}
+		return all, nil
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	switch agg.Function {
+	case planner.AggSumFn:
+		var intSum int64
+		var floatSum float64
+		integral := true
+		for _, v := range values {
+			if n, ok := v.(int64); ok && integral {
+				intSum += n
+				continue
+			}
+			f, ok := number(v)
+			if !ok {
+				return nil, fmt.Errorf("aggregate %s: cannot sum %v", agg.Alias, v)
+			}
+			if integral {
+				floatSum, integral = float64(intSum), false
+			}
+			floatSum += f
+		}
+		if integral {
+			return intSum, nil
+		}
+		return floatSum, nil
+
+	case planner.AggAvgFn:
+		numbers, err := toNumbers(agg, values)
+		if err != nil {
+			return nil, err
+		}
+		return mean(numbers), nil
+
+	case planner.AggMinFn, planner.AggMaxFn:
+		best := values[0]
+		for _, v := range values[1:] {
+			c, err := compare(v, best)
+			if err != nil {
+				return nil, fmt.Errorf("aggregate %s: %w", agg.Alias, err)
+			}
+			if (c < 0) == (agg.Function == planner.AggMinFn) && c != 0 {
+				best = v
+			}
+		}
+		return best, nil
+
+	case planner.AggMedianFn, planner.AggPercentileFn:
+		numbers, err := toNumbers(agg, values)
+		if err != nil {
+			return nil, err
+		}
+		fraction := agg.Fraction
+		if agg.Function == planner.AggMedianFn {
+			fraction = 0.5
+		}
+		return percentileCont(numbers, fraction), nil
+
+	case planner.AggStddevFn, planner.AggVarianceFn:
+		numbers, err := toNumbers(agg, values)
+		if err != nil {
+			return nil, err
+		}
+		// Sample statistics, undefined for a single value
+		if len(numbers) < 2 {
+			return nil, nil
+		}
+		m := mean(numbers)
+		var squares float64
+		for _, f := range numbers {
+			squares += (f - m) * (f - m)
+		}
+		variance := squares / float64(len(numbers)-1)
+		if agg.Function == planner.AggStddevFn {
+			return math.Sqrt(variance), nil
+		}
+		return variance, nil
+
+	case planner.AggStringAggFn:
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = text(v)
+		}
+		return strings.Join(parts, agg.Separator), nil
+
+	case planner.AggBoolAndFn, planner.AggBoolOrFn:
+		and := agg.Function == planner.AggBoolAndFn
+		result := and
+		for _, v := range values {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("aggregate %s: %v is not a boolean", agg.Alias, v)
+			}
+			if b != and {
+				result = b
+			}
+		}
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("aggregate %s is not supported by the in-memory engine", agg.Function)
+	}
+}
+
+// toNumbers converts the values of a numeric aggregate to float64
+func toNumbers(agg planner.AggregateExpr, values []interface{}) ([]float64, error) {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		f, ok := number(v)
+		if !ok {
+			return nil, fmt.Errorf("aggregate %s: %v is not a number", agg.Alias, v)
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+func mean(numbers []float64) float64 {
+	var sum float64
+	for _, f := range numbers {
+		sum += f
+	}
+	return sum / float64(len(numbers))
+}
+
+// percentileCont interpolates the value at fraction of the sorted values,
+// like PostgreSQL's percentile_cont
+func percentileCont(numbers []float64, fraction float64) float64 {
+	sort.Float64s(numbers)
+	pos := fraction * float64(len(numbers)-1)
+	lower := math.Floor(pos)
+	upper := math.Ceil(pos)
+	lo, hi := numbers[int(lower)], numbers[int(upper)]
+	return lo + (hi-lo)*(pos-lower)
+}
@@ -0,0 +1,379 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"udv/internal/adapter"
+	"udv/internal/planner"
+)
+
+// engine evaluates one query plan over a Database, in the order SQL
+// defines: joins, WHERE, GROUP BY with aggregates, HAVING, ORDER BY, then
+// the page
+type engine struct {
+	db   *Database
+	plan *planner.QueryPlan
+}
+
+// result is one output row before projection: a joined tuple, or a group
+// with its tuples, grouping values and aggregates
+type result struct {
+	tuples []tuple
+	group  []interface{}
+	aggs   map[string]interface{}
+}
+
+// first returns the tuple group-level expressions are read from: the row
+// itself, or any row of a group (they agree on grouped columns)
+func (r *result) first() tuple {
+	if len(r.tuples) == 0 {
+		return tuple{}
+	}
+	return r.tuples[0]
+}
+
+// grouped reports whether the plan aggregates rows into groups
+func (e *engine) grouped() bool {
+	return len(e.plan.GroupBy) > 0 || len(e.plan.Aggregates) > 0
+}
+
+// run evaluates the plan up to ORDER BY: every result across all pages
+func (e *engine) run(ctx context.Context) ([]*result, error) {
+	if e.plan == nil || e.plan.RootModel == nil {
+		return nil, fmt.Errorf("query plan has no root model")
+	}
+	if len(e.plan.Windows) > 0 {
+		return nil, fmt.Errorf("window functions are not supported by the in-memory engine")
+	}
+
+	tuples, err := e.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	where, err := compileFilter(e.plan.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile filters: %w", err)
+	}
+	matched := tuples[:0]
+	for i, t := range tuples {
+		if i%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		ok, err := where(t, nil)
+		if err != nil {
+			return nil, err
+		}
+		if ok == truthTrue {
+			matched = append(matched, t)
+		}
+	}
+
+	var results []*result
+	if e.grouped() {
+		if results, err = e.group(matched); err != nil {
+			return nil, err
+		}
+	} else {
+		results = make([]*result, len(matched))
+		for i, t := range matched {
+			results[i] = &result{tuples: []tuple{t}}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := e.sort(results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// table returns the rows loaded for a table
+func (e *engine) table(name string) ([]adapter.Row, error) {
+	rows, ok := e.db.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("no data file is loaded for table %s", name)
+	}
+	return rows, nil
+}
+
+// scan reads the root table and applies the joins of the plan, each as a
+// hash join on its ON columns
+func (e *engine) scan() ([]tuple, error) {
+	rows, err := e.table(e.plan.RootModel.Table)
+	if err != nil {
+		return nil, err
+	}
+	tuples := make([]tuple, len(rows))
+	for i, row := range rows {
+		tuples[i] = tuple{e.plan.RootModel.Alias: row}
+	}
+
+	for _, join := range e.plan.Joins {
+		rows, err := e.table(join.ToTable)
+		if err != nil {
+			return nil, err
+		}
+		index := make(map[string][]adapter.Row)
+		for _, row := range rows {
+			if v := row[join.On.Right.ColumnName]; v != nil {
+				index[key(v)] = append(index[key(v)], row)
+			}
+		}
+
+		var joined []tuple
+		for _, t := range tuples {
+			var matches []adapter.Row
+			if v := t[join.On.Left.TableAlias][join.On.Left.ColumnName]; v != nil {
+				matches = index[key(v)]
+			}
+			if len(matches) == 0 && join.Type == planner.JoinLeft {
+				matches = []adapter.Row{nil}
+			}
+			for _, row := range matches {
+				next := make(tuple, len(t)+1)
+				for alias, r := range t {
+					next[alias] = r
+				}
+				next[join.ToAlias] = row
+				joined = append(joined, next)
+			}
+		}
+		tuples = joined
+	}
+	return tuples, nil
+}
+
+// group buckets tuples by the GROUP BY values, in order of first
+// appearance, then computes the aggregates of each group and applies
+// HAVING. Without GROUP BY all tuples form one group, even when there are
+// none.
+func (e *engine) group(tuples []tuple) ([]*result, error) {
+	var results []*result
+	index := make(map[string]*result)
+	for _, t := range tuples {
+		values := make([]interface{}, len(e.plan.GroupBy))
+		keys := make([]string, len(e.plan.GroupBy))
+		for i, g := range e.plan.GroupBy {
+			v, err := value(t, g.Column)
+			if err != nil {
+				return nil, err
+			}
+			if g.Granularity != "" {
+				if v, err = truncate(v, g.Granularity, g.Timezone); err != nil {
+					return nil, err
+				}
+			}
+			values[i] = v
+			keys[i] = key(v)
+		}
+
+		k := strings.Join(keys, "\x00")
+		r, ok := index[k]
+		if !ok {
+			r = &result{group: values}
+			index[k] = r
+			results = append(results, r)
+		}
+		r.tuples = append(r.tuples, t)
+	}
+	if len(e.plan.GroupBy) == 0 && len(results) == 0 {
+		results = []*result{{}}
+	}
+
+	filters := make([]predicate, len(e.plan.Aggregates))
+	for i, agg := range e.plan.Aggregates {
+		filter, err := compileFilter(agg.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate %s filter: %w", agg.Alias, err)
+		}
+		filters[i] = filter
+	}
+	having, err := compileFilter(e.plan.Having)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile having: %w", err)
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		r.aggs = make(map[string]interface{}, len(e.plan.Aggregates))
+		for i, agg := range e.plan.Aggregates {
+			v, err := aggregate(agg, filters[i], r.tuples)
+			if err != nil {
+				return nil, err
+			}
+			r.aggs[agg.Alias] = v
+		}
+
+		ok, err := having(r.first(), r.aggs)
+		if err != nil {
+			return nil, err
+		}
+		if ok == truthTrue {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
+
+// sortValue returns the value a result is ordered by for one sort
+// expression
+func sortValue(r *result, s planner.SortExpr) (interface{}, error) {
+	switch {
+	case s.Target == planner.SortAggregate && s.Aggregate != nil:
+		return r.aggs[s.Aggregate.Alias], nil
+	case s.Target == planner.SortColumn && s.Column != nil:
+		return value(r.first(), *s.Column)
+	default:
+		return nil, fmt.Errorf("sorting by %s is not supported by the in-memory engine", strings.ToLower(string(s.Target)))
+	}
+}
+
+// sort orders results by the plan's sort expressions. The sort is stable,
+// so ties keep the order of the data files.
+func (e *engine) sort(results []*result) error {
+	if len(e.plan.Sort) == 0 {
+		return nil
+	}
+
+	keys := make([][]interface{}, len(results))
+	for i, r := range results {
+		keys[i] = make([]interface{}, len(e.plan.Sort))
+		for j, s := range e.plan.Sort {
+			v, err := sortValue(r, s)
+			if err != nil {
+				return err
+			}
+			keys[i][j] = v
+		}
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	var err error
+	sort.SliceStable(order, func(a, b int) bool {
+		for j, s := range e.plan.Sort {
+			c, cmpErr := orderValues(keys[order[a]][j], keys[order[b]][j], s.Direction)
+			if cmpErr != nil {
+				err = cmpErr
+				return false
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sort: %w", err)
+	}
+
+	sorted := make([]*result, len(results))
+	for i, idx := range order {
+		sorted[i] = results[idx]
+	}
+	copy(results, sorted)
+	return nil
+}
+
+// paginate cuts the page out of the sorted results: after the keyset
+// cursor, or by offset, then up to the limit
+func (e *engine) paginate(results []*result) ([]*result, error) {
+	if k := e.plan.Keyset; k != nil && k.After != nil {
+		after := make([]interface{}, len(k.Keys))
+		for i, key := range k.Keys {
+			after[i] = operand(key.Column.DataType, k.After[i])
+		}
+
+		start := len(results)
+		for i, r := range results {
+			c, err := e.compareKeyset(r, after)
+			if err != nil {
+				return nil, err
+			}
+			if c > 0 {
+				start = i
+				break
+			}
+		}
+		results = results[start:]
+	} else if e.plan.Keyset == nil {
+		offset := e.plan.Pagination.Offset
+		if offset > len(results) {
+			offset = len(results)
+		}
+		results = results[offset:]
+	}
+
+	if limit := e.plan.Pagination.Limit; limit >= 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// compareKeyset compares a result with the keyset cursor position, in the
+// keyset's ordering. Results are sorted by that ordering, so the page
+// starts at the first result after the cursor.
+func (e *engine) compareKeyset(r *result, after []interface{}) (int, error) {
+	for i, key := range e.plan.Keyset.Keys {
+		v, err := value(r.first(), key.Column)
+		if err != nil {
+			return 0, err
+		}
+		c, err := orderValues(v, after[i], key.Direction)
+		if err != nil {
+			return 0, fmt.Errorf("cursor key %s: %w", key.Field, err)
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// project builds the output rows, keyed as the SQL dialects name their
+// columns: selected fields by alias, grouping columns by name (by alias when
+// bucketed or renamed), aggregates by alias, and otherwise every column of
+// the root table
+func (e *engine) project(results []*result) ([]adapter.Row, error) {
+	rows := make([]adapter.Row, 0, len(results))
+	for _, r := range results {
+		row := make(adapter.Row)
+		switch {
+		case len(e.plan.Select) > 0:
+			for _, s := range e.plan.Select {
+				v, err := value(r.first(), s.Column)
+				if err != nil {
+					return nil, err
+				}
+				row[s.Alias] = v
+			}
+		case e.grouped():
+			for i, g := range e.plan.GroupBy {
+				name := g.Column.ColumnName
+				if g.Granularity != "" || (g.Alias != "" && g.Alias != name) {
+					name = g.Alias
+				}
+				row[name] = r.group[i]
+			}
+		default:
+			for column, v := range r.first()[e.plan.RootModel.Alias] {
+				row[column] = v
+			}
+		}
+		for alias, v := range r.aggs {
+			row[alias] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
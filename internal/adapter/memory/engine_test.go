@@ -0,0 +1,485 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"udv/internal/adapter"
+	"udv/internal/config"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+	"udv/internal/schema"
+)
+
+func setupTestRegistry(t *testing.T) *schema.Registry {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer", Nullable: false},
+					{Name: "user_id", Type: "integer", Nullable: false},
+					{Name: "status", Type: "string", Nullable: false},
+					{Name: "amount", Type: "decimal", Nullable: false},
+					{Name: "created_at", Type: "timestamp", Nullable: false},
+					{Name: "is_paid", Type: "boolean", Nullable: false},
+					{Name: "metadata", Type: "json", Nullable: true},
+					{Name: "coupon", Type: "string", Nullable: true},
+				},
+			},
+			{
+				Name:       "users",
+				Table:      "users",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "string"},
+					{Name: "country", Type: "string"},
+				},
+			},
+		},
+	}
+
+	reg := schema.NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig error: %v", err)
+	}
+	if err := reg.AddRelation("orders", &schema.Relation{Name: "user", Type: schema.ManyToOne, TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}); err != nil {
+		t.Fatalf("AddRelation error: %v", err)
+	}
+	return reg
+}
+
+// setupTestDatabase returns rows typed as Load types them
+func setupTestDatabase() *Database {
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	order := func(id, userID int64, status string, amount float64, createdAt string, paid bool, metadata interface{}, coupon interface{}) adapter.Row {
+		return adapter.Row{
+			"id": id, "user_id": userID, "status": status, "amount": amount,
+			"created_at": at(createdAt), "is_paid": paid, "metadata": metadata, "coupon": coupon,
+		}
+	}
+
+	return NewDatabase(map[string][]adapter.Row{
+		"orders": {
+			order(1, 1, "PAID", 120.5, "2024-01-01T10:00:00Z", true,
+				map[string]interface{}{"priority": int64(2), "tags": []interface{}{"gift"}, "shipping": map[string]interface{}{"country": "NL"}}, nil),
+			order(2, 1, "SHIPPED", 80, "2024-01-01T23:30:00Z", true,
+				map[string]interface{}{"priority": int64(1), "shipping": map[string]interface{}{"country": "DE"}}, "WELCOME"),
+			order(3, 2, "PAID", 40, "2024-01-02T08:00:00Z", false, nil, nil),
+			order(4, 3, "REFUNDED", 15.25, "2024-02-10T12:00:00Z", false,
+				map[string]interface{}{"tags": []interface{}{"fragile", "gift"}}, "SPRING"),
+			order(5, 2, "PAID", 300, "2024-02-11T09:15:00Z", true,
+				map[string]interface{}{"priority": int64(3), "shipping": map[string]interface{}{"country": "NL"}}, nil),
+		},
+		"users": {
+			{"id": int64(1), "name": "Ann", "country": "NL"},
+			{"id": int64(2), "name": "Bob", "country": "DE"},
+			{"id": int64(3), "name": "Cy", "country": "NL"},
+		},
+	})
+}
+
+// plan plans a query, failing the test on error
+func plan(t *testing.T, reg *schema.Registry, q *dsl.Query) *planner.QueryPlan {
+	t.Helper()
+	p, err := planner.NewPlanner(reg).PlanQuery(q)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+	return p
+}
+
+func TestFetchPlan(t *testing.T) {
+	reg := setupTestRegistry(t)
+	db := setupTestDatabase()
+	fraction := 0.25
+
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected []adapter.Row
+	}{
+		{
+			"filter sort and page",
+			&dsl.Query{
+				Model:      "orders",
+				Fields:     []string{"id", "amount"},
+				Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				Sort:       []dsl.Sort{{Field: "amount", Direction: dsl.SortDesc}},
+				Pagination: &dsl.Pagination{Limit: 2},
+			},
+			[]adapter.Row{{"id": int64(5), "amount": 300.0}, {"id": int64(1), "amount": 120.5}},
+		},
+		{
+			"offset",
+			&dsl.Query{
+				Model:      "orders",
+				Fields:     []string{"id"},
+				Sort:       []dsl.Sort{{Field: "id"}},
+				Pagination: &dsl.Pagination{Limit: 2, Offset: 3},
+			},
+			[]adapter.Row{{"id": int64(4)}, {"id": int64(5)}},
+		},
+		{
+			// NULL coupons are neither equal nor unequal to WELCOME
+			"three-valued not",
+			&dsl.Query{
+				Model:   "orders",
+				Fields:  []string{"id"},
+				Filters: &dsl.LogicalFilter{Not: &dsl.ComparisonFilter{Field: "coupon", Op: dsl.OpEqual, Value: "WELCOME"}},
+			},
+			[]adapter.Row{{"id": int64(4)}},
+		},
+		{
+			"string and range operators",
+			&dsl.Query{
+				Model:  "orders",
+				Fields: []string{"id"},
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpILike, Value: "p%"},
+					&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpBetween, Value: []interface{}{50, 200}},
+				}},
+			},
+			[]adapter.Row{{"id": int64(1)}},
+		},
+		{
+			"in and not null",
+			&dsl.Query{
+				Model:  "orders",
+				Fields: []string{"id"},
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpIn, Value: []interface{}{"SHIPPED", "REFUNDED"}},
+					&dsl.ComparisonFilter{Field: "coupon", Op: dsl.OpNotNull},
+				}},
+			},
+			[]adapter.Row{{"id": int64(2)}, {"id": int64(4)}},
+		},
+		{
+			"timestamp filter",
+			&dsl.Query{
+				Model:   "orders",
+				Fields:  []string{"id"},
+				Filters: &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpGTELT, Value: []interface{}{"2024-01-02", "2024-02-11"}},
+			},
+			[]adapter.Row{{"id": int64(3)}, {"id": int64(4)}},
+		},
+		{
+			"json paths",
+			&dsl.Query{
+				Model:   "orders",
+				Fields:  []string{"id", "metadata.shipping.country"},
+				Filters: &dsl.ComparisonFilter{Field: "metadata.priority::integer", Op: dsl.OpGTE, Value: 2},
+			},
+			[]adapter.Row{{"id": int64(1), "metadata.shipping.country": "NL"}, {"id": int64(5), "metadata.shipping.country": "NL"}},
+		},
+		{
+			"json operators",
+			&dsl.Query{
+				Model:  "orders",
+				Fields: []string{"id"},
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "metadata.tags", Op: dsl.OpJSONContains, Value: []interface{}{"gift"}},
+					&dsl.LogicalFilter{Not: &dsl.ComparisonFilter{Field: "metadata", Op: dsl.OpHasKey, Value: "shipping"}},
+				}},
+			},
+			[]adapter.Row{{"id": int64(4)}},
+		},
+		{
+			"relation filter",
+			&dsl.Query{
+				Model:   "orders",
+				Fields:  []string{"id", "user.name"},
+				Filters: &dsl.ComparisonFilter{Field: "user.country", Op: dsl.OpEqual, Value: "DE"},
+			},
+			[]adapter.Row{{"id": int64(3), "user.name": "Bob"}, {"id": int64(5), "user.name": "Bob"}},
+		},
+		{
+			"nulls sort last ascending",
+			&dsl.Query{
+				Model:  "orders",
+				Fields: []string{"id", "coupon"},
+				Sort:   []dsl.Sort{{Field: "coupon"}, {Field: "id", Direction: dsl.SortDesc}},
+			},
+			[]adapter.Row{
+				{"id": int64(4), "coupon": "SPRING"}, {"id": int64(2), "coupon": "WELCOME"},
+				{"id": int64(5), "coupon": nil}, {"id": int64(3), "coupon": nil}, {"id": int64(1), "coupon": nil},
+			},
+		},
+		{
+			"group by with aggregates and having",
+			&dsl.Query{
+				Model:   "orders",
+				GroupBy: []dsl.GroupBy{{Field: "status"}},
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCount, Alias: "n"},
+					{Function: dsl.AggSum, Field: "amount", Alias: "total"},
+					{Function: dsl.AggCountDistinct, Field: "user_id", Alias: "users"},
+					{Function: dsl.AggBoolAnd, Field: "is_paid", Alias: "all_paid"},
+				},
+				Having: &dsl.ComparisonFilter{Field: "total", Op: dsl.OpGT, Value: 50},
+				Sort:   []dsl.Sort{{Field: "n", Direction: dsl.SortDesc}},
+			},
+			[]adapter.Row{
+				{"status": "PAID", "n": int64(3), "total": 460.5, "users": int64(2), "all_paid": false},
+				{"status": "SHIPPED", "n": int64(1), "total": 80.0, "users": int64(1), "all_paid": true},
+			},
+		},
+		{
+			"aggregate filter over a relation",
+			&dsl.Query{
+				Model:   "orders",
+				GroupBy: []dsl.GroupBy{{Field: "user.country"}},
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCount, Alias: "n"},
+					{Function: dsl.AggSum, Field: "amount", Alias: "paid", Filter: &dsl.ComparisonFilter{Field: "is_paid", Op: dsl.OpEqual, Value: true}},
+				},
+				Sort: []dsl.Sort{{Field: "user.country"}},
+			},
+			[]adapter.Row{
+				{"user.country": "DE", "n": int64(2), "paid": 300.0},
+				{"user.country": "NL", "n": int64(3), "paid": 200.5},
+			},
+		},
+		{
+			"array_agg keeps nulls",
+			&dsl.Query{
+				Model:      "orders",
+				GroupBy:    []dsl.GroupBy{{Field: "user_id"}},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggArrayAgg, Field: "coupon", Alias: "coupons"}},
+				Sort:       []dsl.Sort{{Field: "user_id"}},
+			},
+			[]adapter.Row{
+				{"user_id": int64(1), "coupons": []interface{}{nil, "WELCOME"}},
+				{"user_id": int64(2), "coupons": []interface{}{nil, nil}},
+				{"user_id": int64(3), "coupons": []interface{}{"SPRING"}},
+			},
+		},
+		{
+			"percentiles",
+			&dsl.Query{
+				Model: "orders",
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggMedian, Field: "amount", Alias: "median"},
+					{Function: dsl.AggPercentile, Field: "amount", Alias: "p25", Fraction: &fraction},
+					{Function: dsl.AggMin, Field: "created_at", Alias: "first"},
+				},
+			},
+			[]adapter.Row{{"median": 80.0, "p25": 40.0, "first": time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}},
+		},
+		{
+			"global aggregate over no rows",
+			&dsl.Query{
+				Model:   "orders",
+				Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "CANCELLED"},
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCount, Alias: "n"},
+					{Function: dsl.AggSum, Field: "amount", Alias: "total"},
+				},
+			},
+			[]adapter.Row{{"n": int64(0), "total": nil}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.FetchPlan(context.Background(), plan(t, reg, tt.query))
+			if err != nil {
+				t.Fatalf("FetchPlan error: %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.expected) {
+				t.Errorf("rows = %v, want %v", rows, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchPlan_TimeBuckets(t *testing.T) {
+	reg := setupTestRegistry(t)
+	db := setupTestDatabase()
+
+	rows, err := db.FetchPlan(context.Background(), plan(t, reg, &dsl.Query{
+		Model:      "orders",
+		GroupBy:    []dsl.GroupBy{{Field: "created_at", Granularity: dsl.GranularityDay, Timezone: "Europe/Amsterdam"}},
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}},
+	}))
+	if err != nil {
+		t.Fatalf("FetchPlan error: %v", err)
+	}
+
+	// 23:30 UTC on January 1st is already January 2nd in Amsterdam
+	got := make(map[string]int64)
+	for _, row := range rows {
+		got[row["created_at_day"].(time.Time).Format(time.RFC3339)] = row["n"].(int64)
+	}
+	want := map[string]int64{
+		"2024-01-01T00:00:00+01:00": 1,
+		"2024-01-02T00:00:00+01:00": 2,
+		"2024-02-10T00:00:00+01:00": 1,
+		"2024-02-11T00:00:00+01:00": 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buckets = %v, want %v", got, want)
+	}
+}
+
+func TestFetchPlan_Statistics(t *testing.T) {
+	reg := setupTestRegistry(t)
+	db := setupTestDatabase()
+
+	rows, err := db.FetchPlan(context.Background(), plan(t, reg, &dsl.Query{
+		Model: "orders",
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggAvg, Field: "amount", Alias: "avg"},
+			{Function: dsl.AggVariance, Field: "amount", Alias: "variance"},
+			{Function: dsl.AggStddev, Field: "amount", Alias: "stddev"},
+		},
+	}))
+	if err != nil {
+		t.Fatalf("FetchPlan error: %v", err)
+	}
+
+	want := map[string]float64{"avg": 111.15, "variance": 12745.3, "stddev": math.Sqrt(12745.3)}
+	for alias, expected := range want {
+		if got, ok := rows[0][alias].(float64); !ok || math.Abs(got-expected) > 1e-9 {
+			t.Errorf("%s = %v, want %v", alias, rows[0][alias], expected)
+		}
+	}
+}
+
+func TestFetchPlan_Keyset(t *testing.T) {
+	reg := setupTestRegistry(t)
+	db := setupTestDatabase()
+
+	var ids []interface{}
+	cursor := ""
+	for page := 0; page < 4; page++ {
+		p := plan(t, reg, &dsl.Query{
+			Model:      "orders",
+			Sort:       []dsl.Sort{{Field: "coupon", Direction: dsl.SortDesc}},
+			Pagination: &dsl.Pagination{Limit: 2, Keyset: true, Cursor: cursor},
+		})
+		rows, err := db.FetchPlan(context.Background(), p)
+		if err != nil {
+			t.Fatalf("FetchPlan error: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			ids = append(ids, row["id"])
+		}
+		if cursor, err = p.Keyset.NextCursor(rows[len(rows)-1]); err != nil {
+			t.Fatalf("NextCursor error: %v", err)
+		}
+	}
+
+	// NULL coupons come first descending, then ties are broken by id
+	want := []interface{}{int64(5), int64(3), int64(1), int64(2), int64(4)}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	reg := setupTestRegistry(t)
+	db := setupTestDatabase()
+
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected int64
+	}{
+		{
+			"all pages",
+			&dsl.Query{
+				Model:      "orders",
+				Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				Pagination: &dsl.Pagination{Limit: 1},
+			},
+			3,
+		},
+		{
+			"groups",
+			&dsl.Query{Model: "orders", GroupBy: []dsl.GroupBy{{Field: "status"}}, Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}}},
+			3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := db.CountRows(context.Background(), plan(t, reg, tt.query), false)
+			if err != nil {
+				t.Fatalf("CountRows error: %v", err)
+			}
+			if n != tt.expected {
+				t.Errorf("CountRows() = %d, want %d", n, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchPlan_Errors(t *testing.T) {
+	reg := setupTestRegistry(t)
+
+	tests := []struct {
+		name   string
+		db     *Database
+		query  *dsl.Query
+		errMsg string
+	}{
+		{
+			"missing table",
+			NewDatabase(map[string][]adapter.Row{"orders": setupTestDatabase().tables["orders"]}),
+			&dsl.Query{Model: "orders", Fields: []string{"id", "user.name"}},
+			"no data file is loaded for table users",
+		},
+		{
+			"json cast",
+			NewDatabase(map[string][]adapter.Row{"orders": {{"id": int64(1), "metadata": map[string]interface{}{"priority": "high"}}}}),
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "metadata.priority::integer", Op: dsl.OpEqual, Value: 1}},
+			`invalid integer value "high" at metadata.priority`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.db.FetchPlan(context.Background(), plan(t, reg, tt.query))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("FetchPlan() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestLikeRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, text   string
+		caseInsensitive bool
+		want            bool
+	}{
+		{"P_ID", "PAID", false, true},
+		{"pa%", "PAID", false, false},
+		{"pa%", "PAID", true, true},
+		{`100\%`, "100%", false, true},
+		{`100\%`, "1000", false, false},
+		{"a.c", "abc", false, false},
+		{"%\n%", "line\nbreak", false, true},
+	}
+	for _, tt := range tests {
+		re, err := likeRegexp(tt.pattern, tt.caseInsensitive)
+		if err != nil {
+			t.Fatalf("likeRegexp(%q) error: %v", tt.pattern, err)
+		}
+		if got := re.MatchString(tt.text); got != tt.want {
+			t.Errorf("%q LIKE %q = %v, want %v", tt.text, tt.pattern, got, tt.want)
+		}
+	}
+}
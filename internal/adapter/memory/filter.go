@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// truth is a SQL truth value: comparisons with NULL are unknown, and only
+// true rows pass a filter
+type truth int8
+
+const (
+	truthUnknown truth = iota
+	truthFalse
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// predicate evaluates a compiled filter on a tuple. HAVING filters read
+// the aggregates of the tuple's group, by alias.
+type predicate func(t tuple, aggs map[string]interface{}) (truth, error)
+
+// compileFilter compiles a filter tree once per query, normalizing its
+// values for comparison. A nil filter passes every row.
+func compileFilter(expr planner.FilterExpr) (predicate, error) {
+	switch e := expr.(type) {
+	case nil:
+		return func(tuple, map[string]interface{}) (truth, error) { return truthTrue, nil }, nil
+	case *planner.ComparisonFilterIR:
+		return compileComparison(e)
+	case *planner.LogicalFilterIR:
+		return compileLogical(e)
+	default:
+		return nil, fmt.Errorf("unknown filter expression type")
+	}
+}
+
+// compileLogical combines child filters with three-valued AND, OR and NOT
+func compileLogical(f *planner.LogicalFilterIR) (predicate, error) {
+	nodes := make([]predicate, len(f.Nodes))
+	for i, node := range f.Nodes {
+		p, err := compileFilter(node)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = p
+	}
+
+	switch f.Op {
+	case "AND":
+		return func(t tuple, aggs map[string]interface{}) (truth, error) {
+			result := truthTrue
+			for _, node := range nodes {
+				v, err := node(t, aggs)
+				if err != nil || v == truthFalse {
+					return truthFalse, err
+				}
+				if v == truthUnknown {
+					result = truthUnknown
+				}
+			}
+			return result, nil
+		}, nil
+	case "OR":
+		return func(t tuple, aggs map[string]interface{}) (truth, error) {
+			result := truthFalse
+			for _, node := range nodes {
+				v, err := node(t, aggs)
+				if err != nil || v == truthTrue {
+					return v, err
+				}
+				if v == truthUnknown {
+					result = truthUnknown
+				}
+			}
+			return result, nil
+		}, nil
+	case "NOT":
+		if len(nodes) != 1 {
+			return nil, fmt.Errorf("NOT filter requires exactly one condition")
+		}
+		return func(t tuple, aggs map[string]interface{}) (truth, error) {
+			v, err := nodes[0](t, aggs)
+			switch v {
+			case truthTrue:
+				return truthFalse, err
+			case truthFalse:
+				return truthTrue, err
+			}
+			return truthUnknown, err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown logical operator: %s", f.Op)
+	}
+}
+
+// compareOps maps the ordering operators to the comparison results that
+// satisfy them
+var compareOps = map[dsl.FilterOperator]func(int) bool{
+	dsl.OpEqual:    func(c int) bool { return c == 0 },
+	dsl.OpNotEqual: func(c int) bool { return c != 0 },
+	dsl.OpGT:       func(c int) bool { return c > 0 },
+	dsl.OpGTE:      func(c int) bool { return c >= 0 },
+	dsl.OpLT:       func(c int) bool { return c < 0 },
+	dsl.OpLTE:      func(c int) bool { return c <= 0 },
+	dsl.OpBefore:   func(c int) bool { return c < 0 },
+	dsl.OpAfter:    func(c int) bool { return c > 0 },
+}
+
+// compileComparison compiles a single comparison. Every operator but the
+// null checks is unknown on a NULL value.
+func compileComparison(f *planner.ComparisonFilterIR) (predicate, error) {
+	left := func(t tuple, aggs map[string]interface{}) (interface{}, error) {
+		switch {
+		case f.Aggregate != nil:
+			return aggs[f.Aggregate.Alias], nil
+		case dsl.IsJSONOperator(f.Operator):
+			return document(t, f.Left), nil
+		default:
+			return value(t, f.Left)
+		}
+	}
+	// test wraps a check of a non-NULL value into a predicate
+	test := func(check func(v interface{}) (bool, error)) predicate {
+		return func(t tuple, aggs map[string]interface{}) (truth, error) {
+			v, err := left(t, aggs)
+			if err != nil || v == nil {
+				return truthUnknown, err
+			}
+			ok, err := check(v)
+			return truthOf(ok), err
+		}
+	}
+
+	switch f.Operator {
+	case dsl.OpIsNull, dsl.OpNotNull:
+		return func(t tuple, aggs map[string]interface{}) (truth, error) {
+			v, err := left(t, aggs)
+			return truthOf((v == nil) == (f.Operator == dsl.OpIsNull)), err
+		}, nil
+	}
+
+	if f.Value == nil {
+		return nil, fmt.Errorf("value required for %s operator", f.Operator)
+	}
+	fieldType := f.Left.DataType
+
+	if satisfies, ok := compareOps[f.Operator]; ok {
+		want := operand(fieldType, f.Value.Value)
+		return test(func(v interface{}) (bool, error) {
+			c, err := compare(v, want)
+			return satisfies(c), err
+		}), nil
+	}
+
+	switch f.Operator {
+	case dsl.OpIn, dsl.OpNotIn:
+		items, ok := f.Value.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s requires an array of values", f.Operator)
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			list[i] = operand(fieldType, item)
+		}
+		in := f.Operator == dsl.OpIn
+		return test(func(v interface{}) (bool, error) {
+			for _, item := range list {
+				c, err := compare(v, item)
+				if err != nil {
+					return false, err
+				}
+				if c == 0 {
+					return in, nil
+				}
+			}
+			return !in, nil
+		}), nil
+
+	case dsl.OpBetween, dsl.OpGTELT, dsl.OpGTLTE:
+		bounds, ok := f.Value.Value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return nil, fmt.Errorf("%s requires exactly 2 values", f.Operator)
+		}
+		lower, upper := operand(fieldType, bounds[0]), operand(fieldType, bounds[1])
+		if lower == nil && upper == nil {
+			return nil, fmt.Errorf("%s requires at least one non-null bound", f.Operator)
+		}
+		includeLower := f.Operator != dsl.OpGTLTE
+		includeUpper := f.Operator != dsl.OpGTELT
+		return test(func(v interface{}) (bool, error) {
+			if lower != nil {
+				c, err := compare(v, lower)
+				if err != nil || c < 0 || (c == 0 && !includeLower) {
+					return false, err
+				}
+			}
+			if upper != nil {
+				c, err := compare(v, upper)
+				if err != nil || c > 0 || (c == 0 && !includeUpper) {
+					return false, err
+				}
+			}
+			return true, nil
+		}), nil
+
+	case dsl.OpLike, dsl.OpILike, dsl.OpStartsWith, dsl.OpEndsWith, dsl.OpContains:
+		pattern, ok := f.Value.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a string value", f.Operator)
+		}
+		re, err := likeRegexp(dsl.LikePattern(f.Operator, pattern), f.Operator == dsl.OpILike)
+		if err != nil {
+			return nil, err
+		}
+		return test(func(v interface{}) (bool, error) {
+			return re.MatchString(text(v)), nil
+		}), nil
+
+	case dsl.OpHasKey:
+		name, ok := f.Value.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("has_key requires a string key")
+		}
+		// Like jsonb ?, a key of an object or a string element of an array
+		return test(func(v interface{}) (bool, error) {
+			switch doc := v.(type) {
+			case map[string]interface{}:
+				_, found := doc[name]
+				return found, nil
+			case []interface{}:
+				for _, item := range doc {
+					if item == name {
+						return true, nil
+					}
+				}
+			}
+			return false, nil
+		}), nil
+
+	case dsl.OpJSONContains:
+		want := operand(planner.TypeJSON, f.Value.Value)
+		return test(func(v interface{}) (bool, error) {
+			return jsonContains(v, want, true), nil
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("the %s operator is not supported by the in-memory engine", f.Operator)
+	}
+}
+
+// likeRegexp compiles a LIKE pattern into a regular expression whose . also
+// matches newlines, as % and _ do
+func likeRegexp(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	expr, err := dsl.LikeRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	flags := "(?s)"
+	if caseInsensitive {
+		flags = "(?si)"
+	}
+	return regexp.Compile(flags + expr)
+}
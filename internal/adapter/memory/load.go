@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"udv/internal/adapter"
+	"udv/internal/config"
+	"udv/internal/dsl"
+)
+
+// loadFile reads the rows of a model's data file, typing each declared
+// field. Columns the model does not declare are kept as read.
+func loadFile(path string, model *config.Model) ([]adapter.Row, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+	defer f.Close()
+
+	fields := make(map[string]config.Field, len(model.Fields))
+	for _, field := range model.Fields {
+		fields[field.Name] = field
+	}
+
+	var rows []adapter.Row
+	switch model.FileFormat() {
+	case config.FormatCSV:
+		rows, err = readCSV(f, fields)
+	case config.FormatJSON:
+		rows, err = readJSON(f, fields)
+	case config.FormatNDJSON:
+		rows, err = readNDJSON(f, fields)
+	default:
+		return nil, fmt.Errorf("cannot tell the format of file %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rows, nil
+}
+
+// readCSV reads a CSV file whose header row names the columns. An empty
+// cell is NULL unless its field is a non-nullable string.
+func readCSV(r io.Reader, fields map[string]config.Field) ([]adapter.Row, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []adapter.Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := make(adapter.Row, len(header))
+		for i, name := range header {
+			field, declared := fields[name]
+			cell := record[i]
+			switch {
+			case !declared:
+				row[name] = cell
+			case cell == "" && (field.Nullable || field.Type != "string"):
+				row[name] = nil
+			default:
+				value, err := typeValue(field.Type, cell)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: field %s: %v", line, name, err)
+				}
+				row[name] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readJSON reads a JSON array of objects
+func readJSON(r io.Reader, fields map[string]config.Field) ([]adapter.Row, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of objects: %w", err)
+	}
+
+	rows := make([]adapter.Row, len(objects))
+	for i, object := range objects {
+		row, err := typeObject(object, fields)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %v", i, err)
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// readNDJSON reads one JSON object per line, skipping blank lines
+func readNDJSON(r io.Reader, fields map[string]config.Field) ([]adapter.Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var rows []adapter.Row
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return nil, fmt.Errorf("line %d: expected a JSON object: %v", line, err)
+		}
+		row, err := typeObject(object, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// typeObject types the values of a decoded JSON object
+func typeObject(object map[string]interface{}, fields map[string]config.Field) (adapter.Row, error) {
+	row := make(adapter.Row, len(object))
+	for name, value := range object {
+		field, declared := fields[name]
+		if !declared || value == nil {
+			row[name] = plainJSON(value)
+			continue
+		}
+		typed, err := typeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", name, err)
+		}
+		row[name] = typed
+	}
+	return row, nil
+}
+
+// typeValue converts a CSV cell or JSON value to the Go type the engine
+// uses for a field type: int64 for integers, float64 for floats and
+// decimals, bool, time.Time (UTC midnight for dates), the decoded document
+// for json, and strings for everything else
+func typeValue(fieldType string, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case "integer", "int":
+		return dsl.CoerceScalar("integer", value)
+
+	case "float", "decimal":
+		return dsl.CoerceScalar("float", value)
+
+	case "boolean":
+		if s, ok := value.(string); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("expected boolean, got %q", s)
+			}
+			return b, nil
+		}
+		return dsl.CoerceScalar("boolean", value)
+
+	case "timestamp", "datetime":
+		t, err := dsl.CoerceScalar("timestamp", value)
+		if err != nil {
+			return nil, err
+		}
+		return t.(time.Time).UTC(), nil
+
+	case "date":
+		t, err := dsl.CoerceScalar("timestamp", value)
+		if err != nil {
+			return nil, fmt.Errorf("expected date (YYYY-MM-DD), got %v", value)
+		}
+		y, m, d := t.(time.Time).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+
+	case "json":
+		if s, ok := value.(string); ok {
+			decoder := json.NewDecoder(strings.NewReader(s))
+			decoder.UseNumber()
+			var doc interface{}
+			if err := decoder.Decode(&doc); err != nil {
+				return nil, fmt.Errorf("expected JSON document: %v", err)
+			}
+			return plainJSON(doc), nil
+		}
+		return plainJSON(value), nil
+
+	case "uuid":
+		if s, ok := value.(string); ok {
+			return strings.ToLower(s), nil
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return nil, fmt.Errorf("expected %s, got %v", fieldType, value)
+}
+
+// plainJSON replaces the json.Numbers of a decoded document with int64
+// (integral numbers) or float64
+func plainJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = plainJSON(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = plainJSON(item)
+		}
+	}
+	return value
+}
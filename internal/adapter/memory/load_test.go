@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"udv/internal/adapter"
+	"udv/internal/config"
+)
+
+// writeFile writes a data file into dir and returns its name
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	return name
+}
+
+func ordersModel(file, format string) config.Model {
+	return config.Model{
+		Name:       "orders",
+		Table:      "orders",
+		PrimaryKey: "id",
+		Fields: []config.Field{
+			{Name: "id", Type: "integer"},
+			{Name: "status", Type: "string"},
+			{Name: "amount", Type: "decimal"},
+			{Name: "created_at", Type: "timestamp"},
+			{Name: "shipped_on", Type: "date", Nullable: true},
+			{Name: "is_paid", Type: "boolean"},
+			{Name: "metadata", Type: "json", Nullable: true},
+		},
+		File:   file,
+		Format: format,
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	want := []adapter.Row{
+		{
+			"id": int64(1), "status": "PAID", "amount": 120.5, "created_at": time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			"shipped_on": time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), "is_paid": true,
+			"metadata": map[string]interface{}{"priority": int64(2), "weight": 1.5},
+		},
+		{
+			"id": int64(2), "status": "", "amount": 80.0, "created_at": time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC),
+			"shipped_on": nil, "is_paid": false, "metadata": nil,
+		},
+	}
+
+	tests := []struct {
+		name  string
+		model config.Model
+	}{
+		{
+			"csv",
+			ordersModel(writeFile(t, dir, "orders.csv",
+				"id,status,amount,created_at,shipped_on,is_paid,metadata\n"+
+					`1,PAID,120.5,2024-01-01T10:00:00Z,2024-01-03,true,"{""priority"": 2, ""weight"": 1.5}"`+"\n"+
+					"2,,80,2024-01-02 08:00:00,,false,\n"), ""),
+		},
+		{
+			"json",
+			ordersModel(writeFile(t, dir, "orders.json", `[
+				{"id": 1, "status": "PAID", "amount": 120.5, "created_at": "2024-01-01T10:00:00Z", "shipped_on": "2024-01-03", "is_paid": true, "metadata": {"priority": 2, "weight": 1.5}},
+				{"id": 2, "status": "", "amount": "80", "created_at": "2024-01-02T09:00:00+01:00", "shipped_on": null, "is_paid": false, "metadata": null}
+			]`), ""),
+		},
+		{
+			"ndjson with declared format",
+			ordersModel(writeFile(t, dir, "orders.export",
+				`{"id": 1, "status": "PAID", "amount": 120.5, "created_at": "2024-01-01T10:00:00Z", "shipped_on": "2024-01-03", "is_paid": true, "metadata": {"priority": 2, "weight": 1.5}}`+"\n"+
+					"\n"+
+					`{"id": 2, "status": "", "amount": 80, "created_at": "2024-01-02T08:00:00Z", "shipped_on": null, "is_paid": false, "metadata": null}`+"\n"), "ndjson"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Load(&config.Config{Models: []config.Model{tt.model}}, dir)
+			if err != nil {
+				t.Fatalf("Load error: %v", err)
+			}
+			if got := db.tables["orders"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("rows = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		model  config.Model
+		errMsg string
+	}{
+		{
+			"missing file",
+			ordersModel("missing.csv", ""),
+			"failed to open data file",
+		},
+		{
+			"csv cell of the wrong type",
+			ordersModel(writeFile(t, dir, "bad.csv", "id,amount\n1,10\n2,lots\n"), ""),
+			"bad.csv: line 3: field amount: expected number",
+		},
+		{
+			"ndjson line that is not an object",
+			ordersModel(writeFile(t, dir, "bad.ndjson", `{"id": 1}`+"\n"+`[1, 2]`+"\n"), ""),
+			"bad.ndjson: line 2: expected a JSON object",
+		},
+		{
+			"json that is not an array",
+			ordersModel(writeFile(t, dir, "bad.json", `{"id": 1}`), ""),
+			"expected a JSON array of objects",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(&config.Config{Models: []config.Model{tt.model}}, dir)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Load() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"udv/internal/adapter"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// tuple is one row of the joined tables, keyed by table alias. The row of
+// a LEFT JOIN without a match is nil, so all its columns read as NULL.
+type tuple map[string]adapter.Row
+
+// value reads a column of a tuple. A JSON path below the column is
+// extracted like PostgreSQL's ->> and cast to the path's type; json typed
+// paths return the sub-document itself.
+func value(t tuple, ref planner.ColumnRef) (interface{}, error) {
+	if len(ref.JSONPath) == 0 {
+		return t[ref.TableAlias][ref.ColumnName], nil
+	}
+	doc := document(t, ref)
+	if doc == nil || ref.DataType == planner.TypeJSON {
+		return doc, nil
+	}
+	return castJSON(doc, ref)
+}
+
+// document reads a column as a JSON document, or the value at its JSON
+// path (PostgreSQL's ->). Missing keys and out of range indexes are NULL.
+func document(t tuple, ref planner.ColumnRef) interface{} {
+	doc := t[ref.TableAlias][ref.ColumnName]
+	if text, ok := doc.(string); ok {
+		// Documents handed to NewDatabase may still be JSON text
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			doc = decoded
+		}
+	}
+
+	for _, seg := range ref.JSONPath {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			if _, err := strconv.Atoi(seg); err == nil {
+				// Numeric segments index arrays only
+				return nil
+			}
+			doc = node[seg]
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil {
+				return nil
+			}
+			if i < 0 {
+				i += len(node)
+			}
+			if i < 0 || i >= len(node) {
+				return nil
+			}
+			doc = node[i]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
+
+// castJSON converts an extracted JSON value to the type of its path, from
+// its text as ->> returns it
+func castJSON(doc interface{}, ref planner.ColumnRef) (interface{}, error) {
+	text := jsonText(doc)
+	invalid := func() error {
+		return fmt.Errorf("invalid %s value %q at %s.%s", ref.DataType, text, ref.ColumnName, strings.Join(ref.JSONPath, "."))
+	}
+
+	switch ref.DataType {
+	case planner.TypeInteger, planner.TypeInt:
+		n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return n, nil
+	case planner.TypeFloat, planner.TypeDecimal:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return f, nil
+	case planner.TypeBoolean:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, invalid()
+		}
+		return b, nil
+	case planner.TypeTimestamp, planner.TypeDateTime, planner.TypeDate:
+		v, err := typeValue(string(ref.DataType), text)
+		if err != nil {
+			return nil, invalid()
+		}
+		return v, nil
+	default:
+		return text, nil
+	}
+}
+
+// jsonText renders a JSON value as ->> does: strings unquoted, everything
+// else as JSON text
+func jsonText(doc interface{}) string {
+	switch v := doc.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(doc)
+	return string(data)
+}
+
+// text renders a value for string operators and string_agg
+func text(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	}
+	return jsonText(v)
+}
+
+// operand normalizes a filter or cursor value for comparison with a
+// column of the given type: numeric text (verbatim decimals) becomes
+// float64, dates become time.Time and JSON text is decoded
+func operand(fieldType planner.FieldType, v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case string:
+		switch fieldType {
+		case planner.TypeInteger, planner.TypeInt, planner.TypeFloat, planner.TypeDecimal:
+			if f, err := strconv.ParseFloat(x, 64); err == nil {
+				return f
+			}
+		case planner.TypeDate, planner.TypeTimestamp, planner.TypeDateTime:
+			if t, err := typeValue(string(fieldType), x); err == nil {
+				return t
+			}
+		case planner.TypeJSON:
+			var doc interface{}
+			if err := json.Unmarshal([]byte(x), &doc); err == nil {
+				return doc
+			}
+		}
+	}
+	return v
+}
+
+// number returns the value of a numeric Go value as float64
+func number(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compare orders two non-NULL values of the same kind. Integers are
+// compared exactly, other numbers as float64.
+func compare(a, b interface{}) (int, error) {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			return cmpOrdered(x, y), nil
+		}
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return cmpOrdered(x, y), nil
+		}
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case y:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	case map[string]interface{}, []interface{}:
+		switch b.(type) {
+		case map[string]interface{}, []interface{}:
+			if jsonEqual(a, b) {
+				return 0, nil
+			}
+			return strings.Compare(jsonText(a), jsonText(b)), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v (%T) with %v (%T)", a, a, b, b)
+}
+
+func cmpOrdered[T int64 | float64](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// orderValues compares two values in a sort direction. NULLs sort after
+// every value ascending and before them descending, as in PostgreSQL.
+func orderValues(a, b interface{}, direction string) (int, error) {
+	var c int
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		c = 1
+	case b == nil:
+		c = -1
+	default:
+		var err error
+		if c, err = compare(a, b); err != nil {
+			return 0, err
+		}
+	}
+	if direction == "DESC" {
+		c = -c
+	}
+	return c, nil
+}
+
+// key renders a value as a map key, equal for values that compare equal:
+// grouping, DISTINCT and join lookups use it
+func key(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	if f, ok := number(v); ok {
+		return "n:" + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	switch x := v.(type) {
+	case string:
+		return "s:" + x
+	case bool:
+		return "b:" + strconv.FormatBool(x)
+	case time.Time:
+		return "t:" + x.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(normalizeJSON(v))
+	return "j:" + string(data)
+}
+
+// normalizeJSON converts the numbers of a document to float64, so equal
+// documents compare equal whatever their number types
+func normalizeJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			out[k] = normalizeJSON(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			out[i] = normalizeJSON(item)
+		}
+		return out
+	}
+	if f, ok := number(v); ok {
+		return f
+	}
+	return v
+}
+
+// jsonEqual reports whether two JSON documents are equal
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+// jsonContains reports whether doc contains want, like jsonb @>: objects
+// contain the keys of want with contained values, arrays contain each
+// element of want, and a top-level array contains a scalar it has as an
+// element
+func jsonContains(doc, want interface{}, top bool) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		d, ok := doc.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, wv := range w {
+			dv, ok := d[k]
+			if !ok || !jsonContains(dv, wv, false) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		d, ok := doc.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, wv := range w {
+			found := false
+			for _, dv := range d {
+				if jsonContains(dv, wv, false) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	if d, ok := doc.([]interface{}); ok && top {
+		for _, dv := range d {
+			if jsonEqual(dv, want) {
+				return true
+			}
+		}
+		return false
+	}
+	return jsonEqual(doc, want)
+}
+
+// truncate returns the start of the time bucket holding v, in the given
+// IANA time zone (UTC when empty). Weeks start on Monday, as with
+// date_trunc.
+func truncate(v interface{}, granularity, timezone string) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("cannot bucket %v by %s: not a timestamp", v, granularity)
+	}
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone: %s", timezone)
+		}
+	}
+
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch dsl.TimeGranularity(granularity) {
+	case dsl.GranularityMinute:
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc), nil
+	case dsl.GranularityHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc), nil
+	case dsl.GranularityDay:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	case dsl.GranularityWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc), nil
+	case dsl.GranularityMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), nil
+	case dsl.GranularityQuarter:
+		return time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, loc), nil
+	case dsl.GranularityYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+	return nil, fmt.Errorf("unsupported granularity: %s", granularity)
+}
@@ -0,0 +1,244 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"udv/internal/adapter"
+	"udv/internal/planner"
+)
+
+// Dialect renders query plans as aggregate commands. Each build uses its
+// own PipelineBuilder, so a Dialect is safe for concurrent use.
+type Dialect struct{}
+
+var _ adapter.Dialect = Dialect{}
+
+// Name implements adapter.Dialect
+func (Dialect) Name() string {
+	return "MongoDB"
+}
+
+// BuildQuery implements adapter.Dialect, returning the aggregate command as
+// Extended JSON. Values are inlined in the pipeline, so there are no
+// parameters.
+func (Dialect) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	pipeline, err := NewPipelineBuilder().BuildPipeline(plan)
+	if err != nil {
+		return "", nil, err
+	}
+	command, err := json.Marshal(Command(plan.RootModel.Table, pipeline))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode pipeline: %w", err)
+	}
+	return string(command), []interface{}{}, nil
+}
+
+// BuildTimeSeriesQuery implements adapter.Dialect. Time series are not
+// supported (see Capabilities).
+func (Dialect) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("time series are not supported by the MongoDB dialect")
+}
+
+// QuoteIdentifier implements adapter.Dialect; field names are document
+// keys, so they need no quoting
+func (Dialect) QuoteIdentifier(name string) string {
+	return name
+}
+
+// TypeCast implements adapter.Dialect; values are inlined with their BSON
+// types, so there are no placeholders to cast
+func (Dialect) TypeCast(placeholder string, fieldType planner.FieldType) string {
+	return placeholder
+}
+
+// Capabilities implements adapter.Dialect. Text search needs a text index
+// in its own $match stage, and similarity, window functions, aggregate
+// filters and time series are not translated.
+func (Dialect) Capabilities() adapter.Capabilities {
+	return adapter.Capabilities{
+		JSONPaths:       true,
+		Percentiles:     true,
+		StatisticalAggs: true,
+		ArrayAggregates: true,
+	}
+}
+
+// Adapter is the MongoDB adapter.Adapter
+type Adapter struct {
+	db *Database
+}
+
+var (
+	_ adapter.Adapter      = (*Adapter)(nil)
+	_ adapter.Executor     = (*Database)(nil)
+	_ adapter.PlanExecutor = (*Database)(nil)
+	_ adapter.Counter      = (*Database)(nil)
+)
+
+// NewAdapter returns the MongoDB adapter running pipelines on db, or only
+// rendering them when db is nil
+func NewAdapter(db *Database) *Adapter {
+	return &Adapter{db: db}
+}
+
+// Dialect implements adapter.Adapter
+func (a *Adapter) Dialect() adapter.Dialect {
+	return Dialect{}
+}
+
+// Executor implements adapter.Adapter
+func (a *Adapter) Executor() adapter.Executor {
+	if a.db == nil {
+		return nil
+	}
+	return a.db
+}
+
+// Aggregator runs an aggregation pipeline on a collection. UDV does not
+// link a MongoDB driver: the binary implements Aggregator with one (for
+// go.mongodb.org/mongo-driver, bson.UnmarshalExtJSON the pipeline and
+// pass it to Collection.Aggregate), returning each document as a Row with
+// embedded documents as nested maps.
+type Aggregator interface {
+	Aggregate(ctx context.Context, collection string, pipeline []byte) ([]adapter.Row, error)
+	Close() error
+}
+
+// Database runs plans as aggregation pipelines through an Aggregator
+type Database struct {
+	client Aggregator
+}
+
+// NewDatabase wraps a connected Aggregator
+func NewDatabase(client Aggregator) *Database {
+	return &Database{client: client}
+}
+
+// Close implements adapter.Executor
+func (d *Database) Close() error {
+	return d.client.Close()
+}
+
+// Fetch implements adapter.Executor. Pipelines are built from plans, not
+// SQL: use FetchPlan.
+func (d *Database) Fetch(ctx context.Context, sql string, args ...interface{}) ([]adapter.Row, error) {
+	return nil, fmt.Errorf("the MongoDB adapter runs query plans, not SQL")
+}
+
+// Stream implements adapter.Executor, see Fetch
+func (d *Database) Stream(ctx context.Context, sql string, args []interface{}, fn func(adapter.Row) error) error {
+	return fmt.Errorf("the MongoDB adapter runs query plans, not SQL")
+}
+
+// aggregate encodes a pipeline and runs it on the plan's collection
+func (d *Database) aggregate(ctx context.Context, plan *planner.QueryPlan, pipeline Pipeline) ([]adapter.Row, error) {
+	encoded, err := json.Marshal(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pipeline: %w", err)
+	}
+	rows, err := d.client.Aggregate(ctx, plan.RootModel.Table, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("aggregation failed: %w", err)
+	}
+	return rows, nil
+}
+
+// FetchPlan implements adapter.PlanExecutor: it runs the plan's pipeline
+// and returns one page of rows. Output names with dots, which $project
+// turns into embedded documents, are flattened back into the row.
+func (d *Database) FetchPlan(ctx context.Context, plan *planner.QueryPlan) ([]adapter.Row, error) {
+	pipeline, err := NewPipelineBuilder().BuildPipeline(plan)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.aggregate(ctx, plan, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	// $group emits no document for an empty input; SQL returns one row
+	if len(rows) == 0 && len(plan.GroupBy) == 0 && len(plan.Aggregates) > 0 && plan.Having == nil {
+		row := make(adapter.Row, len(plan.Aggregates))
+		for _, agg := range plan.Aggregates {
+			row[agg.Alias] = nil
+			if agg.Function == planner.AggCountFn || agg.Function == planner.AggCountDistinctFn {
+				row[agg.Alias] = int64(0)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	for _, row := range rows {
+		for _, name := range OutputNames(plan) {
+			if strings.Contains(name, ".") {
+				flatten(row, name)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// flatten moves the value at a dotted path of embedded documents to the
+// top-level key name, dropping the documents left empty
+func flatten(row adapter.Row, name string) {
+	parts := strings.Split(name, ".")
+	doc := row
+	var parents []map[string]interface{}
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := doc[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		parents = append(parents, doc)
+		doc = next
+	}
+
+	last := parts[len(parts)-1]
+	v, ok := doc[last]
+	if !ok {
+		return
+	}
+	delete(doc, last)
+	for i := len(parents) - 1; i >= 0 && len(doc) == 0; i-- {
+		delete(parents[i], parts[i])
+		doc = parents[i]
+	}
+	row[name] = v
+}
+
+// CountRows implements adapter.Counter. Counts are always exact: MongoDB's
+// collection estimate is not reached through an Aggregator.
+func (d *Database) CountRows(ctx context.Context, plan *planner.QueryPlan, estimate bool) (int64, error) {
+	if len(plan.GroupBy) == 0 && len(plan.Aggregates) > 0 && plan.Having == nil {
+		// One row, even over no documents (see FetchPlan)
+		return 1, nil
+	}
+
+	pipeline, err := NewPipelineBuilder().BuildCountPipeline(plan)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := d.aggregate(ctx, plan, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	switch total := rows[0]["total"].(type) {
+	case int32:
+		return int64(total), nil
+	case int64:
+		return total, nil
+	case int:
+		return int64(total), nil
+	case float64:
+		return int64(total), nil
+	default:
+		return 0, fmt.Errorf("unexpected count result %v", rows[0]["total"])
+	}
+}
@@ -0,0 +1,871 @@
+package mongo
+
+// Package mongo translates query plans into MongoDB aggregation pipelines
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// E is one key of a document
+type E struct {
+	Key   string
+	Value interface{}
+}
+
+// D is an ordered document, like the driver's bson.D. Key order matters in
+// a pipeline ($sort keys, the stage operator), so documents encode their
+// keys in order rather than sorted as Go maps do.
+type D []E
+
+// MarshalJSON encodes the document with its keys in order
+func (d D) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, e := range d {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", e.Key, err)
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// A is an array of pipeline values
+type A []interface{}
+
+// Pipeline is an aggregation pipeline: one document per stage. It encodes
+// as MongoDB Extended JSON (relaxed), so dates and decimals keep their BSON
+// types when the driver parses it.
+type Pipeline []D
+
+// Command wraps a pipeline in the aggregate command run on a collection,
+// the form shown to API callers
+func Command(collection string, pipeline Pipeline) D {
+	return D{{"aggregate", collection}, {"pipeline", pipeline}}
+}
+
+// groupKeyPrefix names the fields of the $group _id; output names may hold
+// dots, which _id subfields cannot
+const groupKeyPrefix = "g"
+
+// fieldPath renders a column reference as a document field path. Root
+// columns are top-level fields; joined documents are embedded under their
+// table alias by $lookup. JSON paths continue with dots, numeric segments
+// indexing arrays.
+func fieldPath(plan *planner.QueryPlan, ref planner.ColumnRef) string {
+	parts := make([]string, 0, len(ref.JSONPath)+2)
+	if ref.TableAlias != "" && ref.TableAlias != plan.RootModel.Alias {
+		parts = append(parts, ref.TableAlias)
+	}
+	parts = append(parts, ref.ColumnName)
+	parts = append(parts, ref.JSONPath...)
+	return strings.Join(parts, ".")
+}
+
+// mongoValue converts a normalized filter value (see dsl.CoerceValue) to
+// its Extended JSON form for a field type: timestamps and dates become
+// $date, verbatim decimal strings $numberDecimal, and JSON text is decoded
+// into a document
+func mongoValue(fieldType planner.FieldType, v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return D{{"$date", x.UTC().Format(time.RFC3339Nano)}}, nil
+	case string:
+		switch fieldType {
+		case planner.TypeDecimal:
+			return D{{"$numberDecimal", x}}, nil
+		case planner.TypeDate:
+			t, err := time.Parse("2006-01-02", x)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q", x)
+			}
+			return D{{"$date", t.Format(time.RFC3339Nano)}}, nil
+		case planner.TypeJSON:
+			var doc interface{}
+			if err := json.Unmarshal([]byte(x), &doc); err != nil {
+				return nil, fmt.Errorf("invalid JSON value: %v", err)
+			}
+			return doc, nil
+		}
+	}
+	return v, nil
+}
+
+// PipelineBuilder builds aggregation pipelines from query plans
+type PipelineBuilder struct {
+	plan   *planner.QueryPlan
+	having bool // building the having filter, over the grouped output
+}
+
+// NewPipelineBuilder creates a new pipeline builder
+func NewPipelineBuilder() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// checkPlan rejects plan features MongoDB pipelines are not generated for
+func checkPlan(plan *planner.QueryPlan) error {
+	if plan == nil || plan.RootModel == nil {
+		return fmt.Errorf("query plan has no root model")
+	}
+	if len(plan.Windows) > 0 {
+		return fmt.Errorf("window functions are not supported by the MongoDB dialect")
+	}
+	return nil
+}
+
+// BuildPipeline translates a plan into a pipeline:
+//
+//	$lookup/$unwind per join, $match (filters, then the keyset position),
+//	$group and $project for aggregates, $match (having), $sort, $skip,
+//	$limit, and a final $project of the selected fields
+//
+// Grouped plans project before sorting, so sorts and having filters see
+// the output fields.
+func (pb *PipelineBuilder) BuildPipeline(plan *planner.QueryPlan) (Pipeline, error) {
+	pipeline, err := pb.buildStages(plan, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.Keyset == nil && plan.Pagination.Offset > 0 {
+		pipeline = append(pipeline, D{{"$skip", plan.Pagination.Offset}})
+	}
+	pipeline = append(pipeline, D{{"$limit", plan.Pagination.Limit}})
+
+	if !isGrouped(plan) {
+		if project := pb.buildProject(); project != nil {
+			pipeline = append(pipeline, D{{"$project", project}})
+		}
+	}
+	return pipeline, nil
+}
+
+// BuildCountPipeline translates a plan into a pipeline counting its rows
+// (or groups) across all pages, as one document {"total": n}
+func (pb *PipelineBuilder) BuildCountPipeline(plan *planner.QueryPlan) (Pipeline, error) {
+	pipeline, err := pb.buildStages(plan, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(pipeline, D{{"$count", "total"}}), nil
+}
+
+// buildStages builds the stages up to the page: joins, filters, grouping
+// and, when ordered, the keyset position and sort
+func (pb *PipelineBuilder) buildStages(plan *planner.QueryPlan, ordered bool) (Pipeline, error) {
+	if err := checkPlan(plan); err != nil {
+		return nil, err
+	}
+	pb.plan = plan
+
+	var pipeline Pipeline
+	for _, join := range plan.Joins {
+		pipeline = append(pipeline,
+			D{{"$lookup", D{
+				{"from", join.ToTable},
+				{"localField", fieldPath(plan, join.On.Left)},
+				{"foreignField", join.On.Right.ColumnName},
+				{"as", join.ToAlias},
+			}}},
+			D{{"$unwind", D{
+				{"path", "$" + join.ToAlias},
+				{"preserveNullAndEmptyArrays", join.Type == planner.JoinLeft},
+			}}},
+		)
+	}
+
+	if plan.Filters != nil {
+		match, err := pb.buildFilter(plan.Filters)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, D{{"$match", match}})
+	}
+
+	if isGrouped(plan) {
+		group, project, err := pb.buildGroup()
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, D{{"$group", group}}, D{{"$project", project}})
+
+		if plan.Having != nil {
+			pb.having = true
+			match, err := pb.buildFilter(plan.Having)
+			pb.having = false
+			if err != nil {
+				return nil, err
+			}
+			pipeline = append(pipeline, D{{"$match", match}})
+		}
+	}
+
+	if !ordered {
+		return pipeline, nil
+	}
+
+	if plan.Keyset != nil && plan.Keyset.After != nil {
+		match, err := pb.buildKeysetMatch(plan.Keyset)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, D{{"$match", match}})
+	}
+
+	if len(plan.Sort) > 0 {
+		sort, err := pb.buildSort()
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, D{{"$sort", sort}})
+	}
+	return pipeline, nil
+}
+
+// isGrouped reports whether the plan aggregates documents into groups
+func isGrouped(plan *planner.QueryPlan) bool {
+	return len(plan.GroupBy) > 0 || len(plan.Aggregates) > 0
+}
+
+// groupOutputName returns the output field of a grouping column: its name,
+// or its alias when bucketed or renamed, as the SQL dialects name it
+func groupOutputName(g planner.GroupExpr) string {
+	if g.Granularity != "" || (g.Alias != "" && g.Alias != g.Column.ColumnName) {
+		return g.Alias
+	}
+	return g.Column.ColumnName
+}
+
+// OutputNames lists the fields of the rows a plan returns that are
+// addressed by name. Names with dots come back as embedded documents and
+// are flattened by the executor.
+func OutputNames(plan *planner.QueryPlan) []string {
+	var names []string
+	switch {
+	case len(plan.Select) > 0:
+		for _, s := range plan.Select {
+			names = append(names, s.Alias)
+		}
+	case isGrouped(plan):
+		for _, g := range plan.GroupBy {
+			names = append(names, groupOutputName(g))
+		}
+	}
+	for _, agg := range plan.Aggregates {
+		names = append(names, agg.Alias)
+	}
+	return names
+}
+
+// buildProject builds the final $project of an ungrouped plan: the
+// selected fields by alias, or without a field list every root field (the
+// documents embedded by joins are dropped). It returns nil when documents
+// pass through unchanged.
+func (pb *PipelineBuilder) buildProject() D {
+	plan := pb.plan
+	if len(plan.Select) == 0 {
+		if len(plan.Joins) == 0 {
+			return nil
+		}
+		project := D{}
+		for _, join := range plan.Joins {
+			project = append(project, E{join.ToAlias, 0})
+		}
+		return project
+	}
+
+	project := D{}
+	if !selectsField(plan.Select, "_id") {
+		project = append(project, E{"_id", 0})
+	}
+	for _, s := range plan.Select {
+		project = append(project, E{s.Alias, "$" + fieldPath(plan, s.Column)})
+	}
+	return project
+}
+
+func selectsField(selects []planner.SelectExpr, alias string) bool {
+	for _, s := range selects {
+		if s.Alias == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// buildGroup builds the $group stage and the $project renaming its output
+// to the plan's field names. Grouping values are keyed g0, g1, ... in _id;
+// without GROUP BY, _id is null and all documents form one group.
+func (pb *PipelineBuilder) buildGroup() (D, D, error) {
+	plan := pb.plan
+	var id interface{}
+	if len(plan.GroupBy) > 0 {
+		keys := D{}
+		for i, g := range plan.GroupBy {
+			expr, err := pb.groupExpression(g)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, E{fmt.Sprintf("%s%d", groupKeyPrefix, i), expr})
+		}
+		id = keys
+	}
+
+	group := D{{"_id", id}}
+	project := D{{"_id", 0}}
+	if len(plan.Select) > 0 {
+		for _, s := range plan.Select {
+			i := pb.groupIndex(s.Column)
+			if i < 0 {
+				return nil, nil, fmt.Errorf("field %s must be grouped", s.Alias)
+			}
+			project = append(project, E{s.Alias, fmt.Sprintf("$_id.%s%d", groupKeyPrefix, i)})
+		}
+	} else {
+		for i, g := range plan.GroupBy {
+			project = append(project, E{groupOutputName(g), fmt.Sprintf("$_id.%s%d", groupKeyPrefix, i)})
+		}
+	}
+
+	for _, agg := range plan.Aggregates {
+		accumulator, output, err := pb.aggregate(agg)
+		if err != nil {
+			return nil, nil, err
+		}
+		group = append(group, E{agg.Alias, accumulator})
+		project = append(project, E{agg.Alias, output})
+	}
+	return group, project, nil
+}
+
+// groupIndex returns the position of the plain (unbucketed) grouping
+// column ref, or -1
+func (pb *PipelineBuilder) groupIndex(ref planner.ColumnRef) int {
+	path := fieldPath(pb.plan, ref)
+	for i, g := range pb.plan.GroupBy {
+		if g.Granularity == "" && fieldPath(pb.plan, g.Column) == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// groupExpression renders a grouping column, truncated with $dateTrunc
+// (MongoDB 5.0+) when bucketed. Weeks start on Monday, as with date_trunc.
+func (pb *PipelineBuilder) groupExpression(g planner.GroupExpr) (interface{}, error) {
+	field := "$" + fieldPath(pb.plan, g.Column)
+	if g.Granularity == "" {
+		return field, nil
+	}
+
+	switch dsl.TimeGranularity(g.Granularity) {
+	case dsl.GranularityMinute, dsl.GranularityHour, dsl.GranularityDay, dsl.GranularityWeek,
+		dsl.GranularityMonth, dsl.GranularityQuarter, dsl.GranularityYear:
+	default:
+		return nil, fmt.Errorf("unsupported granularity: %s", g.Granularity)
+	}
+
+	trunc := D{{"date", field}, {"unit", g.Granularity}}
+	if g.Timezone != "" {
+		trunc = append(trunc, E{"timezone", g.Timezone})
+	}
+	if dsl.TimeGranularity(g.Granularity) == dsl.GranularityWeek {
+		trunc = append(trunc, E{"startOfWeek", "monday"})
+	}
+	return D{{"$dateTrunc", trunc}}, nil
+}
+
+// aggregate returns the $group accumulator of an aggregate and the
+// $project expression producing its value from the accumulated field.
+// Aggregates without a direct accumulator are finished in $project: count
+// distinct is the size of a set, variance the square of the sample
+// standard deviation, string_agg a reduce over the pushed values.
+func (pb *PipelineBuilder) aggregate(agg planner.AggregateExpr) (interface{}, interface{}, error) {
+	if agg.Filter != nil {
+		return nil, nil, fmt.Errorf("aggregate filters are not supported by the MongoDB dialect")
+	}
+	output := "$" + agg.Alias
+
+	if agg.Column == nil {
+		if agg.Function != planner.AggCountFn {
+			return nil, nil, fmt.Errorf("aggregate %s requires a field", agg.Alias)
+		}
+		return D{{"$sum", 1}}, output, nil
+	}
+	field := "$" + fieldPath(pb.plan, *agg.Column)
+
+	switch agg.Function {
+	case planner.AggCountFn:
+		// Missing and null values are not counted
+		return D{{"$sum", D{{"$cond", A{D{{"$eq", A{D{{"$ifNull", A{field, nil}}}, nil}}}, 0, 1}}}}}, output, nil
+	case planner.AggSumFn:
+		return D{{"$sum", field}}, output, nil
+	case planner.AggAvgFn:
+		return D{{"$avg", field}}, output, nil
+	case planner.AggMinFn, planner.AggBoolAndFn:
+		// false sorts before true, so the minimum is their conjunction
+		return D{{"$min", field}}, output, nil
+	case planner.AggMaxFn, planner.AggBoolOrFn:
+		return D{{"$max", field}}, output, nil
+	case planner.AggCountDistinctFn:
+		return D{{"$addToSet", field}}, D{{"$size", output}}, nil
+	case planner.AggMedianFn:
+		return D{{"$median", D{{"input", field}, {"method", "approximate"}}}}, output, nil
+	case planner.AggPercentileFn:
+		percentile := D{{"$percentile", D{{"input", field}, {"p", A{agg.Fraction}}, {"method", "approximate"}}}}
+		return percentile, D{{"$arrayElemAt", A{output, 0}}}, nil
+	case planner.AggStddevFn:
+		return D{{"$stdDevSamp", field}}, output, nil
+	case planner.AggVarianceFn:
+		return D{{"$stdDevSamp", field}}, D{{"$pow", A{output, 2}}}, nil
+	case planner.AggArrayAggFn:
+		return D{{"$push", field}}, output, nil
+	case planner.AggStringAggFn:
+		values := D{{"$filter", D{{"input", output}, {"cond", D{{"$ne", A{"$$this", nil}}}}}}}
+		join := D{{"$reduce", D{
+			{"input", values},
+			{"initialValue", nil},
+			{"in", D{{"$cond", A{
+				D{{"$eq", A{"$$value", nil}}},
+				D{{"$toString", "$$this"}},
+				D{{"$concat", A{"$$value", agg.Separator, D{{"$toString", "$$this"}}}}},
+			}}}},
+		}}}
+		return D{{"$push", field}}, join, nil
+	default:
+		return nil, nil, fmt.Errorf("aggregate %s is not supported by the MongoDB dialect", agg.Function)
+	}
+}
+
+// buildSort builds the $sort document. Grouped plans sort their projected
+// output, so columns must be grouping columns.
+func (pb *PipelineBuilder) buildSort() (D, error) {
+	plan := pb.plan
+	sort := D{}
+	for _, s := range plan.Sort {
+		direction := 1
+		if s.Direction == "DESC" {
+			direction = -1
+		}
+
+		var path string
+		switch {
+		case s.Target == planner.SortAggregate && s.Aggregate != nil:
+			path = s.Aggregate.Alias
+		case s.Target == planner.SortColumn && s.Column != nil && isGrouped(plan):
+			i := pb.groupIndex(*s.Column)
+			if i < 0 {
+				return nil, fmt.Errorf("sort field %s must be grouped", fieldPath(plan, *s.Column))
+			}
+			path = groupOutputName(plan.GroupBy[i])
+		case s.Target == planner.SortColumn && s.Column != nil:
+			path = fieldPath(plan, *s.Column)
+		default:
+			return nil, fmt.Errorf("sorting by %s is not supported by the MongoDB dialect", strings.ToLower(string(s.Target)))
+		}
+		sort = append(sort, E{path, direction})
+	}
+	return sort, nil
+}
+
+// buildKeysetMatch builds the filter for the documents after the keyset
+// cursor: (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with < for
+// descending keys. MongoDB sorts null (and missing) values first
+// ascending and last descending, so a null position or value follows that
+// order.
+func (pb *PipelineBuilder) buildKeysetMatch(k *planner.Keyset) (D, error) {
+	var branches A
+	var equal A
+	for i, key := range k.Keys {
+		path := fieldPath(pb.plan, key.Column)
+		value, err := mongoValue(key.Column.DataType, k.After[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor value for %s: %w", key.Field, err)
+		}
+
+		var after D
+		switch {
+		case value == nil && key.Direction == "DESC":
+			// Nothing sorts after null descending
+		case value == nil:
+			after = D{{path, D{{"$ne", nil}}}}
+		case key.Direction == "DESC" && key.Nullable:
+			after = D{{"$or", A{D{{path, D{{"$lt", value}}}}, D{{path, nil}}}}}
+		case key.Direction == "DESC":
+			after = D{{path, D{{"$lt", value}}}}
+		default:
+			after = D{{path, D{{"$gt", value}}}}
+		}
+
+		if after != nil {
+			branch := append(append(A{}, equal...), after)
+			if len(branch) == 1 {
+				branches = append(branches, after)
+			} else {
+				branches = append(branches, D{{"$and", branch}})
+			}
+		}
+		equal = append(equal, D{{path, value}})
+	}
+
+	if len(branches) == 0 {
+		// The cursor is past the last document
+		return D{{"_id", D{{"$exists", false}}}}, nil
+	}
+	if len(branches) == 1 {
+		return branches[0].(D), nil
+	}
+	return D{{"$or", branches}}, nil
+}
+
+// buildFilter translates a filter tree into a $match document
+func (pb *PipelineBuilder) buildFilter(expr planner.FilterExpr) (D, error) {
+	switch e := expr.(type) {
+	case *planner.ComparisonFilterIR:
+		return pb.buildComparison(e)
+	case *planner.LogicalFilterIR:
+		return pb.buildLogical(e)
+	default:
+		return nil, fmt.Errorf("unknown filter expression type")
+	}
+}
+
+// buildLogical translates AND, OR and NOT into $and, $or and $nor
+func (pb *PipelineBuilder) buildLogical(f *planner.LogicalFilterIR) (D, error) {
+	var op string
+	switch f.Op {
+	case "AND":
+		op = "$and"
+	case "OR":
+		op = "$or"
+	case "NOT":
+		if len(f.Nodes) != 1 {
+			return nil, fmt.Errorf("NOT filter must have exactly one node")
+		}
+		return pb.buildFalse(f.Nodes[0])
+	default:
+		return nil, fmt.Errorf("unknown logical operator: %s", f.Op)
+	}
+
+	nodes := make(A, len(f.Nodes))
+	for i, node := range f.Nodes {
+		match, err := pb.buildFilter(node)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = match
+	}
+	return D{{op, nodes}}, nil
+}
+
+// buildFalse matches the documents a filter is false for. As in SQL, a
+// comparison with a null or missing field is unknown rather than false, so
+// NOT excludes those documents too: each negated comparison is a $nor
+// guarded by the field being set, and De Morgan's laws push the negation
+// through AND and OR.
+func (pb *PipelineBuilder) buildFalse(expr planner.FilterExpr) (D, error) {
+	switch e := expr.(type) {
+	case *planner.ComparisonFilterIR:
+		match, err := pb.buildComparison(e)
+		if err != nil {
+			return nil, err
+		}
+		if e.Operator == dsl.OpIsNull || e.Operator == dsl.OpNotNull {
+			return D{{"$nor", A{match}}}, nil
+		}
+		path, err := pb.comparisonPath(e)
+		if err != nil {
+			return nil, err
+		}
+		return D{{"$and", A{D{{path, D{{"$ne", nil}}}}, D{{"$nor", A{match}}}}}}, nil
+
+	case *planner.LogicalFilterIR:
+		var op string
+		switch e.Op {
+		case "AND":
+			op = "$or"
+		case "OR":
+			op = "$and"
+		case "NOT":
+			if len(e.Nodes) != 1 {
+				return nil, fmt.Errorf("NOT filter must have exactly one node")
+			}
+			return pb.buildFilter(e.Nodes[0])
+		default:
+			return nil, fmt.Errorf("unknown logical operator: %s", e.Op)
+		}
+		nodes := make(A, len(e.Nodes))
+		for i, node := range e.Nodes {
+			match, err := pb.buildFalse(node)
+			if err != nil {
+				return nil, err
+			}
+			nodes[i] = match
+		}
+		return D{{op, nodes}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown filter expression type")
+	}
+}
+
+// comparisonOps maps comparison operators to query operators
+var comparisonOps = map[dsl.FilterOperator]string{
+	dsl.OpEqual:    "$eq",
+	dsl.OpNotEqual: "$ne",
+	dsl.OpGT:       "$gt",
+	dsl.OpGTE:      "$gte",
+	dsl.OpLT:       "$lt",
+	dsl.OpLTE:      "$lte",
+	dsl.OpBefore:   "$lt",
+	dsl.OpAfter:    "$gt",
+	dsl.OpIn:       "$in",
+	dsl.OpNotIn:    "$nin",
+}
+
+// rangeOps maps the range operators to the query operators of their lower
+// and upper bounds
+var rangeOps = map[dsl.FilterOperator][2]string{
+	dsl.OpBetween: {"$gte", "$lte"},
+	dsl.OpGTELT:   {"$gte", "$lt"},
+	dsl.OpGTLTE:   {"$gt", "$lte"},
+}
+
+// comparisonPath returns the document path a comparison tests. Having
+// filters compare the projected output.
+func (pb *PipelineBuilder) comparisonPath(f *planner.ComparisonFilterIR) (string, error) {
+	switch {
+	case f.Aggregate != nil:
+		return f.Aggregate.Alias, nil
+	case pb.having:
+		i := pb.groupIndex(f.Left)
+		if i < 0 {
+			return "", fmt.Errorf("having field %s must be grouped", fieldPath(pb.plan, f.Left))
+		}
+		return groupOutputName(pb.plan.GroupBy[i]), nil
+	default:
+		return fieldPath(pb.plan, f.Left), nil
+	}
+}
+
+// buildComparison translates a single comparison. MongoDB matches missing
+// and null fields with $ne and $nin, so those also require the field to be
+// set, as SQL's <> and NOT IN do.
+func (pb *PipelineBuilder) buildComparison(f *planner.ComparisonFilterIR) (D, error) {
+	path, err := pb.comparisonPath(f)
+	if err != nil {
+		return nil, err
+	}
+	fieldType := f.Left.DataType
+
+	switch f.Operator {
+	case dsl.OpIsNull:
+		return D{{path, nil}}, nil
+	case dsl.OpNotNull:
+		return D{{path, D{{"$ne", nil}}}}, nil
+	}
+	if f.Value == nil {
+		return nil, fmt.Errorf("value required for %s operator", f.Operator)
+	}
+
+	if op, ok := comparisonOps[f.Operator]; ok {
+		var value interface{}
+		if f.Operator == dsl.OpIn || f.Operator == dsl.OpNotIn {
+			items, ok := f.Value.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s requires an array of values", f.Operator)
+			}
+			values := make(A, len(items))
+			for i, item := range items {
+				v, err := mongoValue(fieldType, item)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = v
+			}
+			value = values
+		} else {
+			v, err := mongoValue(fieldType, f.Value.Value)
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+		if op == "$ne" || op == "$nin" {
+			return D{{"$and", A{D{{path, D{{"$ne", nil}}}}, D{{path, D{{op, value}}}}}}}, nil
+		}
+		return D{{path, D{{op, value}}}}, nil
+	}
+
+	if ops, ok := rangeOps[f.Operator]; ok {
+		bounds, ok := f.Value.Value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return nil, fmt.Errorf("%s requires exactly 2 values", f.Operator)
+		}
+		cond := D{}
+		for i, bound := range bounds {
+			if bound == nil {
+				continue
+			}
+			v, err := mongoValue(fieldType, bound)
+			if err != nil {
+				return nil, err
+			}
+			cond = append(cond, E{ops[i], v})
+		}
+		if len(cond) == 0 {
+			return nil, fmt.Errorf("%s requires at least one non-null bound", f.Operator)
+		}
+		return D{{path, cond}}, nil
+	}
+
+	switch f.Operator {
+	case dsl.OpLike, dsl.OpILike, dsl.OpStartsWith, dsl.OpEndsWith, dsl.OpContains:
+		pattern, ok := f.Value.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a string value", f.Operator)
+		}
+		expr, err := dsl.LikeRegexp(dsl.LikePattern(f.Operator, pattern))
+		if err != nil {
+			return nil, err
+		}
+		options := "s"
+		if f.Operator == dsl.OpILike {
+			options = "is"
+		}
+		return D{{path, D{{"$regex", expr}, {"$options", options}}}}, nil
+
+	case dsl.OpHasKey:
+		name, ok := f.Value.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("has_key requires a string key")
+		}
+		return D{{path + "." + name, D{{"$exists", true}}}}, nil
+
+	case dsl.OpJSONContains:
+		doc, err := mongoValue(planner.TypeJSON, f.Value.Value)
+		if err != nil {
+			return nil, err
+		}
+		conds, err := containsConditions(path, doc)
+		if err != nil {
+			return nil, err
+		}
+		if len(conds) == 1 {
+			return conds[0], nil
+		}
+		and := make(A, len(conds))
+		for i, cond := range conds {
+			and[i] = cond
+		}
+		return D{{"$and", and}}, nil
+
+	default:
+		return nil, fmt.Errorf("the %s operator is not supported by the MongoDB dialect", f.Operator)
+	}
+}
+
+// containsConditions translates JSON containment (jsonb @>) at path into
+// query conditions: every key of an object is matched by its own path,
+// arrays must hold all the given elements ($all, or $elemMatch for
+// objects), and scalars match by equality, which MongoDB also applies to
+// the elements of arrays
+func containsConditions(path string, doc interface{}) ([]D, error) {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var conds []D
+		for _, k := range keys {
+			sub, err := containsConditions(path+"."+k, v[k])
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, sub...)
+		}
+		if len(conds) == 0 {
+			// {} is contained in any object
+			return []D{{{path, D{{"$type", "object"}}}}}, nil
+		}
+		return conds, nil
+
+	case []interface{}:
+		var scalars A
+		var conds []D
+		for _, item := range v {
+			object, isObject := item.(map[string]interface{})
+			switch {
+			case isObject:
+				match, err := elemMatch(object)
+				if err != nil {
+					return nil, err
+				}
+				conds = append(conds, D{{path, D{{"$elemMatch", match}}}})
+			case isContainer(item):
+				return nil, fmt.Errorf("json_contains with nested arrays is not supported by the MongoDB dialect")
+			default:
+				scalars = append(scalars, item)
+			}
+		}
+		if len(scalars) > 0 {
+			conds = append([]D{{{path, D{{"$all", scalars}}}}}, conds...)
+		}
+		if len(conds) == 0 {
+			return []D{{{path, D{{"$type", "array"}}}}}, nil
+		}
+		return conds, nil
+
+	default:
+		return []D{{{path, v}}}, nil
+	}
+}
+
+// elemMatch translates containment of an object inside array elements
+func elemMatch(object map[string]interface{}) (D, error) {
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	match := D{}
+	for _, k := range keys {
+		conds, err := containsConditions(k, object[k])
+		if err != nil {
+			return nil, err
+		}
+		for _, cond := range conds {
+			match = append(match, cond...)
+		}
+	}
+	return match, nil
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,435 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"udv/internal/adapter"
+	"udv/internal/config"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+	"udv/internal/schema"
+)
+
+func setupTestRegistry(t *testing.T) *schema.Registry {
+	t.Helper()
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "user_id", Type: "integer"},
+					{Name: "status", Type: "string"},
+					{Name: "amount", Type: "decimal"},
+					{Name: "priority", Type: "integer", Nullable: true},
+					{Name: "created_at", Type: "timestamp"},
+					{Name: "is_paid", Type: "boolean"},
+					{Name: "metadata", Type: "json", Nullable: true},
+				},
+			},
+			{
+				Name:       "users",
+				Table:      "users",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "string"},
+					{Name: "country", Type: "string"},
+				},
+			},
+		},
+	}
+
+	reg := schema.NewRegistry()
+	reg.LoadFromConfig(cfg)
+	if err := reg.AddRelation("orders", &schema.Relation{Name: "user", Type: schema.ManyToOne, TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}); err != nil {
+		t.Fatalf("AddRelation error: %v", err)
+	}
+	return reg
+}
+
+// plan validates and plans a query, failing the test on error
+func plan(t *testing.T, reg *schema.Registry, q *dsl.Query) *planner.QueryPlan {
+	t.Helper()
+	if err := dsl.NewValidator(reg).ValidateQuery(q); err != nil {
+		t.Fatalf("ValidateQuery error: %v", err)
+	}
+	p, err := planner.NewPlanner(reg).PlanQuery(q)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+	return p
+}
+
+// pipelineJSON renders a pipeline, failing the test on error
+func pipelineJSON(t *testing.T, pipeline Pipeline) string {
+	t.Helper()
+	encoded, err := json.Marshal(pipeline)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	return string(encoded)
+}
+
+func TestBuildPipeline_Golden(t *testing.T) {
+	reg := setupTestRegistry(t)
+	fraction, separator := 0.9, ", "
+
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected string
+	}{
+		{
+			"simple select",
+			&dsl.Query{Model: "orders", Fields: []string{"id", "status"}, Pagination: &dsl.Pagination{Limit: 10, Offset: 20}},
+			`[{"$skip":20},{"$limit":10},{"$project":{"_id":0,"id":"$id","status":"$status"}}]`,
+		},
+		{
+			"comparisons",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpNotEqual, Value: "CANCELLED"},
+					&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01T00:00:00Z"},
+					&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpBetween, Value: []interface{}{10, nil}},
+					&dsl.ComparisonFilter{Field: "priority", Op: dsl.OpIsNull},
+				}},
+			},
+			`[{"$match":{"$and":[{"$and":[{"status":{"$ne":null}},{"status":{"$ne":"CANCELLED"}}]},{"created_at":{"$gt":{"$date":"2024-01-01T00:00:00Z"}}},` +
+				`{"amount":{"$gte":10}},{"priority":null}]}},{"$limit":100}]`,
+		},
+		{
+			"in, not in and not",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{Or: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpIn, Value: []interface{}{"PAID", "SHIPPED"}},
+					&dsl.LogicalFilter{Not: &dsl.ComparisonFilter{Field: "id", Op: dsl.OpNotIn, Value: []interface{}{1, 2}}},
+				}},
+			},
+			`[{"$match":{"$or":[{"status":{"$in":["PAID","SHIPPED"]}},` +
+				`{"$and":[{"id":{"$ne":null}},{"$nor":[{"$and":[{"id":{"$ne":null}},{"id":{"$nin":[1,2]}}]}]}]}]}},{"$limit":100}]`,
+		},
+		{
+			// NOT excludes documents its condition is unknown for, as in SQL
+			"not pushed through and",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{Not: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+					&dsl.ComparisonFilter{Field: "priority", Op: dsl.OpIsNull},
+				}}},
+			},
+			`[{"$match":{"$or":[{"$and":[{"status":{"$ne":null}},{"$nor":[{"status":{"$eq":"PAID"}}]}]},{"$nor":[{"priority":null}]}]}},{"$limit":100}]`,
+		},
+		{
+			"like and contains as regex",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpILike, Value: `pa_d\%%`},
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpContains, Value: "a.b"},
+				}},
+			},
+			`[{"$match":{"$and":[{"status":{"$regex":"^pa.d%.*$","$options":"is"}},{"status":{"$regex":"^.*a\\.b.*$","$options":"s"}}]}},{"$limit":100}]`,
+		},
+		{
+			"json paths and containment",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "metadata.shipping.country", Op: dsl.OpEqual, Value: "NL"},
+					&dsl.ComparisonFilter{Field: "metadata", Op: dsl.OpHasKey, Value: "gift"},
+					&dsl.ComparisonFilter{Field: "metadata", Op: dsl.OpJSONContains, Value: map[string]interface{}{
+						"tags":  []interface{}{"fragile"},
+						"items": []interface{}{map[string]interface{}{"sku": "A1"}},
+					}},
+				}},
+			},
+			`[{"$match":{"$and":[{"metadata.shipping.country":{"$eq":"NL"}},{"metadata.gift":{"$exists":true}},` +
+				`{"$and":[{"metadata.items":{"$elemMatch":{"sku":"A1"}}},{"metadata.tags":{"$all":["fragile"]}}]}]}},{"$limit":100}]`,
+		},
+		{
+			"relation join",
+			&dsl.Query{
+				Model:   "orders",
+				Fields:  []string{"id", "user.email"},
+				Filters: &dsl.ComparisonFilter{Field: "user.country", Op: dsl.OpEqual, Value: "NL"},
+				Sort:    []dsl.Sort{{Field: "id", Direction: dsl.SortDesc}},
+			},
+			`[{"$lookup":{"from":"users","localField":"user_id","foreignField":"id","as":"t1"}},` +
+				`{"$unwind":{"path":"$t1","preserveNullAndEmptyArrays":true}},{"$match":{"t1.country":{"$eq":"NL"}}},` +
+				`{"$sort":{"id":-1}},{"$limit":100},{"$project":{"_id":0,"id":"$id","user.email":"$t1.email"}}]`,
+		},
+		{
+			"group by with having and sort",
+			&dsl.Query{
+				Model:   "orders",
+				GroupBy: []dsl.GroupBy{{Field: "status"}},
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCount, Alias: "order_count"},
+					{Function: dsl.AggSum, Field: "amount", Alias: "revenue"},
+				},
+				Having: &dsl.ComparisonFilter{Field: "order_count", Op: dsl.OpGT, Value: 1},
+				Sort:   []dsl.Sort{{Field: "revenue", Direction: dsl.SortDesc}, {Field: "status"}},
+			},
+			`[{"$group":{"_id":{"g0":"$status"},"order_count":{"$sum":1},"revenue":{"$sum":"$amount"}}},` +
+				`{"$project":{"_id":0,"status":"$_id.g0","order_count":"$order_count","revenue":"$revenue"}},` +
+				`{"$match":{"order_count":{"$gt":1}}},{"$sort":{"revenue":-1,"status":1}},{"$limit":100}]`,
+		},
+		{
+			"time bucket",
+			&dsl.Query{
+				Model:      "orders",
+				GroupBy:    []dsl.GroupBy{{Field: "created_at", Granularity: dsl.GranularityWeek, Timezone: "Europe/Amsterdam"}},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}},
+			},
+			`[{"$group":{"_id":{"g0":{"$dateTrunc":{"date":"$created_at","unit":"week","timezone":"Europe/Amsterdam","startOfWeek":"monday"}}},"n":{"$sum":1}}},` +
+				`{"$project":{"_id":0,"created_at_week":"$_id.g0","n":"$n"}},{"$limit":100}]`,
+		},
+		{
+			"aggregates finished in project",
+			&dsl.Query{
+				Model: "orders",
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCountDistinct, Field: "status", Alias: "statuses"},
+					{Function: dsl.AggPercentile, Field: "amount", Alias: "p90", Fraction: &fraction},
+					{Function: dsl.AggVariance, Field: "amount", Alias: "var"},
+					{Function: dsl.AggStringAgg, Field: "status", Alias: "all", Separator: &separator},
+				},
+			},
+			`[{"$group":{"_id":null,"statuses":{"$addToSet":"$status"},` +
+				`"p90":{"$percentile":{"input":"$amount","p":[0.9],"method":"approximate"}},` +
+				`"var":{"$stdDevSamp":"$amount"},"all":{"$push":"$status"}}},` +
+				`{"$project":{"_id":0,"statuses":{"$size":"$statuses"},"p90":{"$arrayElemAt":["$p90",0]},"var":{"$pow":["$var",2]},` +
+				`"all":{"$reduce":{"input":{"$filter":{"input":"$all","cond":{"$ne":["$$this",null]}}},"initialValue":null,` +
+				`"in":{"$cond":[{"$eq":["$$value",null]},{"$toString":"$$this"},{"$concat":["$$value",", ",{"$toString":"$$this"}]}]}}}}},` +
+				`{"$limit":100}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := NewPipelineBuilder().BuildPipeline(plan(t, reg, tt.query))
+			if err != nil {
+				t.Fatalf("BuildPipeline error: %v", err)
+			}
+			if got := pipelineJSON(t, pipeline); got != tt.expected {
+				t.Errorf("pipeline mismatch\nexpected: %s\ngot:      %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildPipeline_Keyset(t *testing.T) {
+	reg := setupTestRegistry(t)
+
+	tests := []struct {
+		name     string
+		sort     []dsl.Sort
+		after    []interface{}
+		expected string
+	}{
+		{
+			"ascending",
+			[]dsl.Sort{{Field: "status"}},
+			[]interface{}{"PAID", 42},
+			`{"$or":[{"status":{"$gt":"PAID"}},{"$and":[{"status":"PAID"},{"id":{"$gt":42}}]}]}`,
+		},
+		{
+			"nullable key descending",
+			[]dsl.Sort{{Field: "priority", Direction: dsl.SortDesc}},
+			[]interface{}{5, 42},
+			`{"$or":[{"$or":[{"priority":{"$lt":5}},{"priority":null}]},{"$and":[{"priority":5},{"id":{"$lt":42}}]}]}`,
+		},
+		{
+			"null cursor value descending",
+			[]dsl.Sort{{Field: "priority", Direction: dsl.SortDesc}},
+			[]interface{}{nil, 42},
+			`{"$and":[{"priority":null},{"id":{"$lt":42}}]}`,
+		},
+		{
+			"null cursor value ascending",
+			[]dsl.Sort{{Field: "priority"}},
+			[]interface{}{nil, 42},
+			`{"$or":[{"priority":{"$ne":null}},{"$and":[{"priority":null},{"id":{"$gt":42}}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := dsl.EncodeCursor(dsl.KeysetSort(&dsl.Query{Sort: tt.sort}, "id"), tt.after)
+			if err != nil {
+				t.Fatalf("EncodeCursor error: %v", err)
+			}
+			pipeline, err := NewPipelineBuilder().BuildPipeline(plan(t, reg, &dsl.Query{
+				Model:      "orders",
+				Sort:       tt.sort,
+				Pagination: &dsl.Pagination{Limit: 25, Keyset: true, Cursor: cursor},
+			}))
+			if err != nil {
+				t.Fatalf("BuildPipeline error: %v", err)
+			}
+			match := pipelineJSON(t, Pipeline{pipeline[0]})
+			if want := `[{"$match":` + tt.expected + `}]`; match != want {
+				t.Errorf("keyset match mismatch\nexpected: %s\ngot:      %s", want, match)
+			}
+			if last := pipeline[len(pipeline)-1]; !reflect.DeepEqual(last, D{{"$limit", 25}}) {
+				t.Errorf("last stage = %v, want $limit 25 without $skip", last)
+			}
+		})
+	}
+}
+
+func TestBuildCountPipeline(t *testing.T) {
+	reg := setupTestRegistry(t)
+	pipeline, err := NewPipelineBuilder().BuildCountPipeline(plan(t, reg, &dsl.Query{
+		Model:      "orders",
+		Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		Sort:       []dsl.Sort{{Field: "id"}},
+		Pagination: &dsl.Pagination{Limit: 10, Offset: 30},
+	}))
+	if err != nil {
+		t.Fatalf("BuildCountPipeline error: %v", err)
+	}
+	want := `[{"$match":{"status":{"$eq":"PAID"}}},{"$count":"total"}]`
+	if got := pipelineJSON(t, pipeline); got != want {
+		t.Errorf("pipeline mismatch\nexpected: %s\ngot:      %s", want, got)
+	}
+}
+
+func TestDialect_BuildQuery(t *testing.T) {
+	reg := setupTestRegistry(t)
+	command, params, err := Dialect{}.BuildQuery(plan(t, reg, &dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+	}))
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+	want := `{"aggregate":"orders","pipeline":[{"$match":{"status":{"$eq":"PAID"}}},{"$limit":100}]}`
+	if command != want || len(params) != 0 {
+		t.Errorf("BuildQuery() = %s, %v; want %s without params", command, params, want)
+	}
+}
+
+func TestBuildPipeline_Unsupported(t *testing.T) {
+	reg := setupTestRegistry(t)
+
+	tests := []struct {
+		name   string
+		query  *dsl.Query
+		errMsg string
+	}{
+		{
+			"aggregate filter",
+			&dsl.Query{Model: "orders", Aggregates: []dsl.Aggregate{{
+				Function: dsl.AggCount, Alias: "paid",
+				Filter: &dsl.ComparisonFilter{Field: "is_paid", Op: dsl.OpEqual, Value: true},
+			}}},
+			"aggregate filters are not supported",
+		},
+		{
+			"sort by ungrouped column",
+			&dsl.Query{
+				Model:      "orders",
+				GroupBy:    []dsl.GroupBy{{Field: "status"}},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}},
+				Sort:       []dsl.Sort{{Field: "id"}},
+			},
+			"must be grouped",
+		},
+		{
+			"trailing escape in pattern",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpLike, Value: `PA\`}},
+			"must not end with escape character",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := planner.NewPlanner(reg).PlanQuery(tt.query)
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+			_, err = NewPipelineBuilder().BuildPipeline(p)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("BuildPipeline() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+// fakeAggregator returns canned documents and records the pipelines it runs
+type fakeAggregator struct {
+	rows      []adapter.Row
+	pipelines []string
+}
+
+func (f *fakeAggregator) Aggregate(ctx context.Context, collection string, pipeline []byte) ([]adapter.Row, error) {
+	f.pipelines = append(f.pipelines, collection+" "+string(pipeline))
+	return f.rows, nil
+}
+
+func (f *fakeAggregator) Close() error {
+	return nil
+}
+
+func TestDatabase_FetchPlan(t *testing.T) {
+	reg := setupTestRegistry(t)
+	client := &fakeAggregator{rows: []adapter.Row{
+		{"id": int64(1), "user": map[string]interface{}{"email": "a@example.com"}},
+	}}
+	db := NewDatabase(client)
+
+	rows, err := db.FetchPlan(context.Background(), plan(t, reg, &dsl.Query{Model: "orders", Fields: []string{"id", "user.email"}}))
+	if err != nil {
+		t.Fatalf("FetchPlan error: %v", err)
+	}
+	want := []adapter.Row{{"id": int64(1), "user.email": "a@example.com"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+	if len(client.pipelines) != 1 || !strings.HasPrefix(client.pipelines[0], `orders [{"$lookup"`) {
+		t.Errorf("pipelines = %v", client.pipelines)
+	}
+
+	// An aggregate over no documents still returns one row
+	client.rows = nil
+	rows, err = db.FetchPlan(context.Background(), plan(t, reg, &dsl.Query{
+		Model:      "orders",
+		Filters:    &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpBefore, Value: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)},
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}, {Function: dsl.AggSum, Field: "amount", Alias: "total"}},
+	}))
+	if err != nil {
+		t.Fatalf("FetchPlan error: %v", err)
+	}
+	if want := []adapter.Row{{"n": int64(0), "total": nil}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestDatabase_CountRows(t *testing.T) {
+	reg := setupTestRegistry(t)
+	client := &fakeAggregator{rows: []adapter.Row{{"total": int32(7)}}}
+	db := NewDatabase(client)
+
+	total, err := db.CountRows(context.Background(), plan(t, reg, &dsl.Query{Model: "orders"}), false)
+	if err != nil {
+		t.Fatalf("CountRows error: %v", err)
+	}
+	if total != 7 {
+		t.Errorf("total = %d, want 7", total)
+	}
+	if want := `orders [{"$count":"total"}]`; len(client.pipelines) != 1 || client.pipelines[0] != want {
+		t.Errorf("pipelines = %v, want [%s]", client.pipelines, want)
+	}
+}
@@ -0,0 +1,159 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"udv/internal/adapter"
+	"udv/internal/planner"
+)
+
+// Dialect renders query plans as MySQL/MariaDB SQL (MySQL 8.0+, MariaDB
+// 10.5+). Each build uses its own QueryBuilder, so a Dialect is safe for
+// concurrent use.
+type Dialect struct{}
+
+var _ adapter.Dialect = Dialect{}
+
+// Name implements adapter.Dialect
+func (Dialect) Name() string {
+	return "MySQL"
+}
+
+// BuildQuery implements adapter.Dialect
+func (Dialect) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	return NewQueryBuilder().BuildQuery(plan)
+}
+
+// BuildTimeSeriesQuery implements adapter.Dialect
+func (Dialect) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return NewQueryBuilder().BuildTimeSeriesQuery(plan)
+}
+
+// QuoteIdentifier implements adapter.Dialect
+func (Dialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// TypeCast implements adapter.Dialect
+func (Dialect) TypeCast(placeholder string, fieldType planner.FieldType) string {
+	return typeCast(placeholder, fieldType)
+}
+
+// Capabilities implements adapter.Dialect. Full-text search needs FULLTEXT
+// indexes and has no trigram counterpart, MySQL has no percentile
+// aggregates, and time series would need generated bucket rows.
+func (Dialect) Capabilities() adapter.Capabilities {
+	return adapter.Capabilities{
+		JSONPaths:         true,
+		WindowFunctions:   true,
+		StatisticalAggs:   true,
+		FilteredAggregate: true,
+		ArrayAggregates:   true,
+	}
+}
+
+// Adapter is the MySQL adapter.Adapter
+type Adapter struct {
+	db *Database
+}
+
+var (
+	_ adapter.Adapter  = (*Adapter)(nil)
+	_ adapter.Executor = (*Database)(nil)
+	_ adapter.Counter  = (*Database)(nil)
+)
+
+// NewAdapter returns the MySQL adapter running queries on db, or only
+// rendering them when db is nil
+func NewAdapter(db *Database) *Adapter {
+	return &Adapter{db: db}
+}
+
+// Dialect implements adapter.Adapter
+func (a *Adapter) Dialect() adapter.Dialect {
+	return Dialect{}
+}
+
+// Executor implements adapter.Adapter
+func (a *Adapter) Executor() adapter.Executor {
+	if a.db == nil {
+		return nil
+	}
+	return a.db
+}
+
+// Database runs MySQL queries on a database/sql connection pool. UDV does
+// not link a MySQL driver: the binary registers one (such as
+// github.com/go-sql-driver/mysql, with parseTime=true) and opens the pool.
+type Database struct {
+	db *sql.DB
+}
+
+// NewDatabase wraps an open connection pool, checking that it is reachable
+func NewDatabase(db *sql.DB) (*Database, error) {
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return &Database{db: db}, nil
+}
+
+// Close closes the database connection
+func (d *Database) Close() error {
+	return d.db.Close()
+}
+
+// Fetch implements adapter.Executor: it runs a query and returns all its
+// rows
+func (d *Database) Fetch(ctx context.Context, sql string, args ...interface{}) ([]adapter.Row, error) {
+	var results []adapter.Row
+	err := d.Stream(ctx, sql, args, func(row adapter.Row) error {
+		results = append(results, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Stream implements adapter.Executor: it runs a query and hands each row to
+// fn as it is scanned
+func (d *Database) Stream(ctx context.Context, sql string, args []interface{}, fn func(adapter.Row) error) error {
+	rows, err := d.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("query execution failed: %w", err)
+	}
+	defer rows.Close()
+
+	return adapter.ScanRows(rows, fn)
+}
+
+// CountRows implements adapter.Counter. Estimates of a whole table come
+// from information_schema.TABLES.TABLE_ROWS (approximate for InnoDB); MySQL
+// has no cheap estimate for other queries, so those are counted exactly.
+func (d *Database) CountRows(ctx context.Context, plan *planner.QueryPlan, estimate bool) (int64, error) {
+	if estimate && plan.Filters == nil && len(plan.Joins) == 0 && len(plan.GroupBy) == 0 && len(plan.Aggregates) == 0 {
+		var rows sql.NullInt64
+		err := d.db.QueryRowContext(ctx,
+			"SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+			plan.RootModel.Table).Scan(&rows)
+		if err != nil {
+			return 0, fmt.Errorf("table estimate failed: %w", err)
+		}
+		if rows.Valid {
+			return rows.Int64, nil
+		}
+	}
+
+	sql, params, err := NewQueryBuilder().BuildCountQuery(plan)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := d.db.QueryRowContext(ctx, sql, params...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count query failed: %w", err)
+	}
+	return total, nil
+}
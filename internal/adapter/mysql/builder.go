@@ -0,0 +1,303 @@
+package mysql
+
+// Package mysql implements MySQL/MariaDB-specific query generation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"udv/internal/adapter/sqlgen"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// quoteIdentifier quotes a SQL identifier in backticks, doubling embedded
+// backticks
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteLiteral quotes a SQL string literal. Backslashes are escaped too,
+// since MySQL treats them as escape characters in literals by default.
+func quoteLiteral(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// columnSQL renders a resolved column reference as alias.`column`
+func columnSQL(ref planner.ColumnRef) string {
+	return ref.TableAlias + "." + quoteIdentifier(ref.ColumnName)
+}
+
+// typeCast casts a parameter placeholder where MySQL cannot compare the
+// bound value as is; only JSON documents need it
+func typeCast(placeholder string, fieldType planner.FieldType) string {
+	if fieldType == planner.TypeJSON {
+		return fmt.Sprintf("CAST(%s AS JSON)", placeholder)
+	}
+	return placeholder
+}
+
+// jsonPath renders JSON path segments as a MySQL path expression, keys
+// quoted and numeric segments as array indexes: $."items"[0]."sku"
+func jsonPath(segments []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range segments {
+		if n, err := strconv.Atoi(seg); err == nil && n >= 0 {
+			b.WriteString("[" + strconv.Itoa(n) + "]")
+			continue
+		}
+		key := strings.ReplaceAll(seg, `\`, `\\`)
+		b.WriteString(`."` + strings.ReplaceAll(key, `"`, `\"`) + `"`)
+	}
+	return b.String()
+}
+
+// jsonCastTypes maps the cast type of a JSON path to the MySQL type its
+// text is converted to
+var jsonCastTypes = map[planner.FieldType]string{
+	planner.TypeInteger:   "SIGNED",
+	planner.TypeInt:       "SIGNED",
+	planner.TypeFloat:     "DOUBLE",
+	planner.TypeDecimal:   "DECIMAL(65,30)",
+	planner.TypeTimestamp: "DATETIME",
+	planner.TypeDate:      "DATE",
+}
+
+// truncFormats maps the granularities DATE_FORMAT can truncate to with a
+// format string; week and quarter are computed
+var truncFormats = map[string]string{
+	"minute": "%Y-%m-%d %H:%i:00",
+	"hour":   "%Y-%m-%d %H:00:00",
+	"day":    "%Y-%m-%d",
+	"month":  "%Y-%m-01",
+	"year":   "%Y-01-01",
+}
+
+// QueryBuilder builds parameterized MySQL queries from query plans with the
+// shared ?-placeholder builder
+type QueryBuilder struct {
+	*sqlgen.Builder
+}
+
+// NewQueryBuilder creates a new query builder
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{Builder: sqlgen.NewBuilder(syntax{})}
+}
+
+// syntax implements sqlgen.Syntax for MySQL
+type syntax struct{}
+
+// Name implements sqlgen.Syntax
+func (syntax) Name() string {
+	return "MySQL"
+}
+
+// QuoteIdentifier implements sqlgen.Syntax
+func (syntax) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// Param implements sqlgen.Syntax; the driver binds every value as is
+func (syntax) Param(value interface{}) interface{} {
+	return value
+}
+
+// Cast implements sqlgen.Syntax
+func (syntax) Cast(placeholder string, fieldType planner.FieldType) string {
+	return typeCast(placeholder, fieldType)
+}
+
+// Value implements sqlgen.Syntax
+func (syntax) Value(ref planner.ColumnRef) string {
+	return valueSQL(ref)
+}
+
+// Document implements sqlgen.Syntax
+func (syntax) Document(ref planner.ColumnRef) string {
+	return documentSQL(ref)
+}
+
+// Group implements sqlgen.Syntax
+func (syntax) Group(groupExpr planner.GroupExpr) (string, error) {
+	return groupSQL(groupExpr)
+}
+
+// valueSQL renders a column reference as a value of its DataType. A JSON
+// path is extracted with JSON_EXTRACT, unquoted to text and cast to the
+// path's type:
+//
+//	CAST(JSON_UNQUOTE(JSON_EXTRACT(t0.`metadata`, '$."weight"')) AS DECIMAL(65,30))
+//
+// Paths are literals rather than parameters so that a grouped path is the
+// identical expression in SELECT and GROUP BY.
+func valueSQL(ref planner.ColumnRef) string {
+	if len(ref.JSONPath) == 0 {
+		return columnSQL(ref)
+	}
+	if ref.DataType == planner.TypeJSON {
+		return documentSQL(ref)
+	}
+
+	text := fmt.Sprintf("JSON_UNQUOTE(%s)", documentSQL(ref))
+	if ref.DataType == planner.TypeBoolean {
+		return fmt.Sprintf("(%s = 'true')", text)
+	}
+	if mysqlType, ok := jsonCastTypes[ref.DataType]; ok {
+		return fmt.Sprintf("CAST(%s AS %s)", text, mysqlType)
+	}
+	return text
+}
+
+// documentSQL renders a column reference as a JSON document, for the JSON
+// operators: the column itself, or the value at its JSON path
+func documentSQL(ref planner.ColumnRef) string {
+	if len(ref.JSONPath) == 0 {
+		return columnSQL(ref)
+	}
+	return fmt.Sprintf("JSON_EXTRACT(%s, %s)", columnSQL(ref), quoteLiteral(jsonPath(ref.JSONPath)))
+}
+
+// groupSQL renders a GROUP BY key. Bucketed keys truncate the column, first
+// converted from UTC to the bucket timezone (which needs MySQL's time zone
+// tables for named zones):
+//
+//	CAST(DATE_FORMAT(CONVERT_TZ(t0.`created_at`, '+00:00', 'Europe/Amsterdam'), '%Y-%m-%d') AS DATETIME)
+//
+// Granularity and timezone are validated and rendered as literals, so the
+// key is the identical expression in SELECT and GROUP BY.
+func groupSQL(groupExpr planner.GroupExpr) (string, error) {
+	col := valueSQL(groupExpr.Column)
+	if groupExpr.Granularity == "" {
+		return col, nil
+	}
+
+	if groupExpr.Timezone != "" && groupExpr.Column.DataType != planner.TypeDate {
+		col = fmt.Sprintf("CONVERT_TZ(%s, '+00:00', %s)", col, quoteLiteral(groupExpr.Timezone))
+	}
+
+	switch groupExpr.Granularity {
+	case "week":
+		// ISO weeks start on Monday
+		return fmt.Sprintf("CAST(DATE_SUB(DATE(%s), INTERVAL WEEKDAY(%s) DAY) AS DATETIME)", col, col), nil
+	case "quarter":
+		return fmt.Sprintf("CAST(MAKEDATE(YEAR(%s), 1) + INTERVAL QUARTER(%s) - 1 QUARTER AS DATETIME)", col, col), nil
+	}
+	format, ok := truncFormats[groupExpr.Granularity]
+	if !ok {
+		return "", fmt.Errorf("unsupported granularity: %s", groupExpr.Granularity)
+	}
+	return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%s') AS DATETIME)", col, format), nil
+}
+
+// patternOperators maps the pattern operators to how their value becomes a
+// LIKE pattern. They and like compare with LIKE BINARY to stay case
+// sensitive as in PostgreSQL: plain LIKE follows the column's collation,
+// which ignores case and accents for MySQL's default _ci collations.
+var patternOperators = map[dsl.FilterOperator]func(string) string{
+	dsl.OpStartsWith: func(s string) string { return s + "%" },
+	dsl.OpEndsWith:   func(s string) string { return "%" + s },
+	dsl.OpContains:   func(s string) string { return "%" + s + "%" },
+}
+
+// Match implements sqlgen.Syntax
+func (syntax) Match(b *sqlgen.Builder, colName string, f *planner.ComparisonFilterIR) (string, error) {
+	if pattern, ok := patternOperators[f.Operator]; ok {
+		s, ok := f.Value.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("%s requires a string value, got %T", f.Operator, f.Value.Value)
+		}
+		return fmt.Sprintf("%s LIKE BINARY %s", colName, b.AddParam(pattern(s))), nil
+	}
+
+	switch f.Operator {
+	case dsl.OpLike:
+		return fmt.Sprintf("%s LIKE BINARY %s", colName, b.AddParam(f.Value.Value)), nil
+
+	case dsl.OpILike:
+		// MySQL has no ILIKE; lowercasing both sides matches regardless of
+		// the column's collation
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", colName, b.AddParam(f.Value.Value)), nil
+
+	case dsl.OpHasKey:
+		key, ok := f.Value.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("has_key requires a string key, got %T", f.Value.Value)
+		}
+		return fmt.Sprintf("JSON_CONTAINS_PATH(%s, 'one', %s)", colName, b.AddParam(jsonPath([]string{key}))), nil
+
+	case dsl.OpJSONContains:
+		return fmt.Sprintf("JSON_CONTAINS(%s, %s)", colName, b.AddParam(f.Value.Value)), nil
+
+	default:
+		return "", fmt.Errorf("unknown operator: %s", f.Operator)
+	}
+}
+
+// Limit implements sqlgen.Syntax with MySQL's LIMIT offset, count
+func (syntax) Limit(b *sqlgen.Builder, plan *planner.QueryPlan) string {
+	// Keyset pages start from their WHERE position, never an offset
+	if plan.Keyset != nil {
+		return "LIMIT " + b.AddParam(plan.Pagination.Limit)
+	}
+	offset := b.AddParam(plan.Pagination.Offset)
+	return fmt.Sprintf("LIMIT %s, %s", offset, b.AddParam(plan.Pagination.Limit))
+}
+
+// Aggregate implements sqlgen.Syntax. MySQL has no FILTER clause; a
+// filtered aggregate only sees the matching rows' values through CASE,
+// which yields NULL (ignored by aggregates) for the rest:
+//
+//	COUNT(CASE WHEN t0.`status` = ? THEN 1 END)
+func (syntax) Aggregate(b *sqlgen.Builder, agg planner.AggregateExpr) (string, error) {
+	arg := "*"
+	if agg.Column != nil {
+		arg = valueSQL(*agg.Column)
+	}
+	if agg.Filter != nil {
+		if agg.Function == planner.AggArrayAggFn {
+			// JSON_ARRAYAGG keeps NULLs, so CASE cannot filter it
+			return "", fmt.Errorf("aggregate %s: filters are not supported for array_agg by the MySQL dialect", agg.Alias)
+		}
+		cond, err := b.Filter(agg.Filter)
+		if err != nil {
+			return "", fmt.Errorf("aggregate %s filter: %w", agg.Alias, err)
+		}
+		if agg.Column == nil {
+			arg = "1"
+		}
+		arg = fmt.Sprintf("CASE WHEN %s THEN %s END", cond, arg)
+	}
+
+	switch agg.Function {
+	case planner.AggCountFn:
+		return fmt.Sprintf("COUNT(%s)", arg), nil
+	case planner.AggSumFn:
+		return fmt.Sprintf("SUM(%s)", arg), nil
+	case planner.AggAvgFn:
+		return fmt.Sprintf("AVG(%s)", arg), nil
+	case planner.AggMinFn:
+		return fmt.Sprintf("MIN(%s)", arg), nil
+	case planner.AggMaxFn:
+		return fmt.Sprintf("MAX(%s)", arg), nil
+	case planner.AggCountDistinctFn:
+		return fmt.Sprintf("COUNT(DISTINCT %s)", arg), nil
+	case planner.AggStddevFn:
+		// Sample statistics, as STDDEV and VARIANCE are in PostgreSQL
+		return fmt.Sprintf("STDDEV_SAMP(%s)", arg), nil
+	case planner.AggVarianceFn:
+		return fmt.Sprintf("VAR_SAMP(%s)", arg), nil
+	case planner.AggStringAggFn:
+		return fmt.Sprintf("GROUP_CONCAT(%s SEPARATOR %s)", arg, quoteLiteral(agg.Separator)), nil
+	case planner.AggArrayAggFn:
+		return fmt.Sprintf("JSON_ARRAYAGG(%s)", arg), nil
+	case planner.AggBoolAndFn:
+		return fmt.Sprintf("MIN(%s)", arg), nil
+	case planner.AggBoolOrFn:
+		return fmt.Sprintf("MAX(%s)", arg), nil
+	default:
+		return "", fmt.Errorf("aggregate %s is not supported by the MySQL dialect", strings.ToLower(string(agg.Function)))
+	}
+}
@@ -0,0 +1,548 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"udv/internal/adapter"
+	"udv/internal/config"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+	"udv/internal/schema"
+)
+
+func setupTestRegistry() *schema.Registry {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer", Nullable: false},
+					{Name: "user_id", Type: "integer", Nullable: false},
+					{Name: "status", Type: "string", Nullable: false},
+					{Name: "amount", Type: "decimal", Nullable: false},
+					{Name: "created_at", Type: "timestamp", Nullable: false},
+					{Name: "is_paid", Type: "boolean", Nullable: false},
+					{Name: "metadata", Type: "json", Nullable: true},
+				},
+			},
+		},
+	}
+
+	reg := schema.NewRegistry()
+	reg.LoadFromConfig(cfg)
+	return reg
+}
+
+// buildSQL plans and renders a query, failing the test on error
+func buildSQL(t *testing.T, reg *schema.Registry, q *dsl.Query) (string, []interface{}) {
+	t.Helper()
+	plan, err := planner.NewPlanner(reg).PlanQuery(q)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+	sql, params, err := NewQueryBuilder().BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+	return sql, params
+}
+
+// The golden SQL below mirrors the PostgreSQL builder tests
+func TestBuildQuery_Golden(t *testing.T) {
+	reg := setupTestRegistry()
+	separator := "; "
+
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected string
+		params   []interface{}
+	}{
+		{
+			"simple select",
+			&dsl.Query{Model: "orders", Fields: []string{"id", "status", "amount"}},
+			"SELECT t0.`id`, t0.`status`, t0.`amount` FROM `orders` t0 LIMIT ?, ?;",
+			[]interface{}{0, 100},
+		},
+		{
+			"filter",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"}},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? LIMIT ?, ?;",
+			[]interface{}{"PAID", 0, 100},
+		},
+		{
+			"nested logical filter",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{Or: []dsl.FilterExpr{
+					&dsl.LogicalFilter{And: []dsl.FilterExpr{
+						&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+						&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 100},
+					}},
+					&dsl.LogicalFilter{Not: &dsl.ComparisonFilter{Field: "is_paid", Op: dsl.OpEqual, Value: true}},
+				}},
+			},
+			"SELECT * FROM `orders` t0 WHERE ((t0.`status` = ? AND t0.`amount` > ?) OR NOT (t0.`is_paid` = ?)) LIMIT ?, ?;",
+			[]interface{}{"PAID", int64(100), true, 0, 100},
+		},
+		{
+			"group by and aggregate",
+			&dsl.Query{
+				Model:      "orders",
+				GroupBy:    []dsl.GroupBy{{Field: "status"}},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "order_count"}},
+				Sort:       []dsl.Sort{{Field: "order_count", Direction: dsl.SortDesc}},
+				Pagination: &dsl.Pagination{Limit: 10},
+			},
+			"SELECT t0.`status`, COUNT(*) AS `order_count` FROM `orders` t0 GROUP BY t0.`status` ORDER BY `order_count` DESC LIMIT ?, ?;",
+			[]interface{}{0, 10},
+		},
+		{
+			"extended aggregates",
+			&dsl.Query{
+				Model:   "orders",
+				GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCountDistinct, Field: "status", Alias: "statuses"},
+					{Function: dsl.AggStddev, Field: "amount", Alias: "sd"},
+					{Function: dsl.AggVariance, Field: "amount", Alias: "var"},
+					{Function: dsl.AggStringAgg, Field: "status", Alias: "status_list", Separator: &separator},
+					{Function: dsl.AggArrayAgg, Field: "id", Alias: "ids"},
+					{Function: dsl.AggBoolAnd, Field: "is_paid", Alias: "all_paid"},
+					{Function: dsl.AggBoolOr, Field: "is_paid", Alias: "any_paid"},
+				},
+			},
+			"SELECT t0.`user_id`, COUNT(DISTINCT t0.`status`) AS `statuses`, STDDEV_SAMP(t0.`amount`) AS `sd`, VAR_SAMP(t0.`amount`) AS `var`, " +
+				"GROUP_CONCAT(t0.`status` SEPARATOR '; ') AS `status_list`, JSON_ARRAYAGG(t0.`id`) AS `ids`, " +
+				"MIN(t0.`is_paid`) AS `all_paid`, MAX(t0.`is_paid`) AS `any_paid` FROM `orders` t0 GROUP BY t0.`user_id` LIMIT ?, ?;",
+			[]interface{}{0, 100},
+		},
+		{
+			"aggregate filter and having",
+			&dsl.Query{
+				Model:   "orders",
+				Filters: &dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 10},
+				GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+				Aggregates: []dsl.Aggregate{
+					{Function: dsl.AggCount, Alias: "paid", Filter: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"}},
+					{Function: dsl.AggSum, Field: "amount", Alias: "refunded", Filter: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "REFUNDED"}},
+				},
+				Having: &dsl.ComparisonFilter{Field: "paid", Op: dsl.OpGT, Value: 1},
+			},
+			"SELECT t0.`user_id`, COUNT(CASE WHEN t0.`status` = ? THEN 1 END) AS `paid`, SUM(CASE WHEN t0.`status` = ? THEN t0.`amount` END) AS `refunded` " +
+				"FROM `orders` t0 WHERE t0.`amount` > ? GROUP BY t0.`user_id` " +
+				"HAVING COUNT(CASE WHEN t0.`status` = ? THEN 1 END) > ? LIMIT ?, ?;",
+			[]interface{}{"PAID", "REFUNDED", int64(10), "PAID", int64(1), 0, 100},
+		},
+		{
+			"time buckets",
+			&dsl.Query{
+				Model:   "orders",
+				Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				GroupBy: []dsl.GroupBy{
+					{Field: "created_at", Granularity: dsl.GranularityDay, Timezone: "America/New_York"},
+					{Field: "created_at", Granularity: dsl.GranularityQuarter},
+				},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggSum, Field: "amount", Alias: "revenue"}},
+			},
+			"SELECT CAST(DATE_FORMAT(CONVERT_TZ(t0.`created_at`, '+00:00', 'America/New_York'), '%Y-%m-%d') AS DATETIME) AS `created_at_day`, " +
+				"CAST(MAKEDATE(YEAR(t0.`created_at`), 1) + INTERVAL QUARTER(t0.`created_at`) - 1 QUARTER AS DATETIME) AS `created_at_quarter`, " +
+				"SUM(t0.`amount`) AS `revenue` FROM `orders` t0 WHERE t0.`status` = ? " +
+				"GROUP BY CAST(DATE_FORMAT(CONVERT_TZ(t0.`created_at`, '+00:00', 'America/New_York'), '%Y-%m-%d') AS DATETIME), " +
+				"CAST(MAKEDATE(YEAR(t0.`created_at`), 1) + INTERVAL QUARTER(t0.`created_at`) - 1 QUARTER AS DATETIME) LIMIT ?, ?;",
+			[]interface{}{"PAID", 0, 100},
+		},
+		{
+			"pagination",
+			&dsl.Query{Model: "orders", Pagination: &dsl.Pagination{Limit: 25, Offset: 50}},
+			"SELECT * FROM `orders` t0 LIMIT ?, ?;",
+			[]interface{}{50, 25},
+		},
+		{
+			"in list expanded",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpIn, Value: []interface{}{"PAID", "SHIPPED"}}},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` IN (?, ?) LIMIT ?, ?;",
+			[]interface{}{"PAID", "SHIPPED", 0, 100},
+		},
+		{
+			"not in list expanded",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "id", Op: dsl.OpNotIn, Value: []interface{}{1, 2, 3}}},
+			"SELECT * FROM `orders` t0 WHERE t0.`id` NOT IN (?, ?, ?) LIMIT ?, ?;",
+			[]interface{}{int64(1), int64(2), int64(3), 0, 100},
+		},
+		{
+			"case-sensitive patterns compare binary",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpLike, Value: "P_ID"},
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpStartsWith, Value: "PA"},
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEndsWith, Value: "ID"},
+					&dsl.ComparisonFilter{Field: "status", Op: dsl.OpContains, Value: "AI"},
+				}},
+			},
+			"SELECT * FROM `orders` t0 WHERE (t0.`status` LIKE BINARY ? AND t0.`status` LIKE BINARY ? AND t0.`status` LIKE BINARY ? AND t0.`status` LIKE BINARY ?) LIMIT ?, ?;",
+			[]interface{}{"P_ID", "PA%", "%ID", "%AI%", 0, 100},
+		},
+		{
+			"ilike lowercases both sides",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpILike, Value: "pa%"}},
+			"SELECT * FROM `orders` t0 WHERE LOWER(t0.`status`) LIKE LOWER(?) LIMIT ?, ?;",
+			[]interface{}{"pa%", 0, 100},
+		},
+		{
+			"range filters",
+			&dsl.Query{
+				Model: "orders",
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpBetween, Value: []interface{}{float64(10), float64(20)}},
+					&dsl.ComparisonFilter{Field: "id", Op: dsl.OpGTELT, Value: []interface{}{float64(1), float64(10)}},
+					&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpGTELT, Value: []interface{}{"2024-01-01", nil}},
+				}},
+			},
+			"SELECT * FROM `orders` t0 WHERE (t0.`amount` BETWEEN ? AND ? AND (t0.`id` >= ? AND t0.`id` < ?) AND t0.`created_at` >= ?) LIMIT ?, ?;",
+			[]interface{}{float64(10), float64(20), int64(1), int64(10), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 0, 100},
+		},
+		{
+			"json paths",
+			&dsl.Query{
+				Model:      "orders",
+				GroupBy:    []dsl.GroupBy{{Field: "metadata.shipping.country"}},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggSum, Field: "metadata.weight::decimal", Alias: "weight"}},
+				Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+					&dsl.ComparisonFilter{Field: "metadata", Op: dsl.OpHasKey, Value: "shipping"},
+					&dsl.ComparisonFilter{Field: "metadata.tags", Op: dsl.OpJSONContains, Value: []interface{}{"fragile"}},
+					&dsl.ComparisonFilter{Field: "metadata.items.0.sku", Op: dsl.OpEqual, Value: "A-1"},
+				}},
+				Sort:       []dsl.Sort{{Field: "metadata.shipping.country"}},
+				Pagination: &dsl.Pagination{Limit: 10},
+			},
+			"SELECT JSON_UNQUOTE(JSON_EXTRACT(t0.`metadata`, '$.\"shipping\".\"country\"')) AS `metadata.shipping.country`, " +
+				"SUM(CAST(JSON_UNQUOTE(JSON_EXTRACT(t0.`metadata`, '$.\"weight\"')) AS DECIMAL(65,30))) AS `weight` FROM `orders` t0 " +
+				"WHERE (JSON_CONTAINS_PATH(t0.`metadata`, 'one', ?) AND JSON_CONTAINS(JSON_EXTRACT(t0.`metadata`, '$.\"tags\"'), ?) " +
+				"AND JSON_UNQUOTE(JSON_EXTRACT(t0.`metadata`, '$.\"items\"[0].\"sku\"')) = ?) " +
+				"GROUP BY JSON_UNQUOTE(JSON_EXTRACT(t0.`metadata`, '$.\"shipping\".\"country\"')) " +
+				"ORDER BY JSON_UNQUOTE(JSON_EXTRACT(t0.`metadata`, '$.\"shipping\".\"country\"')) ASC LIMIT ?, ?;",
+			[]interface{}{`$."shipping"`, `["fragile"]`, "A-1", 0, 10},
+		},
+		{
+			"windows",
+			&dsl.Query{
+				Model: "orders",
+				Windows: []dsl.Window{
+					{Function: dsl.WinSum, Field: "amount", PartitionBy: []string{"user_id"}, OrderBy: []dsl.Sort{{Field: "created_at"}}, Alias: "running_total"},
+					{Function: dsl.WinRank, OrderBy: []dsl.Sort{{Field: "amount", Direction: dsl.SortDesc}}, Alias: "rank"},
+				},
+				Sort: []dsl.Sort{{Field: "rank"}},
+			},
+			"SELECT t0.*, SUM(t0.`amount`) OVER (PARTITION BY t0.`user_id` ORDER BY t0.`created_at` ASC) AS `running_total`, " +
+				"RANK() OVER (ORDER BY t0.`amount` DESC) AS `rank` FROM `orders` t0 ORDER BY `rank` ASC LIMIT ?, ?;",
+			[]interface{}{0, 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, params := buildSQL(t, reg, tt.query)
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("params = %#v, want %#v", params, tt.params)
+			}
+			// Positional placeholders must match the parameters one to one
+			if n := strings.Count(sql, "?"); n != len(params) {
+				t.Errorf("SQL has %d placeholders for %d params", n, len(params))
+			}
+		})
+	}
+}
+
+func TestBuildQuery_WithRelationJoins(t *testing.T) {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "user_id", Type: "integer"},
+					{Name: "amount", Type: "decimal"},
+				},
+			},
+			{
+				Name:       "users",
+				Table:      "users",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "string"},
+					{Name: "country", Type: "string"},
+				},
+			},
+		},
+	}
+	reg := schema.NewRegistry()
+	reg.LoadFromConfig(cfg)
+	if err := reg.AddRelation("orders", &schema.Relation{Name: "user", Type: schema.ManyToOne, TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}); err != nil {
+		t.Fatalf("AddRelation error: %v", err)
+	}
+
+	sql, _ := buildSQL(t, reg, &dsl.Query{
+		Model:   "orders",
+		Fields:  []string{"id", "user.email"},
+		Filters: &dsl.ComparisonFilter{Field: "user.country", Op: dsl.OpEqual, Value: "NL"},
+	})
+	expected := "SELECT t0.`id`, t1.`email` AS `user.email` FROM `orders` t0 LEFT JOIN `users` t1 ON t0.`user_id` = t1.`id` WHERE t1.`country` = ? LIMIT ?, ?;"
+	if sql != expected {
+		t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", expected, sql)
+	}
+}
+
+func TestBuildQuery_Keyset(t *testing.T) {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "status", Type: "string"},
+					{Name: "created_at", Type: "timestamp"},
+					{Name: "priority", Type: "integer", Nullable: true},
+				},
+			},
+		},
+	}
+	reg := schema.NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		sort     []dsl.Sort
+		after    []interface{} // nil for the first page
+		expected string
+		params   int
+	}{
+		{
+			"first page",
+			[]dsl.Sort{{Field: "created_at", Direction: dsl.SortDesc}},
+			nil,
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? ORDER BY t0.`created_at` DESC, t0.`id` DESC LIMIT ?;",
+			2,
+		},
+		{
+			"single direction",
+			[]dsl.Sort{{Field: "created_at", Direction: dsl.SortDesc}},
+			[]interface{}{"2024-03-01T10:00:00Z", 42},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? AND (t0.`created_at`, t0.`id`) < (?, ?) " +
+				"ORDER BY t0.`created_at` DESC, t0.`id` DESC LIMIT ?;",
+			4,
+		},
+		{
+			"mixed directions bind repeated values again",
+			[]dsl.Sort{{Field: "status"}, {Field: "created_at", Direction: dsl.SortDesc}},
+			[]interface{}{"PAID", "2024-03-01T10:00:00Z", 42},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? AND (t0.`status` > ? OR (t0.`status` = ? AND t0.`created_at` < ?) " +
+				"OR (t0.`status` = ? AND t0.`created_at` = ? AND t0.`id` < ?)) " +
+				"ORDER BY t0.`status` ASC, t0.`created_at` DESC, t0.`id` DESC LIMIT ?;",
+			8,
+		},
+		{
+			// MySQL sorts NULLs first ascending, so they precede the cursor
+			"nullable key",
+			[]dsl.Sort{{Field: "priority"}},
+			[]interface{}{5, 42},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? AND (t0.`priority` > ? OR (t0.`priority` = ? AND t0.`id` > ?)) " +
+				"ORDER BY t0.`priority` ASC, t0.`id` ASC LIMIT ?;",
+			5,
+		},
+		{
+			"null cursor value",
+			[]dsl.Sort{{Field: "priority"}},
+			[]interface{}{nil, 42},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? AND (t0.`priority` IS NOT NULL OR (t0.`priority` IS NULL AND t0.`id` > ?)) " +
+				"ORDER BY t0.`priority` ASC, t0.`id` ASC LIMIT ?;",
+			3,
+		},
+		{
+			"null cursor value descending",
+			[]dsl.Sort{{Field: "priority", Direction: dsl.SortDesc}},
+			[]interface{}{nil, 42},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? AND (t0.`priority` IS NULL AND t0.`id` < ?) " +
+				"ORDER BY t0.`priority` DESC, t0.`id` DESC LIMIT ?;",
+			3,
+		},
+		{
+			"nullable key descending",
+			[]dsl.Sort{{Field: "priority", Direction: dsl.SortDesc}},
+			[]interface{}{5, 42},
+			"SELECT * FROM `orders` t0 WHERE t0.`status` = ? AND ((t0.`priority` < ? OR t0.`priority` IS NULL) OR (t0.`priority` = ? AND t0.`id` < ?)) " +
+				"ORDER BY t0.`priority` DESC, t0.`id` DESC LIMIT ?;",
+			5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagination := &dsl.Pagination{Limit: 25, Keyset: true}
+			if tt.after != nil {
+				cursor, err := dsl.EncodeCursor(dsl.KeysetSort(&dsl.Query{Sort: tt.sort}, "id"), tt.after)
+				if err != nil {
+					t.Fatalf("EncodeCursor error: %v", err)
+				}
+				pagination.Cursor = cursor
+			}
+
+			sql, params := buildSQL(t, reg, &dsl.Query{
+				Model:      "orders",
+				Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				Sort:       tt.sort,
+				Pagination: pagination,
+			})
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+			if len(params) != tt.params || params[len(params)-1] != 25 {
+				t.Errorf("params = %v, want %d ending with limit 25", params, tt.params)
+			}
+		})
+	}
+}
+
+func TestBuildQuery_MixedKeysetParamOrder(t *testing.T) {
+	reg := setupTestRegistry()
+	after := []interface{}{"PAID", 42}
+	sort := []dsl.Sort{{Field: "status"}, {Field: "id", Direction: dsl.SortDesc}}
+	cursor, err := dsl.EncodeCursor(dsl.KeysetSort(&dsl.Query{Sort: sort}, "id"), after)
+	if err != nil {
+		t.Fatalf("EncodeCursor error: %v", err)
+	}
+
+	_, params := buildSQL(t, reg, &dsl.Query{
+		Model:      "orders",
+		Sort:       sort,
+		Pagination: &dsl.Pagination{Limit: 5, Keyset: true, Cursor: cursor},
+	})
+	want := []interface{}{"PAID", "PAID", int64(42), 5}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %#v, want %#v", params, want)
+	}
+}
+
+func TestBuildCountQuery(t *testing.T) {
+	reg := setupTestRegistry()
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected string
+	}{
+		{
+			"plain",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"}, Sort: []dsl.Sort{{Field: "id"}}},
+			"SELECT COUNT(*) FROM `orders` t0 WHERE t0.`status` = ?;",
+		},
+		{
+			"grouped",
+			&dsl.Query{Model: "orders", GroupBy: []dsl.GroupBy{{Field: "status"}}, Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}}},
+			"SELECT COUNT(*) FROM (SELECT t0.`status`, COUNT(*) AS `n` FROM `orders` t0 GROUP BY t0.`status`) AS counted;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.NewPlanner(reg).PlanQuery(tt.query)
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+			sql, _, err := NewQueryBuilder().BuildCountQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildCountQuery error: %v", err)
+			}
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+		})
+	}
+}
+
+func TestBuildQuery_Unsupported(t *testing.T) {
+	reg := setupTestRegistry()
+	tests := []struct {
+		name   string
+		query  *dsl.Query
+		errMsg string
+	}{
+		{
+			"similar",
+			&dsl.Query{Model: "orders", Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpSimilar, Value: "paid"}},
+			"the similar operator is not supported by the MySQL dialect",
+		},
+		{
+			"median",
+			&dsl.Query{Model: "orders", Aggregates: []dsl.Aggregate{{Function: dsl.AggMedian, Field: "amount", Alias: "m"}}},
+			"aggregate median is not supported by the MySQL dialect",
+		},
+		{
+			"filtered array_agg",
+			&dsl.Query{Model: "orders", Aggregates: []dsl.Aggregate{{
+				Function: dsl.AggArrayAgg, Field: "id", Alias: "ids",
+				Filter: &dsl.ComparisonFilter{Field: "is_paid", Op: dsl.OpEqual, Value: true},
+			}}},
+			"filters are not supported for array_agg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.NewPlanner(reg).PlanQuery(tt.query)
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+			_, _, err = NewQueryBuilder().BuildQuery(plan)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("BuildQuery() error = %v, want %q", err, tt.errMsg)
+			}
+
+			// The API rejects these before building, from the capabilities
+			if err := adapter.CheckCapabilities(Dialect{}, plan); err == nil && tt.name != "filtered array_agg" {
+				t.Errorf("CheckCapabilities() error = nil, want unsupported")
+			}
+		})
+	}
+}
+
+func TestBuildQuery_InvalidGranularity(t *testing.T) {
+	plan := &planner.QueryPlan{
+		RootModel: &planner.ModelRef{Name: "orders", Table: "orders", Alias: "t0"},
+		GroupBy: []planner.GroupExpr{{
+			Column:      planner.ColumnRef{TableAlias: "t0", ColumnName: "created_at", DataType: planner.TypeTimestamp},
+			Alias:       "bucket",
+			Granularity: "day'); DROP TABLE orders; --",
+		}},
+		Pagination: planner.Pagination{Limit: 10},
+	}
+
+	if _, _, err := NewQueryBuilder().BuildQuery(plan); err == nil {
+		t.Errorf("BuildQuery() error = nil, want unsupported granularity error")
+	}
+}
+
+func TestQuoting(t *testing.T) {
+	if got := quoteIdentifier("we`ird"); got != "`we``ird`" {
+		t.Errorf("quoteIdentifier() = %s", got)
+	}
+	if got := quoteLiteral(`it's a \ path`); got != `'it''s a \\ path'` {
+		t.Errorf("quoteLiteral() = %s", got)
+	}
+	if got := jsonPath([]string{"a\"b", "2", "c"}); got != `$."a\"b"[2]."c"` {
+		t.Errorf("jsonPath() = %s", got)
+	}
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"udv/internal/adapter"
+	"udv/internal/planner"
+)
+
+// Dialect renders query plans as PostgreSQL SQL. Each build uses its own
+// QueryBuilder, so a Dialect is safe for concurrent use.
+type Dialect struct{}
+
+var _ adapter.Dialect = Dialect{}
+
+// Name implements adapter.Dialect
+func (Dialect) Name() string {
+	return "PostgreSQL"
+}
+
+// BuildQuery implements adapter.Dialect
+func (Dialect) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	return NewQueryBuilder().BuildQuery(plan)
+}
+
+// BuildTimeSeriesQuery implements adapter.Dialect
+func (Dialect) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return NewQueryBuilder().BuildTimeSeriesQuery(plan)
+}
+
+// QuoteIdentifier implements adapter.Dialect
+func (Dialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// TypeCast implements adapter.Dialect
+func (Dialect) TypeCast(placeholder string, fieldType planner.FieldType) string {
+	return addTypeCast(placeholder, fieldType)
+}
+
+// Capabilities implements adapter.Dialect; PostgreSQL renders every
+// feature, though search and similar need extensions (see CheckPlan)
+func (Dialect) Capabilities() adapter.Capabilities {
+	return adapter.Capabilities{
+		FullTextSearch:    true,
+		Similarity:        true,
+		JSONPaths:         true,
+		WindowFunctions:   true,
+		Percentiles:       true,
+		StatisticalAggs:   true,
+		FilteredAggregate: true,
+		ArrayAggregates:   true,
+		TimeSeries:        true,
+	}
+}
+
+// Adapter is the PostgreSQL adapter.Adapter
+type Adapter struct {
+	db *Database
+}
+
+var (
+	_ adapter.Adapter     = (*Adapter)(nil)
+	_ adapter.Executor    = (*Database)(nil)
+	_ adapter.Counter     = (*Database)(nil)
+	_ adapter.PlanChecker = (*Database)(nil)
+)
+
+// NewAdapter returns the PostgreSQL adapter running queries on db, or only
+// rendering them when db is nil
+func NewAdapter(db *Database) *Adapter {
+	return &Adapter{db: db}
+}
+
+// Dialect implements adapter.Adapter
+func (a *Adapter) Dialect() adapter.Dialect {
+	return Dialect{}
+}
+
+// Executor implements adapter.Adapter
+func (a *Adapter) Executor() adapter.Executor {
+	if a.db == nil {
+		return nil
+	}
+	return a.db
+}
+
+// CheckPlan implements adapter.PlanChecker: it fails when the database
+// lacks an extension the plan's SQL depends on, so callers get a clear
+// message instead of a missing-operator error from PostgreSQL. Extensions
+// that cannot be looked up are assumed to be installed.
+func (d *Database) CheckPlan(ctx context.Context, plan *planner.QueryPlan) error {
+	for _, ext := range RequiredExtensions(plan) {
+		installed, err := d.HasExtension(ctx, ext)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		if !installed {
+			return &adapter.UnsupportedError{
+				Feature: ext,
+				Reason:  fmt.Sprintf("this query requires the PostgreSQL extension %s, which is not installed; run CREATE EXTENSION %s", ext, ext),
+			}
+		}
+	}
+	return nil
+}
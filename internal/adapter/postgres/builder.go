@@ -4,6 +4,7 @@ package postgres
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"udv/internal/dsl"
@@ -46,41 +47,177 @@ func addTypeCast(paramPlaceholder string, fieldType planner.FieldType) string {
 	return fmt.Sprintf("$%s::%s", strings.TrimPrefix(paramPlaceholder, "$"), pgType)
 }
 
+// addArrayTypeCast casts an array parameter to an array of the field's type
+// if the element type needs an explicit cast
+func addArrayTypeCast(paramPlaceholder string, fieldType planner.FieldType) string {
+	if !needsTypeCasting(fieldType) {
+		return paramPlaceholder
+	}
+	return addTypeCast(paramPlaceholder, fieldType) + "[]"
+}
+
+// arrayParam checks the value of an in/not_in filter is a list; Database
+// sends list parameters as PostgreSQL arrays
+func arrayParam(value interface{}) ([]interface{}, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected array value, got %T", value)
+	}
+	return values, nil
+}
+
+// stringParam returns the string value of a pattern filter
+func stringParam(f *planner.ComparisonFilterIR) (string, error) {
+	s, ok := f.Value.Value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s requires a string value, got %T", f.Operator, f.Value.Value)
+	}
+	return s, nil
+}
+
+// quoteIdentifier quotes a SQL identifier, doubling embedded quotes
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a SQL string literal, doubling embedded quotes
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// formatAlias returns an output column alias, quoting it when it is not a
+// plain lowercase identifier (e.g. the dotted path "user.email")
+func formatAlias(alias string) string {
+	if alias == "" {
+		return quoteIdentifier(alias)
+	}
+	for i, r := range alias {
+		if r == '_' || (r >= 'a' && r <= 'z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return quoteIdentifier(alias)
+	}
+	return alias
+}
+
+// columnSQL renders a resolved column reference as alias.column
+func columnSQL(ref planner.ColumnRef) string {
+	return fmt.Sprintf("%s.%s", ref.TableAlias, ref.ColumnName)
+}
+
+// jsonCastTypes maps the cast type of a JSON path to the PostgreSQL type
+// its text is converted to
+var jsonCastTypes = map[planner.FieldType]string{
+	planner.TypeInteger:   "bigint",
+	planner.TypeInt:       "bigint",
+	planner.TypeFloat:     "double precision",
+	planner.TypeDecimal:   "numeric",
+	planner.TypeBoolean:   "boolean",
+	planner.TypeTimestamp: "timestamptz",
+	planner.TypeDate:      "date",
+}
+
+// dateTruncUnits lists the granularities accepted by date_trunc
+var dateTruncUnits = map[string]bool{
+	"minute":  true,
+	"hour":    true,
+	"day":     true,
+	"week":    true,
+	"month":   true,
+	"quarter": true,
+	"year":    true,
+}
+
 // QueryBuilder builds parameterized PostgreSQL queries from query plans
 type QueryBuilder struct {
 	params     []interface{}
 	paramCount int
+	groupSQL   []string          // rendered GROUP BY expressions, shared with SELECT
+	jsonSQL    map[string]string // rendered JSON path expressions, by path
+}
+
+// reset clears the state of the previous build
+func (qb *QueryBuilder) reset() {
+	qb.params = []interface{}{}
+	qb.paramCount = 0
+	qb.groupSQL = nil
+	qb.jsonSQL = make(map[string]string)
 }
 
 // BuildQuery converts a QueryPlan into a parameterized SQL query
 func (qb *QueryBuilder) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
-	if plan == nil {
-		return "", nil, fmt.Errorf("query plan is nil")
+	if err := checkPlan(plan); err != nil {
+		return "", nil, err
 	}
 
+	qb.reset()
+
+	// 1-5. SELECT through HAVING
+	parts, err := qb.buildStatement(plan, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 6. ORDER BY clause (if sorting exists)
+	if len(plan.Sort) > 0 {
+		orderByPart := qb.buildOrderByClause(plan)
+		parts = append(parts, orderByPart)
+	}
+
+	// 7. LIMIT/OFFSET clause
+	paginationPart := qb.buildPaginationClause(plan)
+	parts = append(parts, paginationPart)
+
+	// Join all parts
+	sql := strings.Join(parts, " ") + ";"
+
+	return sql, qb.params, nil
+}
+
+// checkPlan rejects plans the builder cannot render
+func checkPlan(plan *planner.QueryPlan) error {
+	if plan == nil {
+		return fmt.Errorf("query plan is nil")
+	}
 	if plan.RootModel == nil {
-		return "", nil, fmt.Errorf("root model is nil")
+		return fmt.Errorf("root model is nil")
 	}
+	return nil
+}
 
-	qb.params = []interface{}{}
-	qb.paramCount = 0
+// buildStatement renders a plan's statement from SELECT through HAVING.
+// selectList, when set, replaces the plan's SELECT list.
+func (qb *QueryBuilder) buildStatement(plan *planner.QueryPlan, selectList string) ([]string, error) {
+	// Render grouping keys once so SELECT and GROUP BY use the identical
+	// expression (and the same timezone parameter)
+	groupSQL, err := qb.buildGroupExpressions(plan)
+	if err != nil {
+		return nil, err
+	}
+	qb.groupSQL = groupSQL
 
 	var parts []string
 
 	// 1. SELECT clause
-	selectPart := qb.buildSelectClause(plan)
+	selectPart := "SELECT " + selectList
+	if selectList == "" {
+		selectPart, err = qb.buildSelectClause(plan)
+		if err != nil {
+			return nil, err
+		}
+	}
 	parts = append(parts, selectPart)
 
 	// 2. FROM clause
 	fromPart := qb.buildFromClause(plan)
 	parts = append(parts, fromPart)
 
-	// 3. WHERE clause (if filters exist)
-	if plan.Filters != nil {
-		wherePart, err := qb.buildWhereClause(plan.Filters)
-		if err != nil {
-			return "", nil, err
-		}
+	// 3. WHERE clause (if filters or a keyset position exist)
+	wherePart, err := qb.buildWhereClause(plan)
+	if err != nil {
+		return nil, err
+	}
+	if wherePart != "" {
 		parts = append(parts, wherePart)
 	}
 
@@ -90,32 +227,97 @@ func (qb *QueryBuilder) BuildQuery(plan *planner.QueryPlan) (string, []interface
 		parts = append(parts, groupByPart)
 	}
 
-	// 5. ORDER BY clause (if sorting exists)
-	if len(plan.Sort) > 0 {
-		orderByPart := qb.buildOrderByClause(plan)
-		parts = append(parts, orderByPart)
+	// 5. HAVING clause (if aggregate filters exist)
+	if plan.Having != nil {
+		havingSQL, err := qb.buildFilterExpression(plan.Having)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, "HAVING "+havingSQL)
 	}
 
-	// 6. LIMIT/OFFSET clause
-	paginationPart := qb.buildPaginationClause(plan)
-	parts = append(parts, paginationPart)
+	return parts, nil
+}
 
-	// Join all parts
-	sql := strings.Join(parts, " ") + ";"
+// addParam appends a parameter and returns its placeholder
+func (qb *QueryBuilder) addParam(value interface{}) string {
+	qb.paramCount++
+	qb.params = append(qb.params, value)
+	return fmt.Sprintf("$%d", qb.paramCount)
+}
 
-	return sql, qb.params, nil
+// valueSQL renders a column reference as a value of its DataType. A JSON
+// path walks the document with -> and extracts the last key with ->> as
+// text, cast to the path's type:
+//
+//	(t0.metadata->$1::text->>$2::text)::numeric
+func (qb *QueryBuilder) valueSQL(ref planner.ColumnRef) string {
+	if len(ref.JSONPath) == 0 {
+		return columnSQL(ref)
+	}
+	if ref.DataType == planner.TypeJSON {
+		return qb.documentSQL(ref)
+	}
+
+	path := qb.jsonPathSQL(ref, "->>")
+	if pgType, ok := jsonCastTypes[ref.DataType]; ok {
+		return fmt.Sprintf("%s::%s", path, pgType)
+	}
+	return path
+}
+
+// documentSQL renders a column reference as a jsonb document, for the JSON
+// operators: the column itself, or the value at its JSON path
+func (qb *QueryBuilder) documentSQL(ref planner.ColumnRef) string {
+	if len(ref.JSONPath) == 0 {
+		return columnSQL(ref)
+	}
+	return qb.jsonPathSQL(ref, "->")
+}
+
+// jsonPathSQL renders a JSON path with every key as a parameter, using last
+// as the operator for the final key. Each path is rendered once per query so
+// that SELECT, GROUP BY and ORDER BY share the same parameters; PostgreSQL
+// only matches grouped expressions that are identical.
+func (qb *QueryBuilder) jsonPathSQL(ref planner.ColumnRef, last string) string {
+	key := columnSQL(ref) + last + strings.Join(ref.JSONPath, ".")
+	if sql, ok := qb.jsonSQL[key]; ok {
+		return sql
+	}
+
+	var b strings.Builder
+	b.WriteString("(" + columnSQL(ref))
+	for i, seg := range ref.JSONPath {
+		op := "->"
+		if i == len(ref.JSONPath)-1 {
+			op = last
+		}
+		// Numeric segments index into arrays
+		if n, err := strconv.Atoi(seg); err == nil {
+			b.WriteString(op + qb.addParam(n) + "::int")
+		} else {
+			b.WriteString(op + qb.addParam(seg) + "::text")
+		}
+	}
+	b.WriteString(")")
+
+	if qb.jsonSQL == nil {
+		qb.jsonSQL = make(map[string]string)
+	}
+	qb.jsonSQL[key] = b.String()
+	return qb.jsonSQL[key]
 }
 
 // buildSelectClause generates the SELECT part of the query
-func (qb *QueryBuilder) buildSelectClause(plan *planner.QueryPlan) string {
+func (qb *QueryBuilder) buildSelectClause(plan *planner.QueryPlan) (string, error) {
 	var columns []string
 
 	// Add selected columns (if any)
 	if len(plan.Select) > 0 {
 		for _, expr := range plan.Select {
-			colName := fmt.Sprintf("%s.%s", expr.Column.TableAlias, expr.Column.ColumnName)
+			colName := qb.valueSQL(expr.Column)
 			if expr.Alias != expr.Column.ColumnName {
-				colName = fmt.Sprintf("%s AS %s", colName, expr.Alias)
+				colName = fmt.Sprintf("%s AS %s", colName, formatAlias(expr.Alias))
 			}
 			columns = append(columns, colName)
 		}
@@ -123,38 +325,163 @@ func (qb *QueryBuilder) buildSelectClause(plan *planner.QueryPlan) string {
 
 	// Add group by columns if grouping
 	if len(plan.GroupBy) > 0 && len(plan.Select) == 0 {
-		for _, groupExpr := range plan.GroupBy {
-			colName := fmt.Sprintf("%s.%s", groupExpr.Column.TableAlias, groupExpr.Column.ColumnName)
+		for i, groupExpr := range plan.GroupBy {
+			colName := qb.groupSQL[i]
+			if groupExpr.Granularity != "" || (groupExpr.Alias != "" && groupExpr.Alias != groupExpr.Column.ColumnName) {
+				colName = fmt.Sprintf("%s AS %s", colName, formatAlias(groupExpr.Alias))
+			}
 			columns = append(columns, colName)
 		}
 	}
 
 	// Add aggregates
 	for _, agg := range plan.Aggregates {
-		aggStr := qb.buildAggregateExpression(agg)
+		aggStr, err := qb.buildAggregateExpression(agg)
+		if err != nil {
+			return "", err
+		}
 		columns = append(columns, aggStr)
 	}
 
-	// If no columns selected, use *
+	// Add windows, after the root table's columns unless fields were listed
+	if len(plan.Windows) > 0 {
+		if len(columns) == 0 {
+			columns = append(columns, plan.RootModel.Alias+".*")
+		}
+		for _, window := range plan.Windows {
+			columns = append(columns, fmt.Sprintf("%s AS %s", qb.windowSQL(window), formatAlias(window.Alias)))
+		}
+	}
+
+	// If no columns selected, use * (restricted to the root table when
+	// joins would otherwise add related columns)
 	if len(columns) == 0 {
-		return "SELECT *"
+		if len(plan.Joins) > 0 {
+			return fmt.Sprintf("SELECT %s.*", plan.RootModel.Alias), nil
+		}
+		return "SELECT *", nil
 	}
 
-	return "SELECT " + strings.Join(columns, ", ")
+	return "SELECT " + strings.Join(columns, ", "), nil
 }
 
-// buildFromClause generates the FROM part of the query
+// buildFromClause generates the FROM part of the query, including joins
 func (qb *QueryBuilder) buildFromClause(plan *planner.QueryPlan) string {
-	return fmt.Sprintf("FROM %s %s", plan.RootModel.Table, plan.RootModel.Alias)
+	from := fmt.Sprintf("FROM %s %s", plan.RootModel.Table, plan.RootModel.Alias)
+	for _, join := range plan.Joins {
+		from += fmt.Sprintf(" %s JOIN %s %s ON %s = %s",
+			join.Type, join.ToTable, join.ToAlias, columnSQL(join.On.Left), columnSQL(join.On.Right))
+	}
+	return from
 }
 
-// buildWhereClause generates the WHERE part of the query
-func (qb *QueryBuilder) buildWhereClause(filterExpr planner.FilterExpr) (string, error) {
-	filterSQL, err := qb.buildFilterExpression(filterExpr)
-	if err != nil {
-		return "", err
+// buildWhereClause generates the WHERE part of the query: the filters and,
+// on keyset pages after the first, the position to continue after. It is
+// empty when there is neither.
+func (qb *QueryBuilder) buildWhereClause(plan *planner.QueryPlan) (string, error) {
+	var conditions []string
+	if plan.Filters != nil {
+		filterSQL, err := qb.buildFilterExpression(plan.Filters)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, filterSQL)
+	}
+	if plan.Keyset != nil && plan.Keyset.After != nil {
+		conditions = append(conditions, qb.buildKeysetPredicate(plan.Keyset))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// buildKeysetPredicate renders "sorts after the cursor row". When every key
+// has the same direction and none can be NULL this is a row comparison,
+// which a matching composite index serves directly:
+//
+//	(t0.created_at, t0.id) < ($1, $2)
+//
+// Otherwise it is expanded key by key, following PostgreSQL's default NULL
+// placement (last ascending, first descending):
+//
+//	(t0.status > $1 OR (t0.status = $1 AND t0.id < $2))
+func (qb *QueryBuilder) buildKeysetPredicate(k *planner.Keyset) string {
+	params := make([]string, len(k.Keys))
+	for i, key := range k.Keys {
+		if k.After[i] == nil {
+			continue
+		}
+		params[i] = qb.addParam(k.After[i])
+		if needsTypeCasting(key.Column.DataType) {
+			params[i] = addTypeCast(params[i], key.Column.DataType)
+		}
+	}
+
+	uniform := true
+	for i, key := range k.Keys {
+		if key.Nullable || k.After[i] == nil || key.Direction != k.Keys[0].Direction {
+			uniform = false
+		}
+	}
+	if uniform {
+		cols := make([]string, len(k.Keys))
+		for i, key := range k.Keys {
+			cols[i] = qb.valueSQL(key.Column)
+		}
+		cmp := ">"
+		if k.Keys[0].Direction == "DESC" {
+			cmp = "<"
+		}
+		if len(cols) == 1 {
+			return fmt.Sprintf("%s %s %s", cols[0], cmp, params[0])
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), cmp, strings.Join(params, ", "))
+	}
+
+	var branches []string
+	var equal []string
+	for i, key := range k.Keys {
+		col := qb.valueSQL(key.Column)
+		if after := keysetAfter(col, key, params[i]); after != "" {
+			branches = append(branches, strings.Join(append(append([]string{}, equal...), after), " AND "))
+		}
+		if params[i] == "" {
+			equal = append(equal, col+" IS NULL")
+		} else {
+			equal = append(equal, fmt.Sprintf("%s = %s", col, params[i]))
+		}
+	}
+	if len(branches) == 0 {
+		return "FALSE"
+	}
+	for i, branch := range branches {
+		if strings.Contains(branch, " AND ") {
+			branches[i] = "(" + branch + ")"
+		}
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return "(" + strings.Join(branches, " OR ") + ")"
+}
+
+// keysetAfter renders "col sorts after the cursor value" for one key; param
+// is empty when the cursor value is NULL. The result is empty when nothing
+// can follow, i.e. a NULL in an ascending key.
+func keysetAfter(col string, key planner.KeysetKey, param string) string {
+	switch {
+	case param == "" && key.Direction == "DESC":
+		return col + " IS NOT NULL"
+	case param == "":
+		return ""
+	case key.Direction == "DESC":
+		return fmt.Sprintf("%s < %s", col, param)
+	case key.Nullable:
+		return fmt.Sprintf("(%s > %s OR %s IS NULL)", col, param, col)
+	default:
+		return fmt.Sprintf("%s > %s", col, param)
 	}
-	return "WHERE " + filterSQL, nil
 }
 
 // buildFilterExpression recursively builds filter expressions
@@ -171,9 +498,62 @@ func (qb *QueryBuilder) buildFilterExpression(expr planner.FilterExpr) (string,
 	}
 }
 
+// rangeComparators maps a range operator to its lower and upper comparisons
+var rangeComparators = map[dsl.FilterOperator][2]string{
+	dsl.OpBetween: {">=", "<="},
+	dsl.OpGTELT:   {">=", "<"},
+	dsl.OpGTLTE:   {">", "<="},
+}
+
+// buildRangeFilter renders a [lower, upper] range. A null bound leaves that
+// side open, so only the other comparison is emitted.
+func (qb *QueryBuilder) buildRangeFilter(colName string, op dsl.FilterOperator, value interface{}) (string, error) {
+	bounds, err := arrayParam(value)
+	if err != nil {
+		return "", err
+	}
+	if len(bounds) != 2 {
+		return "", fmt.Errorf("%s requires exactly 2 values, got %d", op, len(bounds))
+	}
+	lower, upper := bounds[0], bounds[1]
+	cmp := rangeComparators[op]
+
+	switch {
+	case lower != nil && upper != nil:
+		lowerParam := qb.addParam(lower)
+		upperParam := qb.addParam(upper)
+		if op == dsl.OpBetween {
+			return fmt.Sprintf("%s BETWEEN %s AND %s", colName, lowerParam, upperParam), nil
+		}
+		return fmt.Sprintf("(%s %s %s AND %s %s %s)", colName, cmp[0], lowerParam, colName, cmp[1], upperParam), nil
+	case lower != nil:
+		return fmt.Sprintf("%s %s %s", colName, cmp[0], qb.addParam(lower)), nil
+	case upper != nil:
+		return fmt.Sprintf("%s %s %s", colName, cmp[1], qb.addParam(upper)), nil
+	default:
+		return "", fmt.Errorf("%s requires at least one non-null bound", op)
+	}
+}
+
 // buildComparisonFilter builds a single comparison filter
 func (qb *QueryBuilder) buildComparisonFilter(f *planner.ComparisonFilterIR) (string, error) {
-	colName := fmt.Sprintf("%s.%s", f.Left.TableAlias, f.Left.ColumnName)
+	var colName string
+	switch {
+	case f.Aggregate != nil:
+		// HAVING compares the aggregate expression itself, since output
+		// aliases are not visible there
+		aggSQL, err := qb.aggregateSQL(*f.Aggregate)
+		if err != nil {
+			return "", err
+		}
+		colName = aggSQL
+	case dsl.IsJSONOperator(f.Operator):
+		colName = qb.documentSQL(f.Left)
+	case f.Search != nil:
+		// Rendered from the search document below
+	default:
+		colName = qb.valueSQL(f.Left)
+	}
 
 	switch f.Operator {
 	case dsl.OpEqual:
@@ -236,24 +616,22 @@ func (qb *QueryBuilder) buildComparisonFilter(f *planner.ComparisonFilterIR) (st
 		if f.Value == nil {
 			return "", fmt.Errorf("value required for in operator")
 		}
-		qb.paramCount++
-		qb.params = append(qb.params, f.Value.Value)
-		paramPlaceholder := fmt.Sprintf("$%d", qb.paramCount)
-		if needsTypeCasting(f.Left.DataType) {
-			paramPlaceholder = addTypeCast(paramPlaceholder, f.Left.DataType)
+		values, err := arrayParam(f.Value.Value)
+		if err != nil {
+			return "", err
 		}
+		paramPlaceholder := addArrayTypeCast(qb.addParam(values), f.Left.DataType)
 		return fmt.Sprintf("%s = ANY(%s)", colName, paramPlaceholder), nil
 
 	case dsl.OpNotIn:
 		if f.Value == nil {
 			return "", fmt.Errorf("value required for not_in operator")
 		}
-		qb.paramCount++
-		qb.params = append(qb.params, f.Value.Value)
-		paramPlaceholder := fmt.Sprintf("$%d", qb.paramCount)
-		if needsTypeCasting(f.Left.DataType) {
-			paramPlaceholder = addTypeCast(paramPlaceholder, f.Left.DataType)
+		values, err := arrayParam(f.Value.Value)
+		if err != nil {
+			return "", err
 		}
+		paramPlaceholder := addArrayTypeCast(qb.addParam(values), f.Left.DataType)
 		return fmt.Sprintf("%s != ALL(%s)", colName, paramPlaceholder), nil
 
 	case dsl.OpIsNull:
@@ -282,33 +660,67 @@ func (qb *QueryBuilder) buildComparisonFilter(f *planner.ComparisonFilterIR) (st
 		if f.Value == nil {
 			return "", fmt.Errorf("value required for starts_with operator")
 		}
+		pattern, err := stringParam(f)
+		if err != nil {
+			return "", err
+		}
 		qb.paramCount++
-		qb.params = append(qb.params, f.Value.Value.(string)+"%")
+		qb.params = append(qb.params, pattern+"%")
 		return fmt.Sprintf("%s LIKE $%d", colName, qb.paramCount), nil
 
 	case dsl.OpEndsWith:
 		if f.Value == nil {
 			return "", fmt.Errorf("value required for ends_with operator")
 		}
+		pattern, err := stringParam(f)
+		if err != nil {
+			return "", err
+		}
 		qb.paramCount++
-		qb.params = append(qb.params, "%"+f.Value.Value.(string))
+		qb.params = append(qb.params, "%"+pattern)
 		return fmt.Sprintf("%s LIKE $%d", colName, qb.paramCount), nil
 
 	case dsl.OpContains:
 		if f.Value == nil {
 			return "", fmt.Errorf("value required for contains operator")
 		}
+		pattern, err := stringParam(f)
+		if err != nil {
+			return "", err
+		}
 		qb.paramCount++
-		qb.params = append(qb.params, "%"+f.Value.Value.(string)+"%")
+		qb.params = append(qb.params, "%"+pattern+"%")
 		return fmt.Sprintf("%s LIKE $%d", colName, qb.paramCount), nil
 
-	case dsl.OpBetween:
+	case dsl.OpBetween, dsl.OpGTELT, dsl.OpGTLTE:
 		if f.Value == nil {
-			return "", fmt.Errorf("value required for between operator")
+			return "", fmt.Errorf("value required for %s operator", f.Operator)
 		}
-		qb.paramCount++
-		qb.params = append(qb.params, f.Value.Value)
-		return fmt.Sprintf("%s BETWEEN $%d AND $%d", colName, qb.paramCount, qb.paramCount+1), nil
+		return qb.buildRangeFilter(colName, f.Operator, f.Value.Value)
+
+	case dsl.OpSearch:
+		if f.Value == nil || f.Search == nil {
+			return "", fmt.Errorf("value and search document required for search operator")
+		}
+		return fmt.Sprintf("%s @@ %s", qb.searchDocumentSQL(f.Search), qb.searchQuerySQL(f)), nil
+
+	case dsl.OpSimilar:
+		if f.Value == nil {
+			return "", fmt.Errorf("value required for similar operator")
+		}
+		return qb.buildSimilarFilter(colName, f), nil
+
+	case dsl.OpHasKey:
+		if f.Value == nil {
+			return "", fmt.Errorf("value required for has_key operator")
+		}
+		return fmt.Sprintf("%s ? %s::text", colName, qb.addParam(f.Value.Value)), nil
+
+	case dsl.OpJSONContains:
+		if f.Value == nil {
+			return "", fmt.Errorf("value required for json_contains operator")
+		}
+		return fmt.Sprintf("%s @> %s::jsonb", colName, qb.addParam(f.Value.Value)), nil
 
 	case dsl.OpBefore:
 		if f.Value == nil {
@@ -331,6 +743,27 @@ func (qb *QueryBuilder) buildComparisonFilter(f *planner.ComparisonFilterIR) (st
 	}
 }
 
+// buildSimilarFilter renders a pg_trgm similarity match. The % operator can
+// use a trigram index but compares against pg_trgm.similarity_threshold
+// (0.3 by default), so other thresholds add an explicit similarity() check;
+// below the default only that check applies, and no index is used.
+func (qb *QueryBuilder) buildSimilarFilter(colName string, f *planner.ComparisonFilterIR) string {
+	text := qb.addParam(f.Value.Value) + "::text"
+	threshold := f.Threshold
+	if threshold == 0 {
+		threshold = dsl.DefaultSimilarityThreshold
+	}
+
+	switch {
+	case threshold == dsl.DefaultSimilarityThreshold:
+		return fmt.Sprintf("%s %% %s", colName, text)
+	case threshold > dsl.DefaultSimilarityThreshold:
+		return fmt.Sprintf("(%s %% %s AND similarity(%s, %s) >= %s)", colName, text, colName, text, qb.addParam(threshold))
+	default:
+		return fmt.Sprintf("similarity(%s, %s) >= %s", colName, text, qb.addParam(threshold))
+	}
+}
+
 // buildLogicalFilter builds logical filter expressions (AND/OR/NOT)
 func (qb *QueryBuilder) buildLogicalFilter(f *planner.LogicalFilterIR) (string, error) {
 	if len(f.Nodes) == 0 {
@@ -357,21 +790,67 @@ func (qb *QueryBuilder) buildLogicalFilter(f *planner.LogicalFilterIR) (string,
 		if len(parts) != 1 {
 			return "", fmt.Errorf("NOT filter must have exactly one node")
 		}
-		return "NOT " + parts[0], nil
+		return "NOT (" + parts[0] + ")", nil
 
 	default:
 		return "", fmt.Errorf("unknown logical operator: %s", f.Op)
 	}
 }
 
-// buildGroupByClause generates the GROUP BY part of the query
-func (qb *QueryBuilder) buildGroupByClause(plan *planner.QueryPlan) string {
-	var groupCols []string
+// searchDocumentSQL renders the tsvector a search matches. Built documents
+// join their columns with spaces, NULLs as empty strings:
+//
+//	to_tsvector('english', coalesce(t0.title, '') || ' ' || coalesce(t0.body, ''))
+//
+// An expression index on exactly this expression lets PostgreSQL use it.
+func (qb *QueryBuilder) searchDocumentSQL(doc *planner.SearchDoc) string {
+	if doc.Vector != nil {
+		return columnSQL(*doc.Vector)
+	}
+	parts := make([]string, len(doc.Columns))
+	for i, col := range doc.Columns {
+		parts[i] = fmt.Sprintf("coalesce(%s, '')", qb.valueSQL(col))
+	}
+	return fmt.Sprintf("to_tsvector(%s, %s)", quoteLiteral(doc.Language), strings.Join(parts, " || ' ' || "))
+}
+
+// searchQuerySQL renders the search text of a search filter as a tsquery.
+// websearch_to_tsquery accepts free text with quoted phrases, "or" and -word
+// and never fails on user input.
+func (qb *QueryBuilder) searchQuerySQL(f *planner.ComparisonFilterIR) string {
+	return fmt.Sprintf("websearch_to_tsquery(%s, %s)", quoteLiteral(f.Search.Language), qb.addParam(f.Value.Value))
+}
+
+// buildGroupExpressions renders each GROUP BY key. Bucketed keys become
+// date_trunc('<unit>', column[, $n]) where $n is the timezone parameter;
+// date columns carry no time of day, so the timezone does not apply to them.
+func (qb *QueryBuilder) buildGroupExpressions(plan *planner.QueryPlan) ([]string, error) {
+	exprs := make([]string, 0, len(plan.GroupBy))
 	for _, groupExpr := range plan.GroupBy {
-		colName := fmt.Sprintf("%s.%s", groupExpr.Column.TableAlias, groupExpr.Column.ColumnName)
-		groupCols = append(groupCols, colName)
+		col := qb.valueSQL(groupExpr.Column)
+		if groupExpr.Granularity == "" {
+			exprs = append(exprs, col)
+			continue
+		}
+
+		if !dateTruncUnits[groupExpr.Granularity] {
+			return nil, fmt.Errorf("unsupported granularity: %s", groupExpr.Granularity)
+		}
+
+		if groupExpr.Timezone != "" && groupExpr.Column.DataType != planner.TypeDate {
+			qb.paramCount++
+			qb.params = append(qb.params, groupExpr.Timezone)
+			exprs = append(exprs, fmt.Sprintf("date_trunc('%s', %s, $%d)", groupExpr.Granularity, col, qb.paramCount))
+			continue
+		}
+		exprs = append(exprs, fmt.Sprintf("date_trunc('%s', %s)", groupExpr.Granularity, col))
 	}
-	return "GROUP BY " + strings.Join(groupCols, ", ")
+	return exprs, nil
+}
+
+// buildGroupByClause generates the GROUP BY part of the query
+func (qb *QueryBuilder) buildGroupByClause(plan *planner.QueryPlan) string {
+	return "GROUP BY " + strings.Join(qb.groupSQL, ", ")
 }
 
 // buildOrderByClause generates the ORDER BY part of the query
@@ -379,10 +858,16 @@ func (qb *QueryBuilder) buildOrderByClause(plan *planner.QueryPlan) string {
 	var sortCols []string
 	for _, sortExpr := range plan.Sort {
 		var colRef string
-		if sortExpr.Column != nil {
-			colRef = fmt.Sprintf("%s.%s", sortExpr.Column.TableAlias, sortExpr.Column.ColumnName)
-		} else if sortExpr.Aggregate != nil {
-			colRef = sortExpr.Aggregate.Alias
+		if sortExpr.Target == planner.SortAggregate && sortExpr.Aggregate != nil {
+			colRef = formatAlias(sortExpr.Aggregate.Alias)
+		} else if sortExpr.Target == planner.SortRank && sortExpr.Search != nil {
+			colRef = fmt.Sprintf("ts_rank(%s, %s)", qb.searchDocumentSQL(sortExpr.Search.Search), qb.searchQuerySQL(sortExpr.Search))
+		} else if sortExpr.Target == planner.SortSimilarity && sortExpr.Search != nil {
+			colRef = fmt.Sprintf("similarity(%s, %s::text)", qb.valueSQL(sortExpr.Search.Left), qb.addParam(sortExpr.Search.Value.Value))
+		} else if sortExpr.Target == planner.SortWindow && sortExpr.Window != nil {
+			colRef = formatAlias(sortExpr.Window.Alias)
+		} else if sortExpr.Column != nil {
+			colRef = qb.valueSQL(*sortExpr.Column)
 		}
 
 		direction := "ASC"
@@ -397,6 +882,11 @@ func (qb *QueryBuilder) buildOrderByClause(plan *planner.QueryPlan) string {
 
 // buildPaginationClause generates the LIMIT/OFFSET part of the query
 func (qb *QueryBuilder) buildPaginationClause(plan *planner.QueryPlan) string {
+	// Keyset pages start from their WHERE position, never an offset
+	if plan.Keyset != nil {
+		return "LIMIT " + qb.addParam(plan.Pagination.Limit)
+	}
+
 	qb.paramCount++
 	limitParam := qb.paramCount
 	qb.params = append(qb.params, plan.Pagination.Limit)
@@ -408,35 +898,116 @@ func (qb *QueryBuilder) buildPaginationClause(plan *planner.QueryPlan) string {
 	return fmt.Sprintf("LIMIT $%d OFFSET $%d", limitParam, offsetParam)
 }
 
-// buildAggregateExpression builds an aggregate function expression
-func (qb *QueryBuilder) buildAggregateExpression(agg planner.AggregateExpr) string {
-	var aggSQL string
+// buildAggregateExpression builds an aliased aggregate for the SELECT list
+func (qb *QueryBuilder) buildAggregateExpression(agg planner.AggregateExpr) (string, error) {
+	aggSQL, err := qb.aggregateSQL(agg)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s AS %s", aggSQL, formatAlias(agg.Alias)), nil
+}
+
+// aggregateSQL builds an aggregate expression without its alias, including
+// its FILTER (WHERE ...) clause if it has one. Filter parameters continue
+// the numbering of the enclosing query.
+func (qb *QueryBuilder) aggregateSQL(agg planner.AggregateExpr) (string, error) {
+	call := qb.aggregateCallSQL(agg)
+	if agg.Filter == nil {
+		return call, nil
+	}
 
+	filterSQL, err := qb.buildFilterExpression(agg.Filter)
+	if err != nil {
+		return "", fmt.Errorf("aggregate %s filter: %w", agg.Alias, err)
+	}
+	return fmt.Sprintf("%s FILTER (WHERE %s)", call, filterSQL), nil
+}
+
+// aggregateCallSQL builds the aggregate function call itself
+func (qb *QueryBuilder) aggregateCallSQL(agg planner.AggregateExpr) string {
 	switch agg.Function {
 	case planner.AggCountFn:
 		if agg.Column == nil {
-			aggSQL = "COUNT(*)"
-		} else {
-			aggSQL = fmt.Sprintf("COUNT(%s.%s)", agg.Column.TableAlias, agg.Column.ColumnName)
+			return "COUNT(*)"
 		}
+		return fmt.Sprintf("COUNT(%s)", qb.valueSQL(*agg.Column))
 
 	case planner.AggSumFn:
-		aggSQL = fmt.Sprintf("SUM(%s.%s)", agg.Column.TableAlias, agg.Column.ColumnName)
+		return fmt.Sprintf("SUM(%s)", qb.valueSQL(*agg.Column))
 
 	case planner.AggAvgFn:
-		aggSQL = fmt.Sprintf("AVG(%s.%s)", agg.Column.TableAlias, agg.Column.ColumnName)
+		return fmt.Sprintf("AVG(%s)", qb.valueSQL(*agg.Column))
 
 	case planner.AggMinFn:
-		aggSQL = fmt.Sprintf("MIN(%s.%s)", agg.Column.TableAlias, agg.Column.ColumnName)
+		return fmt.Sprintf("MIN(%s)", qb.valueSQL(*agg.Column))
 
 	case planner.AggMaxFn:
-		aggSQL = fmt.Sprintf("MAX(%s.%s)", agg.Column.TableAlias, agg.Column.ColumnName)
+		return fmt.Sprintf("MAX(%s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggCountDistinctFn:
+		return fmt.Sprintf("COUNT(DISTINCT %s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggMedianFn:
+		return fmt.Sprintf("percentile_cont(0.5) WITHIN GROUP (ORDER BY %s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggPercentileFn:
+		return fmt.Sprintf("percentile_cont(%s) WITHIN GROUP (ORDER BY %s)",
+			strconv.FormatFloat(agg.Fraction, 'f', -1, 64), qb.valueSQL(*agg.Column))
+
+	case planner.AggStddevFn:
+		return fmt.Sprintf("STDDEV(%s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggVarianceFn:
+		return fmt.Sprintf("VARIANCE(%s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggStringAggFn:
+		return fmt.Sprintf("STRING_AGG(%s, %s::text)", qb.valueSQL(*agg.Column), qb.addParam(agg.Separator))
+
+	case planner.AggArrayAggFn:
+		return fmt.Sprintf("ARRAY_AGG(%s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggBoolAndFn:
+		return fmt.Sprintf("BOOL_AND(%s)", qb.valueSQL(*agg.Column))
+
+	case planner.AggBoolOrFn:
+		return fmt.Sprintf("BOOL_OR(%s)", qb.valueSQL(*agg.Column))
 
 	default:
-		aggSQL = "COUNT(*)"
+		return "COUNT(*)"
 	}
+}
 
-	return fmt.Sprintf("%s AS %s", aggSQL, agg.Alias)
+// windowSQL builds a window function call with its OVER clause, e.g.
+// LAG(t0.amount, 2) OVER (PARTITION BY t0.user_id ORDER BY t0.created_at ASC)
+func (qb *QueryBuilder) windowSQL(w planner.WindowExpr) string {
+	var call string
+	switch {
+	case w.Column == nil && w.Function == planner.WinCountFn:
+		call = "COUNT(*)"
+	case w.Column == nil:
+		call = string(w.Function) + "()"
+	case w.Offset > 0:
+		call = fmt.Sprintf("%s(%s, %d)", w.Function, qb.valueSQL(*w.Column), w.Offset)
+	default:
+		call = fmt.Sprintf("%s(%s)", w.Function, qb.valueSQL(*w.Column))
+	}
+
+	var over []string
+	if len(w.PartitionBy) > 0 {
+		cols := make([]string, len(w.PartitionBy))
+		for i, col := range w.PartitionBy {
+			cols[i] = qb.valueSQL(col)
+		}
+		over = append(over, "PARTITION BY "+strings.Join(cols, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		cols := make([]string, len(w.OrderBy))
+		for i, s := range w.OrderBy {
+			cols[i] = qb.valueSQL(*s.Column) + " " + s.Direction
+		}
+		over = append(over, "ORDER BY "+strings.Join(cols, ", "))
+	}
+	return fmt.Sprintf("%s OVER (%s)", call, strings.Join(over, " "))
 }
 
 // NewQueryBuilder creates a new query builder
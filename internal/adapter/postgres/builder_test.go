@@ -1,8 +1,11 @@
 package postgres
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"udv/internal/config"
 	"udv/internal/dsl"
@@ -23,6 +26,8 @@ func setupTestRegistry() *schema.Registry {
 					{Name: "status", Type: "string", Nullable: false},
 					{Name: "amount", Type: "decimal", Nullable: false},
 					{Name: "created_at", Type: "timestamp", Nullable: false},
+					{Name: "is_paid", Type: "boolean", Nullable: false},
+					{Name: "metadata", Type: "json", Nullable: true},
 				},
 			},
 		},
@@ -115,9 +120,9 @@ func TestBuildQuery_WithLogicalFilter(t *testing.T) {
 	dslQuery := &dsl.Query{
 		Model: "orders",
 		Filters: &dsl.LogicalFilter{
-			And: []*dsl.ComparisonFilter{
-				{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
-				{Field: "amount", Op: dsl.OpGT, Value: 1000},
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 1000},
 			},
 		},
 	}
@@ -143,13 +148,159 @@ func TestBuildQuery_WithLogicalFilter(t *testing.T) {
 	}
 }
 
+func TestBuildQuery_WithNestedLogicalFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	dslQuery := &dsl.Query{
+		Model: "orders",
+		Filters: &dsl.LogicalFilter{
+			Or: []dsl.FilterExpr{
+				&dsl.LogicalFilter{
+					And: []dsl.FilterExpr{
+						&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "paid"},
+						&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 100},
+					},
+				},
+				&dsl.LogicalFilter{
+					And: []dsl.FilterExpr{
+						&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "refunded"},
+						&dsl.LogicalFilter{
+							Not: &dsl.ComparisonFilter{Field: "user_id", Op: dsl.OpEqual, Value: 7},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	plan, err := queryPlanner.PlanQuery(dslQuery)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	expected := "WHERE ((t0.status = $1 AND t0.amount > $2) OR (t0.status = $3 AND NOT (t0.user_id = $4)))"
+	if !strings.Contains(sql, expected) {
+		t.Errorf("SQL = %s, want it to contain %s", sql, expected)
+	}
+
+	if len(params) != 6 { // 4 filter values, limit, offset
+		t.Errorf("Expected 6 params, got %d", len(params))
+	}
+}
+
+func TestBuildQuery_WithRelationJoins(t *testing.T) {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "user_id", Type: "integer"},
+					{Name: "amount", Type: "decimal"},
+				},
+			},
+			{
+				Name:       "users",
+				Table:      "users",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "string"},
+					{Name: "country", Type: "string"},
+				},
+			},
+		},
+	}
+	reg := schema.NewRegistry()
+	reg.LoadFromConfig(cfg)
+	if err := reg.AddRelation("orders", &schema.Relation{Name: "user", Type: schema.ManyToOne, TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}); err != nil {
+		t.Fatalf("AddRelation error: %v", err)
+	}
+	queryPlanner := planner.NewPlanner(reg)
+
+	t.Run("select and filter", func(t *testing.T) {
+		plan, err := queryPlanner.PlanQuery(&dsl.Query{
+			Model:   "orders",
+			Fields:  []string{"id", "user.email"},
+			Filters: &dsl.ComparisonFilter{Field: "user.country", Op: dsl.OpEqual, Value: "NL"},
+		})
+		if err != nil {
+			t.Fatalf("PlanQuery error: %v", err)
+		}
+
+		sql, _, err := NewQueryBuilder().BuildQuery(plan)
+		if err != nil {
+			t.Fatalf("BuildQuery error: %v", err)
+		}
+
+		expected := `SELECT t0.id, t1.email AS "user.email" FROM orders t0 LEFT JOIN users t1 ON t0.user_id = t1.id WHERE t1.country = $1`
+		if !strings.HasPrefix(sql, expected) {
+			t.Errorf("SQL = %s, want prefix %s", sql, expected)
+		}
+	})
+
+	t.Run("select star restricted to root", func(t *testing.T) {
+		plan, err := queryPlanner.PlanQuery(&dsl.Query{
+			Model: "orders",
+			Sort:  []dsl.Sort{{Field: "user.email", Direction: dsl.SortDesc}},
+		})
+		if err != nil {
+			t.Fatalf("PlanQuery error: %v", err)
+		}
+
+		sql, _, err := NewQueryBuilder().BuildQuery(plan)
+		if err != nil {
+			t.Fatalf("BuildQuery error: %v", err)
+		}
+
+		if !strings.HasPrefix(sql, "SELECT t0.* FROM orders t0 LEFT JOIN users t1") {
+			t.Errorf("SQL = %s, want SELECT t0.* with join", sql)
+		}
+		if !strings.Contains(sql, "ORDER BY t1.email DESC") {
+			t.Errorf("SQL = %s, want ORDER BY t1.email DESC", sql)
+		}
+	})
+
+	t.Run("group by related field", func(t *testing.T) {
+		plan, err := queryPlanner.PlanQuery(&dsl.Query{
+			Model:      "orders",
+			GroupBy:    []dsl.GroupBy{{Field: "user.country"}},
+			Aggregates: []dsl.Aggregate{{Function: dsl.AggSum, Field: "amount", Alias: "total"}},
+		})
+		if err != nil {
+			t.Fatalf("PlanQuery error: %v", err)
+		}
+
+		sql, _, err := NewQueryBuilder().BuildQuery(plan)
+		if err != nil {
+			t.Fatalf("BuildQuery error: %v", err)
+		}
+
+		if !strings.Contains(sql, `SELECT t1.country AS "user.country", SUM(t0.amount) AS total`) {
+			t.Errorf("SQL = %s, want aliased group column", sql)
+		}
+		if !strings.Contains(sql, "GROUP BY t1.country") {
+			t.Errorf("SQL = %s, want GROUP BY t1.country", sql)
+		}
+	})
+}
+
 func TestBuildQuery_WithGroupByAndAggregate(t *testing.T) {
 	reg := setupTestRegistry()
 	queryPlanner := planner.NewPlanner(reg)
 
 	dslQuery := &dsl.Query{
 		Model:   "orders",
-		GroupBy: []string{"status"},
+		GroupBy: []dsl.GroupBy{{Field: "status"}},
 		Aggregates: []dsl.Aggregate{
 			{Function: dsl.AggSum, Field: "amount", Alias: "total_amount"},
 			{Function: dsl.AggCount, Field: "", Alias: "order_count"},
@@ -186,6 +337,225 @@ func TestBuildQuery_WithGroupByAndAggregate(t *testing.T) {
 	}
 }
 
+func TestBuildQuery_ExtendedAggregates(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	fraction := 0.95
+	separator := "; "
+	dslQuery := &dsl.Query{
+		Model:   "orders",
+		GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggCountDistinct, Field: "status", Alias: "statuses"},
+			{Function: dsl.AggMedian, Field: "amount", Alias: "median_amount"},
+			{Function: dsl.AggPercentile, Field: "amount", Alias: "p95", Fraction: &fraction},
+			{Function: dsl.AggStddev, Field: "amount", Alias: "sd"},
+			{Function: dsl.AggVariance, Field: "amount", Alias: "var"},
+			{Function: dsl.AggStringAgg, Field: "status", Alias: "status_list", Separator: &separator},
+			{Function: dsl.AggArrayAgg, Field: "id", Alias: "ids"},
+			{Function: dsl.AggBoolAnd, Field: "is_paid", Alias: "all_paid"},
+			{Function: dsl.AggBoolOr, Field: "is_paid", Alias: "any_paid"},
+		},
+	}
+
+	plan, err := queryPlanner.PlanQuery(dslQuery)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	expected := "SELECT t0.user_id, COUNT(DISTINCT t0.status) AS statuses, " +
+		"percentile_cont(0.5) WITHIN GROUP (ORDER BY t0.amount) AS median_amount, " +
+		"percentile_cont(0.95) WITHIN GROUP (ORDER BY t0.amount) AS p95, " +
+		"STDDEV(t0.amount) AS sd, VARIANCE(t0.amount) AS var, " +
+		"STRING_AGG(t0.status, $1::text) AS status_list, ARRAY_AGG(t0.id) AS ids, " +
+		"BOOL_AND(t0.is_paid) AS all_paid, BOOL_OR(t0.is_paid) AS any_paid FROM orders t0"
+	if !strings.Contains(sql, expected) {
+		t.Errorf("SQL = %s, want it to contain %s", sql, expected)
+	}
+
+	if len(params) != 3 || params[0] != "; " {
+		t.Errorf("params = %v, want separator first", params)
+	}
+}
+
+func TestBuildQuery_AggregateFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	dslQuery := &dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 10},
+		GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggCount, Alias: "paid", Filter: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"}},
+			{Function: dsl.AggSum, Field: "amount", Alias: "refunded", Filter: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "REFUNDED"}},
+		},
+		Having: &dsl.ComparisonFilter{Field: "paid", Op: dsl.OpGT, Value: 1},
+	}
+
+	plan, err := queryPlanner.PlanQuery(dslQuery)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	expected := "SELECT t0.user_id, COUNT(*) FILTER (WHERE t0.status = $1) AS paid, SUM(t0.amount) FILTER (WHERE t0.status = $2) AS refunded " +
+		"FROM orders t0 WHERE t0.amount > $3 GROUP BY t0.user_id " +
+		"HAVING COUNT(*) FILTER (WHERE t0.status = $4) > $5 LIMIT $6 OFFSET $7"
+	if !strings.Contains(sql, expected) {
+		t.Errorf("SQL = %s, want it to contain %s", sql, expected)
+	}
+
+	wantParams := []interface{}{"PAID", "REFUNDED", int64(10), "PAID", int64(1), 100, 0}
+	if len(params) != len(wantParams) {
+		t.Fatalf("params = %v, want %v", params, wantParams)
+	}
+	for i := range wantParams {
+		if params[i] != wantParams[i] {
+			t.Errorf("params[%d] = %v, want %v", i, params[i], wantParams[i])
+		}
+	}
+}
+
+func TestBuildQuery_SortByAggregateAlias(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	dslQuery := &dsl.Query{
+		Model:      "orders",
+		GroupBy:    []dsl.GroupBy{{Field: "status"}},
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "order_count"}},
+		Sort:       []dsl.Sort{{Field: "order_count", Direction: dsl.SortDesc}},
+		Pagination: &dsl.Pagination{Limit: 10},
+	}
+
+	plan, err := queryPlanner.PlanQuery(dslQuery)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, _, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	expected := "SELECT t0.status, COUNT(*) AS order_count FROM orders t0 GROUP BY t0.status ORDER BY order_count DESC LIMIT $1 OFFSET $2"
+	if !strings.Contains(sql, expected) {
+		t.Errorf("SQL = %s, want it to contain %s", sql, expected)
+	}
+}
+
+func TestBuildQuery_TimeBucketGroupBy(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	dslQuery := &dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		GroupBy: []dsl.GroupBy{
+			{Field: "created_at", Granularity: dsl.GranularityWeek, Timezone: "America/New_York"},
+			{Field: "created_at", Granularity: dsl.GranularityYear},
+		},
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggSum, Field: "amount", Alias: "revenue"}},
+	}
+
+	plan, err := queryPlanner.PlanQuery(dslQuery)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	expected := "SELECT date_trunc('week', t0.created_at, $1) AS created_at_week, date_trunc('year', t0.created_at) AS created_at_year, SUM(t0.amount) AS revenue " +
+		"FROM orders t0 WHERE t0.status = $2 " +
+		"GROUP BY date_trunc('week', t0.created_at, $1), date_trunc('year', t0.created_at) LIMIT $3 OFFSET $4"
+	if !strings.Contains(sql, expected) {
+		t.Errorf("SQL = %s, want it to contain %s", sql, expected)
+	}
+
+	if len(params) != 4 || params[0] != "America/New_York" || params[1] != "PAID" {
+		t.Errorf("params = %v, want [America/New_York PAID 100 0]", params)
+	}
+}
+
+func TestBuildQuery_InvalidGranularity(t *testing.T) {
+	plan := &planner.QueryPlan{
+		RootModel: &planner.ModelRef{Name: "orders", Table: "orders", Alias: "t0"},
+		GroupBy: []planner.GroupExpr{{
+			Column:      planner.ColumnRef{TableAlias: "t0", ColumnName: "created_at", DataType: planner.TypeTimestamp},
+			Alias:       "bucket",
+			Granularity: "day'); DROP TABLE orders; --",
+		}},
+		Pagination: planner.Pagination{Limit: 10},
+	}
+
+	builder := NewQueryBuilder()
+	if _, _, err := builder.BuildQuery(plan); err == nil {
+		t.Errorf("BuildQuery() error = nil, want unsupported granularity error")
+	}
+}
+
+func TestBuildQuery_WithHaving(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	dslQuery := &dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggSum, Field: "amount", Alias: "total_amount"},
+			{Function: dsl.AggCount, Alias: "order_count"},
+		},
+		Having: &dsl.LogicalFilter{
+			Or: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "total_amount", Op: dsl.OpGT, Value: 1000},
+				&dsl.ComparisonFilter{Field: "order_count", Op: dsl.OpGTE, Value: 5},
+			},
+		},
+	}
+
+	plan, err := queryPlanner.PlanQuery(dslQuery)
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	expected := "WHERE t0.status = $1 GROUP BY t0.user_id HAVING (SUM(t0.amount) > $2 OR COUNT(*) >= $3) LIMIT $4 OFFSET $5"
+	if !strings.Contains(sql, expected) {
+		t.Errorf("SQL = %s, want it to contain %s", sql, expected)
+	}
+
+	if len(params) != 5 {
+		t.Fatalf("Expected 5 params, got %d", len(params))
+	}
+	if params[1] != int64(1000) || params[2] != int64(5) {
+		t.Errorf("HAVING params = %v, %v; want 1000, 5", params[1], params[2])
+	}
+}
+
 func TestBuildQuery_WithSort(t *testing.T) {
 	reg := setupTestRegistry()
 	queryPlanner := planner.NewPlanner(reg)
@@ -260,6 +630,369 @@ func TestBuildQuery_WithPagination(t *testing.T) {
 	}
 }
 
+func TestBuildQuery_RangeFilters(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      string
+		op         dsl.FilterOperator
+		value      interface{}
+		wantWhere  string
+		wantParams []interface{}
+	}{
+		{
+			"between", "amount", dsl.OpBetween, []interface{}{float64(10), float64(20)},
+			`WHERE t0.amount BETWEEN $1 AND $2`, []interface{}{float64(10), float64(20)},
+		},
+		{
+			"gte_lt", "id", dsl.OpGTELT, []interface{}{float64(1), float64(10)},
+			`WHERE (t0.id >= $1 AND t0.id < $2)`, []interface{}{int64(1), int64(10)},
+		},
+		{
+			"gt_lte", "id", dsl.OpGTLTE, []interface{}{float64(1), float64(10)},
+			`WHERE (t0.id > $1 AND t0.id <= $2)`, []interface{}{int64(1), int64(10)},
+		},
+		{
+			"open upper bound", "created_at", dsl.OpGTELT, []interface{}{"2024-01-01", nil},
+			`WHERE t0.created_at >= $1`, []interface{}{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			"open lower bound", "created_at", dsl.OpBetween, []interface{}{nil, "2024-01-31"},
+			`WHERE t0.created_at <= $1`, []interface{}{time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := setupTestRegistry()
+			plan, err := planner.NewPlanner(reg).PlanQuery(&dsl.Query{
+				Model:      "orders",
+				Filters:    &dsl.ComparisonFilter{Field: tt.field, Op: tt.op, Value: tt.value},
+				Pagination: &dsl.Pagination{Limit: 10},
+			})
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+
+			sql, params, err := NewQueryBuilder().BuildQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildQuery error: %v", err)
+			}
+			if !strings.Contains(sql, tt.wantWhere) {
+				t.Errorf("SQL missing %q: %s", tt.wantWhere, sql)
+			}
+
+			// The LIMIT and OFFSET params follow the range bounds
+			next := len(tt.wantParams) + 1
+			if want := fmt.Sprintf("LIMIT $%d OFFSET $%d", next, next+1); !strings.Contains(sql, want) {
+				t.Errorf("SQL missing %q: %s", want, sql)
+			}
+			if !reflect.DeepEqual(params[:len(tt.wantParams)], tt.wantParams) {
+				t.Errorf("params = %#v, want prefix %#v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestBuildQuery_JSONPaths(t *testing.T) {
+	reg := setupTestRegistry()
+	plan, err := planner.NewPlanner(reg).PlanQuery(&dsl.Query{
+		Model:   "orders",
+		GroupBy: []dsl.GroupBy{{Field: "metadata.shipping.country"}},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggSum, Field: "metadata.weight::decimal", Alias: "weight"},
+		},
+		Filters: &dsl.LogicalFilter{
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "metadata", Op: dsl.OpHasKey, Value: "shipping"},
+				&dsl.ComparisonFilter{Field: "metadata.tags", Op: dsl.OpJSONContains, Value: []interface{}{"fragile"}},
+				&dsl.ComparisonFilter{Field: "metadata.items.0.sku", Op: dsl.OpEqual, Value: "A-1"},
+			},
+		},
+		Sort:       []dsl.Sort{{Field: "metadata.shipping.country"}},
+		Pagination: &dsl.Pagination{Limit: 10},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	sql, params, err := NewQueryBuilder().BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	// The grouped path is rendered once and reused by SELECT and ORDER BY
+	expected := `SELECT (t0.metadata->$1::text->>$2::text) AS "metadata.shipping.country", ` +
+		`SUM((t0.metadata->>$3::text)::numeric) AS weight FROM orders t0 ` +
+		`WHERE (t0.metadata ? $4::text AND (t0.metadata->$5::text) @> $6::jsonb AND (t0.metadata->$7::text->$8::int->>$9::text) = $10) ` +
+		`GROUP BY (t0.metadata->$1::text->>$2::text) ORDER BY (t0.metadata->$1::text->>$2::text) ASC LIMIT $11 OFFSET $12;`
+	if sql != expected {
+		t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", expected, sql)
+	}
+
+	wantParams := []interface{}{"shipping", "country", "weight", "shipping", "tags", `["fragile"]`, "items", 0, "sku", "A-1", 10, 0}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("params = %#v, want %#v", params, wantParams)
+	}
+}
+
+func TestBuildQuery_Search(t *testing.T) {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "articles",
+				Table:      "articles",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "title", Type: "string"},
+					{Name: "body", Type: "string"},
+				},
+				SearchFields: []string{"title", "body"},
+			},
+			{
+				Name:           "documents",
+				Table:          "documents",
+				PrimaryKey:     "id",
+				Fields:         []config.Field{{Name: "id", Type: "integer"}},
+				SearchVector:   "search_vector",
+				SearchLanguage: "simple",
+			},
+		},
+	}
+	reg := schema.NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		expected string
+	}{
+		{
+			"search fields",
+			"articles",
+			"SELECT * FROM articles t0 " +
+				"WHERE to_tsvector('english', coalesce(t0.title, '') || ' ' || coalesce(t0.body, '')) @@ websearch_to_tsquery('english', $1) " +
+				"ORDER BY ts_rank(to_tsvector('english', coalesce(t0.title, '') || ' ' || coalesce(t0.body, '')), websearch_to_tsquery('english', $2)) DESC " +
+				"LIMIT $3 OFFSET $4;",
+		},
+		{
+			"search vector",
+			"documents",
+			"SELECT * FROM documents t0 WHERE t0.search_vector @@ websearch_to_tsquery('simple', $1) " +
+				"ORDER BY ts_rank(t0.search_vector, websearch_to_tsquery('simple', $2)) DESC LIMIT $3 OFFSET $4;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.NewPlanner(reg).PlanQuery(&dsl.Query{
+				Model:   tt.model,
+				Filters: &dsl.ComparisonFilter{Op: dsl.OpSearch, Value: "postgres tuning"},
+				Sort:    []dsl.Sort{{Field: dsl.SearchRankField, Direction: dsl.SortDesc}},
+			})
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+
+			sql, params, err := NewQueryBuilder().BuildQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildQuery error: %v", err)
+			}
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+			if params[0] != "postgres tuning" || params[1] != "postgres tuning" {
+				t.Errorf("params = %v, want search text twice", params)
+			}
+		})
+	}
+}
+
+func TestBuildQuery_Similar(t *testing.T) {
+	reg := setupTestRegistry()
+
+	tests := []struct {
+		name      string
+		threshold *float64
+		where     string
+		params    []interface{}
+	}{
+		{
+			"default threshold",
+			nil,
+			"WHERE t0.status % $1::text",
+			[]interface{}{"shiped"},
+		},
+		{
+			"stricter threshold",
+			floatPtr(0.6),
+			"WHERE (t0.status % $1::text AND similarity(t0.status, $1::text) >= $2)",
+			[]interface{}{"shiped", 0.6},
+		},
+		{
+			"looser threshold",
+			floatPtr(0.1),
+			"WHERE similarity(t0.status, $1::text) >= $2",
+			[]interface{}{"shiped", 0.1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.NewPlanner(reg).PlanQuery(&dsl.Query{
+				Model:   "orders",
+				Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpSimilar, Value: "shiped", Threshold: tt.threshold},
+				Sort:    []dsl.Sort{{Field: dsl.SimilarityField, Direction: dsl.SortDesc}},
+			})
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+
+			sql, params, err := NewQueryBuilder().BuildQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildQuery error: %v", err)
+			}
+			n := len(tt.params)
+			expected := fmt.Sprintf("SELECT * FROM orders t0 %s ORDER BY similarity(t0.status, $%d::text) DESC LIMIT $%d OFFSET $%d;", tt.where, n+1, n+2, n+3)
+			if sql != expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", expected, sql)
+			}
+			if !reflect.DeepEqual(params[:n], tt.params) {
+				t.Errorf("params = %v, want prefix %v", params, tt.params)
+			}
+
+			if exts := RequiredExtensions(plan); !reflect.DeepEqual(exts, []string{"pg_trgm"}) {
+				t.Errorf("RequiredExtensions() = %v, want [pg_trgm]", exts)
+			}
+		})
+	}
+}
+
+func TestRequiredExtensions_None(t *testing.T) {
+	plan, err := planner.NewPlanner(setupTestRegistry()).PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+	if exts := RequiredExtensions(plan); len(exts) != 0 {
+		t.Errorf("RequiredExtensions() = %v, want none", exts)
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestBuildQuery_Keyset(t *testing.T) {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "status", Type: "string"},
+					{Name: "created_at", Type: "timestamp"},
+					{Name: "priority", Type: "integer", Nullable: true},
+				},
+			},
+		},
+	}
+	reg := schema.NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		sort     []dsl.Sort
+		after    []interface{} // nil for the first page
+		expected string
+	}{
+		{
+			"first page",
+			[]dsl.Sort{{Field: "created_at", Direction: dsl.SortDesc}},
+			nil,
+			"SELECT * FROM orders t0 WHERE t0.status = $1 ORDER BY t0.created_at DESC, t0.id DESC LIMIT $2;",
+		},
+		{
+			"single direction",
+			[]dsl.Sort{{Field: "created_at", Direction: dsl.SortDesc}},
+			[]interface{}{"2024-03-01T10:00:00Z", 42},
+			"SELECT * FROM orders t0 WHERE t0.status = $1 AND (t0.created_at, t0.id) < ($2::timestamp, $3) " +
+				"ORDER BY t0.created_at DESC, t0.id DESC LIMIT $4;",
+		},
+		{
+			"mixed directions",
+			[]dsl.Sort{{Field: "status"}, {Field: "created_at", Direction: dsl.SortDesc}},
+			[]interface{}{"PAID", "2024-03-01T10:00:00Z", 42},
+			"SELECT * FROM orders t0 WHERE t0.status = $1 AND (t0.status > $2 OR (t0.status = $2 AND t0.created_at < $3::timestamp) " +
+				"OR (t0.status = $2 AND t0.created_at = $3::timestamp AND t0.id < $4)) " +
+				"ORDER BY t0.status ASC, t0.created_at DESC, t0.id DESC LIMIT $5;",
+		},
+		{
+			"nullable key",
+			[]dsl.Sort{{Field: "priority"}},
+			[]interface{}{5, 42},
+			"SELECT * FROM orders t0 WHERE t0.status = $1 AND ((t0.priority > $2 OR t0.priority IS NULL) OR (t0.priority = $2 AND t0.id > $3)) " +
+				"ORDER BY t0.priority ASC, t0.id ASC LIMIT $4;",
+		},
+		{
+			"null cursor value",
+			[]dsl.Sort{{Field: "priority"}},
+			[]interface{}{nil, 42},
+			"SELECT * FROM orders t0 WHERE t0.status = $1 AND (t0.priority IS NULL AND t0.id > $2) " +
+				"ORDER BY t0.priority ASC, t0.id ASC LIMIT $3;",
+		},
+		{
+			"null cursor value descending",
+			[]dsl.Sort{{Field: "priority", Direction: dsl.SortDesc}},
+			[]interface{}{nil, 42},
+			"SELECT * FROM orders t0 WHERE t0.status = $1 AND (t0.priority IS NOT NULL OR (t0.priority IS NULL AND t0.id < $2)) " +
+				"ORDER BY t0.priority DESC, t0.id DESC LIMIT $3;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagination := &dsl.Pagination{Limit: 25, Keyset: true}
+			if tt.after != nil {
+				cursor, err := dsl.EncodeCursor(dsl.KeysetSort(&dsl.Query{Sort: tt.sort}, "id"), tt.after)
+				if err != nil {
+					t.Fatalf("EncodeCursor error: %v", err)
+				}
+				pagination.Cursor = cursor
+			}
+
+			plan, err := planner.NewPlanner(reg).PlanQuery(&dsl.Query{
+				Model:      "orders",
+				Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				Sort:       tt.sort,
+				Pagination: pagination,
+			})
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+
+			sql, params, err := NewQueryBuilder().BuildQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildQuery error: %v", err)
+			}
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+			if params[len(params)-1] != 25 {
+				t.Errorf("last param = %v, want limit 25", params[len(params)-1])
+			}
+		})
+	}
+}
+
 func TestBuildQuery_FilterOperators(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -286,8 +1019,11 @@ func TestBuildQuery_FilterOperators(t *testing.T) {
 			queryPlanner := planner.NewPlanner(reg)
 
 			var value interface{} = "test"
-			if tt.op == dsl.OpIsNull || tt.op == dsl.OpNotNull {
+			switch tt.op {
+			case dsl.OpIsNull, dsl.OpNotNull:
 				value = nil
+			case dsl.OpIn, dsl.OpNotIn:
+				value = []interface{}{"test"}
 			}
 
 			dslQuery := &dsl.Query{
@@ -318,6 +1054,50 @@ func TestBuildQuery_FilterOperators(t *testing.T) {
 	}
 }
 
+func TestBuildQuery_InListParam(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	plan, err := queryPlanner.PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpIn, Value: []interface{}{"PAID", "SHIPPED"}},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	if !strings.Contains(sql, "WHERE t0.status = ANY($1)") {
+		t.Errorf("SQL = %s, want = ANY($1)", sql)
+	}
+	list, ok := params[0].([]interface{})
+	if !ok || len(list) != 2 || list[0] != "PAID" || list[1] != "SHIPPED" {
+		t.Errorf("params[0] = %#v, want list of statuses", params[0])
+	}
+}
+
+func TestBuildQuery_PatternRequiresString(t *testing.T) {
+	plan := &planner.QueryPlan{
+		RootModel: &planner.ModelRef{Name: "orders", Table: "orders", Alias: "t0"},
+		Filters: &planner.ComparisonFilterIR{
+			Left:     planner.ColumnRef{TableAlias: "t0", ColumnName: "status", DataType: planner.TypeString},
+			Operator: dsl.OpStartsWith,
+			Value:    &planner.ValueExpr{Value: 42, Type: planner.TypeString},
+		},
+		Pagination: planner.Pagination{Limit: 10},
+	}
+
+	builder := NewQueryBuilder()
+	if _, _, err := builder.BuildQuery(plan); err == nil {
+		t.Errorf("BuildQuery() error = nil, want error for non-string pattern")
+	}
+}
+
 func TestBuildQuery_ComplexQuery(t *testing.T) {
 	reg := setupTestRegistry()
 	queryPlanner := planner.NewPlanner(reg)
@@ -325,11 +1105,11 @@ func TestBuildQuery_ComplexQuery(t *testing.T) {
 	dslQuery := &dsl.Query{
 		Model:   "orders",
 		Fields:  []string{"status"},
-		GroupBy: []string{"status"},
+		GroupBy: []dsl.GroupBy{{Field: "status"}},
 		Filters: &dsl.LogicalFilter{
-			And: []*dsl.ComparisonFilter{
-				{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01"},
-				{Field: "amount", Op: dsl.OpGTE, Value: 100},
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01"},
+				&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGTE, Value: 100},
 			},
 		},
 		Aggregates: []dsl.Aggregate{
@@ -448,3 +1228,69 @@ func TestNewQueryBuilder(t *testing.T) {
 		t.Errorf("Initial paramCount should be 0")
 	}
 }
+
+func TestBuildQuery_Windows(t *testing.T) {
+	reg := setupTestRegistry()
+	byUser := []string{"user_id"}
+	byDate := []dsl.Sort{{Field: "created_at"}}
+	offset := 3
+
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected string
+	}{
+		{
+			"running total",
+			&dsl.Query{
+				Model:   "orders",
+				Windows: []dsl.Window{{Function: dsl.WinSum, Field: "amount", PartitionBy: byUser, OrderBy: byDate, Alias: "running_total"}},
+			},
+			"SELECT t0.*, SUM(t0.amount) OVER (PARTITION BY t0.user_id ORDER BY t0.created_at ASC) AS running_total FROM orders t0 LIMIT $1 OFFSET $2;",
+		},
+		{
+			"ranking with fields and sort",
+			&dsl.Query{
+				Model:  "orders",
+				Fields: []string{"id", "amount"},
+				Windows: []dsl.Window{{Function: dsl.WinDenseRank, PartitionBy: byUser,
+					OrderBy: []dsl.Sort{{Field: "amount", Direction: dsl.SortDesc}}, Alias: "amount_rank"}},
+				Sort: []dsl.Sort{{Field: "amount_rank"}},
+			},
+			"SELECT t0.id, t0.amount, DENSE_RANK() OVER (PARTITION BY t0.user_id ORDER BY t0.amount DESC) AS amount_rank FROM orders t0 ORDER BY amount_rank ASC LIMIT $1 OFFSET $2;",
+		},
+		{
+			"lag with offset",
+			&dsl.Query{
+				Model:   "orders",
+				Windows: []dsl.Window{{Function: dsl.WinLag, Field: "amount", OrderBy: byDate, Offset: &offset, Alias: "prev_amount"}},
+			},
+			"SELECT t0.*, LAG(t0.amount, 3) OVER (ORDER BY t0.created_at ASC) AS prev_amount FROM orders t0 LIMIT $1 OFFSET $2;",
+		},
+		{
+			"count over whole result",
+			&dsl.Query{
+				Model:   "orders",
+				Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				Windows: []dsl.Window{{Function: dsl.WinCount, Alias: "matching"}},
+			},
+			"SELECT t0.*, COUNT(*) OVER () AS matching FROM orders t0 WHERE t0.status = $1 LIMIT $2 OFFSET $3;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.NewPlanner(reg).PlanQuery(tt.query)
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+			sql, _, err := NewQueryBuilder().BuildQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildQuery error: %v", err)
+			}
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"udv/internal/planner"
+)
+
+// BuildCountQuery renders a query counting the rows a plan returns across
+// all pages. Sort, pagination and the keyset position do not apply. Plain
+// queries count their rows directly:
+//
+//	SELECT COUNT(*) FROM orders t0 WHERE t0.status = $1;
+//
+// aggregate queries count their groups:
+//
+//	SELECT COUNT(*) FROM (SELECT t0.status, COUNT(*) AS n FROM orders t0 GROUP BY t0.status) AS counted;
+func (qb *QueryBuilder) BuildCountQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	if err := checkPlan(plan); err != nil {
+		return "", nil, err
+	}
+
+	qb.reset()
+	unpaged := unpagedPlan(plan)
+	if !isAggregatePlan(plan) {
+		parts, err := qb.buildStatement(unpaged, "COUNT(*)")
+		if err != nil {
+			return "", nil, err
+		}
+		return strings.Join(parts, " ") + ";", qb.params, nil
+	}
+
+	parts, err := qb.buildStatement(unpaged, "")
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT COUNT(*) FROM (" + strings.Join(parts, " ") + ") AS counted;", qb.params, nil
+}
+
+// BuildEstimateQuery renders EXPLAIN (FORMAT JSON) of the unpaginated
+// query; the top plan node's "Plan Rows" estimates its row count without
+// running it
+func (qb *QueryBuilder) BuildEstimateQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	if err := checkPlan(plan); err != nil {
+		return "", nil, err
+	}
+
+	qb.reset()
+	parts, err := qb.buildStatement(unpagedPlan(plan), "")
+	if err != nil {
+		return "", nil, err
+	}
+	return "EXPLAIN (FORMAT JSON) " + strings.Join(parts, " ") + ";", qb.params, nil
+}
+
+// unpagedPlan returns a copy of plan without sort and keyset position, the
+// parts that only select a page
+func unpagedPlan(plan *planner.QueryPlan) *planner.QueryPlan {
+	unpaged := *plan
+	unpaged.Sort = nil
+	unpaged.Keyset = nil
+	return &unpaged
+}
+
+func isAggregatePlan(plan *planner.QueryPlan) bool {
+	return len(plan.GroupBy) > 0 || len(plan.Aggregates) > 0
+}
+
+// CountRows implements adapter.Counter: exact or estimated plan totals.
+//
+// CountRows returns the number of rows a plan returns across all pages:
+// exactly with a COUNT(*) query, or estimated. Estimates of a whole table
+// come from pg_class.reltuples (kept current by VACUUM and ANALYZE); any
+// other query is estimated by the planner with EXPLAIN. Both are only as
+// good as the table statistics.
+func (d *Database) CountRows(ctx context.Context, plan *planner.QueryPlan, estimate bool) (int64, error) {
+	qb := NewQueryBuilder()
+	if !estimate {
+		sql, params, err := qb.BuildCountQuery(plan)
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		if err := d.db.QueryRowContext(ctx, sql, driverArgs(params)...).Scan(&total); err != nil {
+			return 0, fmt.Errorf("count query failed: %w", err)
+		}
+		return total, nil
+	}
+
+	if plan.Filters == nil && len(plan.Joins) == 0 && !isAggregatePlan(plan) {
+		var reltuples float64
+		err := d.db.QueryRowContext(ctx, "SELECT reltuples FROM pg_class WHERE oid = to_regclass($1)", plan.RootModel.Table).Scan(&reltuples)
+		if err != nil {
+			return 0, fmt.Errorf("table estimate failed: %w", err)
+		}
+		// -1 means the table was never analyzed; fall through to EXPLAIN
+		if reltuples >= 0 {
+			return int64(math.Round(reltuples)), nil
+		}
+	}
+
+	sql, params, err := qb.BuildEstimateQuery(plan)
+	if err != nil {
+		return 0, err
+	}
+	var data []byte
+	if err := d.db.QueryRowContext(ctx, sql, driverArgs(params)...).Scan(&data); err != nil {
+		return 0, fmt.Errorf("estimate query failed: %w", err)
+	}
+	return parseExplainRows(data)
+}
+
+// parseExplainRows reads the estimated row count of the top plan node from
+// EXPLAIN (FORMAT JSON) output
+func parseExplainRows(data []byte) (int64, error) {
+	var explain []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(data, &explain); err != nil || len(explain) == 0 {
+		return 0, fmt.Errorf("unexpected EXPLAIN output: %s", data)
+	}
+	return int64(math.Round(explain[0].Plan.Rows)), nil
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+func TestBuildCountQuery(t *testing.T) {
+	reg := setupTestRegistry()
+
+	tests := []struct {
+		name     string
+		query    *dsl.Query
+		expected string
+		params   []interface{}
+	}{
+		{
+			"plain query ignores sort and pagination",
+			&dsl.Query{
+				Model:      "orders",
+				Fields:     []string{"id", "metadata.channel"},
+				Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				Sort:       []dsl.Sort{{Field: "created_at", Direction: dsl.SortDesc}},
+				Pagination: &dsl.Pagination{Limit: 10, Offset: 30},
+			},
+			"SELECT COUNT(*) FROM orders t0 WHERE t0.status = $1;",
+			[]interface{}{"PAID"},
+		},
+		{
+			"keyset position does not narrow the count",
+			&dsl.Query{
+				Model:      "orders",
+				Sort:       []dsl.Sort{{Field: "amount"}},
+				Pagination: &dsl.Pagination{Limit: 10, Cursor: mustCursor(t, []dsl.Sort{{Field: "amount"}}, "12.50", 7)},
+			},
+			"SELECT COUNT(*) FROM orders t0;",
+			nil,
+		},
+		{
+			"aggregate query counts groups",
+			&dsl.Query{
+				Model:      "orders",
+				GroupBy:    []dsl.GroupBy{{Field: "status"}},
+				Aggregates: []dsl.Aggregate{{Function: dsl.AggSum, Field: "amount", Alias: "revenue"}},
+				Having:     &dsl.ComparisonFilter{Field: "revenue", Op: dsl.OpGT, Value: 100},
+				Sort:       []dsl.Sort{{Field: "revenue", Direction: dsl.SortDesc}},
+			},
+			`SELECT COUNT(*) FROM (SELECT t0.status, SUM(t0.amount) AS revenue FROM orders t0 GROUP BY t0.status HAVING SUM(t0.amount) > $1) AS counted;`,
+			[]interface{}{int64(100)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.NewPlanner(reg).PlanQuery(tt.query)
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+
+			sql, params, err := NewQueryBuilder().BuildCountQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildCountQuery error: %v", err)
+			}
+			if sql != tt.expected {
+				t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", tt.expected, sql)
+			}
+			if len(params) != len(tt.params) || (len(params) > 0 && !reflect.DeepEqual(params, tt.params)) {
+				t.Errorf("params = %#v, want %#v", params, tt.params)
+			}
+		})
+	}
+}
+
+func TestBuildEstimateQuery(t *testing.T) {
+	plan, err := planner.NewPlanner(setupTestRegistry()).PlanQuery(&dsl.Query{
+		Model:      "orders",
+		Filters:    &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		Sort:       []dsl.Sort{{Field: "created_at"}},
+		Pagination: &dsl.Pagination{Limit: 10},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	sql, _, err := NewQueryBuilder().BuildEstimateQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildEstimateQuery error: %v", err)
+	}
+	expected := "EXPLAIN (FORMAT JSON) SELECT * FROM orders t0 WHERE t0.status = $1;"
+	if sql != expected {
+		t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", expected, sql)
+	}
+}
+
+func TestParseExplainRows(t *testing.T) {
+	rows, err := parseExplainRows([]byte(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 48213.6}}]`))
+	if err != nil || rows != 48214 {
+		t.Errorf("parseExplainRows() = %d, %v; want 48214", rows, err)
+	}
+
+	if _, err := parseExplainRows([]byte(`[]`)); err == nil {
+		t.Errorf("parseExplainRows([]) error = nil, want error")
+	}
+}
+
+func mustCursor(t *testing.T, sort []dsl.Sort, values ...interface{}) string {
+	t.Helper()
+	cursor, err := dsl.EncodeCursor(dsl.KeysetSort(&dsl.Query{Sort: sort}, "id"), values)
+	if err != nil {
+		t.Fatalf("EncodeCursor error: %v", err)
+	}
+	return cursor
+}
@@ -1,15 +1,24 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+
+	"udv/internal/adapter"
 )
 
-// Database wraps a PostgreSQL connection pool
+// Database wraps a PostgreSQL connection pool; it is the executor of the
+// PostgreSQL adapter
 type Database struct {
 	db *sql.DB
+
+	// extensions caches installed extensions; missing ones are checked
+	// again so installing one needs no restart
+	extensions sync.Map
 }
 
 // Connect opens a connection to a PostgreSQL database using a DSN
@@ -34,65 +43,73 @@ func (d *Database) Close() error {
 
 // Query executes a parameterized query and returns rows
 func (d *Database) Query(sql string, args ...interface{}) (*sql.Rows, error) {
-	return d.db.Query(sql, args...)
+	return d.db.Query(sql, driverArgs(args)...)
 }
 
 // QueryRow executes a query that returns a single row
 func (d *Database) QueryRow(sql string, args ...interface{}) *sql.Row {
-	return d.db.QueryRow(sql, args...)
+	return d.db.QueryRow(sql, driverArgs(args)...)
 }
 
 // Exec executes a query that doesn't return rows
 func (d *Database) Exec(sql string, args ...interface{}) (sql.Result, error) {
-	return d.db.Exec(sql, args...)
+	return d.db.Exec(sql, driverArgs(args)...)
 }
 
-// ExecuteAndFetchRows executes a query and returns results as []map[string]interface{}
-func (d *Database) ExecuteAndFetchRows(sql string, args ...interface{}) ([]map[string]interface{}, error) {
-	rows, err := d.db.Query(sql, args...)
+// Fetch implements adapter.Executor: it runs a query and returns all its
+// rows
+func (d *Database) Fetch(ctx context.Context, sql string, args ...interface{}) ([]adapter.Row, error) {
+	var results []adapter.Row
+	err := d.Stream(ctx, sql, args, func(row adapter.Row) error {
+		results = append(results, row)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("query execution failed: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+	return results, nil
+}
 
-	// Get column names
-	columns, err := rows.Columns()
+// Stream implements adapter.Executor: it runs a query and hands each row to
+// fn as it is scanned, so large results need not be held in memory
+func (d *Database) Stream(ctx context.Context, sql string, args []interface{}, fn func(adapter.Row) error) error {
+	rows, err := d.db.QueryContext(ctx, sql, driverArgs(args)...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+		return fmt.Errorf("query execution failed: %w", err)
 	}
+	defer rows.Close()
 
-	// Fetch all rows
-	var results []map[string]interface{}
-	for rows.Next() {
-		// Create a slice of interface{} to hold the values
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range columns {
-			valuePtrs[i] = &values[i]
-		}
-
-		// Scan the row
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
+	return adapter.ScanRows(rows, fn)
+}
 
-		// Convert to map
-		entry := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-			// Convert []byte to string for better JSON serialization
-			if b, ok := val.([]byte); ok {
-				entry[col] = string(b)
-			} else {
-				entry[col] = val
-			}
-		}
-		results = append(results, entry)
+// HasExtension reports whether a PostgreSQL extension is installed in the
+// connected database
+func (d *Database) HasExtension(ctx context.Context, name string) (bool, error) {
+	if _, ok := d.extensions.Load(name); ok {
+		return true, nil
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	var installed bool
+	err := d.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1)", name).Scan(&installed)
+	if err != nil {
+		return false, fmt.Errorf("failed to check extension %s: %w", name, err)
+	}
+	if installed {
+		d.extensions.Store(name, true)
 	}
+	return installed, nil
+}
 
-	return results, nil
+// driverArgs converts list parameters (from in/not_in filters) into
+// PostgreSQL arrays; other parameters are passed through unchanged
+func driverArgs(args []interface{}) []interface{} {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		if list, ok := arg.([]interface{}); ok {
+			out[i] = pq.Array(list)
+			continue
+		}
+		out[i] = arg
+	}
+	return out
 }
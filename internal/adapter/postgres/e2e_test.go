@@ -144,7 +144,7 @@ func TestE2EQueryExecution(t *testing.T) {
 	t.Run("SELECT with GROUP BY and aggregate", func(t *testing.T) {
 		q := &dsl.Query{
 			Model:   "orders",
-			GroupBy: []string{"status"},
+			GroupBy: []dsl.GroupBy{{Field: "status"}},
 			Aggregates: []dsl.Aggregate{
 				{Function: dsl.AggCount, Field: "", Alias: "count"},
 				{Function: dsl.AggSum, Field: "amount", Alias: "total"},
@@ -232,7 +232,7 @@ func TestE2EQueryExecution(t *testing.T) {
 	t.Run("Complex query", func(t *testing.T) {
 		q := &dsl.Query{
 			Model:   "orders",
-			GroupBy: []string{"status"},
+			GroupBy: []dsl.GroupBy{{Field: "status"}},
 			Filters: &dsl.ComparisonFilter{
 				Field: "amount",
 				Op:    dsl.OpGT,
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"sort"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// operatorExtensions maps filter operators to the PostgreSQL extension their
+// SQL depends on
+var operatorExtensions = map[dsl.FilterOperator]string{
+	dsl.OpSimilar: "pg_trgm",
+}
+
+// RequiredExtensions lists the extensions a plan's SQL needs, sorted and
+// without duplicates
+func RequiredExtensions(plan *planner.QueryPlan) []string {
+	seen := make(map[string]bool)
+	var walk func(expr planner.FilterExpr)
+	walk = func(expr planner.FilterExpr) {
+		switch e := expr.(type) {
+		case *planner.ComparisonFilterIR:
+			if ext, ok := operatorExtensions[e.Operator]; ok {
+				seen[ext] = true
+			}
+		case *planner.LogicalFilterIR:
+			for _, node := range e.Nodes {
+				walk(node)
+			}
+		}
+	}
+
+	walk(plan.Filters)
+	walk(plan.Having)
+	for _, agg := range plan.Aggregates {
+		walk(agg.Filter)
+	}
+	for _, s := range plan.Sort {
+		if s.Search != nil {
+			walk(s.Search)
+		}
+	}
+
+	exts := make([]string, 0, len(seen))
+	for ext := range seen {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"udv/internal/planner"
+)
+
+// seriesSteps maps a bucket granularity to the generate_series step
+var seriesSteps = map[string]string{
+	"minute":  "1 minute",
+	"hour":    "1 hour",
+	"day":     "1 day",
+	"week":    "1 week",
+	"month":   "1 month",
+	"quarter": "3 months",
+	"year":    "1 year",
+}
+
+// BuildTimeSeriesQuery converts a TimeSeriesPlan into a parameterized query
+// returning one row per bucket of the range. Every bucket comes from
+// generate_series and is LEFT JOINed against the aggregated rows, so buckets
+// without data appear with NULL (or 0) aggregates:
+//
+//	WITH buckets AS (SELECT generate_series(...) AS bucket),
+//	agg AS (SELECT date_trunc(...) AS bucket, ... GROUP BY date_trunc(...))
+//	SELECT buckets.bucket, ... FROM buckets LEFT JOIN agg ON agg.bucket = buckets.bucket
+func (qb *QueryBuilder) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	if plan == nil || plan.Query == nil {
+		return "", nil, fmt.Errorf("time series plan is nil")
+	}
+	if plan.Query.RootModel == nil {
+		return "", nil, fmt.Errorf("root model is nil")
+	}
+
+	step, ok := seriesSteps[plan.Bucket.Granularity]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported granularity: %s", plan.Bucket.Granularity)
+	}
+
+	qb.reset()
+
+	// The bucket expression adds the timezone parameter first (if any), so
+	// the series below can refer to the same placeholder
+	groupSQL, err := qb.buildGroupExpressions(plan.Query)
+	if err != nil {
+		return "", nil, err
+	}
+	qb.groupSQL = groupSQL
+	tzParam := ""
+	if qb.paramCount > 0 {
+		tzParam = fmt.Sprintf("$%d", qb.paramCount)
+	}
+
+	isDate := plan.Bucket.Column.DataType == planner.TypeDate
+	var fromParam, toParam string
+	if isDate {
+		fromParam = qb.addParam(plan.From.Format("2006-01-02")) + "::date"
+		toParam = qb.addParam(plan.To.Format("2006-01-02")) + "::date"
+	} else {
+		fromParam = qb.addParam(plan.From) + "::timestamptz"
+		toParam = qb.addParam(plan.To) + "::timestamptz"
+	}
+
+	// Bucket starts from the one containing From up to the one containing
+	// the last instant before To. With a timezone the series is generated in
+	// local wall time so that days and months follow DST transitions.
+	var series string
+	switch {
+	case isDate:
+		series = fmt.Sprintf("generate_series(date_trunc('%s', %s::timestamp), %s::timestamp - interval '1 microsecond', interval '%s')",
+			plan.Bucket.Granularity, fromParam, toParam, step)
+	case tzParam != "":
+		series = fmt.Sprintf("generate_series(date_trunc('%s', %s AT TIME ZONE %s), (%s AT TIME ZONE %s) - interval '1 microsecond', interval '%s') AT TIME ZONE %s",
+			plan.Bucket.Granularity, fromParam, tzParam, toParam, tzParam, step, tzParam)
+	default:
+		series = fmt.Sprintf("generate_series(date_trunc('%s', %s), %s - interval '1 microsecond', interval '%s')",
+			plan.Bucket.Granularity, fromParam, toParam, step)
+	}
+
+	// Aggregated rows restricted to the range
+	timeCol := qb.valueSQL(plan.Bucket.Column)
+	conditions := []string{}
+	if plan.Query.Filters != nil {
+		filterSQL, err := qb.buildFilterExpression(plan.Query.Filters)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, filterSQL)
+	}
+	conditions = append(conditions,
+		fmt.Sprintf("%s >= %s", timeCol, fromParam),
+		fmt.Sprintf("%s < %s", timeCol, toParam))
+
+	selectSQL, err := qb.buildSelectClause(plan.Query)
+	if err != nil {
+		return "", nil, err
+	}
+	aggSQL := strings.Join([]string{
+		selectSQL,
+		qb.buildFromClause(plan.Query),
+		"WHERE " + strings.Join(conditions, " AND "),
+		qb.buildGroupByClause(plan.Query),
+	}, " ")
+
+	bucketAlias := formatAlias(plan.Bucket.Alias)
+	columns := []string{"buckets." + bucketAlias}
+	for _, agg := range plan.Query.Aggregates {
+		alias := formatAlias(agg.Alias)
+		if plan.ZeroFill[agg.Alias] {
+			columns = append(columns, fmt.Sprintf("COALESCE(agg.%s, 0) AS %s", alias, alias))
+		} else {
+			columns = append(columns, fmt.Sprintf("agg.%s AS %s", alias, alias))
+		}
+	}
+
+	sql := fmt.Sprintf("WITH buckets AS (SELECT %s AS %s), agg AS (%s) SELECT %s FROM buckets LEFT JOIN agg ON agg.%s = buckets.%s ORDER BY buckets.%s ASC;",
+		series, bucketAlias, aggSQL, strings.Join(columns, ", "), bucketAlias, bucketAlias, bucketAlias)
+
+	return sql, qb.params, nil
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+func TestBuildTimeSeriesQuery(t *testing.T) {
+	reg := setupTestRegistry()
+	queryPlanner := planner.NewPlanner(reg)
+
+	plan, err := queryPlanner.PlanTimeSeries(&dsl.TimeSeriesQuery{
+		Model:    "orders",
+		Field:    "created_at",
+		Interval: dsl.GranularityDay,
+		Timezone: "America/New_York",
+		Range:    dsl.TimeRange{From: "2024-01-01", To: "2024-01-08"},
+		Filters:  &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggCount, Alias: "orders"},
+			{Function: dsl.AggSum, Field: "amount", Alias: "revenue"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PlanTimeSeries error: %v", err)
+	}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildTimeSeriesQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildTimeSeriesQuery error: %v", err)
+	}
+
+	expected := "WITH buckets AS (SELECT generate_series(date_trunc('day', $2::timestamptz AT TIME ZONE $1), ($3::timestamptz AT TIME ZONE $1) - interval '1 microsecond', interval '1 day') AT TIME ZONE $1 AS bucket), " +
+		"agg AS (SELECT date_trunc('day', t0.created_at, $1) AS bucket, COUNT(*) AS orders, SUM(t0.amount) AS revenue FROM orders t0 " +
+		"WHERE t0.status = $4 AND t0.created_at >= $2::timestamptz AND t0.created_at < $3::timestamptz " +
+		"GROUP BY date_trunc('day', t0.created_at, $1)) " +
+		"SELECT buckets.bucket, COALESCE(agg.orders, 0) AS orders, agg.revenue AS revenue FROM buckets LEFT JOIN agg ON agg.bucket = buckets.bucket ORDER BY buckets.bucket ASC;"
+	if sql != expected {
+		t.Errorf("SQL =\n%s\nwant\n%s", sql, expected)
+	}
+
+	if len(params) != 4 {
+		t.Fatalf("Expected 4 params, got %d: %v", len(params), params)
+	}
+	if params[0] != "America/New_York" || params[3] != "PAID" {
+		t.Errorf("params = %v", params)
+	}
+	from, ok := params[1].(time.Time)
+	if !ok || !from.Equal(time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)) {
+		t.Errorf("from param = %v, want 2024-01-01 midnight in New York", params[1])
+	}
+}
+
+func TestBuildTimeSeriesQuery_DateField(t *testing.T) {
+	plan := &planner.TimeSeriesPlan{
+		Query: &planner.QueryPlan{
+			RootModel: &planner.ModelRef{Name: "events", Table: "events", Alias: "t0"},
+			Aggregates: []planner.AggregateExpr{
+				{Function: planner.AggCountFn, Alias: "events"},
+			},
+		},
+		From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		ZeroFill: map[string]bool{"events": true},
+	}
+	plan.Bucket = planner.GroupExpr{
+		Column:      planner.ColumnRef{TableAlias: "t0", ColumnName: "day", DataType: planner.TypeDate},
+		Alias:       "bucket",
+		Granularity: "quarter",
+		Timezone:    "UTC",
+	}
+	plan.Query.GroupBy = []planner.GroupExpr{plan.Bucket}
+
+	builder := NewQueryBuilder()
+	sql, params, err := builder.BuildTimeSeriesQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildTimeSeriesQuery error: %v", err)
+	}
+
+	if !strings.Contains(sql, "generate_series(date_trunc('quarter', $1::date::timestamp), $2::date::timestamp - interval '1 microsecond', interval '3 months')") {
+		t.Errorf("SQL = %s, want a date series without timezone", sql)
+	}
+	if !strings.Contains(sql, "WHERE t0.day >= $1::date AND t0.day < $2::date GROUP BY date_trunc('quarter', t0.day)") {
+		t.Errorf("SQL = %s, want date range predicate", sql)
+	}
+	if len(params) != 2 || params[0] != "2024-01-01" || params[1] != "2024-07-01" {
+		t.Errorf("params = %v, want [2024-01-01 2024-07-01]", params)
+	}
+}
@@ -0,0 +1,610 @@
+// Package sqlgen renders query plans as SQL with positional ? placeholders.
+// It is the query generation the MySQL and SQLite dialects share; each
+// supplies a Syntax for what differs between them: quoting, JSON access,
+// time buckets, pattern matching, aggregates and pagination.
+package sqlgen
+
+import (
+	"fmt"
+	"strings"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// Syntax renders the parts of a query that differ between ?-placeholder
+// dialects
+type Syntax interface {
+	// Name names the dialect in errors
+	Name() string
+	// QuoteIdentifier quotes a table, column or alias name
+	QuoteIdentifier(name string) string
+	// Param converts a value before it is bound
+	Param(value interface{}) interface{}
+	// Cast wraps a placeholder where the bound value cannot be compared
+	// with a column of fieldType as is
+	Cast(placeholder string, fieldType planner.FieldType) string
+	// Value renders a column reference as a value of its DataType,
+	// extracting JSON paths
+	Value(ref planner.ColumnRef) string
+	// Document renders a column reference as a JSON document, for the JSON
+	// operators
+	Document(ref planner.ColumnRef) string
+	// Group renders a GROUP BY key, truncating bucketed keys. It must not
+	// bind parameters, so the key is identical in SELECT and GROUP BY.
+	Group(groupExpr planner.GroupExpr) (string, error)
+	// Match renders the comparisons the Builder leaves to the dialect:
+	// like, ilike, the pattern operators and the JSON operators
+	Match(b *Builder, colName string, f *planner.ComparisonFilterIR) (string, error)
+	// Aggregate renders an aggregate with its filter, without its alias
+	Aggregate(b *Builder, agg planner.AggregateExpr) (string, error)
+	// Limit renders the LIMIT clause; keyset pages have no offset
+	Limit(b *Builder, plan *planner.QueryPlan) string
+}
+
+// Builder builds parameterized queries from query plans. Placeholders are
+// positional (?), so parameters are added in the order their placeholders
+// appear in the SQL, and an expression repeated in two clauses (a grouped
+// JSON path, say) must render without parameters.
+type Builder struct {
+	syntax Syntax
+	params []interface{}
+}
+
+// NewBuilder creates a query builder rendering with syntax
+func NewBuilder(syntax Syntax) *Builder {
+	return &Builder{
+		syntax: syntax,
+		params: []interface{}{},
+	}
+}
+
+// reset clears the state of the previous build
+func (b *Builder) reset() {
+	b.params = []interface{}{}
+}
+
+// AddParam appends a parameter and returns its placeholder
+func (b *Builder) AddParam(value interface{}) string {
+	b.params = append(b.params, b.syntax.Param(value))
+	return "?"
+}
+
+// formatAlias returns an output column alias. Aliases are always quoted:
+// common names such as rank or value are reserved words in MySQL 8.
+func (b *Builder) formatAlias(alias string) string {
+	return b.syntax.QuoteIdentifier(alias)
+}
+
+// ColumnSQL renders a resolved column reference as alias."column", quoted
+// by syntax
+func ColumnSQL(syntax Syntax, ref planner.ColumnRef) string {
+	return ref.TableAlias + "." + syntax.QuoteIdentifier(ref.ColumnName)
+}
+
+// checkPlan rejects plans the builder cannot render
+func checkPlan(plan *planner.QueryPlan) error {
+	if plan == nil {
+		return fmt.Errorf("query plan is nil")
+	}
+	if plan.RootModel == nil {
+		return fmt.Errorf("root model is nil")
+	}
+	return nil
+}
+
+// BuildQuery converts a QueryPlan into a parameterized query
+func (b *Builder) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	if err := checkPlan(plan); err != nil {
+		return "", nil, err
+	}
+
+	b.reset()
+
+	// 1-5. SELECT through HAVING
+	parts, err := b.buildStatement(plan, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 6. ORDER BY clause (if sorting exists)
+	if len(plan.Sort) > 0 {
+		orderByPart, err := b.buildOrderByClause(plan)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, orderByPart)
+	}
+
+	// 7. LIMIT clause
+	parts = append(parts, b.syntax.Limit(b, plan))
+
+	return strings.Join(parts, " ") + ";", b.params, nil
+}
+
+// BuildCountQuery renders a query counting the rows a plan returns across
+// all pages, like its PostgreSQL counterpart: plain queries count their
+// rows directly, aggregate queries count their groups.
+func (b *Builder) BuildCountQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	if err := checkPlan(plan); err != nil {
+		return "", nil, err
+	}
+
+	b.reset()
+	unpaged := *plan
+	unpaged.Sort = nil
+	unpaged.Keyset = nil
+	if len(plan.GroupBy) == 0 && len(plan.Aggregates) == 0 {
+		parts, err := b.buildStatement(&unpaged, "COUNT(*)")
+		if err != nil {
+			return "", nil, err
+		}
+		return strings.Join(parts, " ") + ";", b.params, nil
+	}
+
+	parts, err := b.buildStatement(&unpaged, "")
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT COUNT(*) FROM (" + strings.Join(parts, " ") + ") AS counted;", b.params, nil
+}
+
+// BuildTimeSeriesQuery is not supported: neither MySQL nor SQLite has
+// generate_series to produce the empty buckets
+func (b *Builder) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("time series are not supported by the %s dialect", b.syntax.Name())
+}
+
+// buildStatement renders a plan's statement from SELECT through HAVING, in
+// placeholder order. selectList, when set, replaces the plan's SELECT list.
+func (b *Builder) buildStatement(plan *planner.QueryPlan, selectList string) ([]string, error) {
+	var parts []string
+
+	// 1. SELECT clause
+	selectPart := "SELECT " + selectList
+	if selectList == "" {
+		var err error
+		selectPart, err = b.buildSelectClause(plan)
+		if err != nil {
+			return nil, err
+		}
+	}
+	parts = append(parts, selectPart)
+
+	// 2. FROM clause
+	parts = append(parts, b.buildFromClause(plan))
+
+	// 3. WHERE clause (if filters or a keyset position exist)
+	wherePart, err := b.buildWhereClause(plan)
+	if err != nil {
+		return nil, err
+	}
+	if wherePart != "" {
+		parts = append(parts, wherePart)
+	}
+
+	// 4. GROUP BY clause (if grouping exists)
+	if len(plan.GroupBy) > 0 {
+		exprs := make([]string, len(plan.GroupBy))
+		for i, groupExpr := range plan.GroupBy {
+			exprs[i], err = b.syntax.Group(groupExpr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		parts = append(parts, "GROUP BY "+strings.Join(exprs, ", "))
+	}
+
+	// 5. HAVING clause (if aggregate filters exist)
+	if plan.Having != nil {
+		havingSQL, err := b.Filter(plan.Having)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, "HAVING "+havingSQL)
+	}
+
+	return parts, nil
+}
+
+// buildSelectClause generates the SELECT part of the query
+func (b *Builder) buildSelectClause(plan *planner.QueryPlan) (string, error) {
+	var columns []string
+
+	// Add selected columns (if any)
+	for _, expr := range plan.Select {
+		colName := b.syntax.Value(expr.Column)
+		if expr.Alias != expr.Column.ColumnName {
+			colName = fmt.Sprintf("%s AS %s", colName, b.formatAlias(expr.Alias))
+		}
+		columns = append(columns, colName)
+	}
+
+	// Add group by columns if grouping
+	if len(plan.GroupBy) > 0 && len(plan.Select) == 0 {
+		for _, groupExpr := range plan.GroupBy {
+			colName, err := b.syntax.Group(groupExpr)
+			if err != nil {
+				return "", err
+			}
+			if groupExpr.Granularity != "" || (groupExpr.Alias != "" && groupExpr.Alias != groupExpr.Column.ColumnName) {
+				colName = fmt.Sprintf("%s AS %s", colName, b.formatAlias(groupExpr.Alias))
+			}
+			columns = append(columns, colName)
+		}
+	}
+
+	// Add aggregates
+	for _, agg := range plan.Aggregates {
+		aggSQL, err := b.syntax.Aggregate(b, agg)
+		if err != nil {
+			return "", err
+		}
+		columns = append(columns, fmt.Sprintf("%s AS %s", aggSQL, b.formatAlias(agg.Alias)))
+	}
+
+	// Add windows, after the root table's columns unless fields were listed
+	if len(plan.Windows) > 0 {
+		if len(columns) == 0 {
+			columns = append(columns, plan.RootModel.Alias+".*")
+		}
+		for _, window := range plan.Windows {
+			columns = append(columns, fmt.Sprintf("%s AS %s", b.windowSQL(window), b.formatAlias(window.Alias)))
+		}
+	}
+
+	// If no columns selected, use * (restricted to the root table when
+	// joins would otherwise add related columns)
+	if len(columns) == 0 {
+		if len(plan.Joins) > 0 {
+			return fmt.Sprintf("SELECT %s.*", plan.RootModel.Alias), nil
+		}
+		return "SELECT *", nil
+	}
+
+	return "SELECT " + strings.Join(columns, ", "), nil
+}
+
+// buildFromClause generates the FROM part of the query, including joins
+func (b *Builder) buildFromClause(plan *planner.QueryPlan) string {
+	from := fmt.Sprintf("FROM %s %s", b.syntax.QuoteIdentifier(plan.RootModel.Table), plan.RootModel.Alias)
+	for _, join := range plan.Joins {
+		from += fmt.Sprintf(" %s JOIN %s %s ON %s = %s",
+			join.Type, b.syntax.QuoteIdentifier(join.ToTable), join.ToAlias,
+			ColumnSQL(b.syntax, join.On.Left), ColumnSQL(b.syntax, join.On.Right))
+	}
+	return from
+}
+
+// buildWhereClause generates the WHERE part of the query: the filters and,
+// on keyset pages after the first, the position to continue after. It is
+// empty when there is neither.
+func (b *Builder) buildWhereClause(plan *planner.QueryPlan) (string, error) {
+	var conditions []string
+	if plan.Filters != nil {
+		filterSQL, err := b.Filter(plan.Filters)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, filterSQL)
+	}
+	if plan.Keyset != nil && plan.Keyset.After != nil {
+		conditions = append(conditions, b.buildKeysetPredicate(plan.Keyset))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// buildKeysetPredicate renders "sorts after the cursor row", as a row value
+// comparison when every key has the same direction and none can be NULL:
+//
+//	(t0.`created_at`, t0.`id`) < (?, ?)
+//
+// Otherwise it is expanded key by key, following MySQL's and SQLite's NULL
+// placement (NULLs are the smallest value: first ascending, last
+// descending; the opposite of PostgreSQL). Cursor values are bound again
+// wherever they repeat.
+func (b *Builder) buildKeysetPredicate(k *planner.Keyset) string {
+	uniform := true
+	for i, key := range k.Keys {
+		if key.Nullable || k.After[i] == nil || key.Direction != k.Keys[0].Direction {
+			uniform = false
+		}
+	}
+	if uniform {
+		cols := make([]string, len(k.Keys))
+		params := make([]string, len(k.Keys))
+		for i, key := range k.Keys {
+			cols[i] = b.syntax.Value(key.Column)
+		}
+		for i, key := range k.Keys {
+			params[i] = b.syntax.Cast(b.AddParam(k.After[i]), key.Column.DataType)
+		}
+		cmp := ">"
+		if k.Keys[0].Direction == "DESC" {
+			cmp = "<"
+		}
+		if len(cols) == 1 {
+			return fmt.Sprintf("%s %s %s", cols[0], cmp, params[0])
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), cmp, strings.Join(params, ", "))
+	}
+
+	var branches []string
+	for i, key := range k.Keys {
+		// A NULL sorts last descending; nothing follows it on this key
+		if k.After[i] == nil && key.Direction == "DESC" {
+			continue
+		}
+		var conds []string
+		for j := 0; j < i; j++ {
+			col := b.syntax.Value(k.Keys[j].Column)
+			if k.After[j] == nil {
+				conds = append(conds, col+" IS NULL")
+			} else {
+				conds = append(conds, fmt.Sprintf("%s = %s", col, b.syntax.Cast(b.AddParam(k.After[j]), k.Keys[j].Column.DataType)))
+			}
+		}
+		branch := strings.Join(append(conds, b.keysetAfter(key, k.After[i])), " AND ")
+		if len(conds) > 0 {
+			branch = "(" + branch + ")"
+		}
+		branches = append(branches, branch)
+	}
+	if len(branches) == 0 {
+		return "FALSE"
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return "(" + strings.Join(branches, " OR ") + ")"
+}
+
+// keysetAfter renders "col sorts after the cursor value" for one key,
+// binding the value. A NULL cursor value in a descending key has nothing
+// after it; callers skip that case.
+func (b *Builder) keysetAfter(key planner.KeysetKey, value interface{}) string {
+	col := b.syntax.Value(key.Column)
+	switch {
+	case value == nil:
+		return col + " IS NOT NULL"
+	case key.Direction == "DESC" && key.Nullable:
+		return fmt.Sprintf("(%s < %s OR %s IS NULL)", col, b.syntax.Cast(b.AddParam(value), key.Column.DataType), col)
+	case key.Direction == "DESC":
+		return fmt.Sprintf("%s < %s", col, b.syntax.Cast(b.AddParam(value), key.Column.DataType))
+	default:
+		return fmt.Sprintf("%s > %s", col, b.syntax.Cast(b.AddParam(value), key.Column.DataType))
+	}
+}
+
+// Filter recursively builds filter expressions
+func (b *Builder) Filter(expr planner.FilterExpr) (string, error) {
+	switch e := expr.(type) {
+	case *planner.ComparisonFilterIR:
+		return b.buildComparisonFilter(e)
+
+	case *planner.LogicalFilterIR:
+		return b.buildLogicalFilter(e)
+
+	default:
+		return "", fmt.Errorf("unknown filter expression type")
+	}
+}
+
+// rangeComparators maps a range operator to its lower and upper comparisons
+var rangeComparators = map[dsl.FilterOperator][2]string{
+	dsl.OpBetween: {">=", "<="},
+	dsl.OpGTELT:   {">=", "<"},
+	dsl.OpGTLTE:   {">", "<="},
+}
+
+// buildRangeFilter renders a [lower, upper] range. A null bound leaves that
+// side open, so only the other comparison is emitted.
+func (b *Builder) buildRangeFilter(colName string, op dsl.FilterOperator, value interface{}) (string, error) {
+	bounds, ok := value.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected array value, got %T", value)
+	}
+	if len(bounds) != 2 {
+		return "", fmt.Errorf("%s requires exactly 2 values, got %d", op, len(bounds))
+	}
+	lower, upper := bounds[0], bounds[1]
+	cmp := rangeComparators[op]
+
+	switch {
+	case lower != nil && upper != nil:
+		if op == dsl.OpBetween {
+			return fmt.Sprintf("%s BETWEEN %s AND %s", colName, b.AddParam(lower), b.AddParam(upper)), nil
+		}
+		return fmt.Sprintf("(%s %s %s AND %s %s %s)", colName, cmp[0], b.AddParam(lower), colName, cmp[1], b.AddParam(upper)), nil
+	case lower != nil:
+		return fmt.Sprintf("%s %s %s", colName, cmp[0], b.AddParam(lower)), nil
+	case upper != nil:
+		return fmt.Sprintf("%s %s %s", colName, cmp[1], b.AddParam(upper)), nil
+	default:
+		return "", fmt.Errorf("%s requires at least one non-null bound", op)
+	}
+}
+
+// buildInFilter expands an in/not_in list into one placeholder per value.
+// MySQL rejects an empty IN (), so an empty list matches nothing (in) or
+// everything (not_in).
+func (b *Builder) buildInFilter(colName string, f *planner.ComparisonFilterIR) (string, error) {
+	values, ok := f.Value.Value.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("expected array value, got %T", f.Value.Value)
+	}
+	if len(values) == 0 {
+		if f.Operator == dsl.OpIn {
+			return "FALSE", nil
+		}
+		return "TRUE", nil
+	}
+
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = b.syntax.Cast(b.AddParam(v), f.Left.DataType)
+	}
+	op := "IN"
+	if f.Operator == dsl.OpNotIn {
+		op = "NOT IN"
+	}
+	return fmt.Sprintf("%s %s (%s)", colName, op, strings.Join(placeholders, ", ")), nil
+}
+
+// comparisonOperators maps the plain comparison operators to SQL
+var comparisonOperators = map[dsl.FilterOperator]string{
+	dsl.OpEqual:    "=",
+	dsl.OpNotEqual: "!=",
+	dsl.OpGT:       ">",
+	dsl.OpGTE:      ">=",
+	dsl.OpLT:       "<",
+	dsl.OpLTE:      "<=",
+	dsl.OpBefore:   "<",
+	dsl.OpAfter:    ">",
+}
+
+// buildComparisonFilter builds a single comparison filter, leaving the
+// operators the dialects render differently to Syntax.Match
+func (b *Builder) buildComparisonFilter(f *planner.ComparisonFilterIR) (string, error) {
+	var colName string
+	switch {
+	case f.Aggregate != nil:
+		// HAVING compares the aggregate expression itself, like the
+		// PostgreSQL builder
+		aggSQL, err := b.syntax.Aggregate(b, *f.Aggregate)
+		if err != nil {
+			return "", err
+		}
+		colName = aggSQL
+	case dsl.IsJSONOperator(f.Operator):
+		colName = b.syntax.Document(f.Left)
+	default:
+		colName = b.syntax.Value(f.Left)
+	}
+
+	switch f.Operator {
+	case dsl.OpIsNull:
+		return fmt.Sprintf("%s IS NULL", colName), nil
+	case dsl.OpNotNull:
+		return fmt.Sprintf("%s IS NOT NULL", colName), nil
+	case dsl.OpSearch, dsl.OpSimilar:
+		return "", fmt.Errorf("the %s operator is not supported by the %s dialect", f.Operator, b.syntax.Name())
+	}
+
+	if f.Value == nil {
+		return "", fmt.Errorf("value required for %s operator", f.Operator)
+	}
+
+	if sqlOp, ok := comparisonOperators[f.Operator]; ok {
+		param := b.AddParam(f.Value.Value)
+		if f.Operator == dsl.OpEqual || f.Operator == dsl.OpNotEqual {
+			param = b.syntax.Cast(param, f.Left.DataType)
+		}
+		return fmt.Sprintf("%s %s %s", colName, sqlOp, param), nil
+	}
+
+	switch f.Operator {
+	case dsl.OpIn, dsl.OpNotIn:
+		return b.buildInFilter(colName, f)
+
+	case dsl.OpBetween, dsl.OpGTELT, dsl.OpGTLTE:
+		return b.buildRangeFilter(colName, f.Operator, f.Value.Value)
+
+	default:
+		return b.syntax.Match(b, colName, f)
+	}
+}
+
+// buildLogicalFilter builds logical filter expressions (AND/OR/NOT)
+func (b *Builder) buildLogicalFilter(f *planner.LogicalFilterIR) (string, error) {
+	if len(f.Nodes) == 0 {
+		return "", fmt.Errorf("logical filter has no nodes")
+	}
+
+	var parts []string
+	for _, node := range f.Nodes {
+		nodeSQL, err := b.Filter(node)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, nodeSQL)
+	}
+
+	switch f.Op {
+	case "AND":
+		return "(" + strings.Join(parts, " AND ") + ")", nil
+
+	case "OR":
+		return "(" + strings.Join(parts, " OR ") + ")", nil
+
+	case "NOT":
+		if len(parts) != 1 {
+			return "", fmt.Errorf("NOT filter must have exactly one node")
+		}
+		return "NOT (" + parts[0] + ")", nil
+
+	default:
+		return "", fmt.Errorf("unknown logical operator: %s", f.Op)
+	}
+}
+
+// buildOrderByClause generates the ORDER BY part of the query
+func (b *Builder) buildOrderByClause(plan *planner.QueryPlan) (string, error) {
+	var sortCols []string
+	for _, sortExpr := range plan.Sort {
+		var colRef string
+		switch {
+		case sortExpr.Target == planner.SortAggregate && sortExpr.Aggregate != nil:
+			colRef = b.formatAlias(sortExpr.Aggregate.Alias)
+		case sortExpr.Target == planner.SortWindow && sortExpr.Window != nil:
+			colRef = b.formatAlias(sortExpr.Window.Alias)
+		case sortExpr.Target == planner.SortRank || sortExpr.Target == planner.SortSimilarity:
+			return "", fmt.Errorf("sorting by %s is not supported by the %s dialect", strings.ToLower(string(sortExpr.Target)), b.syntax.Name())
+		case sortExpr.Column != nil:
+			colRef = b.syntax.Value(*sortExpr.Column)
+		}
+
+		direction := "ASC"
+		if sortExpr.Direction == "DESC" {
+			direction = "DESC"
+		}
+		sortCols = append(sortCols, colRef+" "+direction)
+	}
+	return "ORDER BY " + strings.Join(sortCols, ", "), nil
+}
+
+// windowSQL builds a window function call with its OVER clause, e.g.
+// LAG(t0.`amount`, 2) OVER (PARTITION BY t0.`user_id` ORDER BY t0.`created_at` ASC)
+func (b *Builder) windowSQL(w planner.WindowExpr) string {
+	var call string
+	switch {
+	case w.Column == nil && w.Function == planner.WinCountFn:
+		call = "COUNT(*)"
+	case w.Column == nil:
+		call = string(w.Function) + "()"
+	case w.Offset > 0:
+		call = fmt.Sprintf("%s(%s, %d)", w.Function, b.syntax.Value(*w.Column), w.Offset)
+	default:
+		call = fmt.Sprintf("%s(%s)", w.Function, b.syntax.Value(*w.Column))
+	}
+
+	var over []string
+	if len(w.PartitionBy) > 0 {
+		cols := make([]string, len(w.PartitionBy))
+		for i, col := range w.PartitionBy {
+			cols[i] = b.syntax.Value(col)
+		}
+		over = append(over, "PARTITION BY "+strings.Join(cols, ", "))
+	}
+	if len(w.OrderBy) > 0 {
+		cols := make([]string, len(w.OrderBy))
+		for i, s := range w.OrderBy {
+			cols[i] = b.syntax.Value(*s.Column) + " " + s.Direction
+		}
+		over = append(over, "ORDER BY "+strings.Join(cols, ", "))
+	}
+	return fmt.Sprintf("%s OVER (%s)", call, strings.Join(over, " "))
+}
@@ -0,0 +1,106 @@
+package sqlgen
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"udv/internal/config"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+	"udv/internal/schema"
+)
+
+// testSyntax quotes in brackets, binds strings upper-cased and renders
+// every operator left to the dialect as MATCH
+type testSyntax struct{}
+
+func (testSyntax) Name() string                       { return "Test" }
+func (testSyntax) QuoteIdentifier(name string) string { return "[" + name + "]" }
+
+func (testSyntax) Param(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		return strings.ToUpper(s)
+	}
+	return value
+}
+
+func (testSyntax) Cast(placeholder string, fieldType planner.FieldType) string { return placeholder }
+func (s testSyntax) Value(ref planner.ColumnRef) string                        { return ColumnSQL(s, ref) }
+func (s testSyntax) Document(ref planner.ColumnRef) string                     { return ColumnSQL(s, ref) }
+func (s testSyntax) Group(groupExpr planner.GroupExpr) (string, error) {
+	return ColumnSQL(s, groupExpr.Column), nil
+}
+
+func (testSyntax) Match(b *Builder, colName string, f *planner.ComparisonFilterIR) (string, error) {
+	return fmt.Sprintf("%s MATCH %s", colName, b.AddParam(f.Value.Value)), nil
+}
+
+func (testSyntax) Aggregate(b *Builder, agg planner.AggregateExpr) (string, error) {
+	return "COUNT(*)", nil
+}
+
+func (testSyntax) Limit(b *Builder, plan *planner.QueryPlan) string {
+	return "LIMIT " + b.AddParam(plan.Pagination.Limit)
+}
+
+func setupTestRegistry() *schema.Registry {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer", Nullable: false},
+					{Name: "status", Type: "string", Nullable: false},
+					{Name: "amount", Type: "decimal", Nullable: false},
+				},
+			},
+		},
+	}
+
+	reg := schema.NewRegistry()
+	reg.LoadFromConfig(cfg)
+	return reg
+}
+
+// TestBuildQuery_Syntax checks the builder renders through its Syntax:
+// quoting, parameter conversion, delegated operators and pagination
+func TestBuildQuery_Syntax(t *testing.T) {
+	plan, err := planner.NewPlanner(setupTestRegistry()).PlanQuery(&dsl.Query{
+		Model:  "orders",
+		Fields: []string{"id"},
+		Filters: &dsl.LogicalFilter{And: []dsl.FilterExpr{
+			&dsl.ComparisonFilter{Field: "status", Op: dsl.OpIn, Value: []interface{}{"paid", "shipped"}},
+			&dsl.ComparisonFilter{Field: "status", Op: dsl.OpStartsWith, Value: "pa"},
+			&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGTELT, Value: []interface{}{10, nil}},
+		}},
+		Sort:       []dsl.Sort{{Field: "id", Direction: dsl.SortDesc}},
+		Pagination: &dsl.Pagination{Limit: 5},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+
+	sql, params, err := NewBuilder(testSyntax{}).BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+	wantSQL := `SELECT t0.[id] FROM [orders] t0 WHERE (t0.[status] IN (?, ?) AND t0.[status] MATCH ? AND t0.[amount] >= ?) ORDER BY t0.[id] DESC LIMIT ?;`
+	if sql != wantSQL {
+		t.Errorf("BuildQuery() SQL =\n%s\nwant\n%s", sql, wantSQL)
+	}
+	wantParams := []interface{}{"PAID", "SHIPPED", "PA", int64(10), 5}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("BuildQuery() params = %#v, want %#v", params, wantParams)
+	}
+}
+
+func TestBuildQuery_UnsupportedNamesDialect(t *testing.T) {
+	b := NewBuilder(testSyntax{})
+	if _, _, err := b.BuildTimeSeriesQuery(nil); err == nil || !strings.Contains(err.Error(), "Test dialect") {
+		t.Errorf("BuildTimeSeriesQuery() error = %v, want it to name the dialect", err)
+	}
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"udv/internal/adapter"
+	"udv/internal/planner"
+
+	_ "modernc.org/sqlite"
+)
+
+// Dialect renders query plans as SQLite SQL (SQLite 3.30+, for aggregate
+// FILTER clauses). Each build uses its own QueryBuilder, so a Dialect is
+// safe for concurrent use.
+type Dialect struct{}
+
+var _ adapter.Dialect = Dialect{}
+
+// Name implements adapter.Dialect
+func (Dialect) Name() string {
+	return "SQLite"
+}
+
+// BuildQuery implements adapter.Dialect
+func (Dialect) BuildQuery(plan *planner.QueryPlan) (string, []interface{}, error) {
+	return NewQueryBuilder().BuildQuery(plan)
+}
+
+// BuildTimeSeriesQuery implements adapter.Dialect
+func (Dialect) BuildTimeSeriesQuery(plan *planner.TimeSeriesPlan) (string, []interface{}, error) {
+	return NewQueryBuilder().BuildTimeSeriesQuery(plan)
+}
+
+// QuoteIdentifier implements adapter.Dialect
+func (Dialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// TypeCast implements adapter.Dialect
+func (Dialect) TypeCast(placeholder string, fieldType planner.FieldType) string {
+	return typeCast(placeholder, fieldType)
+}
+
+// Capabilities implements adapter.Dialect. Full-text search needs FTS
+// virtual tables, and SQLite has neither statistical nor percentile
+// aggregates built in.
+func (Dialect) Capabilities() adapter.Capabilities {
+	return adapter.Capabilities{
+		JSONPaths:         true,
+		WindowFunctions:   true,
+		FilteredAggregate: true,
+		ArrayAggregates:   true,
+	}
+}
+
+// Adapter is the SQLite adapter.Adapter
+type Adapter struct {
+	db *Database
+}
+
+var (
+	_ adapter.Adapter  = (*Adapter)(nil)
+	_ adapter.Executor = (*Database)(nil)
+	_ adapter.Counter  = (*Database)(nil)
+)
+
+// NewAdapter returns the SQLite adapter running queries on db, or only
+// rendering them when db is nil
+func NewAdapter(db *Database) *Adapter {
+	return &Adapter{db: db}
+}
+
+// Dialect implements adapter.Adapter
+func (a *Adapter) Dialect() adapter.Dialect {
+	return Dialect{}
+}
+
+// Executor implements adapter.Adapter
+func (a *Adapter) Executor() adapter.Executor {
+	if a.db == nil {
+		return nil
+	}
+	return a.db
+}
+
+// IsDatabasePath reports whether a connection string names a SQLite
+// database: a sqlite: or file: URI, or a path ending in .db, .sqlite or
+// .sqlite3
+func IsDatabasePath(dsn string) bool {
+	if strings.HasPrefix(dsn, "sqlite:") || strings.HasPrefix(dsn, "file:") {
+		return true
+	}
+	for _, ext := range []string{".db", ".sqlite", ".sqlite3"} {
+		if strings.HasSuffix(dsn, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenDB opens a SQLite database file with the pure-Go modernc.org/sqlite
+// driver. Plain paths must exist, so a typo does not silently create an
+// empty database.
+func OpenDB(dsn string) (*sql.DB, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(dsn, "sqlite://"), "sqlite:")
+	if !strings.HasPrefix(path, "file:") {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
+}
+
+// Database runs SQLite queries on a database/sql connection pool
+type Database struct {
+	db *sql.DB
+}
+
+// Open opens a SQLite database file for querying, see OpenDB
+func Open(dsn string) (*Database, error) {
+	db, err := OpenDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{db: db}, nil
+}
+
+// NewDatabase wraps an open connection pool, checking that it is reachable
+func NewDatabase(db *sql.DB) (*Database, error) {
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return &Database{db: db}, nil
+}
+
+// Close closes the database connection
+func (d *Database) Close() error {
+	return d.db.Close()
+}
+
+// Fetch implements adapter.Executor: it runs a query and returns all its
+// rows
+func (d *Database) Fetch(ctx context.Context, sql string, args ...interface{}) ([]adapter.Row, error) {
+	var results []adapter.Row
+	err := d.Stream(ctx, sql, args, func(row adapter.Row) error {
+		results = append(results, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Stream implements adapter.Executor: it runs a query and hands each row to
+// fn as it is scanned
+func (d *Database) Stream(ctx context.Context, sql string, args []interface{}, fn func(adapter.Row) error) error {
+	rows, err := d.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("query execution failed: %w", err)
+	}
+	defer rows.Close()
+
+	return adapter.ScanRows(rows, fn)
+}
+
+// CountRows implements adapter.Counter. SQLite keeps no row count
+// statistics by default, so estimates are exact counts too; local files
+// are small enough for that to be cheap.
+func (d *Database) CountRows(ctx context.Context, plan *planner.QueryPlan, estimate bool) (int64, error) {
+	sql, params, err := NewQueryBuilder().BuildCountQuery(plan)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := d.db.QueryRowContext(ctx, sql, params...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count query failed: %w", err)
+	}
+	return total, nil
+}
@@ -0,0 +1,200 @@
+package sqlite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"udv/internal/dsl"
+	"udv/internal/planner"
+	"udv/internal/schema_processor"
+)
+
+// createTestDatabase writes a small users/orders database to a temp file
+func createTestDatabase(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "shop.db")
+	// OpenDB only opens existing files; an empty file is an empty database
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	db, err := OpenDB(path)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	defer db.Close()
+
+	stmts := []string{
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL)`,
+		`CREATE TABLE orders (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			status TEXT NOT NULL,
+			amount REAL NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			is_paid BOOLEAN NOT NULL,
+			metadata JSON
+		)`,
+		`INSERT INTO users (id, email) VALUES (1, 'ada@example.com'), (2, 'bob@example.com')`,
+		`INSERT INTO orders (id, user_id, status, amount, created_at, is_paid) VALUES
+			(1, 1, 'PAID', 120.5, '2024-01-05 10:00:00', 1),
+			(2, 1, 'PENDING', 40, '2024-01-06 11:00:00', 0),
+			(3, 2, 'PAID', 75, '2024-02-01 09:30:00', 1)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Exec(%q) error: %v", stmt, err)
+		}
+	}
+	return path
+}
+
+// TestOpenDB_Introspection reads the schema of a database file
+func TestOpenDB_Introspection(t *testing.T) {
+	db, err := OpenDB(createTestDatabase(t))
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	defer db.Close()
+
+	sp := schema_processor.NewSQLiteSchemaProcessor(db)
+	tables, err := sp.GetAllTables()
+	if err != nil {
+		t.Fatalf("GetAllTables error: %v", err)
+	}
+	if want := []string{"orders", "users"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("GetAllTables() = %v, want %v", tables, want)
+	}
+
+	fks, err := sp.GetForeignKeys("orders")
+	if err != nil {
+		t.Fatalf("GetForeignKeys error: %v", err)
+	}
+	want := []schema_processor.ForeignKeyInfo{
+		{ConstraintName: "orders_user_id_fkey", Table: "orders", Column: "user_id", RefTable: "users", RefColumn: "id"},
+	}
+	if !reflect.DeepEqual(fks, want) {
+		t.Errorf("GetForeignKeys() = %+v, want %+v", fks, want)
+	}
+}
+
+// TestOpenDB_URIs opens the same file through each accepted spelling
+func TestOpenDB_URIs(t *testing.T) {
+	path := createTestDatabase(t)
+	for _, dsn := range []string{path, "sqlite:" + path, "sqlite://" + path, "file:" + path + "?mode=ro"} {
+		db, err := OpenDB(dsn)
+		if err != nil {
+			t.Errorf("OpenDB(%q) error: %v", dsn, err)
+			continue
+		}
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&n); err != nil || n != 3 {
+			t.Errorf("OpenDB(%q) counted %d orders, err = %v; want 3", dsn, n, err)
+		}
+		db.Close()
+	}
+}
+
+func TestOpenDB_MissingFile(t *testing.T) {
+	if _, err := OpenDB(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("OpenDB() error = nil, want error for a missing file")
+	}
+}
+
+// TestDatabase_Fetch runs a rendered query against a database file
+func TestDatabase_Fetch(t *testing.T) {
+	db, err := Open(createTestDatabase(t))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+
+	plan, err := planner.NewPlanner(setupTestRegistry()).PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Fields:  []string{"id", "status"},
+		Filters: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		Sort:    []dsl.Sort{{Field: "id", Direction: "desc"}},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery error: %v", err)
+	}
+	sql, params, err := Dialect{}.BuildQuery(plan)
+	if err != nil {
+		t.Fatalf("BuildQuery error: %v", err)
+	}
+
+	rows, err := db.Fetch(context.Background(), sql, params...)
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"id": int64(3), "status": "PAID"},
+		{"id": int64(1), "status": "PAID"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Fetch() returned %d rows, want %d: %v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(map[string]interface{}(rows[i]), want[i]) {
+			t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+		}
+	}
+}
+
+// TestDatabase_FetchTimestamps filters on timestamps stored as text, which
+// SQLite compares lexically with the bound parameters
+func TestDatabase_FetchTimestamps(t *testing.T) {
+	db, err := Open(createTestDatabase(t))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name   string
+		filter dsl.FilterExpr
+		want   []int64
+	}{
+		{"equal", &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpEqual, Value: "2024-01-05T10:00:00Z"}, []int64{1}},
+		{"gte", &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpGTE, Value: "2024-01-05T10:00:00Z"}, []int64{1, 2, 3}},
+		{"before with offset", &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpBefore, Value: "2024-01-06T11:00:00+01:00"}, []int64{1}},
+		{"between dates", &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpBetween, Value: []interface{}{"2024-01-06", "2024-02-01T09:30:00Z"}}, []int64{2, 3}},
+	}
+
+	reg := setupTestRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &dsl.Query{
+				Model:   "orders",
+				Fields:  []string{"id"},
+				Filters: tt.filter,
+				Sort:    []dsl.Sort{{Field: "id"}},
+			}
+			if err := dsl.NewValidator(reg).ValidateQuery(q); err != nil {
+				t.Fatalf("ValidateQuery error: %v", err)
+			}
+			plan, err := planner.NewPlanner(reg).PlanQuery(q)
+			if err != nil {
+				t.Fatalf("PlanQuery error: %v", err)
+			}
+			sql, params, err := Dialect{}.BuildQuery(plan)
+			if err != nil {
+				t.Fatalf("BuildQuery error: %v", err)
+			}
+			rows, err := db.Fetch(context.Background(), sql, params...)
+			if err != nil {
+				t.Fatalf("Fetch error: %v", err)
+			}
+			ids := []int64{}
+			for _, row := range rows {
+				ids = append(ids, row["id"].(int64))
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ids = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package sqlite
+
+// Package sqlite implements SQLite-specific query generation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"udv/internal/adapter/sqlgen"
+	"udv/internal/dsl"
+	"udv/internal/planner"
+)
+
+// quoteIdentifier quotes a SQL identifier in double quotes, doubling
+// embedded quotes
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a SQL string literal; SQLite has no backslash escapes
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// columnSQL renders a resolved column reference as alias."column"
+func columnSQL(ref planner.ColumnRef) string {
+	return ref.TableAlias + "." + quoteIdentifier(ref.ColumnName)
+}
+
+// typeCast wraps a parameter placeholder where SQLite cannot compare the
+// bound value as is: JSON documents are stored as text, so a bound document
+// is minified with json() like the stored ones
+func typeCast(placeholder string, fieldType planner.FieldType) string {
+	if fieldType == planner.TypeJSON {
+		return fmt.Sprintf("json(%s)", placeholder)
+	}
+	return placeholder
+}
+
+// jsonPath renders JSON path segments as a SQLite path expression, keys
+// quoted and numeric segments as array indexes: $."items"[0]."sku"
+func jsonPath(segments []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range segments {
+		if n, err := strconv.Atoi(seg); err == nil && n >= 0 {
+			b.WriteString("[" + strconv.Itoa(n) + "]")
+			continue
+		}
+		b.WriteString(`."` + strings.ReplaceAll(seg, `"`, `\"`) + `"`)
+	}
+	return b.String()
+}
+
+// jsonCastTypes maps the cast type of a JSON path to the SQLite expression
+// its extracted value is converted with
+var jsonCastTypes = map[planner.FieldType]string{
+	planner.TypeInteger:   "CAST(%s AS INTEGER)",
+	planner.TypeInt:       "CAST(%s AS INTEGER)",
+	planner.TypeFloat:     "CAST(%s AS REAL)",
+	planner.TypeDecimal:   "CAST(%s AS NUMERIC)",
+	planner.TypeTimestamp: "datetime(%s)",
+	planner.TypeDate:      "date(%s)",
+}
+
+// truncFormats maps the granularities strftime can truncate to with a
+// format string; week and quarter are computed
+var truncFormats = map[string]string{
+	"minute": "%Y-%m-%d %H:%M:00",
+	"hour":   "%Y-%m-%d %H:00:00",
+	"day":    "%Y-%m-%d 00:00:00",
+	"month":  "%Y-%m-01 00:00:00",
+	"year":   "%Y-01-01 00:00:00",
+}
+
+// timestampLayout is how SQLite's date functions write a timestamp. SQLite
+// compares timestamps stored as text lexically, so time parameters are
+// bound in the same UTC layout rather than the driver's, which appends a
+// zone offset.
+const timestampLayout = "2006-01-02 15:04:05.999999999"
+
+// QueryBuilder builds parameterized SQLite queries from query plans with the
+// shared ?-placeholder builder
+type QueryBuilder struct {
+	*sqlgen.Builder
+}
+
+// NewQueryBuilder creates a new query builder
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{Builder: sqlgen.NewBuilder(syntax{})}
+}
+
+// syntax implements sqlgen.Syntax for SQLite
+type syntax struct{}
+
+// Name implements sqlgen.Syntax
+func (syntax) Name() string {
+	return "SQLite"
+}
+
+// QuoteIdentifier implements sqlgen.Syntax
+func (syntax) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// Param implements sqlgen.Syntax, binding times in timestampLayout
+func (syntax) Param(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(timestampLayout)
+	}
+	return value
+}
+
+// Cast implements sqlgen.Syntax
+func (syntax) Cast(placeholder string, fieldType planner.FieldType) string {
+	return typeCast(placeholder, fieldType)
+}
+
+// Value implements sqlgen.Syntax
+func (syntax) Value(ref planner.ColumnRef) string {
+	return valueSQL(ref)
+}
+
+// Document implements sqlgen.Syntax
+func (syntax) Document(ref planner.ColumnRef) string {
+	return documentSQL(ref)
+}
+
+// Group implements sqlgen.Syntax
+func (syntax) Group(groupExpr planner.GroupExpr) (string, error) {
+	return groupSQL(groupExpr)
+}
+
+// valueSQL renders a column reference as a value of its DataType. A JSON
+// path is read with json_extract, which already returns SQL values (text
+// unquoted, true and false as 1 and 0), and cast to the path's type:
+//
+//	CAST(json_extract(t0."metadata", '$."weight"') AS NUMERIC)
+//
+// Paths are literals rather than parameters so that a grouped path is the
+// identical expression in SELECT and GROUP BY.
+func valueSQL(ref planner.ColumnRef) string {
+	if len(ref.JSONPath) == 0 {
+		return columnSQL(ref)
+	}
+
+	value := documentSQL(ref)
+	if cast, ok := jsonCastTypes[ref.DataType]; ok {
+		return fmt.Sprintf(cast, value)
+	}
+	return value
+}
+
+// documentSQL renders a column reference as a JSON document, for the JSON
+// operators: the column itself, or the value at its JSON path
+func documentSQL(ref planner.ColumnRef) string {
+	if len(ref.JSONPath) == 0 {
+		return columnSQL(ref)
+	}
+	return fmt.Sprintf("json_extract(%s, %s)", columnSQL(ref), quoteLiteral(jsonPath(ref.JSONPath)))
+}
+
+// groupSQL renders a GROUP BY key. Bucketed keys format the column with
+// strftime, which yields the bucket start as text:
+//
+//	strftime('%Y-%m-%d 00:00:00', t0."created_at")
+//
+// SQLite has no time zone database, so buckets are always computed in UTC.
+// Granularity is validated and rendered as a literal, so the key is the
+// identical expression in SELECT and GROUP BY.
+func groupSQL(groupExpr planner.GroupExpr) (string, error) {
+	col := valueSQL(groupExpr.Column)
+	if groupExpr.Granularity == "" {
+		return col, nil
+	}
+
+	switch groupExpr.Timezone {
+	case "", "UTC", "Etc/UTC":
+	default:
+		return "", fmt.Errorf("time zone %s is not supported by the SQLite dialect, which buckets in UTC", groupExpr.Timezone)
+	}
+
+	switch groupExpr.Granularity {
+	case "week":
+		// ISO weeks start on Monday: step to the next Sunday (or stay on
+		// one), then back six days
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d 00:00:00', %s, 'weekday 0', '-6 days')", col), nil
+	case "quarter":
+		return fmt.Sprintf("strftime('%%Y-', %s) || printf('%%02d', (CAST(strftime('%%m', %s) AS INTEGER) + 2) / 3 * 3 - 2) || '-01 00:00:00'", col, col), nil
+	}
+	format, ok := truncFormats[groupExpr.Granularity]
+	if !ok {
+		return "", fmt.Errorf("unsupported granularity: %s", groupExpr.Granularity)
+	}
+	return fmt.Sprintf("strftime('%s', %s)", format, col), nil
+}
+
+// globEscaper escapes the GLOB wildcards of literal text; a bracket
+// expression holding a single character matches that character
+var globEscaper = strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+
+// likeToGlob rewrites a LIKE pattern (with PostgreSQL's default backslash
+// escape) as a GLOB pattern: % becomes *, _ becomes ?, and everything else
+// matches literally
+func likeToGlob(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(globEscaper.Replace(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteByte('*')
+		case r == '_':
+			b.WriteByte('?')
+		default:
+			b.WriteString(globEscaper.Replace(string(r)))
+		}
+	}
+	if escaped {
+		b.WriteString(`\`)
+	}
+	return b.String()
+}
+
+// patternOperators maps the pattern operators to how their value becomes a
+// GLOB pattern. As in the other dialects the value is a LIKE pattern, so %
+// and _ in it stay wildcards.
+var patternOperators = map[dsl.FilterOperator]func(string) string{
+	dsl.OpStartsWith: func(s string) string { return likeToGlob(s) + "*" },
+	dsl.OpEndsWith:   func(s string) string { return "*" + likeToGlob(s) },
+	dsl.OpContains:   func(s string) string { return "*" + likeToGlob(s) + "*" },
+}
+
+// Match implements sqlgen.Syntax. SQLite's LIKE ignores ASCII case, so
+// the case-sensitive pattern operators (like, starts_with, ends_with,
+// contains) render as GLOB, and ilike as LIKE.
+func (syntax) Match(b *sqlgen.Builder, colName string, f *planner.ComparisonFilterIR) (string, error) {
+	if pattern, ok := patternOperators[f.Operator]; ok {
+		s, ok := f.Value.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("%s requires a string value, got %T", f.Operator, f.Value.Value)
+		}
+		return fmt.Sprintf("%s GLOB %s", colName, b.AddParam(pattern(s))), nil
+	}
+
+	switch f.Operator {
+	case dsl.OpLike:
+		s, ok := f.Value.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("like requires a string value, got %T", f.Value.Value)
+		}
+		return fmt.Sprintf("%s GLOB %s", colName, b.AddParam(likeToGlob(s))), nil
+
+	case dsl.OpILike:
+		// SQLite has no default LIKE escape; declare PostgreSQL's
+		return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, colName, b.AddParam(f.Value.Value)), nil
+
+	case dsl.OpHasKey:
+		key, ok := f.Value.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("has_key requires a string key, got %T", f.Value.Value)
+		}
+		return fmt.Sprintf("json_type(%s, %s) IS NOT NULL", colName, b.AddParam(jsonPath([]string{key}))), nil
+
+	case dsl.OpJSONContains:
+		return "", fmt.Errorf("the %s operator is not supported by the SQLite dialect", f.Operator)
+
+	default:
+		return "", fmt.Errorf("unknown operator: %s", f.Operator)
+	}
+}
+
+// Limit implements sqlgen.Syntax with LIMIT ? OFFSET ?
+func (syntax) Limit(b *sqlgen.Builder, plan *planner.QueryPlan) string {
+	limit := b.AddParam(plan.Pagination.Limit)
+	// Keyset pages start from their WHERE position, never an offset
+	if plan.Keyset != nil {
+		return "LIMIT " + limit
+	}
+	return fmt.Sprintf("LIMIT %s OFFSET %s", limit, b.AddParam(plan.Pagination.Offset))
+}
+
+// Aggregate implements sqlgen.Syntax, rendering a filter as a FILTER
+// (WHERE ...) clause (SQLite 3.30+)
+func (syntax) Aggregate(b *sqlgen.Builder, agg planner.AggregateExpr) (string, error) {
+	arg := "*"
+	if agg.Column != nil {
+		arg = valueSQL(*agg.Column)
+	}
+
+	var call string
+	switch agg.Function {
+	case planner.AggCountFn:
+		call = fmt.Sprintf("COUNT(%s)", arg)
+	case planner.AggSumFn:
+		call = fmt.Sprintf("SUM(%s)", arg)
+	case planner.AggAvgFn:
+		call = fmt.Sprintf("AVG(%s)", arg)
+	case planner.AggMinFn:
+		call = fmt.Sprintf("MIN(%s)", arg)
+	case planner.AggMaxFn:
+		call = fmt.Sprintf("MAX(%s)", arg)
+	case planner.AggCountDistinctFn:
+		call = fmt.Sprintf("COUNT(DISTINCT %s)", arg)
+	case planner.AggStringAggFn:
+		call = fmt.Sprintf("GROUP_CONCAT(%s, %s)", arg, quoteLiteral(agg.Separator))
+	case planner.AggArrayAggFn:
+		call = fmt.Sprintf("json_group_array(%s)", arg)
+	case planner.AggBoolAndFn:
+		// Booleans are stored as 0 and 1
+		call = fmt.Sprintf("MIN(%s)", arg)
+	case planner.AggBoolOrFn:
+		call = fmt.Sprintf("MAX(%s)", arg)
+	default:
+		return "", fmt.Errorf("aggregate %s is not supported by the SQLite dialect", strings.ToLower(string(agg.Function)))
+	}
+
+	if agg.Filter == nil {
+		return call, nil
+	}
+	cond, err := b.Filter(agg.Filter)
+	if err != nil {
+		return "", fmt.Errorf("aggregate %s filter: %w", agg.Alias, err)
+	}
+	return fmt.Sprintf("%s FILTER (WHERE %s)", call, cond), nil
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+    "context"
     "encoding/json"
     "fmt"
+    "io"
     "net/http"
+    "time"
 
-    "udv/internal/adapter/postgres"
+    "udv/internal/adapter"
     "udv/internal/dsl"
     "udv/internal/planner"
     "udv/internal/schema"
@@ -16,25 +19,34 @@ type API struct {
     registry  *schema.Registry
     validator *dsl.Validator
     planner   *planner.Planner
-    builder   *postgres.QueryBuilder
-    db        *postgres.Database
+    dialect   adapter.Dialect
+    db        adapter.Executor // nil in SQL-generation-only mode
 }
 
-// New creates a new API instance with optional database connection
-func New(reg *schema.Registry, db *postgres.Database) *API {
+// New creates a new API instance rendering queries with the adapter's
+// dialect and running them on its executor, if it has one
+func New(reg *schema.Registry, a adapter.Adapter) *API {
     return &API{
         registry:  reg,
         validator: dsl.NewValidator(reg),
         planner:   planner.NewPlanner(reg),
-        builder:   postgres.NewQueryBuilder(),
-        db:        db,
+        dialect:   a.Dialect(),
+        db:        a.Executor(),
     }
 }
 
+// SetMaxFilterDepth limits how deeply and/or/not groups may be nested in
+// incoming queries
+func (a *API) SetMaxFilterDepth(depth int) {
+    a.validator.SetMaxFilterDepth(depth)
+}
+
 // RegisterRoutes registers HTTP handlers onto the provided mux
 func (a *API) RegisterRoutes(mux *http.ServeMux) {
     mux.HandleFunc("/models", a.handleModels)
     mux.HandleFunc("/query", a.handleQuery)
+    mux.HandleFunc("/timeseries", a.handleTimeSeries)
+    mux.HandleFunc("/models/{model}/fields/{field}/values", a.handleFieldValues)
 }
 
 // handleModels returns a JSON list of models and their fields
@@ -51,11 +63,19 @@ func (a *API) handleModels(w http.ResponseWriter, r *http.Request) {
         Type string `json:"type"`
     }
 
+    type relationResp struct {
+        Name  string `json:"name"`
+        Type  string `json:"type"`
+        Model string `json:"model"`
+    }
+
     type modelResp struct {
-        Name       string      `json:"name"`
-        Table      string      `json:"table"`
-        PrimaryKey string      `json:"primary_key"`
-        Fields     []fieldResp `json:"fields"`
+        Name       string         `json:"name"`
+        Table      string         `json:"table"`
+        PrimaryKey string         `json:"primary_key"`
+        Fields     []fieldResp    `json:"fields"`
+        Relations  []relationResp `json:"relations"`
+        Searchable bool           `json:"searchable"` // accepts the search operator without a field
     }
 
     var out []modelResp
@@ -67,14 +87,20 @@ func (a *API) handleModels(w http.ResponseWriter, r *http.Request) {
             Table:      "",
             PrimaryKey: "",
             Fields:     []fieldResp{},
+            Relations:  []relationResp{},
         }
         if md != nil {
             fr.Table = md.Table
             fr.PrimaryKey = md.PrimaryKey
+            fr.Searchable = md.Searchable()
         }
         for _, f := range fields {
             fr.Fields = append(fr.Fields, fieldResp{Name: f.Name, Type: f.Type})
         }
+        relations, _ := a.registry.ListRelations(m)
+        for _, rel := range relations {
+            fr.Relations = append(fr.Relations, relationResp{Name: rel.Name, Type: string(rel.Type), Model: rel.TargetModel})
+        }
         out = append(out, fr)
     }
 
@@ -82,21 +108,52 @@ func (a *API) handleModels(w http.ResponseWriter, r *http.Request) {
     _ = json.NewEncoder(w).Encode(out)
 }
 
+// queryMeta is the metadata envelope of a /query response. Total is only
+// present when a count was requested and could be computed.
+type queryMeta struct {
+    Total     *int64        `json:"total,omitempty"`
+    TotalMode dsl.CountMode `json:"total_mode,omitempty"`
+    Limit     int           `json:"limit"`
+    Offset    int           `json:"offset"`
+    Returned  int           `json:"returned"`
+    Executed  bool          `json:"executed"`
+    Timing    queryTiming   `json:"timing"`
+}
+
+// queryTiming reports where a request spent its time, in milliseconds.
+// Steps that did not run report 0.
+type queryTiming struct {
+    PlanMs  float64 `json:"plan_ms"`  // validate, plan and build SQL
+    QueryMs float64 `json:"query_ms"` // run the page query
+    CountMs float64 `json:"count_ms"` // compute the total
+    TotalMs float64 `json:"total_ms"`
+}
+
+// millis converts a duration to fractional milliseconds, to the microsecond
+func millis(d time.Duration) float64 {
+    return float64(d.Microseconds()) / 1000
+}
+
 // handleQuery accepts a DSL query JSON, validates, plans, and returns SQL+params
 func (a *API) handleQuery(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
         http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
         return
     }
+    start := time.Now()
     // Decode into a raw structure so we can handle the FilterExpr interface
     type rawQuery struct {
         Model      string          `json:"model"`
         Fields     []string        `json:"fields,omitempty"`
         Filters    json.RawMessage `json:"filters,omitempty"`
-        GroupBy    []string        `json:"group_by,omitempty"`
+        GroupBy    []dsl.GroupBy   `json:"group_by,omitempty"`
         Aggregates []dsl.Aggregate `json:"aggregates,omitempty"`
+        Having     json.RawMessage `json:"having,omitempty"`
+        Windows    []dsl.Window    `json:"windows,omitempty"`
         Sort       []dsl.Sort      `json:"sort,omitempty"`
         Pagination *dsl.Pagination `json:"pagination,omitempty"`
+        Timezone   string          `json:"timezone,omitempty"`
+        Count      dsl.CountMode   `json:"count,omitempty"`
     }
 
     var rq rawQuery
@@ -110,25 +167,30 @@ func (a *API) handleQuery(w http.ResponseWriter, r *http.Request) {
         Fields:     rq.Fields,
         GroupBy:    rq.GroupBy,
         Aggregates: rq.Aggregates,
+        Windows:    rq.Windows,
         Sort:       rq.Sort,
         Pagination: rq.Pagination,
+        Timezone:   rq.Timezone,
+        Count:      rq.Count,
     }
 
-    // Parse filters if provided
+    // Parse filters if provided; and/or/not groups may nest arbitrarily
     if len(rq.Filters) > 0 {
-        // try ComparisonFilter first
-        var cf dsl.ComparisonFilter
-        if err := json.Unmarshal(rq.Filters, &cf); err == nil && cf.Field != "" {
-            q.Filters = &cf
-        } else {
-            var lf dsl.LogicalFilter
-            if err := json.Unmarshal(rq.Filters, &lf); err == nil {
-                q.Filters = &lf
-            } else {
-                http.Error(w, "invalid filters format", http.StatusBadRequest)
-                return
-            }
+        filters, err := dsl.ParseFilterExpr(rq.Filters)
+        if err != nil {
+            http.Error(w, fmt.Sprintf("invalid filters format: %v", err), http.StatusBadRequest)
+            return
+        }
+        q.Filters = filters
+    }
+
+    if len(rq.Having) > 0 {
+        having, err := dsl.ParseFilterExpr(rq.Having)
+        if err != nil {
+            http.Error(w, fmt.Sprintf("invalid having format: %v", err), http.StatusBadRequest)
+            return
         }
+        q.Having = having
     }
 
     if err := a.validator.ValidateQuery(&q); err != nil {
@@ -142,29 +204,296 @@ func (a *API) handleQuery(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    sql, params, err := a.builder.BuildQuery(plan)
+    if err := a.checkPlan(r.Context(), plan); err != nil {
+        http.Error(w, err.Error(), http.StatusNotImplemented)
+        return
+    }
+
+    sql, params, err := a.dialect.BuildQuery(plan)
     if err != nil {
         http.Error(w, fmt.Sprintf("sql build error: %v", err), http.StatusInternalServerError)
         return
     }
 
+    meta := queryMeta{
+        Limit:  plan.Pagination.Limit,
+        Offset: plan.Pagination.Offset,
+    }
+    meta.Timing.PlanMs = millis(time.Since(start))
+
     resp := map[string]interface{}{
         "sql":    sql,
         "params": params,
     }
+    if len(plan.Resolved) > 0 {
+        resp["resolved"] = resolvedValues(plan.Resolved)
+    }
 
     // Execute query if database is available
     if a.db != nil {
-        rows, err := a.db.ExecuteAndFetchRows(sql, params...)
+        queryStart := time.Now()
+        rows, err := a.fetch(r.Context(), plan, sql, params)
+        meta.Timing.QueryMs = millis(time.Since(queryStart))
         if err != nil {
             fmt.Printf("Warning: Failed to execute query: %v\n", err)
             // Don't fail the request - still return SQL and params
             // Frontend can see what query would have been executed
         } else {
             resp["data"] = rows
+            meta.Executed = true
+            meta.Returned = len(rows)
+            // A full keyset page may have more after it
+            if plan.Keyset != nil && len(rows) == plan.Pagination.Limit {
+                cursor, err := plan.Keyset.NextCursor(rows[len(rows)-1])
+                if err != nil {
+                    fmt.Printf("Warning: Failed to build next cursor: %v\n", err)
+                } else {
+                    resp["next_cursor"] = cursor
+                }
+            }
+        }
+
+        if counter, ok := a.db.(adapter.Counter); ok && q.Count != dsl.CountNone {
+            countStart := time.Now()
+            total, err := counter.CountRows(r.Context(), plan, q.Count == dsl.CountEstimate)
+            meta.Timing.CountMs = millis(time.Since(countStart))
+            if err != nil {
+                fmt.Printf("Warning: Failed to count query rows: %v\n", err)
+            } else {
+                meta.Total = &total
+                meta.TotalMode = q.Count
+            }
+        }
+    }
+
+    meta.Timing.TotalMs = millis(time.Since(start))
+    resp["meta"] = meta
+
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleTimeSeries accepts a time series request and returns one row per
+// bucket of the requested range, including buckets with no data
+func (a *API) handleTimeSeries(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    type rawTimeSeries struct {
+        Model      string              `json:"model"`
+        Field      string              `json:"field"`
+        Interval   dsl.TimeGranularity `json:"interval"`
+        Timezone   string              `json:"timezone,omitempty"`
+        Range      dsl.TimeRange       `json:"range"`
+        Filters    json.RawMessage     `json:"filters,omitempty"`
+        Aggregates []dsl.Aggregate     `json:"aggregates"`
+        Fill       dsl.FillMode        `json:"fill,omitempty"`
+    }
+
+    var rq rawTimeSeries
+    if err := json.NewDecoder(r.Body).Decode(&rq); err != nil {
+        http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+        return
+    }
+
+    q := dsl.TimeSeriesQuery{
+        Model:      rq.Model,
+        Field:      rq.Field,
+        Interval:   rq.Interval,
+        Timezone:   rq.Timezone,
+        Range:      rq.Range,
+        Aggregates: rq.Aggregates,
+        Fill:       rq.Fill,
+    }
+
+    if len(rq.Filters) > 0 {
+        filters, err := dsl.ParseFilterExpr(rq.Filters)
+        if err != nil {
+            http.Error(w, fmt.Sprintf("invalid filters format: %v", err), http.StatusBadRequest)
+            return
+        }
+        q.Filters = filters
+    }
+
+    if err := a.validator.ValidateTimeSeries(&q); err != nil {
+        http.Error(w, fmt.Sprintf("validation error: %v", err), http.StatusBadRequest)
+        return
+    }
+
+    plan, err := a.planner.PlanTimeSeries(&q)
+    if err != nil {
+        http.Error(w, fmt.Sprintf("planning error: %v", err), http.StatusInternalServerError)
+        return
+    }
+
+    if !a.dialect.Capabilities().TimeSeries {
+        http.Error(w, fmt.Sprintf("time series are not supported by the %s adapter", a.dialect.Name()), http.StatusNotImplemented)
+        return
+    }
+    if err := a.checkPlan(r.Context(), plan.Query); err != nil {
+        http.Error(w, err.Error(), http.StatusNotImplemented)
+        return
+    }
+
+    sql, params, err := a.dialect.BuildTimeSeriesQuery(plan)
+    if err != nil {
+        http.Error(w, fmt.Sprintf("sql build error: %v", err), http.StatusInternalServerError)
+        return
+    }
+
+    resp := map[string]interface{}{
+        "sql":    sql,
+        "params": params,
+    }
+    if len(plan.Query.Resolved) > 0 {
+        resp["resolved"] = resolvedValues(plan.Query.Resolved)
+    }
+
+    if a.db != nil {
+        rows, err := a.db.Fetch(r.Context(), sql, params...)
+        if err != nil {
+            fmt.Printf("Warning: Failed to execute time series query: %v\n", err)
+        } else {
+            resp["data"] = rows
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleFieldValues returns the distinct values of a field with their row
+// counts among the rows matching the given filters, for filter facets
+func (a *API) handleFieldValues(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    start := time.Now()
+
+    type rawFieldValues struct {
+        Filters  json.RawMessage `json:"filters,omitempty"`
+        Prefix   string          `json:"prefix,omitempty"`
+        Limit    int             `json:"limit,omitempty"`
+        Timezone string          `json:"timezone,omitempty"`
+    }
+
+    // The body is optional; without it all values are listed
+    var rq rawFieldValues
+    if err := json.NewDecoder(r.Body).Decode(&rq); err != nil && err != io.EOF {
+        http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+        return
+    }
+
+    q := dsl.FieldValuesQuery{
+        Model:    r.PathValue("model"),
+        Field:    r.PathValue("field"),
+        Prefix:   rq.Prefix,
+        Limit:    rq.Limit,
+        Timezone: rq.Timezone,
+    }
+
+    if len(rq.Filters) > 0 {
+        filters, err := dsl.ParseFilterExpr(rq.Filters)
+        if err != nil {
+            http.Error(w, fmt.Sprintf("invalid filters format: %v", err), http.StatusBadRequest)
+            return
+        }
+        q.Filters = filters
+    }
+
+    if err := a.validator.ValidateFieldValues(&q); err != nil {
+        http.Error(w, fmt.Sprintf("validation error: %v", err), http.StatusBadRequest)
+        return
+    }
+
+    plan, err := a.planner.PlanQuery(q.Query())
+    if err != nil {
+        http.Error(w, fmt.Sprintf("planning error: %v", err), http.StatusInternalServerError)
+        return
+    }
+
+    if err := a.checkPlan(r.Context(), plan); err != nil {
+        http.Error(w, err.Error(), http.StatusNotImplemented)
+        return
+    }
+
+    sql, params, err := a.dialect.BuildQuery(plan)
+    if err != nil {
+        http.Error(w, fmt.Sprintf("sql build error: %v", err), http.StatusInternalServerError)
+        return
+    }
+
+    meta := queryMeta{Limit: plan.Pagination.Limit}
+    meta.Timing.PlanMs = millis(time.Since(start))
+
+    resp := map[string]interface{}{
+        "sql":    sql,
+        "params": params,
+    }
+    if len(plan.Resolved) > 0 {
+        resp["resolved"] = resolvedValues(plan.Resolved)
+    }
+
+    if a.db != nil {
+        queryStart := time.Now()
+        rows, err := a.fetch(r.Context(), plan, sql, params)
+        meta.Timing.QueryMs = millis(time.Since(queryStart))
+        if err != nil {
+            fmt.Printf("Warning: Failed to execute field values query: %v\n", err)
+        } else {
+            if rows == nil {
+                rows = []map[string]interface{}{}
+            }
+            resp["values"] = rows
+            meta.Executed = true
+            meta.Returned = len(rows)
         }
     }
 
+    meta.Timing.TotalMs = millis(time.Since(start))
+    resp["meta"] = meta
+
     w.Header().Set("Content-Type", "application/json")
     _ = json.NewEncoder(w).Encode(resp)
 }
+
+// checkPlan fails when the plan uses a feature the dialect cannot render
+// or, with a database, something the database lacks (e.g. a PostgreSQL
+// extension), so callers get a clear message instead of a database error
+func (a *API) checkPlan(ctx context.Context, plan *planner.QueryPlan) error {
+    if err := adapter.CheckCapabilities(a.dialect, plan); err != nil {
+        return err
+    }
+    if checker, ok := a.db.(adapter.PlanChecker); ok {
+        return checker.CheckPlan(ctx, plan)
+    }
+    return nil
+}
+
+// fetch runs a planned query: executors that evaluate plans get the plan,
+// the rest run its SQL
+func (a *API) fetch(ctx context.Context, plan *planner.QueryPlan, sql string, params []interface{}) ([]adapter.Row, error) {
+    if pe, ok := a.db.(adapter.PlanExecutor); ok {
+        return pe.FetchPlan(ctx, plan)
+    }
+    return a.db.Fetch(ctx, sql, params...)
+}
+
+// resolvedValues lists the relative date filter values of a plan with the
+// absolute values they were evaluated to, so callers can see which range a
+// saved query actually covered
+func resolvedValues(resolved []planner.ResolvedValue) []map[string]interface{} {
+    out := make([]map[string]interface{}, 0, len(resolved))
+    for _, rv := range resolved {
+        out = append(out, map[string]interface{}{
+            "field":      rv.Field,
+            "op":         rv.Operator,
+            "expression": rv.Expression,
+            "value":      rv.Value,
+        })
+    }
+    return out
+}
@@ -7,7 +7,11 @@ import (
     "net/http"
     "net/http/httptest"
     "testing"
+    "time"
 
+    "udv/internal/adapter"
+    "udv/internal/adapter/memory"
+    "udv/internal/adapter/postgres"
     "udv/internal/config"
     "udv/internal/dsl"
     "udv/internal/schema"
@@ -24,6 +28,7 @@ func setupRegistryForTest() *schema.Registry {
                     {Name: "id", Type: "integer"},
                     {Name: "status", Type: "string"},
                     {Name: "amount", Type: "decimal"},
+                    {Name: "created_at", Type: "timestamp"},
                 },
             },
         },
@@ -36,7 +41,7 @@ func setupRegistryForTest() *schema.Registry {
 
 func TestModelsEndpoint(t *testing.T) {
     reg := setupRegistryForTest()
-    a := New(reg, nil)  // ← Pass nil for database since we're testing without DB
+    a := New(reg, postgres.NewAdapter(nil))  // ← Pass nil for database since we're testing without DB
     mux := http.NewServeMux()
     a.RegisterRoutes(mux)
 
@@ -66,7 +71,7 @@ func TestModelsEndpoint(t *testing.T) {
 
 func TestQueryEndpoint_Simple(t *testing.T) {
     reg := setupRegistryForTest()
-    a := New(reg, nil)  // ← Pass nil for database since we're testing without DB
+    a := New(reg, postgres.NewAdapter(nil))  // ← Pass nil for database since we're testing without DB
     mux := http.NewServeMux()
     a.RegisterRoutes(mux)
 
@@ -108,3 +113,523 @@ func TestQueryEndpoint_Simple(t *testing.T) {
         t.Fatalf("response missing params or empty")
     }
 }
+
+func TestQueryEndpoint_NestedFilters(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "filters": {
+            "or": [
+                {"and": [
+                    {"field": "status", "op": "=", "value": "paid"},
+                    {"field": "amount", "op": ">", "value": 100}
+                ]},
+                {"not": {"field": "status", "op": "=", "value": "refunded"}}
+            ]
+        }
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out map[string]interface{}
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    sql, _ := out["sql"].(string)
+    if !bytes.Contains([]byte(sql), []byte("((t0.status = $1 AND t0.amount > $2) OR NOT (t0.status = $3))")) {
+        t.Errorf("unexpected sql: %s", sql)
+    }
+}
+
+func TestQueryEndpoint_FilterTooDeep(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    a.SetMaxFilterDepth(1)
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{"model": "orders", "filters": {"not": {"not": {"field": "status", "op": "=", "value": "paid"}}}}`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusBadRequest {
+        t.Fatalf("unexpected status: %d, want 400", resp.StatusCode)
+    }
+}
+
+func TestQueryEndpoint_Having(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "group_by": ["status"],
+        "aggregates": [{"fn": "sum", "field": "amount", "alias": "total"}],
+        "having": {"field": "total", "op": ">", "value": 500}
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out map[string]interface{}
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    sql, _ := out["sql"].(string)
+    if !bytes.Contains([]byte(sql), []byte("GROUP BY t0.status HAVING SUM(t0.amount) > $1")) {
+        t.Errorf("unexpected sql: %s", sql)
+    }
+}
+
+func TestTimeSeriesEndpoint(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "field": "created_at",
+        "interval": "month",
+        "range": {"from": "2024-01-01", "to": "2025-01-01"},
+        "filters": {"field": "status", "op": "=", "value": "paid"},
+        "aggregates": [{"fn": "count", "alias": "orders"}]
+    }`
+
+    resp, err := http.Post(ts.URL+"/timeseries", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /timeseries failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out map[string]interface{}
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    sql, _ := out["sql"].(string)
+    if !bytes.Contains([]byte(sql), []byte("generate_series(")) || !bytes.Contains([]byte(sql), []byte("COALESCE(agg.orders, 0) AS orders")) {
+        t.Errorf("unexpected sql: %s", sql)
+    }
+}
+
+func TestQueryEndpoint_RelativeDates(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    a.planner.SetClock(func() time.Time {
+        return time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+    })
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "filters": {"field": "created_at", "op": "after", "value": "now-7d"},
+        "timezone": "UTC"
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out struct {
+        Params   []interface{}            `json:"params"`
+        Resolved []map[string]interface{} `json:"resolved"`
+    }
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    if len(out.Resolved) != 1 {
+        t.Fatalf("expected 1 resolved value, got %s", string(respBody))
+    }
+    rv := out.Resolved[0]
+    if rv["field"] != "created_at" || rv["expression"] != "now-7d" || rv["value"] != "2024-03-07T12:00:00Z" {
+        t.Errorf("unexpected resolved value: %v", rv)
+    }
+    if len(out.Params) == 0 || out.Params[0] != "2024-03-07T12:00:00Z" {
+        t.Errorf("unexpected params: %v", out.Params)
+    }
+}
+
+func TestTimeSeriesEndpoint_InvalidField(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{"model": "orders", "field": "status", "interval": "day", "range": {"from": "2024-01-01", "to": "2024-02-01"}, "aggregates": [{"fn": "count", "alias": "n"}]}`
+
+    resp, err := http.Post(ts.URL+"/timeseries", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /timeseries failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusBadRequest {
+        t.Fatalf("unexpected status: %d, want 400", resp.StatusCode)
+    }
+}
+
+func TestQueryEndpoint_AggregateFilter(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "group_by": ["status"],
+        "aggregates": [
+            {"fn": "count", "alias": "big", "filter": {"field": "amount", "op": ">", "value": 100}},
+            {"fn": "count", "alias": "total"}
+        ]
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out map[string]interface{}
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    sql, _ := out["sql"].(string)
+    if !bytes.Contains([]byte(sql), []byte("COUNT(*) FILTER (WHERE t0.amount > $1) AS big, COUNT(*) AS total")) {
+        t.Errorf("unexpected sql: %s", sql)
+    }
+}
+
+func TestQueryEndpoint_Windows(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "windows": [
+            {"fn": "sum", "field": "amount", "partition_by": ["status"], "order_by": [{"field": "created_at"}], "alias": "running_total"}
+        ],
+        "sort": [{"field": "running_total", "direction": "desc"}]
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out map[string]interface{}
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    sql, _ := out["sql"].(string)
+    if !bytes.Contains([]byte(sql), []byte("SUM(t0.amount) OVER (PARTITION BY t0.status ORDER BY t0.created_at ASC) AS running_total")) ||
+        !bytes.Contains([]byte(sql), []byte("ORDER BY running_total DESC")) {
+        t.Errorf("unexpected sql: %s", sql)
+    }
+}
+
+// limitedDialect renders PostgreSQL but claims no optional features
+type limitedDialect struct {
+    postgres.Dialect
+}
+
+func (limitedDialect) Name() string { return "Limited" }
+
+func (limitedDialect) Capabilities() adapter.Capabilities { return adapter.Capabilities{} }
+
+type limitedAdapter struct{}
+
+func (limitedAdapter) Dialect() adapter.Dialect   { return limitedDialect{} }
+func (limitedAdapter) Executor() adapter.Executor { return nil }
+
+func TestQueryEndpoint_UnsupportedFeature(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, limitedAdapter{})
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{"model": "orders", "windows": [{"fn": "count", "alias": "n"}]}`
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusNotImplemented {
+        t.Fatalf("status = %d, want 501; body: %s", resp.StatusCode, string(respBody))
+    }
+    if !bytes.Contains(respBody, []byte("window functions is not supported by the Limited adapter")) {
+        t.Errorf("unexpected body: %s", string(respBody))
+    }
+
+    // Time series are a capability too
+    body = `{"model": "orders", "field": "created_at", "interval": "day",
+        "range": {"from": "2024-01-01", "to": "2024-01-08"}, "aggregates": [{"fn": "count", "alias": "n"}]}`
+    resp, err = http.Post(ts.URL+"/timeseries", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /timeseries failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ = ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusNotImplemented {
+        t.Fatalf("status = %d, want 501; body: %s", resp.StatusCode, string(respBody))
+    }
+}
+
+func TestQueryEndpoint_Meta(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "pagination": {"limit": 25, "offset": 50},
+        "count": "exact"
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out struct {
+        Meta map[string]interface{} `json:"meta"`
+    }
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    // Without a database nothing runs, so there is no total
+    m := out.Meta
+    if m["limit"] != float64(25) || m["offset"] != float64(50) || m["returned"] != float64(0) || m["executed"] != false {
+        t.Errorf("unexpected meta: %v", m)
+    }
+    if _, ok := m["total"]; ok {
+        t.Errorf("meta has a total without a database: %v", m)
+    }
+    timing, ok := m["timing"].(map[string]interface{})
+    if !ok {
+        t.Fatalf("meta missing timing: %v", m)
+    }
+    for _, key := range []string{"plan_ms", "query_ms", "count_ms", "total_ms"} {
+        if _, ok := timing[key]; !ok {
+            t.Errorf("timing missing %s: %v", key, timing)
+        }
+    }
+
+    // Unknown count modes are rejected
+    resp2, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(`{"model": "orders", "count": "fast"}`)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp2.Body.Close()
+    respBody, _ = ioutil.ReadAll(resp2.Body)
+    if resp2.StatusCode != http.StatusBadRequest || !bytes.Contains(respBody, []byte("invalid count mode: fast")) {
+        t.Errorf("unexpected response for bad count mode: %d %s", resp2.StatusCode, string(respBody))
+    }
+}
+
+func TestQueryEndpoint_InMemory(t *testing.T) {
+    reg := setupRegistryForTest()
+    db := memory.NewDatabase(map[string][]adapter.Row{
+        "orders": {
+            {"id": int64(1), "status": "PAID", "amount": 120.5},
+            {"id": int64(2), "status": "SHIPPED", "amount": 80.0},
+            {"id": int64(3), "status": "PAID", "amount": 300.0},
+        },
+    })
+    a := New(reg, memory.NewAdapter(db))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "model": "orders",
+        "fields": ["id"],
+        "filters": {"field": "status", "op": "=", "value": "PAID"},
+        "sort": [{"field": "amount", "direction": "desc"}],
+        "pagination": {"limit": 1},
+        "count": "exact"
+    }`
+
+    resp, err := http.Post(ts.URL+"/query", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST /query failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out struct {
+        Data []map[string]interface{} `json:"data"`
+        Meta map[string]interface{}   `json:"meta"`
+    }
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    // The plan is evaluated in memory: one page, counted across all pages
+    if len(out.Data) != 1 || out.Data[0]["id"] != float64(3) {
+        t.Errorf("unexpected data: %v", out.Data)
+    }
+    if out.Meta["executed"] != true || out.Meta["total"] != float64(2) {
+        t.Errorf("unexpected meta: %v", out.Meta)
+    }
+}
+
+func TestFieldValuesEndpoint(t *testing.T) {
+    reg := setupRegistryForTest()
+    a := New(reg, postgres.NewAdapter(nil))
+    mux := http.NewServeMux()
+    a.RegisterRoutes(mux)
+
+    ts := httptest.NewServer(mux)
+    defer ts.Close()
+
+    body := `{
+        "filters": {"field": "amount", "op": ">", "value": 100},
+        "prefix": "pa",
+        "limit": 10
+    }`
+
+    resp, err := http.Post(ts.URL+"/models/orders/fields/status/values", "application/json", bytes.NewReader([]byte(body)))
+    if err != nil {
+        t.Fatalf("POST values failed: %v", err)
+    }
+    defer resp.Body.Close()
+
+    respBody, _ := ioutil.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("unexpected status: %d body: %s", resp.StatusCode, string(respBody))
+    }
+
+    var out struct {
+        SQL    string        `json:"sql"`
+        Params []interface{} `json:"params"`
+    }
+    if err := json.Unmarshal(respBody, &out); err != nil {
+        t.Fatalf("invalid json response: %v", err)
+    }
+
+    expected := "SELECT t0.status AS value, COUNT(*) AS count FROM orders t0 WHERE (t0.amount > $1 AND t0.status ILIKE $2) " +
+        "GROUP BY t0.status ORDER BY count DESC, t0.status ASC LIMIT $3 OFFSET $4;"
+    if out.SQL != expected {
+        t.Errorf("SQL mismatch\nexpected: %s\ngot:      %s", expected, out.SQL)
+    }
+    if len(out.Params) != 4 || out.Params[1] != "pa%" || out.Params[2] != float64(10) {
+        t.Errorf("unexpected params: %v", out.Params)
+    }
+
+    // Without a body every value is listed; bad fields are rejected
+    resp2, err := http.Post(ts.URL+"/models/orders/fields/colour/values", "application/json", nil)
+    if err != nil {
+        t.Fatalf("POST values failed: %v", err)
+    }
+    defer resp2.Body.Close()
+    respBody, _ = ioutil.ReadAll(resp2.Body)
+    if resp2.StatusCode != http.StatusBadRequest || !bytes.Contains(respBody, []byte("invalid field")) {
+        t.Errorf("unexpected response for unknown field: %d %s", resp2.StatusCode, string(respBody))
+    }
+}
@@ -4,16 +4,61 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // Model represents a data model configuration
 type Model struct {
-	Name       string  `json:"name"`
-	Table      string  `json:"table"`
-	PrimaryKey string  `json:"primaryKey"`
-	Fields     []Field `json:"fields"`
+	Name       string     `json:"name"`
+	Table      string     `json:"table"`
+	PrimaryKey string     `json:"primaryKey"`
+	Fields     []Field    `json:"fields"`
+	Relations  []Relation `json:"relations,omitempty"`
+
+	// Full-text search: the string fields the search operator matches, or a
+	// precomputed tsvector column to match instead, and the text search
+	// configuration (language) to parse with
+	SearchFields   []string `json:"searchFields,omitempty"`
+	SearchVector   string   `json:"searchVector,omitempty"`
+	SearchLanguage string   `json:"searchLanguage,omitempty"`
+
+	// In-memory engine: the CSV, JSON or NDJSON file holding the model's
+	// rows, relative to the config file, and its format when the file
+	// extension does not tell
+	File   string `json:"file,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// File formats the in-memory engine reads
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"   // an array of objects
+	FormatNDJSON = "ndjson" // one object per line
+)
+
+// FileFormat returns the format of the model's file: its declared format,
+// or the one its extension implies (.csv, .json, .ndjson or .jsonl). It is
+// empty when neither tells.
+func (m *Model) FileFormat() string {
+	if m.Format != "" {
+		return m.Format
+	}
+	switch strings.ToLower(filepath.Ext(m.File)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return ""
 }
 
+// DefaultSearchLanguage is the text search configuration used when a model
+// does not set searchLanguage
+const DefaultSearchLanguage = "english"
+
 // Field represents a field within a model
 type Field struct {
 	Name     string `json:"name"`
@@ -21,6 +66,25 @@ type Field struct {
 	Nullable bool   `json:"nullable"`
 }
 
+// Relation represents a relationship from a model to another model.
+// ForeignKey is a field on the declaring model and ReferenceKey a field on
+// the target model; either may be omitted where it defaults to a primary key.
+type Relation struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Model        string     `json:"model"`
+	ForeignKey   string     `json:"foreignKey,omitempty"`
+	ReferenceKey string     `json:"referenceKey,omitempty"`
+	JoinTable    *JoinTable `json:"joinTable,omitempty"`
+}
+
+// JoinTable describes the link table of a many_to_many relation
+type JoinTable struct {
+	Table     string `json:"table"`
+	SourceKey string `json:"sourceKey"` // Column referencing the declaring model
+	TargetKey string `json:"targetKey"` // Column referencing the target model
+}
+
 // Config represents the entire configuration
 type Config struct {
 	Models []Model `json:"models"`
@@ -93,6 +157,7 @@ func ValidateModel(model *Model, index int) error {
 	// Validate that primary key exists in fields
 	primaryKeyExists := false
 	fieldNames := make(map[string]bool)
+	fieldTypes := make(map[string]string)
 
 	for j, field := range model.Fields {
 		if err := ValidateField(&field, index, model.Name, j); err != nil {
@@ -103,6 +168,7 @@ func ValidateModel(model *Model, index int) error {
 			return fmt.Errorf("model[%d] %s: duplicate field name: %s", index, model.Name, field.Name)
 		}
 		fieldNames[field.Name] = true
+		fieldTypes[field.Name] = field.Type
 
 		if field.Name == model.PrimaryKey {
 			primaryKeyExists = true
@@ -113,6 +179,113 @@ func ValidateModel(model *Model, index int) error {
 		return fmt.Errorf("model[%d] %s: primaryKey %s not found in fields", index, model.Name, model.PrimaryKey)
 	}
 
+	relationNames := make(map[string]bool)
+	for j, rel := range model.Relations {
+		if err := ValidateRelation(&rel, index, model.Name, j); err != nil {
+			return err
+		}
+
+		if relationNames[rel.Name] {
+			return fmt.Errorf("model[%d] %s: duplicate relation name: %s", index, model.Name, rel.Name)
+		}
+		if fieldNames[rel.Name] {
+			return fmt.Errorf("model[%d] %s: relation name %s conflicts with a field", index, model.Name, rel.Name)
+		}
+		relationNames[rel.Name] = true
+	}
+
+	searchFields := make(map[string]bool)
+	for _, name := range model.SearchFields {
+		typ, exists := fieldTypes[name]
+		if !exists {
+			return fmt.Errorf("model[%d] %s: search field %s not found in fields", index, model.Name, name)
+		}
+		if typ != "string" {
+			return fmt.Errorf("model[%d] %s: search field %s must be a string field, got %s", index, model.Name, name, typ)
+		}
+		if searchFields[name] {
+			return fmt.Errorf("model[%d] %s: duplicate search field: %s", index, model.Name, name)
+		}
+		searchFields[name] = true
+	}
+	if model.SearchVector != "" && !isSQLName(model.SearchVector) {
+		return fmt.Errorf("model[%d] %s: invalid searchVector column %q", index, model.Name, model.SearchVector)
+	}
+	if model.SearchLanguage != "" && !isSQLName(model.SearchLanguage) {
+		return fmt.Errorf("model[%d] %s: invalid searchLanguage %q", index, model.Name, model.SearchLanguage)
+	}
+
+	if model.Format != "" && model.File == "" {
+		return fmt.Errorf("model[%d] %s: format requires a file", index, model.Name)
+	}
+	if model.File != "" {
+		switch model.FileFormat() {
+		case FormatCSV, FormatJSON, FormatNDJSON:
+		case "":
+			return fmt.Errorf("model[%d] %s: cannot tell the format of file %s; set format to csv, json or ndjson", index, model.Name, model.File)
+		default:
+			return fmt.Errorf("model[%d] %s: invalid format %q (expected csv, json or ndjson)", index, model.Name, model.Format)
+		}
+	}
+
+	return nil
+}
+
+// isSQLName reports whether s is a plain lowercase SQL name, safe to render
+// without quoting
+func isSQLName(s string) bool {
+	for i, c := range s {
+		if !(c >= 'a' && c <= 'z' || c == '_' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// ValidateRelation validates the shape of a single relation. Whether the
+// target model and key fields exist is checked when the schema registry loads
+// the config.
+func ValidateRelation(rel *Relation, modelIndex int, modelName string, relIndex int) error {
+	if rel.Name == "" {
+		return fmt.Errorf("model[%d] %s: relation[%d] name is required", modelIndex, modelName, relIndex)
+	}
+
+	if strings.Contains(rel.Name, ".") {
+		return fmt.Errorf("model[%d] %s: relation[%d] %s: name must not contain '.'", modelIndex, modelName, relIndex, rel.Name)
+	}
+
+	if rel.Model == "" {
+		return fmt.Errorf("model[%d] %s: relation[%d] %s: model is required", modelIndex, modelName, relIndex, rel.Name)
+	}
+
+	switch rel.Type {
+	case "many_to_one":
+		if rel.ForeignKey == "" {
+			return fmt.Errorf("model[%d] %s: relation[%d] %s: foreignKey is required for many_to_one", modelIndex, modelName, relIndex, rel.Name)
+		}
+	case "one_to_many":
+		if rel.ReferenceKey == "" {
+			return fmt.Errorf("model[%d] %s: relation[%d] %s: referenceKey is required for one_to_many", modelIndex, modelName, relIndex, rel.Name)
+		}
+	case "one_to_one":
+		if rel.ForeignKey == "" && rel.ReferenceKey == "" {
+			return fmt.Errorf("model[%d] %s: relation[%d] %s: foreignKey or referenceKey is required for one_to_one", modelIndex, modelName, relIndex, rel.Name)
+		}
+	case "many_to_many":
+		if rel.JoinTable == nil {
+			return fmt.Errorf("model[%d] %s: relation[%d] %s: joinTable is required for many_to_many", modelIndex, modelName, relIndex, rel.Name)
+		}
+		if rel.JoinTable.Table == "" || rel.JoinTable.SourceKey == "" || rel.JoinTable.TargetKey == "" {
+			return fmt.Errorf("model[%d] %s: relation[%d] %s: joinTable requires table, sourceKey and targetKey", modelIndex, modelName, relIndex, rel.Name)
+		}
+	default:
+		return fmt.Errorf("model[%d] %s: relation[%d] %s: invalid type %q", modelIndex, modelName, relIndex, rel.Name, rel.Type)
+	}
+
+	if rel.Type != "many_to_many" && rel.JoinTable != nil {
+		return fmt.Errorf("model[%d] %s: relation[%d] %s: joinTable is only valid for many_to_many", modelIndex, modelName, relIndex, rel.Name)
+	}
+
 	return nil
 }
 
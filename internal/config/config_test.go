@@ -266,6 +266,241 @@ func TestValidateConfig(t *testing.T) {
 	}
 }
 
+func TestValidateConfigRelations(t *testing.T) {
+	baseModels := func(rels ...Relation) *Config {
+		return &Config{
+			Models: []Model{
+				{
+					Name:       "orders",
+					Table:      "orders",
+					PrimaryKey: "id",
+					Fields: []Field{
+						{Name: "id", Type: "integer"},
+						{Name: "user_id", Type: "integer"},
+					},
+					Relations: rels,
+				},
+				{
+					Name:       "users",
+					Table:      "users",
+					PrimaryKey: "id",
+					Fields: []Field{
+						{Name: "id", Type: "integer"},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:    "valid many_to_one",
+			config:  baseModels(Relation{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "user_id"}),
+			wantErr: false,
+		},
+		{
+			name:    "valid one_to_many",
+			config:  baseModels(Relation{Name: "lines", Type: "one_to_many", Model: "order_lines", ReferenceKey: "order_id"}),
+			wantErr: false,
+		},
+		{
+			name: "valid many_to_many",
+			config: baseModels(Relation{Name: "tags", Type: "many_to_many", Model: "tags",
+				JoinTable: &JoinTable{Table: "order_tags", SourceKey: "order_id", TargetKey: "tag_id"}}),
+			wantErr: false,
+		},
+		{
+			name:    "missing name",
+			config:  baseModels(Relation{Type: "many_to_one", Model: "users", ForeignKey: "user_id"}),
+			wantErr: true,
+			errMsg:  "relation[0] name is required",
+		},
+		{
+			name:    "dotted name",
+			config:  baseModels(Relation{Name: "a.b", Type: "many_to_one", Model: "users", ForeignKey: "user_id"}),
+			wantErr: true,
+			errMsg:  "must not contain '.'",
+		},
+		{
+			name:    "missing target model",
+			config:  baseModels(Relation{Name: "user", Type: "many_to_one", ForeignKey: "user_id"}),
+			wantErr: true,
+			errMsg:  "model is required",
+		},
+		{
+			name:    "invalid type",
+			config:  baseModels(Relation{Name: "user", Type: "belongs_to", Model: "users", ForeignKey: "user_id"}),
+			wantErr: true,
+			errMsg:  "invalid type",
+		},
+		{
+			name:    "many_to_one without foreignKey",
+			config:  baseModels(Relation{Name: "user", Type: "many_to_one", Model: "users"}),
+			wantErr: true,
+			errMsg:  "foreignKey is required",
+		},
+		{
+			name:    "one_to_many without referenceKey",
+			config:  baseModels(Relation{Name: "lines", Type: "one_to_many", Model: "order_lines"}),
+			wantErr: true,
+			errMsg:  "referenceKey is required",
+		},
+		{
+			name:    "one_to_one without keys",
+			config:  baseModels(Relation{Name: "invoice", Type: "one_to_one", Model: "invoices"}),
+			wantErr: true,
+			errMsg:  "foreignKey or referenceKey is required",
+		},
+		{
+			name:    "many_to_many without joinTable",
+			config:  baseModels(Relation{Name: "tags", Type: "many_to_many", Model: "tags"}),
+			wantErr: true,
+			errMsg:  "joinTable is required",
+		},
+		{
+			name: "incomplete joinTable",
+			config: baseModels(Relation{Name: "tags", Type: "many_to_many", Model: "tags",
+				JoinTable: &JoinTable{Table: "order_tags", SourceKey: "order_id"}}),
+			wantErr: true,
+			errMsg:  "joinTable requires table, sourceKey and targetKey",
+		},
+		{
+			name: "joinTable on many_to_one",
+			config: baseModels(Relation{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "user_id",
+				JoinTable: &JoinTable{Table: "x", SourceKey: "a", TargetKey: "b"}}),
+			wantErr: true,
+			errMsg:  "joinTable is only valid for many_to_many",
+		},
+		{
+			name: "duplicate relation",
+			config: baseModels(
+				Relation{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "user_id"},
+				Relation{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "user_id"},
+			),
+			wantErr: true,
+			errMsg:  "duplicate relation name",
+		},
+		{
+			name:    "relation named like a field",
+			config:  baseModels(Relation{Name: "user_id", Type: "many_to_one", Model: "users", ForeignKey: "user_id"}),
+			wantErr: true,
+			errMsg:  "conflicts with a field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && tt.errMsg != "" && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateConfig() error message = %v, want to contain %v", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateConfigSearch(t *testing.T) {
+	withSearch := func(fields []string, vector, language string) *Config {
+		return &Config{
+			Models: []Model{
+				{
+					Name:       "articles",
+					Table:      "articles",
+					PrimaryKey: "id",
+					Fields: []Field{
+						{Name: "id", Type: "integer"},
+						{Name: "title", Type: "string"},
+						{Name: "body", Type: "string"},
+					},
+					SearchFields:   fields,
+					SearchVector:   vector,
+					SearchLanguage: language,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+		errMsg  string
+	}{
+		{name: "search fields", config: withSearch([]string{"title", "body"}, "", "")},
+		{name: "search vector", config: withSearch(nil, "search_vector", "simple")},
+		{name: "unknown search field", config: withSearch([]string{"summary"}, "", ""), wantErr: true, errMsg: "search field summary not found"},
+		{name: "non-string search field", config: withSearch([]string{"id"}, "", ""), wantErr: true, errMsg: "must be a string field"},
+		{name: "duplicate search field", config: withSearch([]string{"title", "title"}, "", ""), wantErr: true, errMsg: "duplicate search field"},
+		{name: "invalid vector column", config: withSearch(nil, "vec; drop", ""), wantErr: true, errMsg: "invalid searchVector"},
+		{name: "invalid language", config: withSearch([]string{"title"}, "", "english'"), wantErr: true, errMsg: "invalid searchLanguage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && tt.errMsg != "" && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateConfig() error message = %v, want to contain %v", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	withFile := func(file, format string) *Config {
+		return &Config{
+			Models: []Model{
+				{
+					Name:       "orders",
+					Table:      "orders",
+					PrimaryKey: "id",
+					Fields:     []Field{{Name: "id", Type: "integer"}},
+					File:       file,
+					Format:     format,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+		errMsg  string
+	}{
+		{name: "csv by extension", config: withFile("data/orders.csv", "")},
+		{name: "ndjson by jsonl extension", config: withFile("orders.JSONL", "")},
+		{name: "declared format", config: withFile("orders.export", "json")},
+		{name: "unknown extension", config: withFile("orders.txt", ""), wantErr: true, errMsg: "cannot tell the format"},
+		{name: "invalid format", config: withFile("orders.csv", "xml"), wantErr: true, errMsg: "invalid format"},
+		{name: "format without file", config: withFile("", "csv"), wantErr: true, errMsg: "format requires a file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && tt.errMsg != "" && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateConfig() error message = %v, want to contain %v", err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	tests := []struct {
 		name    string
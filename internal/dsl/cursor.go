@@ -0,0 +1,98 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Keyset (cursor) pagination pages through a query's sort order instead of
+// skipping rows with OFFSET. The ordering is the query's sort followed by the
+// model's primary key, which makes it total; a cursor records the values of
+// those keys for the last row of a page, and the next page continues after
+// them. Cursors are opaque to clients: base64url-encoded JSON
+//
+//	{"k": ["created_at:desc", "id:desc"], "v": ["2024-03-01T10:00:00Z", 4711]}
+//
+// carrying the ordering they were issued for, so a cursor cannot be reused
+// with a different sort.
+
+type cursorPayload struct {
+	Keys   []string      `json:"k"`
+	Values []interface{} `json:"v"`
+}
+
+// KeysetSort returns the ordering keyset pagination pages through: the
+// query's sort with directions filled in, then the primary key unless the
+// sort already includes it. The primary key follows the direction of the
+// last sort key, so a single-direction sort stays a single-direction
+// ordering that one composite index can serve.
+func KeysetSort(q *Query, primaryKey string) []Sort {
+	sorts := make([]Sort, 0, len(q.Sort)+1)
+	hasKey := false
+	direction := SortAsc
+	for _, s := range q.Sort {
+		if s.Direction == "" {
+			s.Direction = SortAsc
+		}
+		if s.Field == primaryKey {
+			hasKey = true
+		}
+		direction = s.Direction
+		sorts = append(sorts, s)
+	}
+	if !hasKey {
+		sorts = append(sorts, Sort{Field: primaryKey, Direction: direction})
+	}
+	return sorts
+}
+
+// EncodeCursor builds the cursor for the row whose keyset values are given,
+// one per entry of sorts
+func EncodeCursor(sorts []Sort, values []interface{}) (string, error) {
+	if len(values) != len(sorts) {
+		return "", fmt.Errorf("cursor needs %d values, got %d", len(sorts), len(values))
+	}
+	data, err := json.Marshal(cursorPayload{Keys: cursorKeys(sorts), Values: values})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor returns the keyset values of a cursor, checking that it was
+// issued for the ordering sorts. Values are as decoded from JSON (numbers as
+// json.Number) and still need coercing to their field types.
+func DecodeCursor(cursor string, sorts []Sort) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var payload cursorPayload
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	want := cursorKeys(sorts)
+	if strings.Join(payload.Keys, ",") != strings.Join(want, ",") {
+		return nil, fmt.Errorf("cursor was issued for sort %s, not %s", strings.Join(payload.Keys, ", "), strings.Join(want, ", "))
+	}
+	if len(payload.Values) != len(want) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return payload.Values, nil
+}
+
+// cursorKeys renders an ordering as "field:direction" keys
+func cursorKeys(sorts []Sort) []string {
+	keys := make([]string, len(sorts))
+	for i, s := range sorts {
+		keys[i] = s.Field + ":" + string(s.Direction)
+	}
+	return keys
+}
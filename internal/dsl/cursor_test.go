@@ -0,0 +1,66 @@
+package dsl
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeysetSort(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []Sort
+		want []Sort
+	}{
+		{"no sort", nil, []Sort{{Field: "id", Direction: SortAsc}}},
+		{
+			"primary key follows last direction",
+			[]Sort{{Field: "status"}, {Field: "created_at", Direction: SortDesc}},
+			[]Sort{{Field: "status", Direction: SortAsc}, {Field: "created_at", Direction: SortDesc}, {Field: "id", Direction: SortDesc}},
+		},
+		{
+			"primary key already sorted",
+			[]Sort{{Field: "id", Direction: SortDesc}, {Field: "status"}},
+			[]Sort{{Field: "id", Direction: SortDesc}, {Field: "status", Direction: SortAsc}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := KeysetSort(&Query{Sort: tt.sort}, "id")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeysetSort() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	sorts := []Sort{{Field: "created_at", Direction: SortDesc}, {Field: "id", Direction: SortDesc}}
+
+	cursor, err := EncodeCursor(sorts, []interface{}{"2024-03-01T10:00:00Z", int64(9007199254740993)})
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	values, err := DecodeCursor(cursor, sorts)
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	// Large integers survive as json.Number
+	want := []interface{}{"2024-03-01T10:00:00Z", json.Number("9007199254740993")}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("DecodeCursor() = %#v, want %#v", values, want)
+	}
+
+	// A cursor only continues the ordering it was issued for
+	_, err = DecodeCursor(cursor, []Sort{{Field: "id", Direction: SortAsc}})
+	if err == nil || !strings.Contains(err.Error(), "cursor was issued for sort created_at:desc, id:desc, not id:asc") {
+		t.Errorf("DecodeCursor() with other sort error = %v", err)
+	}
+
+	if _, err := DecodeCursor("not a cursor!", sorts); err == nil || err.Error() != "invalid cursor" {
+		t.Errorf("DecodeCursor() garbage error = %v, want invalid cursor", err)
+	}
+}
@@ -0,0 +1,146 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ParseFilterExpr decodes a JSON filter expression. Objects with an "and",
+// "or" or "not" key become a LogicalFilter (recursively); anything else is
+// decoded as a ComparisonFilter.
+func ParseFilterExpr(data []byte) (FilterExpr, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("filter must be a JSON object: %w", err)
+	}
+
+	_, hasAnd := keys["and"]
+	_, hasOr := keys["or"]
+	_, hasNot := keys["not"]
+	if hasAnd || hasOr || hasNot {
+		if _, hasField := keys["field"]; hasField {
+			return nil, fmt.Errorf("filter cannot mix field comparison with and/or/not")
+		}
+		var lf LogicalFilter
+		if err := json.Unmarshal(data, &lf); err != nil {
+			return nil, err
+		}
+		return &lf, nil
+	}
+
+	var cf ComparisonFilter
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("invalid comparison filter: %w", err)
+	}
+	return &cf, nil
+}
+
+// UnmarshalJSON decodes and/or/not children as nested filter expressions
+func (l *LogicalFilter) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		And []json.RawMessage `json:"and"`
+		Or  []json.RawMessage `json:"or"`
+		Not json.RawMessage   `json:"not"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid logical filter: %w", err)
+	}
+
+	and, err := parseFilterList("and", raw.And)
+	if err != nil {
+		return err
+	}
+	or, err := parseFilterList("or", raw.Or)
+	if err != nil {
+		return err
+	}
+
+	var not FilterExpr
+	if len(raw.Not) > 0 {
+		not, err = ParseFilterExpr(raw.Not)
+		if err != nil {
+			return fmt.Errorf("not: %w", err)
+		}
+	}
+
+	l.And = and
+	l.Or = or
+	l.Not = not
+	return nil
+}
+
+func parseFilterList(op string, items []json.RawMessage) ([]FilterExpr, error) {
+	if items == nil {
+		return nil, nil
+	}
+
+	exprs := make([]FilterExpr, 0, len(items))
+	for i, item := range items {
+		expr, err := ParseFilterExpr(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", op, i, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// UnmarshalJSON accepts either a bare field path ("status") or an object
+// ({"field": "created_at", "granularity": "day", "timezone": "UTC"})
+func (g *GroupBy) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var field string
+		if err := json.Unmarshal(data, &field); err != nil {
+			return fmt.Errorf("invalid group_by entry: %w", err)
+		}
+		*g = GroupBy{Field: field}
+		return nil
+	}
+
+	type plain GroupBy
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("invalid group_by entry: %w", err)
+	}
+	*g = GroupBy(p)
+	return nil
+}
+
+// MarshalJSON encodes plain grouping keys as a bare field path so that
+// existing queries round-trip unchanged
+func (g GroupBy) MarshalJSON() ([]byte, error) {
+	if g.Granularity == "" && g.Timezone == "" && g.Alias == "" {
+		return json.Marshal(g.Field)
+	}
+	type plain GroupBy
+	return json.Marshal(plain(g))
+}
+
+// UnmarshalJSON decodes an aggregate, parsing its optional filter as a
+// nested filter expression
+func (a *Aggregate) UnmarshalJSON(data []byte) error {
+	type plain Aggregate
+	var raw struct {
+		plain
+		Filter json.RawMessage `json:"filter"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid aggregate: %w", err)
+	}
+
+	filter, err := ParseFilterExpr(raw.Filter)
+	if err != nil {
+		return fmt.Errorf("invalid aggregate filter: %w", err)
+	}
+
+	*a = Aggregate(raw.plain)
+	a.Filter = filter
+	return nil
+}
@@ -0,0 +1,158 @@
+package dsl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilterExpr_Comparison(t *testing.T) {
+	expr, err := ParseFilterExpr([]byte(`{"field": "status", "op": "=", "value": "PAID"}`))
+	if err != nil {
+		t.Fatalf("ParseFilterExpr() error = %v", err)
+	}
+
+	cf, ok := expr.(*ComparisonFilter)
+	if !ok {
+		t.Fatalf("ParseFilterExpr() = %T, want *ComparisonFilter", expr)
+	}
+	if cf.Field != "status" || cf.Op != OpEqual || cf.Value != "PAID" {
+		t.Errorf("ParseFilterExpr() = %+v", cf)
+	}
+}
+
+func TestParseFilterExpr_Nested(t *testing.T) {
+	data := `{
+		"or": [
+			{"and": [
+				{"field": "status", "op": "=", "value": "paid"},
+				{"field": "amount", "op": ">", "value": 100}
+			]},
+			{"and": [
+				{"field": "status", "op": "=", "value": "refunded"},
+				{"not": {"field": "region", "op": "=", "value": "EU"}}
+			]}
+		]
+	}`
+
+	expr, err := ParseFilterExpr([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseFilterExpr() error = %v", err)
+	}
+
+	root, ok := expr.(*LogicalFilter)
+	if !ok || len(root.Or) != 2 {
+		t.Fatalf("ParseFilterExpr() root = %#v, want OR with 2 children", expr)
+	}
+
+	second, ok := root.Or[1].(*LogicalFilter)
+	if !ok || len(second.And) != 2 {
+		t.Fatalf("Or[1] = %#v, want AND with 2 children", root.Or[1])
+	}
+
+	not, ok := second.And[1].(*LogicalFilter)
+	if !ok || not.Not == nil {
+		t.Fatalf("Or[1].And[1] = %#v, want NOT", second.And[1])
+	}
+	if cf, ok := not.Not.(*ComparisonFilter); !ok || cf.Field != "region" {
+		t.Errorf("Or[1].And[1].Not = %#v, want region comparison", not.Not)
+	}
+}
+
+func TestParseFilterExpr_RoundTrip(t *testing.T) {
+	original := &LogicalFilter{
+		And: []FilterExpr{
+			&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"},
+			&LogicalFilter{Not: &ComparisonFilter{Field: "notes", Op: OpIsNull}},
+		},
+	}
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	expr, err := ParseFilterExpr(data)
+	if err != nil {
+		t.Fatalf("ParseFilterExpr() error = %v", err)
+	}
+
+	lf, ok := expr.(*LogicalFilter)
+	if !ok || len(lf.And) != 2 {
+		t.Fatalf("ParseFilterExpr() = %#v, want AND with 2 children", expr)
+	}
+	if _, ok := lf.And[1].(*LogicalFilter); !ok {
+		t.Errorf("And[1] = %T, want *LogicalFilter", lf.And[1])
+	}
+}
+
+func TestParseFilterExpr_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not an object", `[1, 2]`},
+		{"mixed field and group", `{"field": "status", "and": []}`},
+		{"bad child", `{"and": ["status"]}`},
+		{"bad not", `{"not": 5}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFilterExpr([]byte(tt.data)); err == nil {
+				t.Errorf("ParseFilterExpr(%s) error = nil, want error", tt.data)
+			}
+		})
+	}
+}
+
+func TestGroupByJSON(t *testing.T) {
+	var q Query
+	data := []byte(`{"model": "orders", "group_by": ["status", {"field": "created_at", "granularity": "week", "timezone": "UTC"}]}`)
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := []GroupBy{
+		{Field: "status"},
+		{Field: "created_at", Granularity: GranularityWeek, Timezone: "UTC"},
+	}
+	if !reflect.DeepEqual(q.GroupBy, want) {
+		t.Fatalf("GroupBy = %+v, want %+v", q.GroupBy, want)
+	}
+	if q.GroupBy[1].Name() != "created_at_week" {
+		t.Errorf("Name() = %s, want created_at_week", q.GroupBy[1].Name())
+	}
+
+	out, err := json.Marshal(q.GroupBy)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(out) != `["status",{"field":"created_at","granularity":"week","timezone":"UTC"}]` {
+		t.Errorf("Marshal() = %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"group_by": [42]}`), &q); err == nil {
+		t.Errorf("Unmarshal() error = nil, want error for numeric group_by entry")
+	}
+}
+
+func TestAggregateJSON_Filter(t *testing.T) {
+	var agg Aggregate
+	data := []byte(`{"fn": "count", "alias": "paid", "filter": {"or": [{"field": "status", "op": "=", "value": "PAID"}, {"field": "status", "op": "=", "value": "SETTLED"}]}}`)
+	if err := json.Unmarshal(data, &agg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if agg.Function != AggCount || agg.Alias != "paid" {
+		t.Errorf("Aggregate = %+v, want count paid", agg)
+	}
+	lf, ok := agg.Filter.(*LogicalFilter)
+	if !ok || len(lf.Or) != 2 {
+		t.Fatalf("Filter = %#v, want or-group with 2 conditions", agg.Filter)
+	}
+
+	if err := json.Unmarshal([]byte(`{"fn": "count", "alias": "n", "filter": [1]}`), &agg); err == nil {
+		t.Errorf("Unmarshal() error = nil, want error for non-object filter")
+	}
+}
@@ -0,0 +1,157 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"udv/internal/limits"
+)
+
+// Output columns of a field values query
+const (
+	FieldValuesValueColumn = "value"
+	FieldValuesCountColumn = "count"
+)
+
+// DefaultFieldValuesLimit is the number of values returned when a field
+// values request sets no limit
+const DefaultFieldValuesLimit = 50
+
+// FieldValuesQuery requests the distinct values of a field among the rows
+// matching Filters, each with the number of rows that have it, e.g. to
+// offer facets in a filter dropdown. Prefix keeps only values starting
+// with it, ignoring case.
+type FieldValuesQuery struct {
+	Model    string     `json:"model"`
+	Field    string     `json:"field"`
+	Filters  FilterExpr `json:"filters,omitempty"`
+	Prefix   string     `json:"prefix,omitempty"`
+	Limit    int        `json:"limit,omitempty"`
+	Timezone string     `json:"timezone,omitempty"`
+}
+
+// Query returns the grouped query answering q: one row per value with
+// columns "value" and "count", most frequent first, ties by value
+func (q *FieldValuesQuery) Query() *Query {
+	filters := q.Filters
+	if q.Prefix != "" {
+		prefix := &ComparisonFilter{Field: q.Field, Op: OpILike, Value: EscapeLikePattern(q.Prefix) + "%"}
+		if and, ok := filters.(*LogicalFilter); ok && len(and.Or) == 0 && and.Not == nil {
+			// Extend a top-level and rather than nesting it one level deeper
+			filters = &LogicalFilter{And: append(append([]FilterExpr{}, and.And...), prefix)}
+		} else if filters != nil {
+			filters = &LogicalFilter{And: []FilterExpr{filters, prefix}}
+		} else {
+			filters = prefix
+		}
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultFieldValuesLimit
+	}
+
+	return &Query{
+		Model:      q.Model,
+		Filters:    filters,
+		GroupBy:    []GroupBy{{Field: q.Field, Alias: FieldValuesValueColumn}},
+		Aggregates: []Aggregate{{Function: AggCount, Alias: FieldValuesCountColumn}},
+		Sort: []Sort{
+			{Field: FieldValuesCountColumn, Direction: SortDesc},
+			{Field: q.Field, Direction: SortAsc},
+		},
+		Pagination: &Pagination{Limit: limit},
+		Timezone:   q.Timezone,
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards and the default escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLikePattern escapes s for use in a LIKE pattern so it matches
+// literally
+func EscapeLikePattern(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// LikePattern returns the LIKE pattern a pattern operator matches its value
+// with. Like the SQL dialects, starts_with, ends_with and contains wrap the
+// value in % without escaping it.
+func LikePattern(op FilterOperator, value string) string {
+	switch op {
+	case OpStartsWith:
+		return value + "%"
+	case OpEndsWith:
+		return "%" + value
+	case OpContains:
+		return "%" + value + "%"
+	}
+	return value
+}
+
+// LikeRegexp translates a LIKE pattern (% any run, _ any character,
+// backslash escaping the next character) into an anchored regular
+// expression, for engines without LIKE. Callers add the flags for
+// case-insensitive and multi-line matching.
+func LikeRegexp(pattern string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return "", fmt.Errorf("LIKE pattern must not end with escape character")
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// ValidateFieldValues validates a field values request. The field must be
+// groupable, and filterable too when a prefix is given; the generated query
+// then goes through the same checks as any other query.
+func (v *Validator) ValidateFieldValues(q *FieldValuesQuery) error {
+	if q == nil {
+		return fmt.Errorf("query is nil")
+	}
+
+	if q.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if !v.registry.ModelExists(q.Model) {
+		return fmt.Errorf("model not found: %s", q.Model)
+	}
+
+	if q.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	f, err := v.resolveField(q.Model, q.Field)
+	if err != nil {
+		return fmt.Errorf("invalid field: %v", err)
+	}
+	if !f.Groupable {
+		return fmt.Errorf("field is not groupable: %s", q.Field)
+	}
+	if q.Prefix != "" && f.Type != "string" {
+		return fmt.Errorf("prefix requires a string field, %s is %s", q.Field, f.Type)
+	}
+
+	if q.Limit < 0 || q.Limit > limits.MaxFieldValues {
+		return fmt.Errorf("limit must be between 1 and %d", limits.MaxFieldValues)
+	}
+
+	return v.ValidateQuery(q.Query())
+}
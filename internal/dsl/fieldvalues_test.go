@@ -0,0 +1,120 @@
+package dsl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldValuesQuery_Query(t *testing.T) {
+	q := (&FieldValuesQuery{Model: "orders", Field: "status"}).Query()
+
+	if q.Filters != nil {
+		t.Errorf("Filters = %#v, want nil", q.Filters)
+	}
+	if !reflect.DeepEqual(q.GroupBy, []GroupBy{{Field: "status", Alias: "value"}}) {
+		t.Errorf("GroupBy = %+v", q.GroupBy)
+	}
+	if !reflect.DeepEqual(q.Aggregates, []Aggregate{{Function: AggCount, Alias: "count"}}) {
+		t.Errorf("Aggregates = %+v", q.Aggregates)
+	}
+	wantSort := []Sort{{Field: "count", Direction: SortDesc}, {Field: "status", Direction: SortAsc}}
+	if !reflect.DeepEqual(q.Sort, wantSort) {
+		t.Errorf("Sort = %+v, want %+v", q.Sort, wantSort)
+	}
+	if q.Pagination.Limit != DefaultFieldValuesLimit {
+		t.Errorf("Limit = %d, want %d", q.Pagination.Limit, DefaultFieldValuesLimit)
+	}
+
+	// The prefix matches literally and joins a top-level and
+	amount := &ComparisonFilter{Field: "amount", Op: OpGT, Value: 10}
+	q = (&FieldValuesQuery{
+		Model:   "orders",
+		Field:   "status",
+		Filters: &LogicalFilter{And: []FilterExpr{amount}},
+		Prefix:  "50%_off",
+		Limit:   5,
+	}).Query()
+	want := &LogicalFilter{And: []FilterExpr{
+		amount,
+		&ComparisonFilter{Field: "status", Op: OpILike, Value: `50\%\_off%`},
+	}}
+	if !reflect.DeepEqual(q.Filters, want) {
+		t.Errorf("Filters = %#v, want %#v", q.Filters, want)
+	}
+	if q.Pagination.Limit != 5 {
+		t.Errorf("Limit = %d, want 5", q.Pagination.Limit)
+	}
+}
+
+func TestValidateFieldValues(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	ok := &FieldValuesQuery{
+		Model:   "orders",
+		Field:   "status",
+		Filters: &ComparisonFilter{Field: "created_at", Op: OpAfter, Value: "now-30d"},
+		Prefix:  "pa",
+	}
+	if err := v.ValidateFieldValues(ok); err != nil {
+		t.Errorf("ValidateFieldValues() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name   string
+		query  *FieldValuesQuery
+		errMsg string
+	}{
+		{"unknown field", &FieldValuesQuery{Model: "orders", Field: "colour"}, "invalid field"},
+		{"prefix on number", &FieldValuesQuery{Model: "orders", Field: "amount", Prefix: "1"}, "prefix requires a string field, amount is decimal"},
+		{"limit too high", &FieldValuesQuery{Model: "orders", Field: "status", Limit: 5000}, "limit must be between 1 and 1000"},
+		{
+			"invalid filter",
+			&FieldValuesQuery{Model: "orders", Field: "status", Filters: &ComparisonFilter{Field: "amount", Op: OpEqual, Value: "lots"}},
+			"invalid value for field amount",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateFieldValues(tt.query)
+			if err == nil {
+				t.Fatalf("ValidateFieldValues() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateFieldValues() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestLikeRegexp(t *testing.T) {
+	tests := []struct {
+		op      FilterOperator
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{OpLike, "P_ID%", `^P.ID.*$`, false},
+		{OpLike, `100\%`, `^100%$`, false},
+		{OpStartsWith, "a.c", `^a\.c.*$`, false},
+		{OpEndsWith, "_D", `^.*.D$`, false},
+		{OpContains, "x", `^.*x.*$`, false},
+		{OpLike, `ends\`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := LikeRegexp(LikePattern(tt.op, tt.value))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("LikeRegexp(%s %q) error = nil, want error", tt.op, tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("LikeRegexp(%s %q) error: %v", tt.op, tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("LikeRegexp(%s %q) = %q, want %q", tt.op, tt.value, got, tt.want)
+		}
+	}
+}
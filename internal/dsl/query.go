@@ -2,7 +2,9 @@ package dsl
 
 import (
 	"fmt"
+	"time"
 
+	"udv/internal/limits"
 	"udv/internal/schema"
 )
 
@@ -33,8 +35,79 @@ const (
 	OpBefore  FilterOperator = "before"
 	OpAfter   FilterOperator = "after"
 	OpBetween FilterOperator = "between"
+
+	// Range operators; like between, their value is [lower, upper] and
+	// either bound may be null for an open-ended range
+	OpGTELT FilterOperator = "gte_lt"  // lower <= field < upper
+	OpGTLTE FilterOperator = "gt_lte"  // lower < field <= upper
+
+	// Full-text search; without a field it matches the model's search document
+	OpSearch FilterOperator = "search"
+
+	// Fuzzy match by trigram similarity, for string fields
+	OpSimilar FilterOperator = "similar"
+
+	// JSON operators, for json fields and JSON paths
+	OpHasKey       FilterOperator = "has_key"       // document has a top-level key
+	OpJSONContains FilterOperator = "json_contains" // document contains the value
 )
 
+// SearchRankField is the sort field that orders rows by full-text search
+// relevance to the query's search filter
+const SearchRankField = "_rank"
+
+// SimilarityField is the sort field that orders rows by trigram similarity
+// to the query's similar filter
+const SimilarityField = "_similarity"
+
+// DefaultSimilarityThreshold is the similarity a similar filter requires
+// when it sets no threshold (pg_trgm's default)
+const DefaultSimilarityThreshold = 0.3
+
+// FindSearchFilter returns the first model-level search filter (one without
+// a field) in a filter tree, or nil. Negated filters are not considered.
+func FindSearchFilter(expr FilterExpr) *ComparisonFilter {
+	return findFilter(expr, func(f *ComparisonFilter) bool {
+		return f.Op == OpSearch && f.Field == ""
+	})
+}
+
+// FindSimilarFilter returns the first similar filter in a filter tree, or
+// nil. Negated filters are not considered.
+func FindSimilarFilter(expr FilterExpr) *ComparisonFilter {
+	return findFilter(expr, func(f *ComparisonFilter) bool {
+		return f.Op == OpSimilar
+	})
+}
+
+func findFilter(expr FilterExpr, match func(*ComparisonFilter) bool) *ComparisonFilter {
+	switch e := expr.(type) {
+	case *ComparisonFilter:
+		if match(e) {
+			return e
+		}
+	case *LogicalFilter:
+		for _, children := range [][]FilterExpr{e.And, e.Or} {
+			for _, child := range children {
+				if f := findFilter(child, match); f != nil {
+					return f
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// IsJSONOperator reports whether op applies to a JSON document
+func IsJSONOperator(op FilterOperator) bool {
+	return op == OpHasKey || op == OpJSONContains
+}
+
+// IsRangeOperator reports whether op takes a [lower, upper] bounds value
+func IsRangeOperator(op FilterOperator) bool {
+	return op == OpBetween || op == OpGTELT || op == OpGTLTE
+}
+
 // AggregateFunc represents an aggregate function
 type AggregateFunc string
 
@@ -44,8 +117,45 @@ const (
 	AggAvg   AggregateFunc = "avg"
 	AggMin   AggregateFunc = "min"
 	AggMax   AggregateFunc = "max"
+
+	// Extended aggregate functions
+	AggCountDistinct AggregateFunc = "count_distinct"
+	AggMedian        AggregateFunc = "median"
+	AggPercentile    AggregateFunc = "percentile"
+	AggStddev        AggregateFunc = "stddev"
+	AggVariance      AggregateFunc = "variance"
+	AggStringAgg     AggregateFunc = "string_agg"
+	AggArrayAgg      AggregateFunc = "array_agg"
+	AggBoolAnd       AggregateFunc = "bool_and"
+	AggBoolOr        AggregateFunc = "bool_or"
 )
 
+// DefaultStringAggSeparator joins string_agg values when no separator is given
+const DefaultStringAggSeparator = ", "
+
+// TimeGranularity represents the bucket size for grouping on a time field
+type TimeGranularity string
+
+const (
+	GranularityMinute  TimeGranularity = "minute"
+	GranularityHour    TimeGranularity = "hour"
+	GranularityDay     TimeGranularity = "day"
+	GranularityWeek    TimeGranularity = "week"
+	GranularityMonth   TimeGranularity = "month"
+	GranularityQuarter TimeGranularity = "quarter"
+	GranularityYear    TimeGranularity = "year"
+)
+
+var validGranularities = map[TimeGranularity]bool{
+	GranularityMinute:  true,
+	GranularityHour:    true,
+	GranularityDay:     true,
+	GranularityWeek:    true,
+	GranularityMonth:   true,
+	GranularityQuarter: true,
+	GranularityYear:    true,
+}
+
 // SortDirection represents sort order
 type SortDirection string
 
@@ -59,10 +169,32 @@ type Query struct {
 	Model      string         `json:"model"`
 	Fields     []string       `json:"fields,omitempty"`
 	Filters    FilterExpr     `json:"filters,omitempty"`
-	GroupBy    []string       `json:"group_by,omitempty"`
+	GroupBy    []GroupBy      `json:"group_by,omitempty"`
 	Aggregates []Aggregate    `json:"aggregates,omitempty"`
+	Having     FilterExpr     `json:"having,omitempty"`
 	Sort       []Sort         `json:"sort,omitempty"`
 	Pagination *Pagination    `json:"pagination,omitempty"`
+	Timezone   string         `json:"timezone,omitempty"` // resolves relative dates; default UTC
+	Count      CountMode      `json:"count,omitempty"`
+	Windows    []Window       `json:"windows,omitempty"`
+}
+
+// CountMode selects whether and how the total number of result rows
+// (across all pages) is computed
+type CountMode string
+
+const (
+	CountNone     CountMode = ""         // no total
+	CountExact    CountMode = "exact"    // run a COUNT(*) of the query
+	CountEstimate CountMode = "estimate" // use planner statistics; fast on huge tables
+)
+
+// Location returns the request timezone, UTC when none is set
+func (q *Query) Location() (*time.Location, error) {
+	if q.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(q.Timezone)
 }
 
 // FilterExpr represents a filter expression (can be AND, OR, NOT, or atomic)
@@ -70,11 +202,12 @@ type FilterExpr interface {
 	isFilterExpr()
 }
 
-// LogicalFilter represents AND/OR/NOT operators
+// LogicalFilter represents AND/OR/NOT operators. Exactly one of And, Or
+// or Not must be set; their children may be any FilterExpr, so groups nest.
 type LogicalFilter struct {
-	And []*ComparisonFilter `json:"and,omitempty"`
-	Or  []*ComparisonFilter `json:"or,omitempty"`
-	Not *ComparisonFilter   `json:"not,omitempty"`
+	And []FilterExpr `json:"and,omitempty"`
+	Or  []FilterExpr `json:"or,omitempty"`
+	Not FilterExpr   `json:"not,omitempty"`
 }
 
 func (l *LogicalFilter) isFilterExpr() {}
@@ -84,15 +217,43 @@ type ComparisonFilter struct {
 	Field string        `json:"field"`
 	Op    FilterOperator `json:"op"`
 	Value interface{}   `json:"value,omitempty"`
+
+	// Threshold is the minimum similarity (0-1] for the similar operator
+	Threshold *float64 `json:"threshold,omitempty"`
 }
 
 func (c *ComparisonFilter) isFilterExpr() {}
 
 // Aggregate represents an aggregate function
 type Aggregate struct {
-	Function AggregateFunc `json:"fn"`
-	Field    string        `json:"field,omitempty"`
-	Alias    string        `json:"alias"`
+	Function  AggregateFunc `json:"fn"`
+	Field     string        `json:"field,omitempty"`
+	Alias     string        `json:"alias"`
+	Fraction  *float64      `json:"fraction,omitempty"`  // percentile only, between 0 and 1
+	Separator *string       `json:"separator,omitempty"` // string_agg only
+	Filter    FilterExpr    `json:"filter,omitempty"`    // only rows matching the filter are aggregated
+}
+
+// GroupBy represents a grouping key. A time field may be bucketed to a
+// granularity, optionally in a given IANA timezone.
+type GroupBy struct {
+	Field       string          `json:"field"`
+	Granularity TimeGranularity `json:"granularity,omitempty"`
+	Timezone    string          `json:"timezone,omitempty"`
+	Alias       string          `json:"alias,omitempty"`
+}
+
+// Name returns the output column name of the grouping key: the explicit
+// alias, the field path for plain keys, or "<field>_<granularity>" for
+// bucketed keys
+func (g GroupBy) Name() string {
+	if g.Alias != "" {
+		return g.Alias
+	}
+	if g.Granularity != "" {
+		return g.Field + "_" + string(g.Granularity)
+	}
+	return g.Field
 }
 
 // Sort represents a sort specification
@@ -101,20 +262,37 @@ type Sort struct {
 	Direction SortDirection `json:"direction,omitempty"`
 }
 
-// Pagination represents pagination parameters
+// Pagination represents pagination parameters. Keyset asks for cursor
+// pagination from the first page on; Cursor continues after a previous page.
 type Pagination struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset,omitempty"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset,omitempty"`
+	Keyset bool   `json:"keyset,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// IsKeyset reports whether the query uses keyset (cursor) pagination
+func (p *Pagination) IsKeyset() bool {
+	return p != nil && (p.Keyset || p.Cursor != "")
 }
 
 // Validator validates queries against schema
 type Validator struct {
-	registry *schema.Registry
+	registry       *schema.Registry
+	maxFilterDepth int
 }
 
 // NewValidator creates a new query validator
 func NewValidator(reg *schema.Registry) *Validator {
-	return &Validator{registry: reg}
+	return &Validator{
+		registry:       reg,
+		maxFilterDepth: limits.DefaultMaxFilterDepth,
+	}
+}
+
+// SetMaxFilterDepth sets how deeply and/or/not groups may be nested
+func (v *Validator) SetMaxFilterDepth(depth int) {
+	v.maxFilterDepth = depth
 }
 
 // ValidateQuery validates a complete query
@@ -131,6 +309,16 @@ func (v *Validator) ValidateQuery(q *Query) error {
 		return fmt.Errorf("model not found: %s", q.Model)
 	}
 
+	if _, err := q.Location(); err != nil {
+		return fmt.Errorf("invalid timezone: %s", q.Timezone)
+	}
+
+	switch q.Count {
+	case CountNone, CountExact, CountEstimate:
+	default:
+		return fmt.Errorf("invalid count mode: %s (expected exact or estimate)", q.Count)
+	}
+
 	// Validate fields
 	if err := v.validateFields(q.Model, q.Fields); err != nil {
 		return err
@@ -147,14 +335,33 @@ func (v *Validator) ValidateQuery(q *Query) error {
 	if err := v.validateGroupBy(q.Model, q.GroupBy); err != nil {
 		return err
 	}
+	// Listed fields replace the group keys in SELECT, so a bucket would be
+	// grouped on but never selected
+	if len(q.Fields) > 0 {
+		for _, g := range q.GroupBy {
+			if g.Granularity != "" {
+				return fmt.Errorf("fields cannot be combined with a bucketed group_by on %s", g.Field)
+			}
+		}
+	}
 
 	// Validate aggregates
 	if err := v.validateAggregates(q.Model, q.Aggregates, len(q.GroupBy) > 0); err != nil {
 		return err
 	}
 
+	// Validate having
+	if err := v.validateHaving(q); err != nil {
+		return err
+	}
+
+	// Validate windows
+	if err := v.validateWindows(q); err != nil {
+		return err
+	}
+
 	// Validate sort
-	if err := v.validateSort(q.Model, q.Sort); err != nil {
+	if err := v.validateSort(q); err != nil {
 		return err
 	}
 
@@ -162,6 +369,9 @@ func (v *Validator) ValidateQuery(q *Query) error {
 	if err := v.validatePagination(q.Pagination); err != nil {
 		return err
 	}
+	if err := v.validateKeyset(q); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -175,70 +385,156 @@ func (v *Validator) validateFields(modelName string, fields []string) error {
 		if field == "" {
 			return fmt.Errorf("field name cannot be empty")
 		}
-		if !v.registry.FieldExists(modelName, field) {
-			return fmt.Errorf("field not found in model %s: %s", modelName, field)
+		if _, err := v.resolveField(modelName, field); err != nil {
+			return fmt.Errorf("invalid field in model %s: %v", modelName, err)
 		}
 	}
 	return nil
 }
 
 func (v *Validator) validateFilterExpr(modelName string, expr FilterExpr) error {
+	return v.validateFilterTree(expr, 0, func(f *ComparisonFilter) error {
+		return v.validateComparisonFilter(modelName, f)
+	})
+}
+
+// validateFilterTree checks the and/or/not structure of a filter expression
+// and hands every comparison to validateLeaf
+func (v *Validator) validateFilterTree(expr FilterExpr, depth int, validateLeaf func(*ComparisonFilter) error) error {
 	switch e := expr.(type) {
 	case *LogicalFilter:
+		if e == nil {
+			return fmt.Errorf("filter expression is empty")
+		}
+		if depth+1 > v.maxFilterDepth {
+			return fmt.Errorf("filter nesting exceeds maximum depth of %d", v.maxFilterDepth)
+		}
+
+		set := 0
 		if e.And != nil {
-			for _, f := range e.And {
-				if err := v.validateComparisonFilter(modelName, f); err != nil {
-					return err
-				}
+			set++
+		}
+		if e.Or != nil {
+			set++
+		}
+		if e.Not != nil {
+			set++
+		}
+		if set != 1 {
+			return fmt.Errorf("logical filter must have exactly one of and, or, not")
+		}
+
+		if e.And != nil {
+			if err := v.validateFilterList("and", e.And, depth+1, validateLeaf); err != nil {
+				return err
 			}
 		}
 		if e.Or != nil {
-			for _, f := range e.Or {
-				if err := v.validateComparisonFilter(modelName, f); err != nil {
-					return err
-				}
+			if err := v.validateFilterList("or", e.Or, depth+1, validateLeaf); err != nil {
+				return err
 			}
 		}
 		if e.Not != nil {
-			if err := v.validateComparisonFilter(modelName, e.Not); err != nil {
+			if err := v.validateFilterTree(e.Not, depth+1, validateLeaf); err != nil {
 				return err
 			}
 		}
 		return nil
 
 	case *ComparisonFilter:
-		return v.validateComparisonFilter(modelName, e)
+		if e == nil {
+			return fmt.Errorf("filter expression is empty")
+		}
+		return validateLeaf(e)
+
+	case nil:
+		return fmt.Errorf("filter expression is empty")
 
 	default:
 		return fmt.Errorf("invalid filter expression type")
 	}
 }
 
+func (v *Validator) validateFilterList(op string, exprs []FilterExpr, depth int, validateLeaf func(*ComparisonFilter) error) error {
+	if len(exprs) == 0 {
+		return fmt.Errorf("%s filter must have at least one condition", op)
+	}
+	for i, f := range exprs {
+		if err := v.validateFilterTree(f, depth, validateLeaf); err != nil {
+			return fmt.Errorf("%s[%d]: %w", op, i, err)
+		}
+	}
+	return nil
+}
+
 func (v *Validator) validateComparisonFilter(modelName string, f *ComparisonFilter) error {
 	if f == nil {
 		return nil
 	}
 
+	if f.Threshold != nil {
+		if f.Op != OpSimilar {
+			return fmt.Errorf("threshold is only valid for the similar operator, got %s", f.Op)
+		}
+		if *f.Threshold <= 0 || *f.Threshold > 1 {
+			return fmt.Errorf("similarity threshold must be in (0, 1], got %v", *f.Threshold)
+		}
+	}
+
 	if f.Field == "" {
+		if f.Op == OpSearch {
+			return v.validateModelSearch(modelName, f)
+		}
 		return fmt.Errorf("filter field is required")
 	}
 
-	// Check field exists
-	field, err := v.registry.GetField(modelName, f.Field)
+	// Check field exists (directly or through relations)
+	fp, err := v.registry.ResolveFieldPath(modelName, f.Field)
 	if err != nil {
 		return fmt.Errorf("invalid filter field: %v", err)
 	}
+	field := fieldOfPath(fp)
 
 	// Check field is filterable
 	if !field.Filterable {
 		return fmt.Errorf("field is not filterable: %s", f.Field)
 	}
 
+	// JSON operators see the document an uncast JSON path points at, not
+	// its text
+	if IsJSONOperator(f.Op) && fp.IsJSONDocument() {
+		field.Type = "json"
+	}
+
 	// Validate operator for field type
 	if err := v.validateOperatorForType(f.Op, field.Type, f.Value); err != nil {
 		return fmt.Errorf("invalid filter operator for field %s: %v", f.Field, err)
 	}
 
+	// Relative dates are resolved at plan time; here only their syntax matters
+	value, _, err := ResolveRelativeValue(field.Type, f.Op, f.Value, time.Now(), time.UTC)
+	if err != nil {
+		return fmt.Errorf("invalid value for field %s: %v", f.Field, err)
+	}
+
+	// Validate the value fits the field type
+	if _, err := CoerceValue(field.Type, f.Op, value); err != nil {
+		return fmt.Errorf("invalid value for field %s: %v", f.Field, err)
+	}
+
+	return nil
+}
+
+// validateModelSearch checks a search filter without a field, which matches
+// the model's configured search document
+func (v *Validator) validateModelSearch(modelName string, f *ComparisonFilter) error {
+	model := v.registry.GetModel(modelName)
+	if model == nil || !model.Searchable() {
+		return fmt.Errorf("model %s has no searchFields or searchVector configured", modelName)
+	}
+	if _, err := CoerceValue("string", f.Op, f.Value); err != nil {
+		return fmt.Errorf("invalid search value: %v", err)
+	}
 	return nil
 }
 
@@ -254,13 +550,24 @@ func (v *Validator) validateOperatorForType(op FilterOperator, fieldType string,
 		return nil
 	}
 
-	if op == OpBetween {
-		// Between requires array value
+	if IsRangeOperator(op) {
+		// Ranges need an ordered type; the bounds are checked by CoerceValue
+		switch fieldType {
+		case "boolean", "json", "binary", "uuid":
+			return fmt.Errorf("range operator %s not valid for type %s", op, fieldType)
+		}
+		return nil
+	}
+
+	if IsJSONOperator(op) {
+		if fieldType != "json" {
+			return fmt.Errorf("json operator %s not valid for type %s", op, fieldType)
+		}
 		return nil
 	}
 
 	// String operators
-	if op == OpLike || op == OpILike || op == OpStartsWith || op == OpEndsWith || op == OpContains {
+	if op == OpLike || op == OpILike || op == OpStartsWith || op == OpEndsWith || op == OpContains || op == OpSearch || op == OpSimilar {
 		if fieldType != "string" {
 			return fmt.Errorf("string operator %s not valid for type %s", op, fieldType)
 		}
@@ -286,40 +593,83 @@ func (v *Validator) validateOperatorForType(op FilterOperator, fieldType string,
 	return fmt.Errorf("unknown operator: %s", op)
 }
 
-func (v *Validator) validateGroupBy(modelName string, groupBy []string) error {
+func (v *Validator) validateGroupBy(modelName string, groupBy []GroupBy) error {
 	if len(groupBy) == 0 {
 		return nil
 	}
 
-	for _, field := range groupBy {
-		if field == "" {
+	names := make(map[string]bool, len(groupBy))
+	for _, g := range groupBy {
+		if g.Field == "" {
 			return fmt.Errorf("group_by field cannot be empty")
 		}
 
-		f, err := v.registry.GetField(modelName, field)
+		f, err := v.resolveField(modelName, g.Field)
 		if err != nil {
 			return fmt.Errorf("invalid group_by field: %v", err)
 		}
 
 		if !f.Groupable {
-			return fmt.Errorf("field is not groupable: %s", field)
+			return fmt.Errorf("field is not groupable: %s", g.Field)
 		}
+
+		if g.Granularity != "" {
+			if !validGranularities[g.Granularity] {
+				return fmt.Errorf("invalid granularity for %s: %s", g.Field, g.Granularity)
+			}
+			if !isTimeType(f.Type) {
+				return fmt.Errorf("granularity requires a date or timestamp field, %s is %s", g.Field, f.Type)
+			}
+		}
+
+		if g.Timezone != "" {
+			if g.Granularity == "" {
+				return fmt.Errorf("timezone requires a granularity on %s", g.Field)
+			}
+			if _, err := time.LoadLocation(g.Timezone); err != nil {
+				return fmt.Errorf("invalid timezone for %s: %s", g.Field, g.Timezone)
+			}
+		}
+
+		name := g.Name()
+		if names[name] {
+			return fmt.Errorf("duplicate group_by key: %s", name)
+		}
+		names[name] = true
 	}
 
 	return nil
 }
 
+// isTimeType reports whether a field type can be bucketed by granularity
+func isTimeType(fieldType string) bool {
+	switch fieldType {
+	case "date", "datetime", "timestamp":
+		return true
+	}
+	return false
+}
+
 func (v *Validator) validateAggregates(modelName string, aggs []Aggregate, hasGroupBy bool) error {
 	if len(aggs) == 0 {
 		return nil
 	}
 
 	validFuncs := map[AggregateFunc]bool{
-		AggCount: true,
-		AggSum:   true,
-		AggAvg:   true,
-		AggMin:   true,
-		AggMax:   true,
+		AggCount:         true,
+		AggSum:           true,
+		AggAvg:           true,
+		AggMin:           true,
+		AggMax:           true,
+		AggCountDistinct: true,
+		AggMedian:        true,
+		AggPercentile:    true,
+		AggStddev:        true,
+		AggVariance:      true,
+		AggStringAgg:     true,
+		AggArrayAgg:      true,
+		AggBoolAnd:       true,
+		AggBoolOr:        true,
 	}
 
 	for i, agg := range aggs {
@@ -331,6 +681,27 @@ func (v *Validator) validateAggregates(modelName string, aggs []Aggregate, hasGr
 			return fmt.Errorf("aggregate[%d] unknown function: %s", i, agg.Function)
 		}
 
+		if agg.Function == AggPercentile {
+			if agg.Fraction == nil {
+				return fmt.Errorf("aggregate[%d] fraction is required for function %s", i, agg.Function)
+			}
+			if *agg.Fraction < 0 || *agg.Fraction > 1 {
+				return fmt.Errorf("aggregate[%d] fraction must be between 0 and 1, got %v", i, *agg.Fraction)
+			}
+		} else if agg.Fraction != nil {
+			return fmt.Errorf("aggregate[%d] fraction is only valid for function %s", i, AggPercentile)
+		}
+
+		if agg.Separator != nil && agg.Function != AggStringAgg {
+			return fmt.Errorf("aggregate[%d] separator is only valid for function %s", i, AggStringAgg)
+		}
+
+		if agg.Filter != nil {
+			if err := v.validateFilterExpr(modelName, agg.Filter); err != nil {
+				return fmt.Errorf("aggregate[%d] invalid filter: %w", i, err)
+			}
+		}
+
 		// count can omit field
 		if agg.Function == AggCount && agg.Field == "" {
 			continue
@@ -340,7 +711,7 @@ func (v *Validator) validateAggregates(modelName string, aggs []Aggregate, hasGr
 			return fmt.Errorf("aggregate[%d] field is required for function %s", i, agg.Function)
 		}
 
-		f, err := v.registry.GetField(modelName, agg.Field)
+		f, err := v.resolveField(modelName, agg.Field)
 		if err != nil {
 			return fmt.Errorf("aggregate[%d] invalid field: %v", i, err)
 		}
@@ -363,7 +734,7 @@ func (v *Validator) validateAggregateForType(fn AggregateFunc, fieldType string)
 	case AggCount:
 		return nil // count works on any type
 
-	case AggSum, AggAvg:
+	case AggSum, AggAvg, AggMedian, AggPercentile, AggStddev, AggVariance:
 		// Only numeric types
 		if fieldType != "integer" && fieldType != "int" && fieldType != "float" && fieldType != "decimal" {
 			return fmt.Errorf("function %s requires numeric field, got %s", fn, fieldType)
@@ -373,23 +744,138 @@ func (v *Validator) validateAggregateForType(fn AggregateFunc, fieldType string)
 	case AggMin, AggMax:
 		return nil // min/max work on comparable types
 
+	case AggCountDistinct, AggArrayAgg:
+		return nil // distinct counts and arrays work on any type
+
+	case AggStringAgg:
+		if fieldType != "string" {
+			return fmt.Errorf("function %s requires string field, got %s", fn, fieldType)
+		}
+		return nil
+
+	case AggBoolAnd, AggBoolOr:
+		if fieldType != "boolean" {
+			return fmt.Errorf("function %s requires boolean field, got %s", fn, fieldType)
+		}
+		return nil
+
 	default:
 		return fmt.Errorf("unknown aggregate function: %s", fn)
 	}
 }
 
-func (v *Validator) validateSort(modelName string, sort []Sort) error {
-	if len(sort) == 0 {
+// validateHaving checks that a having filter is only used on grouped or
+// aggregated queries and that it compares aggregate aliases only
+func (v *Validator) validateHaving(q *Query) error {
+	if q.Having == nil {
 		return nil
 	}
 
-	for i, s := range sort {
+	if len(q.GroupBy) == 0 && len(q.Aggregates) == 0 {
+		return fmt.Errorf("having requires group_by or aggregates")
+	}
+
+	err := v.validateFilterTree(q.Having, 0, func(f *ComparisonFilter) error {
+		if f.Field == "" {
+			return fmt.Errorf("having field is required")
+		}
+
+		agg := findAggregate(q.Aggregates, f.Field)
+		if agg == nil {
+			return fmt.Errorf("having field is not an aggregate alias: %s", f.Field)
+		}
+
+		switch f.Op {
+		case OpEqual, OpNotEqual, OpGT, OpGTE, OpLT, OpLTE, OpIn, OpNotIn, OpBetween, OpGTELT, OpGTLTE, OpIsNull, OpNotNull:
+		default:
+			return fmt.Errorf("operator %s not supported in having", f.Op)
+		}
+
+		if agg.Function == AggArrayAgg && f.Op != OpIsNull && f.Op != OpNotNull {
+			return fmt.Errorf("%s result %s can only be tested for null", AggArrayAgg, f.Field)
+		}
+
+		resultType, err := v.aggregateResultType(q.Model, agg)
+		if err != nil {
+			return err
+		}
+		if err := v.validateOperatorForType(f.Op, resultType, f.Value); err != nil {
+			return fmt.Errorf("invalid having operator for %s: %v", f.Field, err)
+		}
+		if _, err := CoerceValue(resultType, f.Op, f.Value); err != nil {
+			return fmt.Errorf("invalid having value for %s: %v", f.Field, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid having: %w", err)
+	}
+
+	return nil
+}
+
+// aggregateResultType returns the field type an aggregate produces
+func (v *Validator) aggregateResultType(modelName string, agg *Aggregate) (string, error) {
+	switch agg.Function {
+	case AggCount, AggCountDistinct:
+		return "integer", nil
+	case AggSum, AggAvg, AggMedian, AggPercentile, AggStddev, AggVariance:
+		return "decimal", nil
+	case AggStringAgg:
+		return "string", nil
+	case AggArrayAgg:
+		return "json", nil
+	case AggBoolAnd, AggBoolOr:
+		return "boolean", nil
+	default:
+		f, err := v.resolveField(modelName, agg.Field)
+		if err != nil {
+			return "", err
+		}
+		return f.Type, nil
+	}
+}
+
+// findAggregate returns the aggregate with the given alias, or nil
+func findAggregate(aggs []Aggregate, alias string) *Aggregate {
+	for i := range aggs {
+		if aggs[i].Alias == alias {
+			return &aggs[i]
+		}
+	}
+	return nil
+}
+
+// validateSort checks sort entries. A sort field may name either a model
+// field or the alias of an aggregate in the same query; aliases take
+// precedence, matching how ORDER BY resolves output column names. The
+// reserved fields _rank and _similarity order by search relevance and
+// trigram similarity.
+func (v *Validator) validateSort(q *Query) error {
+	if len(q.Sort) == 0 {
+		return nil
+	}
+
+	for i, s := range q.Sort {
 		if s.Field == "" {
 			return fmt.Errorf("sort[%d] field is required", i)
 		}
 
-		if !v.registry.FieldExists(modelName, s.Field) {
-			return fmt.Errorf("sort[%d] field not found: %s", i, s.Field)
+		if s.Field == SearchRankField || s.Field == SimilarityField {
+			// Scores are per row, so they need their filter and no grouping
+			if s.Field == SearchRankField && FindSearchFilter(q.Filters) == nil {
+				return fmt.Errorf("sort[%d] %s requires a search filter without a field", i, SearchRankField)
+			}
+			if s.Field == SimilarityField && FindSimilarFilter(q.Filters) == nil {
+				return fmt.Errorf("sort[%d] %s requires a similar filter", i, SimilarityField)
+			}
+			if len(q.GroupBy) > 0 || len(q.Aggregates) > 0 {
+				return fmt.Errorf("sort[%d] %s is not allowed in an aggregate query", i, s.Field)
+			}
+		} else if findAggregate(q.Aggregates, s.Field) == nil && findWindow(q.Windows, s.Field) == nil {
+			if _, err := v.resolveField(q.Model, s.Field); err != nil {
+				return fmt.Errorf("sort[%d] field not found: %s", i, s.Field)
+			}
 		}
 
 		// Validate direction
@@ -417,6 +903,72 @@ func (v *Validator) validatePagination(p *Pagination) error {
 	return nil
 }
 
+// validateKeyset checks a keyset paginated query. Its sort keys must be
+// plain fields of the model so each page can be read back from the rows,
+// and a cursor must match the query's ordering.
+func (v *Validator) validateKeyset(q *Query) error {
+	if !q.Pagination.IsKeyset() {
+		return nil
+	}
+
+	if q.Pagination.Offset > 0 {
+		return fmt.Errorf("cursor pagination cannot be combined with offset")
+	}
+	if len(q.GroupBy) > 0 || len(q.Aggregates) > 0 {
+		return fmt.Errorf("cursor pagination is not supported for aggregate queries")
+	}
+	// The cursor position filters rows before windows see them
+	if len(q.Windows) > 0 {
+		return fmt.Errorf("cursor pagination cannot be combined with windows")
+	}
+
+	model := v.registry.GetModel(q.Model)
+	sorts := KeysetSort(q, model.PrimaryKey)
+	fields := make([]*schema.Field, len(sorts))
+	for i, s := range sorts {
+		fp, err := v.registry.ResolveFieldPath(q.Model, s.Field)
+		if err != nil || len(fp.Relations) > 0 || len(fp.JSONPath) > 0 {
+			return fmt.Errorf("cursor pagination requires sort fields of model %s itself, got %s", q.Model, s.Field)
+		}
+		fields[i] = fp.Field
+	}
+
+	if q.Pagination.Cursor == "" {
+		return nil
+	}
+	values, err := DecodeCursor(q.Pagination.Cursor, sorts)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if _, err := CoerceScalar(fields[i].Type, value); err != nil {
+			return fmt.Errorf("invalid cursor value for %s: %v", sorts[i].Field, err)
+		}
+	}
+	return nil
+}
+
+// resolveField resolves a plain field name or a dotted path through the
+// model's relations (e.g. "user.email") to its schema field
+func (v *Validator) resolveField(modelName, path string) (*schema.Field, error) {
+	fp, err := v.registry.ResolveFieldPath(modelName, path)
+	if err != nil {
+		return nil, err
+	}
+	return fieldOfPath(fp), nil
+}
+
+// fieldOfPath returns the field a path refers to. For JSON paths this is a
+// copy of the json field carrying the type of the extracted value.
+func fieldOfPath(fp *schema.FieldPath) *schema.Field {
+	field := *fp.Field
+	field.Type = fp.Type()
+	return &field
+}
+
 // Helper function to create a simple comparison filter
 func NewComparisonFilter(field string, op FilterOperator, value interface{}) *ComparisonFilter {
 	return &ComparisonFilter{
@@ -1,6 +1,7 @@
 package dsl
 
 import (
+	"strings"
 	"testing"
 
 	"udv/internal/config"
@@ -21,6 +22,8 @@ func setupTestRegistry() *schema.Registry {
 					{Name: "amount", Type: "decimal", Nullable: false},
 					{Name: "created_at", Type: "timestamp", Nullable: false},
 					{Name: "notes", Type: "string", Nullable: true},
+					{Name: "is_paid", Type: "boolean", Nullable: false},
+					{Name: "metadata", Type: "json", Nullable: true},
 				},
 			},
 			{
@@ -33,6 +36,7 @@ func setupTestRegistry() *schema.Registry {
 					{Name: "email", Type: "string", Nullable: false},
 					{Name: "age", Type: "integer", Nullable: true},
 				},
+				SearchFields: []string{"name", "email"},
 			},
 		},
 	}
@@ -171,9 +175,9 @@ func TestValidateQuery_LogicalFilterAnd(t *testing.T) {
 	query := &Query{
 		Model: "orders",
 		Filters: &LogicalFilter{
-			And: []*ComparisonFilter{
-				{Field: "status", Op: OpEqual, Value: "PAID"},
-				{Field: "amount", Op: OpGT, Value: 1000},
+			And: []FilterExpr{
+				&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"},
+				&ComparisonFilter{Field: "amount", Op: OpGT, Value: 1000},
 			},
 		},
 	}
@@ -191,9 +195,9 @@ func TestValidateQuery_LogicalFilterOr(t *testing.T) {
 	query := &Query{
 		Model: "orders",
 		Filters: &LogicalFilter{
-			Or: []*ComparisonFilter{
-				{Field: "status", Op: OpEqual, Value: "PAID"},
-				{Field: "status", Op: OpEqual, Value: "PENDING"},
+			Or: []FilterExpr{
+				&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"},
+				&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PENDING"},
 			},
 		},
 	}
@@ -225,13 +229,194 @@ func TestValidateQuery_LogicalFilterNot(t *testing.T) {
 	}
 }
 
+func TestValidateQuery_NestedLogicalFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	// (status = PAID AND amount > 100) OR (status = REFUNDED AND NOT notes is_null)
+	query := &Query{
+		Model: "orders",
+		Filters: &LogicalFilter{
+			Or: []FilterExpr{
+				&LogicalFilter{
+					And: []FilterExpr{
+						&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"},
+						&ComparisonFilter{Field: "amount", Op: OpGT, Value: 100},
+					},
+				},
+				&LogicalFilter{
+					And: []FilterExpr{
+						&ComparisonFilter{Field: "status", Op: OpEqual, Value: "REFUNDED"},
+						&LogicalFilter{
+							Not: &ComparisonFilter{Field: "notes", Op: OpIsNull},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := v.ValidateQuery(query)
+	if err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+}
+
+func TestValidateQuery_NestedFilterInvalidField(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model: "orders",
+		Filters: &LogicalFilter{
+			Or: []FilterExpr{
+				&LogicalFilter{
+					And: []FilterExpr{
+						&ComparisonFilter{Field: "nonexistent", Op: OpEqual, Value: "x"},
+					},
+				},
+			},
+		},
+	}
+
+	err := v.ValidateQuery(query)
+	if err == nil {
+		t.Fatalf("ValidateQuery() error = nil, want error for nested invalid field")
+	}
+	if !contains(err.Error(), "or[0]: and[0]") {
+		t.Errorf("ValidateQuery() error = %v, want path to nested condition", err)
+	}
+}
+
+func TestValidateQuery_FilterMaxDepth(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+	v.SetMaxFilterDepth(3)
+
+	var expr FilterExpr = &ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"}
+	for i := 0; i < 3; i++ {
+		expr = &LogicalFilter{Not: expr}
+	}
+
+	if err := v.ValidateQuery(&Query{Model: "orders", Filters: expr}); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil at max depth", err)
+	}
+
+	expr = &LogicalFilter{And: []FilterExpr{expr}}
+	err := v.ValidateQuery(&Query{Model: "orders", Filters: expr})
+	if err == nil {
+		t.Fatalf("ValidateQuery() error = nil, want error beyond max depth")
+	}
+	if !contains(err.Error(), "maximum depth") {
+		t.Errorf("ValidateQuery() error = %v, want maximum depth error", err)
+	}
+}
+
+func TestValidateQuery_LogicalFilterMultipleOps(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model: "orders",
+		Filters: &LogicalFilter{
+			And: []FilterExpr{&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"}},
+			Or:  []FilterExpr{&ComparisonFilter{Field: "status", Op: OpEqual, Value: "PENDING"}},
+		},
+	}
+
+	if err := v.ValidateQuery(query); err == nil {
+		t.Errorf("ValidateQuery() error = nil, want error for and+or in one group")
+	}
+}
+
+func TestValidateQuery_EmptyLogicalFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	tests := []struct {
+		name   string
+		filter *LogicalFilter
+	}{
+		{"no operator", &LogicalFilter{}},
+		{"empty and", &LogicalFilter{And: []FilterExpr{}}},
+		{"nil child", &LogicalFilter{Or: []FilterExpr{nil}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.ValidateQuery(&Query{Model: "orders", Filters: tt.filter}); err == nil {
+				t.Errorf("ValidateQuery() error = nil, want error")
+			}
+		})
+	}
+}
+
+func setupRelationTestRegistry(t *testing.T) *schema.Registry {
+	reg := setupTestRegistry()
+	if err := reg.AddRelation("orders", &schema.Relation{
+		Name:         "user",
+		Type:         schema.ManyToOne,
+		TargetModel:  "users",
+		ForeignKey:   "user_id",
+		ReferenceKey: "id",
+	}); err != nil {
+		t.Fatalf("AddRelation() error = %v", err)
+	}
+	return reg
+}
+
+func TestValidateQuery_RelationPaths(t *testing.T) {
+	reg := setupRelationTestRegistry(t)
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model:   "orders",
+		Fields:  []string{"id", "user.email"},
+		Filters: &ComparisonFilter{Field: "user.name", Op: OpStartsWith, Value: "A"},
+		GroupBy: []GroupBy{{Field: "user.email"}},
+		Aggregates: []Aggregate{
+			{Function: AggMax, Field: "user.age", Alias: "oldest"},
+		},
+		Sort: []Sort{{Field: "user.email", Direction: SortAsc}},
+	}
+
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+}
+
+func TestValidateQuery_InvalidRelationPath(t *testing.T) {
+	reg := setupRelationTestRegistry(t)
+	v := NewValidator(reg)
+
+	tests := []struct {
+		name  string
+		query *Query
+	}{
+		{"unknown relation in fields", &Query{Model: "orders", Fields: []string{"buyer.email"}}},
+		{"unknown field on relation", &Query{Model: "orders", Fields: []string{"user.phone"}}},
+		{"filter", &Query{Model: "orders", Filters: &ComparisonFilter{Field: "user.missing", Op: OpEqual, Value: "x"}}},
+		{"group_by", &Query{Model: "orders", GroupBy: []GroupBy{{Field: "buyer.email"}}}},
+		{"sort", &Query{Model: "orders", Sort: []Sort{{Field: "user.missing"}}}},
+		{"string op on related integer", &Query{Model: "orders", Filters: &ComparisonFilter{Field: "user.age", Op: OpLike, Value: "1%"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.ValidateQuery(tt.query); err == nil {
+				t.Errorf("ValidateQuery() error = nil, want error")
+			}
+		})
+	}
+}
+
 func TestValidateQuery_ValidGroupBy(t *testing.T) {
 	reg := setupTestRegistry()
 	v := NewValidator(reg)
 
 	query := &Query{
 		Model:   "orders",
-		GroupBy: []string{"status"},
+		GroupBy: []GroupBy{{Field: "status"}},
 	}
 
 	err := v.ValidateQuery(query)
@@ -246,7 +431,7 @@ func TestValidateQuery_InvalidGroupByField(t *testing.T) {
 
 	query := &Query{
 		Model:   "orders",
-		GroupBy: []string{"nonexistent"},
+		GroupBy: []GroupBy{{Field: "nonexistent"}},
 	}
 
 	err := v.ValidateQuery(query)
@@ -261,7 +446,7 @@ func TestValidateQuery_ValidAggregate(t *testing.T) {
 
 	query := &Query{
 		Model:   "orders",
-		GroupBy: []string{"status"},
+		GroupBy: []GroupBy{{Field: "status"}},
 		Aggregates: []Aggregate{
 			{Function: AggSum, Field: "amount", Alias: "total_amount"},
 			{Function: AggCount, Field: "", Alias: "order_count"},
@@ -325,6 +510,640 @@ func TestValidateQuery_AggregateNonNumericField(t *testing.T) {
 	}
 }
 
+func TestValidateQuery_ExtendedAggregates(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	fraction := 0.95
+	separator := " | "
+	query := &Query{
+		Model:   "orders",
+		GroupBy: []GroupBy{{Field: "user_id"}},
+		Aggregates: []Aggregate{
+			{Function: AggCountDistinct, Field: "status", Alias: "statuses"},
+			{Function: AggMedian, Field: "amount", Alias: "median_amount"},
+			{Function: AggPercentile, Field: "amount", Alias: "p95", Fraction: &fraction},
+			{Function: AggStddev, Field: "amount", Alias: "amount_stddev"},
+			{Function: AggVariance, Field: "amount", Alias: "amount_variance"},
+			{Function: AggStringAgg, Field: "notes", Alias: "all_notes", Separator: &separator},
+			{Function: AggArrayAgg, Field: "created_at", Alias: "dates"},
+			{Function: AggBoolAnd, Field: "is_paid", Alias: "all_paid"},
+			{Function: AggBoolOr, Field: "is_paid", Alias: "any_paid"},
+		},
+		Having: &ComparisonFilter{Field: "p95", Op: OpGT, Value: 100},
+	}
+
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+}
+
+func TestValidateQuery_ExtendedAggregateErrors(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	half := 0.5
+	tooBig := 1.5
+	separator := ","
+
+	tests := []struct {
+		name   string
+		agg    Aggregate
+		errMsg string
+	}{
+		{"count_distinct without field", Aggregate{Function: AggCountDistinct, Alias: "a"}, "field is required"},
+		{"percentile without fraction", Aggregate{Function: AggPercentile, Field: "amount", Alias: "a"}, "fraction is required"},
+		{"percentile fraction out of range", Aggregate{Function: AggPercentile, Field: "amount", Alias: "a", Fraction: &tooBig}, "fraction must be between 0 and 1"},
+		{"fraction on median", Aggregate{Function: AggMedian, Field: "amount", Alias: "a", Fraction: &half}, "fraction is only valid"},
+		{"separator on array_agg", Aggregate{Function: AggArrayAgg, Field: "notes", Alias: "a", Separator: &separator}, "separator is only valid"},
+		{"stddev on string", Aggregate{Function: AggStddev, Field: "status", Alias: "a"}, "requires numeric field"},
+		{"median on timestamp", Aggregate{Function: AggMedian, Field: "created_at", Alias: "a"}, "requires numeric field"},
+		{"string_agg on integer", Aggregate{Function: AggStringAgg, Field: "user_id", Alias: "a"}, "requires string field"},
+		{"bool_or on string", Aggregate{Function: AggBoolOr, Field: "status", Alias: "a"}, "requires boolean field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(&Query{Model: "orders", Aggregates: []Aggregate{tt.agg}})
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	err := v.ValidateQuery(&Query{
+		Model:      "orders",
+		Aggregates: []Aggregate{{Function: AggArrayAgg, Field: "status", Alias: "statuses"}},
+		Having:     &ComparisonFilter{Field: "statuses", Op: OpEqual, Value: "PAID"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "can only be tested for null") {
+		t.Errorf("ValidateQuery() error = %v, want array_agg having error", err)
+	}
+}
+
+func TestValidateQuery_AggregateFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model:   "orders",
+		GroupBy: []GroupBy{{Field: "user_id"}},
+		Aggregates: []Aggregate{
+			{Function: AggCount, Alias: "paid", Filter: &ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"}},
+			{Function: AggSum, Field: "amount", Alias: "refunded_amount", Filter: &LogicalFilter{
+				And: []FilterExpr{
+					&ComparisonFilter{Field: "status", Op: OpEqual, Value: "REFUNDED"},
+					&ComparisonFilter{Field: "amount", Op: OpGT, Value: 0},
+				},
+			}},
+		},
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	query.Aggregates[0].Filter = &ComparisonFilter{Field: "missing", Op: OpEqual, Value: "x"}
+	err := v.ValidateQuery(query)
+	if err == nil || !strings.Contains(err.Error(), "aggregate[0] invalid filter") {
+		t.Errorf("ValidateQuery() error = %v, want aggregate filter error", err)
+	}
+
+	query.Aggregates[0].Filter = &LogicalFilter{}
+	if err := v.ValidateQuery(query); err == nil {
+		t.Errorf("ValidateQuery() error = nil, want error for empty aggregate filter")
+	}
+}
+
+func TestValidateQuery_RelativeDates(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model: "orders",
+		Filters: &LogicalFilter{
+			And: []FilterExpr{
+				&ComparisonFilter{Field: "created_at", Op: OpAfter, Value: "now-7d"},
+				&ComparisonFilter{Field: "created_at", Op: OpGTELT, Value: []interface{}{"start_of_month", "now"}},
+			},
+		},
+		Timezone: "America/New_York",
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	query.Timezone = "Mars/Olympus"
+	if err := v.ValidateQuery(query); err == nil || !strings.Contains(err.Error(), "invalid timezone") {
+		t.Errorf("ValidateQuery() error = %v, want invalid timezone", err)
+	}
+
+	// Expressions only apply to time fields
+	query.Timezone = ""
+	query.Filters = &ComparisonFilter{Field: "amount", Op: OpGT, Value: "now"}
+	if err := v.ValidateQuery(query); err == nil {
+		t.Errorf("ValidateQuery() error = nil, want error for relative date on decimal field")
+	}
+}
+
+func TestValidateQuery_JSONPaths(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model:  "orders",
+		Fields: []string{"id", "metadata.shipping.country"},
+		Filters: &LogicalFilter{
+			And: []FilterExpr{
+				&ComparisonFilter{Field: "metadata.shipping.country", Op: OpEqual, Value: "DE"},
+				&ComparisonFilter{Field: "metadata.weight::float", Op: OpGT, Value: 2.5},
+				&ComparisonFilter{Field: "metadata", Op: OpHasKey, Value: "gift"},
+				&ComparisonFilter{Field: "metadata.tags", Op: OpJSONContains, Value: []interface{}{"fragile"}},
+			},
+		},
+		Sort: []Sort{{Field: "metadata.weight::float", Direction: SortDesc}},
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	grouped := &Query{
+		Model:      "orders",
+		GroupBy:    []GroupBy{{Field: "metadata.shipping.country"}, {Field: "metadata.shipped_at::timestamp", Granularity: GranularityDay}},
+		Aggregates: []Aggregate{{Function: AggSum, Field: "metadata.weight::decimal", Alias: "weight"}},
+	}
+	if err := v.ValidateQuery(grouped); err != nil {
+		t.Errorf("ValidateQuery(grouped) error = %v, want nil", err)
+	}
+
+	invalid := []struct {
+		name   string
+		filter *ComparisonFilter
+		errMsg string
+	}{
+		{"has_key on cast path", &ComparisonFilter{Field: "metadata.weight::float", Op: OpHasKey, Value: "x"}, "json operator has_key not valid for type float"},
+		{"has_key on string", &ComparisonFilter{Field: "status", Op: OpHasKey, Value: "x"}, "json operator has_key not valid for type string"},
+		{"has_key without key", &ComparisonFilter{Field: "metadata", Op: OpHasKey, Value: float64(1)}, "has_key requires a non-empty string key"},
+		{"json_contains without value", &ComparisonFilter{Field: "metadata", Op: OpJSONContains}, "value is required for operator json_contains"},
+		{"cast value mismatch", &ComparisonFilter{Field: "metadata.weight::float", Op: OpGT, Value: "heavy"}, "expected number"},
+		{"path on plain field", &ComparisonFilter{Field: "status.code", Op: OpEqual, Value: "x"}, "invalid filter field"},
+		{"unknown cast", &ComparisonFilter{Field: "metadata.weight::money", Op: OpEqual, Value: "x"}, "invalid cast type"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(&Query{Model: "orders", Filters: tt.filter})
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_Search(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model: "users",
+		Filters: &LogicalFilter{
+			And: []FilterExpr{
+				&ComparisonFilter{Op: OpSearch, Value: `"jane doe" -smith`},
+				&ComparisonFilter{Field: "age", Op: OpGTE, Value: 18},
+			},
+		},
+		Sort: []Sort{{Field: SearchRankField, Direction: SortDesc}},
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name   string
+		query  *Query
+		errMsg string
+	}{
+		{
+			"model without search config",
+			&Query{Model: "orders", Filters: &ComparisonFilter{Op: OpSearch, Value: "x"}},
+			"model orders has no searchFields or searchVector configured",
+		},
+		{
+			"empty search text",
+			&Query{Model: "users", Filters: &ComparisonFilter{Op: OpSearch, Value: "  "}},
+			"search requires non-empty search text",
+		},
+		{
+			"field search on number",
+			&Query{Model: "users", Filters: &ComparisonFilter{Field: "age", Op: OpSearch, Value: "x"}},
+			"string operator search not valid for type integer",
+		},
+		{
+			"rank without search",
+			&Query{Model: "users", Sort: []Sort{{Field: SearchRankField}}},
+			"_rank requires a search filter",
+		},
+		{
+			"rank in aggregate query",
+			&Query{
+				Model:      "users",
+				Filters:    &ComparisonFilter{Op: OpSearch, Value: "x"},
+				GroupBy:    []GroupBy{{Field: "age"}},
+				Aggregates: []Aggregate{{Function: AggCount, Alias: "n"}},
+				Sort:       []Sort{{Field: SearchRankField}},
+			},
+			"_rank is not allowed in an aggregate query",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(tt.query)
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_Similar(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	threshold := 0.5
+	query := &Query{
+		Model:   "users",
+		Filters: &ComparisonFilter{Field: "name", Op: OpSimilar, Value: "jonh smiht", Threshold: &threshold},
+		Sort:    []Sort{{Field: SimilarityField, Direction: SortDesc}},
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	tooHigh, zero := 1.5, 0.0
+	tests := []struct {
+		name   string
+		query  *Query
+		errMsg string
+	}{
+		{
+			"similar on number",
+			&Query{Model: "users", Filters: &ComparisonFilter{Field: "age", Op: OpSimilar, Value: "x"}},
+			"string operator similar not valid for type integer",
+		},
+		{
+			"empty text",
+			&Query{Model: "users", Filters: &ComparisonFilter{Field: "name", Op: OpSimilar, Value: ""}},
+			"similar requires non-empty search text",
+		},
+		{
+			"threshold above 1",
+			&Query{Model: "users", Filters: &ComparisonFilter{Field: "name", Op: OpSimilar, Value: "x", Threshold: &tooHigh}},
+			"similarity threshold must be in (0, 1], got 1.5",
+		},
+		{
+			"zero threshold",
+			&Query{Model: "users", Filters: &ComparisonFilter{Field: "name", Op: OpSimilar, Value: "x", Threshold: &zero}},
+			"similarity threshold must be in (0, 1]",
+		},
+		{
+			"threshold on other operator",
+			&Query{Model: "users", Filters: &ComparisonFilter{Field: "name", Op: OpEqual, Value: "x", Threshold: &threshold}},
+			"threshold is only valid for the similar operator, got =",
+		},
+		{
+			"similarity without similar filter",
+			&Query{Model: "users", Sort: []Sort{{Field: SimilarityField}}},
+			"_similarity requires a similar filter",
+		},
+		{
+			"similar filter under not",
+			&Query{
+				Model:   "users",
+				Filters: &LogicalFilter{Not: &ComparisonFilter{Field: "name", Op: OpSimilar, Value: "x"}},
+				Sort:    []Sort{{Field: SimilarityField}},
+			},
+			"_similarity requires a similar filter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(tt.query)
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_Keyset(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	sort := []Sort{{Field: "created_at", Direction: SortDesc}}
+	cursor, err := EncodeCursor(KeysetSort(&Query{Sort: sort}, "id"), []interface{}{"2024-03-01T10:00:00Z", 42})
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	for _, p := range []*Pagination{{Limit: 50, Keyset: true}, {Limit: 50, Cursor: cursor}} {
+		if err := v.ValidateQuery(&Query{Model: "orders", Sort: sort, Pagination: p}); err != nil {
+			t.Errorf("ValidateQuery(%+v) error = %v, want nil", p, err)
+		}
+	}
+
+	badValue, _ := EncodeCursor(KeysetSort(&Query{Sort: sort}, "id"), []interface{}{"yesterday-ish", 42})
+
+	tests := []struct {
+		name   string
+		query  *Query
+		errMsg string
+	}{
+		{
+			"with offset",
+			&Query{Model: "orders", Pagination: &Pagination{Limit: 10, Offset: 10, Keyset: true}},
+			"cursor pagination cannot be combined with offset",
+		},
+		{
+			"aggregate query",
+			&Query{
+				Model:      "orders",
+				GroupBy:    []GroupBy{{Field: "status"}},
+				Aggregates: []Aggregate{{Function: AggCount, Alias: "n"}},
+				Pagination: &Pagination{Limit: 10, Keyset: true},
+			},
+			"cursor pagination is not supported for aggregate queries",
+		},
+		{
+			"json path sort",
+			&Query{Model: "orders", Sort: []Sort{{Field: "metadata.priority"}}, Pagination: &Pagination{Limit: 10, Keyset: true}},
+			"cursor pagination requires sort fields of model orders itself, got metadata.priority",
+		},
+		{
+			"cursor for another sort",
+			&Query{Model: "orders", Sort: []Sort{{Field: "amount"}}, Pagination: &Pagination{Limit: 10, Cursor: cursor}},
+			"cursor was issued for sort created_at:desc, id:desc, not amount:asc, id:asc",
+		},
+		{
+			"cursor value of wrong type",
+			&Query{Model: "orders", Sort: sort, Pagination: &Pagination{Limit: 10, Cursor: badValue}},
+			"invalid cursor value for created_at",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(tt.query)
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_InvalidFilterValues(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	tests := []struct {
+		name   string
+		filter *ComparisonFilter
+		errMsg string
+	}{
+		{"integer field with text", &ComparisonFilter{Field: "user_id", Op: OpEqual, Value: "abc"}, "invalid value for field user_id: expected integer"},
+		{"in without array", &ComparisonFilter{Field: "status", Op: OpIn, Value: "PAID"}, "invalid value for field status: in requires an array"},
+		{"between arity", &ComparisonFilter{Field: "amount", Op: OpBetween, Value: []interface{}{float64(1)}}, "between requires exactly 2 values, got 1"},
+		{"range on boolean", &ComparisonFilter{Field: "is_paid", Op: OpGTELT, Value: []interface{}{false, true}}, "range operator gte_lt not valid for type boolean"},
+		{"relative date anchor", &ComparisonFilter{Field: "created_at", Op: OpAfter, Value: "last_week"}, `invalid value for field created_at: invalid relative date "last_week"`},
+		{"timestamp format", &ComparisonFilter{Field: "created_at", Op: OpAfter, Value: "01/02/2024"}, "invalid value for field created_at: expected ISO 8601 timestamp"},
+		{"contains on number", &ComparisonFilter{Field: "notes", Op: OpContains, Value: float64(5)}, "contains requires a string value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(&Query{Model: "orders", Filters: tt.filter})
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	err := v.ValidateQuery(&Query{
+		Model:      "orders",
+		Aggregates: []Aggregate{{Function: AggCount, Alias: "n"}},
+		Having:     &ComparisonFilter{Field: "n", Op: OpGT, Value: "lots"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid having value for n") {
+		t.Errorf("ValidateQuery() error = %v, want having value error", err)
+	}
+}
+
+func TestValidateQuery_SortByAggregateAlias(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model:      "orders",
+		GroupBy:    []GroupBy{{Field: "status"}},
+		Aggregates: []Aggregate{{Function: AggCount, Alias: "order_count"}},
+		Sort: []Sort{
+			{Field: "order_count", Direction: SortDesc},
+			{Field: "status", Direction: SortAsc},
+		},
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	// An alias is only visible when the aggregate is defined in the same query
+	query = &Query{
+		Model: "orders",
+		Sort:  []Sort{{Field: "order_count", Direction: SortDesc}},
+	}
+	err := v.ValidateQuery(query)
+	if err == nil || !contains(err.Error(), "sort[0] field not found") {
+		t.Errorf("ValidateQuery() error = %v, want sort field not found", err)
+	}
+}
+
+func TestValidateQuery_TimeBucketGroupBy(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	valid := &Query{
+		Model: "orders",
+		GroupBy: []GroupBy{
+			{Field: "created_at", Granularity: GranularityMonth, Timezone: "America/New_York"},
+			{Field: "status"},
+		},
+		Aggregates: []Aggregate{{Function: AggCount, Alias: "order_count"}},
+	}
+	if err := v.ValidateQuery(valid); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name    string
+		groupBy GroupBy
+		errMsg  string
+	}{
+		{"non-time field", GroupBy{Field: "status", Granularity: GranularityDay}, "granularity requires a date or timestamp field"},
+		{"unknown granularity", GroupBy{Field: "created_at", Granularity: "fortnight"}, "invalid granularity"},
+		{"timezone without granularity", GroupBy{Field: "created_at", Timezone: "UTC"}, "timezone requires a granularity"},
+		{"unknown timezone", GroupBy{Field: "created_at", Granularity: GranularityDay, Timezone: "Mars/Olympus"}, "invalid timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(&Query{Model: "orders", GroupBy: []GroupBy{tt.groupBy}})
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateQuery_FieldsWithBucketedGroupBy(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	err := v.ValidateQuery(&Query{
+		Model:   "orders",
+		Fields:  []string{"created_at"},
+		GroupBy: []GroupBy{{Field: "created_at", Granularity: GranularityDay}},
+	})
+	if err == nil || !contains(err.Error(), "fields cannot be combined with a bucketed group_by on created_at") {
+		t.Errorf("ValidateQuery() error = %v, want fields rejected", err)
+	}
+
+	err = v.ValidateQuery(&Query{
+		Model:   "orders",
+		Fields:  []string{"status"},
+		GroupBy: []GroupBy{{Field: "status"}},
+	})
+	if err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil for unbucketed group_by", err)
+	}
+}
+
+func TestValidateQuery_DuplicateGroupByKey(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	err := v.ValidateQuery(&Query{
+		Model: "orders",
+		GroupBy: []GroupBy{
+			{Field: "created_at", Granularity: GranularityDay},
+			{Field: "created_at", Granularity: GranularityDay},
+		},
+	})
+	if err == nil || !contains(err.Error(), "duplicate group_by key: created_at_day") {
+		t.Errorf("ValidateQuery() error = %v, want duplicate group_by key", err)
+	}
+}
+
+func TestValidateQuery_ValidHaving(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	query := &Query{
+		Model:   "orders",
+		GroupBy: []GroupBy{{Field: "user_id"}},
+		Aggregates: []Aggregate{
+			{Function: AggSum, Field: "amount", Alias: "total_amount"},
+			{Function: AggCount, Alias: "order_count"},
+		},
+		Having: &LogicalFilter{
+			And: []FilterExpr{
+				&ComparisonFilter{Field: "total_amount", Op: OpGT, Value: 1000},
+				&ComparisonFilter{Field: "order_count", Op: OpGTE, Value: 2},
+			},
+		},
+	}
+
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+}
+
+func TestValidateQuery_InvalidHaving(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	aggs := []Aggregate{
+		{Function: AggSum, Field: "amount", Alias: "total_amount"},
+		{Function: AggMax, Field: "status", Alias: "max_status"},
+	}
+
+	tests := []struct {
+		name   string
+		query  *Query
+		errMsg string
+	}{
+		{
+			name: "without group_by or aggregates",
+			query: &Query{
+				Model:  "orders",
+				Having: &ComparisonFilter{Field: "total_amount", Op: OpGT, Value: 1},
+			},
+			errMsg: "having requires group_by or aggregates",
+		},
+		{
+			name: "plain field instead of alias",
+			query: &Query{
+				Model: "orders", GroupBy: []GroupBy{{Field: "user_id"}}, Aggregates: aggs,
+				Having: &ComparisonFilter{Field: "amount", Op: OpGT, Value: 1},
+			},
+			errMsg: "not an aggregate alias",
+		},
+		{
+			name: "string operator",
+			query: &Query{
+				Model: "orders", GroupBy: []GroupBy{{Field: "user_id"}}, Aggregates: aggs,
+				Having: &ComparisonFilter{Field: "max_status", Op: OpLike, Value: "P%"},
+			},
+			errMsg: "not supported in having",
+		},
+		{
+			name: "nested unknown alias",
+			query: &Query{
+				Model: "orders", GroupBy: []GroupBy{{Field: "user_id"}}, Aggregates: aggs,
+				Having: &LogicalFilter{Not: &ComparisonFilter{Field: "nope", Op: OpEqual, Value: 1}},
+			},
+			errMsg: "not an aggregate alias",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(tt.query)
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
 func TestValidateQuery_ValidSort(t *testing.T) {
 	reg := setupTestRegistry()
 	v := NewValidator(reg)
@@ -438,11 +1257,11 @@ func TestValidateQuery_ComplexQuery(t *testing.T) {
 	query := &Query{
 		Model:   "orders",
 		Fields:  []string{"status", "amount"},
-		GroupBy: []string{"status"},
+		GroupBy: []GroupBy{{Field: "status"}},
 		Filters: &LogicalFilter{
-			And: []*ComparisonFilter{
-				{Field: "created_at", Op: OpAfter, Value: "2024-01-01"},
-				{Field: "amount", Op: OpGTE, Value: 100},
+			And: []FilterExpr{
+				&ComparisonFilter{Field: "created_at", Op: OpAfter, Value: "2024-01-01"},
+				&ComparisonFilter{Field: "amount", Op: OpGTE, Value: 100},
 			},
 		},
 		Aggregates: []Aggregate{
@@ -0,0 +1,174 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Relative date expressions let saved queries name a point in time that is
+// evaluated when the query runs, e.g. "now-7d" or "start_of_month". An
+// expression is an anchor followed by any number of signed offsets:
+//
+//	now | today | yesterday | tomorrow
+//	start_of_day | start_of_week | start_of_month | start_of_quarter | start_of_year
+//	offsets: +N or -N with unit s, m, h, d, w, M (month) or y
+//
+// Anchors are computed in the request timezone; weeks start on Monday.
+// Day, week, month and year offsets are calendar arithmetic, so "today-1d"
+// is the previous midnight even across a DST change.
+
+// relativeAnchors returns the anchor instant for now in now's location
+var relativeAnchors = map[string]func(now time.Time) time.Time{
+	"now":   func(now time.Time) time.Time { return now },
+	"today": startOfDay,
+	"yesterday": func(now time.Time) time.Time {
+		return startOfDay(now).AddDate(0, 0, -1)
+	},
+	"tomorrow": func(now time.Time) time.Time {
+		return startOfDay(now).AddDate(0, 0, 1)
+	},
+	"start_of_day": startOfDay,
+	"start_of_week": func(now time.Time) time.Time {
+		// time.Weekday counts from Sunday; shift so Monday is day 0
+		offset := (int(now.Weekday()) + 6) % 7
+		return startOfDay(now).AddDate(0, 0, -offset)
+	},
+	"start_of_month": func(now time.Time) time.Time {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	},
+	"start_of_quarter": func(now time.Time) time.Time {
+		month := (now.Month()-1)/3*3 + 1
+		return time.Date(now.Year(), month, 1, 0, 0, 0, 0, now.Location())
+	},
+	"start_of_year": func(now time.Time) time.Time {
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+	},
+}
+
+func startOfDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// IsRelativeDate reports whether a value looks like a relative date
+// expression rather than an absolute one. Absolute dates start with a digit,
+// expressions with an anchor name.
+func IsRelativeDate(value interface{}) bool {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return false
+	}
+	c := s[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// ResolveRelativeDate evaluates a relative date expression at now in loc
+func ResolveRelativeDate(expr string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	end := strings.IndexAny(expr, "+-")
+	if end < 0 {
+		end = len(expr)
+	}
+	anchor, ok := relativeAnchors[expr[:end]]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid relative date %q: unknown anchor %q", expr, expr[:end])
+	}
+	t := anchor(now.In(loc))
+
+	rest := expr[end:]
+	for rest != "" {
+		sign := 1
+		if rest[0] == '-' {
+			sign = -1
+		}
+		rest = rest[1:]
+
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) {
+			return time.Time{}, fmt.Errorf("invalid relative date %q: offsets look like +3d or -1M", expr)
+		}
+		n, err := strconv.Atoi(rest[:digits])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative date %q: %v", expr, err)
+		}
+		n *= sign
+
+		switch rest[digits] {
+		case 's':
+			t = t.Add(time.Duration(n) * time.Second)
+		case 'm':
+			t = t.Add(time.Duration(n) * time.Minute)
+		case 'h':
+			t = t.Add(time.Duration(n) * time.Hour)
+		case 'd':
+			t = t.AddDate(0, 0, n)
+		case 'w':
+			t = t.AddDate(0, 0, 7*n)
+		case 'M':
+			t = t.AddDate(0, n, 0)
+		case 'y':
+			t = t.AddDate(n, 0, 0)
+		default:
+			return time.Time{}, fmt.Errorf("invalid relative date %q: unknown unit %q", expr, rest[digits])
+		}
+		rest = rest[digits+1:]
+	}
+
+	return t, nil
+}
+
+// ResolveRelativeValue replaces relative date expressions in a filter value
+// with absolute times, evaluated at now in loc. Only time-typed fields and
+// single-value or range operators accept expressions; any other value is
+// returned unchanged for CoerceValue to check. The bool result reports
+// whether anything was replaced.
+func ResolveRelativeValue(fieldType string, op FilterOperator, value interface{}, now time.Time, loc *time.Location) (interface{}, bool, error) {
+	if !isTimeType(fieldType) {
+		return value, false, nil
+	}
+
+	switch op {
+	case OpEqual, OpNotEqual, OpGT, OpGTE, OpLT, OpLTE, OpBefore, OpAfter:
+		if !IsRelativeDate(value) {
+			return value, false, nil
+		}
+		t, err := ResolveRelativeDate(value.(string), now, loc)
+		if err != nil {
+			return nil, false, err
+		}
+		return t, true, nil
+
+	case OpBetween, OpGTELT, OpGTLTE:
+		items, ok := toSlice(value)
+		if !ok {
+			return value, false, nil
+		}
+		out := make([]interface{}, len(items))
+		resolved := false
+		for i, item := range items {
+			out[i] = item
+			if !IsRelativeDate(item) {
+				continue
+			}
+			t, err := ResolveRelativeDate(item.(string), now, loc)
+			if err != nil {
+				return nil, false, fmt.Errorf("value[%d]: %v", i, err)
+			}
+			out[i] = t
+			resolved = true
+		}
+		if !resolved {
+			return value, false, nil
+		}
+		return out, true, nil
+	}
+
+	return value, false, nil
+}
@@ -0,0 +1,107 @@
+package dsl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveRelativeDate(t *testing.T) {
+	// Thursday 2024-03-14 23:30 UTC, already Friday in Berlin
+	now := time.Date(2024, 3, 14, 23, 30, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		loc  *time.Location
+		want time.Time
+	}{
+		{"now", time.UTC, now},
+		{"now-7d", time.UTC, time.Date(2024, 3, 7, 23, 30, 0, 0, time.UTC)},
+		{"now-90m", time.UTC, time.Date(2024, 3, 14, 22, 0, 0, 0, time.UTC)},
+		{"today", time.UTC, time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", time.UTC, time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{"start_of_week", time.UTC, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"start_of_month", time.UTC, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"start_of_month-1M", time.UTC, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"start_of_quarter", time.UTC, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"start_of_year+1y-1d", time.UTC, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"today", berlin, time.Date(2024, 3, 15, 0, 0, 0, 0, berlin)},
+		// Calendar days stay at midnight across the DST change on 2024-03-31
+		{"today+17d", berlin, time.Date(2024, 4, 1, 0, 0, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.loc.String(), func(t *testing.T) {
+			got, err := ResolveRelativeDate(tt.expr, now, tt.loc)
+			if err != nil {
+				t.Fatalf("ResolveRelativeDate() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ResolveRelativeDate(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+			if got.Location() != tt.loc {
+				t.Errorf("location = %v, want %v", got.Location(), tt.loc)
+			}
+		})
+	}
+}
+
+func TestResolveRelativeDate_Errors(t *testing.T) {
+	now := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr   string
+		errMsg string
+	}{
+		{"last_tuesday", `unknown anchor "last_tuesday"`},
+		{"now-7", "offsets look like"},
+		{"now-d", "offsets look like"},
+		{"now-7x", `unknown unit 'x'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ResolveRelativeDate(tt.expr, now, time.UTC)
+			if err == nil {
+				t.Fatalf("ResolveRelativeDate() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ResolveRelativeDate() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestResolveRelativeValue(t *testing.T) {
+	now := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	got, resolved, err := ResolveRelativeValue("timestamp", OpBetween, []interface{}{"start_of_month", "2024-03-20"}, now, time.UTC)
+	if err != nil {
+		t.Fatalf("ResolveRelativeValue() error = %v", err)
+	}
+	want := []interface{}{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "2024-03-20"}
+	if !resolved || !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveRelativeValue() = %#v, %v, want %#v, true", got, resolved, want)
+	}
+
+	// Non-time fields and absolute values pass through untouched
+	for _, tc := range []struct {
+		fieldType string
+		op        FilterOperator
+		value     interface{}
+	}{
+		{"string", OpEqual, "now"},
+		{"timestamp", OpAfter, "2024-01-01"},
+		{"timestamp", OpIn, []interface{}{"now"}},
+	} {
+		got, resolved, err := ResolveRelativeValue(tc.fieldType, tc.op, tc.value, now, time.UTC)
+		if err != nil || resolved || !reflect.DeepEqual(got, tc.value) {
+			t.Errorf("ResolveRelativeValue(%s, %s, %v) = %v, %v, %v; want unchanged", tc.fieldType, tc.op, tc.value, got, resolved, err)
+		}
+	}
+}
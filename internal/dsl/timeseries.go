@@ -0,0 +1,156 @@
+package dsl
+
+import (
+	"fmt"
+	"time"
+
+	"udv/internal/limits"
+)
+
+// FillMode controls what empty time series buckets report for aggregates
+type FillMode string
+
+const (
+	// FillNull leaves empty buckets NULL, except counts which are always 0
+	FillNull FillMode = "null"
+	// FillZero reports 0 for every aggregate with a numeric result
+	FillZero FillMode = "zero"
+)
+
+// TimeSeriesBucketColumn is the output column holding each bucket's start
+const TimeSeriesBucketColumn = "bucket"
+
+// TimeRange is a half-open [from, to) time range. Values are RFC 3339
+// timestamps or YYYY-MM-DD dates; dates are read in the query timezone.
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TimeSeriesQuery requests aggregates over every bucket of a time range,
+// including buckets with no matching rows
+type TimeSeriesQuery struct {
+	Model      string          `json:"model"`
+	Field      string          `json:"field"`
+	Interval   TimeGranularity `json:"interval"`
+	Timezone   string          `json:"timezone,omitempty"`
+	Range      TimeRange       `json:"range"`
+	Filters    FilterExpr      `json:"filters,omitempty"`
+	Aggregates []Aggregate     `json:"aggregates"`
+	Fill       FillMode        `json:"fill,omitempty"`
+}
+
+// Location returns the query timezone, defaulting to UTC
+func (q *TimeSeriesQuery) Location() (*time.Location, error) {
+	if q.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(q.Timezone)
+}
+
+// Bounds parses the range into absolute times
+func (q *TimeSeriesQuery) Bounds() (time.Time, time.Time, error) {
+	loc, err := q.Location()
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid timezone: %s", q.Timezone)
+	}
+
+	from, err := parseRangeTime(q.Range.From, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range.from: %w", err)
+	}
+	to, err := parseRangeTime(q.Range.To, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range.to: %w", err)
+	}
+	return from, to, nil
+}
+
+func parseRangeTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("value is required")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date, got %q", value)
+}
+
+// approxBucketSize is a lower bound on each granularity's length, used to
+// cap how many buckets a range may produce
+var approxBucketSize = map[TimeGranularity]time.Duration{
+	GranularityMinute:  time.Minute,
+	GranularityHour:    time.Hour,
+	GranularityDay:     23 * time.Hour,
+	GranularityWeek:    7*24*time.Hour - time.Hour,
+	GranularityMonth:   28 * 24 * time.Hour,
+	GranularityQuarter: 89 * 24 * time.Hour,
+	GranularityYear:    365 * 24 * time.Hour,
+}
+
+// ValidateTimeSeries validates a time series request
+func (v *Validator) ValidateTimeSeries(q *TimeSeriesQuery) error {
+	if q == nil {
+		return fmt.Errorf("query is nil")
+	}
+
+	if q.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if !v.registry.ModelExists(q.Model) {
+		return fmt.Errorf("model not found: %s", q.Model)
+	}
+
+	if q.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	f, err := v.resolveField(q.Model, q.Field)
+	if err != nil {
+		return fmt.Errorf("invalid field: %v", err)
+	}
+	if !isTimeType(f.Type) {
+		return fmt.Errorf("field must be a date or timestamp field, %s is %s", q.Field, f.Type)
+	}
+
+	if !validGranularities[q.Interval] {
+		return fmt.Errorf("invalid interval: %q", q.Interval)
+	}
+
+	from, to, err := q.Bounds()
+	if err != nil {
+		return err
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("range.from must be before range.to")
+	}
+	if buckets := int64(to.Sub(from)/approxBucketSize[q.Interval]) + 1; buckets > limits.MaxTimeSeriesBuckets {
+		return fmt.Errorf("range produces too many %s buckets (max %d)", q.Interval, limits.MaxTimeSeriesBuckets)
+	}
+
+	if q.Filters != nil {
+		if err := v.validateFilterExpr(q.Model, q.Filters); err != nil {
+			return err
+		}
+	}
+
+	if len(q.Aggregates) == 0 {
+		return fmt.Errorf("at least one aggregate is required")
+	}
+	if err := v.validateAggregates(q.Model, q.Aggregates, true); err != nil {
+		return err
+	}
+	for i, agg := range q.Aggregates {
+		if agg.Alias == TimeSeriesBucketColumn {
+			return fmt.Errorf("aggregate[%d] alias %q is reserved", i, TimeSeriesBucketColumn)
+		}
+	}
+
+	if q.Fill != "" && q.Fill != FillNull && q.Fill != FillZero {
+		return fmt.Errorf("invalid fill: %q", q.Fill)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validTimeSeriesQuery() *TimeSeriesQuery {
+	return &TimeSeriesQuery{
+		Model:    "orders",
+		Field:    "created_at",
+		Interval: GranularityDay,
+		Range:    TimeRange{From: "2024-01-01", To: "2024-02-01"},
+		Aggregates: []Aggregate{
+			{Function: AggCount, Alias: "orders"},
+			{Function: AggSum, Field: "amount", Alias: "revenue"},
+		},
+	}
+}
+
+func TestValidateTimeSeries_Valid(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	q := validTimeSeriesQuery()
+	q.Timezone = "Asia/Kolkata"
+	q.Fill = FillZero
+	q.Filters = &ComparisonFilter{Field: "status", Op: OpEqual, Value: "PAID"}
+
+	if err := v.ValidateTimeSeries(q); err != nil {
+		t.Errorf("ValidateTimeSeries() error = %v, want nil", err)
+	}
+}
+
+func TestValidateTimeSeries_Invalid(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	tests := []struct {
+		name   string
+		modify func(q *TimeSeriesQuery)
+		errMsg string
+	}{
+		{"non-time field", func(q *TimeSeriesQuery) { q.Field = "status" }, "must be a date or timestamp field"},
+		{"missing interval", func(q *TimeSeriesQuery) { q.Interval = "" }, "invalid interval"},
+		{"bad range", func(q *TimeSeriesQuery) { q.Range.From = "yesterday" }, "invalid range.from"},
+		{"empty range", func(q *TimeSeriesQuery) { q.Range.To = q.Range.From }, "range.from must be before range.to"},
+		{"too many buckets", func(q *TimeSeriesQuery) { q.Interval = GranularityMinute }, "too many minute buckets"},
+		{"no aggregates", func(q *TimeSeriesQuery) { q.Aggregates = nil }, "at least one aggregate is required"},
+		{"reserved alias", func(q *TimeSeriesQuery) { q.Aggregates[0].Alias = "bucket" }, "is reserved"},
+		{"bad fill", func(q *TimeSeriesQuery) { q.Fill = "previous" }, "invalid fill"},
+		{"bad timezone", func(q *TimeSeriesQuery) { q.Timezone = "Nowhere/Land" }, "invalid timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := validTimeSeriesQuery()
+			tt.modify(q)
+			err := v.ValidateTimeSeries(q)
+			if err == nil {
+				t.Fatalf("ValidateTimeSeries() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateTimeSeries() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestTimeSeriesQuery_Bounds(t *testing.T) {
+	q := validTimeSeriesQuery()
+	q.Timezone = "America/New_York"
+	q.Range.To = "2024-01-02T05:00:00Z"
+
+	from, to, err := q.Bounds()
+	if err != nil {
+		t.Fatalf("Bounds() error = %v", err)
+	}
+
+	// Dates are midnight in the request timezone; timestamps are absolute
+	if want := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("from = %v, want %v", from, want)
+	}
+	if want := time.Date(2024, 1, 2, 5, 0, 0, 0, time.UTC); !to.Equal(want) {
+		t.Errorf("to = %v, want %v", to, want)
+	}
+}
@@ -0,0 +1,339 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	uuidPattern    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+)
+
+// timestampLayouts are the accepted timestamp formats, most specific first.
+// Values without an offset are read as UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// CoerceValue checks that a filter value is usable with an operator on a
+// field of the given type and returns it normalized:
+//
+//   - integer: int64 (integral JSON numbers and numeric strings)
+//   - float: float64
+//   - decimal: int64 or float64 for numbers; numeric strings are kept
+//     verbatim so no precision is lost
+//   - boolean: bool (also "true"/"false")
+//   - timestamp, datetime: time.Time (RFC 3339 or ISO date)
+//   - date: "YYYY-MM-DD"
+//   - time: "HH:MM:SS"
+//   - uuid: lowercase canonical string
+//   - json: JSON text
+//
+// in/not_in take a non-empty array and the range operators (between,
+// gte_lt, gt_lte) exactly two bounds, one of which may be null; each element
+// is coerced to the field type. search and similar take non-empty text,
+// has_key takes a key name and json_contains
+// any JSON value, whatever the field type. Null checks take no value.
+func CoerceValue(fieldType string, op FilterOperator, value interface{}) (interface{}, error) {
+	switch op {
+	case OpIsNull, OpNotNull:
+		return nil, nil
+
+	case OpIn, OpNotIn:
+		items, ok := toSlice(value)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an array of values", op)
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("%s requires at least one value", op)
+		}
+		return coerceItems(fieldType, items)
+
+	case OpBetween, OpGTELT, OpGTLTE:
+		items, ok := toSlice(value)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an array of 2 values", op)
+		}
+		if len(items) != 2 {
+			return nil, fmt.Errorf("%s requires exactly 2 values, got %d", op, len(items))
+		}
+		return coerceBounds(op, fieldType, items)
+
+	case OpSearch, OpSimilar:
+		text, ok := value.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("%s requires non-empty search text, got %s", op, describeValue(value))
+		}
+		return text, nil
+
+	case OpHasKey:
+		key, ok := value.(string)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s requires a non-empty string key, got %s", op, describeValue(value))
+		}
+		return key, nil
+
+	case OpJSONContains:
+		if value == nil {
+			return nil, fmt.Errorf("value is required for operator %s", op)
+		}
+		return CoerceScalar("json", value)
+
+	case OpLike, OpILike, OpStartsWith, OpEndsWith, OpContains:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a string value, got %s", op, describeValue(value))
+		}
+		return s, nil
+	}
+
+	if value == nil {
+		return nil, fmt.Errorf("value is required for operator %s", op)
+	}
+	return CoerceScalar(fieldType, value)
+}
+
+func coerceItems(fieldType string, items []interface{}) ([]interface{}, error) {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		if item == nil {
+			return nil, fmt.Errorf("value[%d] cannot be null", i)
+		}
+		v, err := CoerceScalar(fieldType, item)
+		if err != nil {
+			return nil, fmt.Errorf("value[%d]: %v", i, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// coerceBounds coerces [lower, upper] range bounds; a null bound leaves that
+// side of the range open
+func coerceBounds(op FilterOperator, fieldType string, items []interface{}) ([]interface{}, error) {
+	if items[0] == nil && items[1] == nil {
+		return nil, fmt.Errorf("%s requires at least one non-null bound", op)
+	}
+
+	out := make([]interface{}, 2)
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		v, err := CoerceScalar(fieldType, item)
+		if err != nil {
+			return nil, fmt.Errorf("value[%d]: %v", i, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// CoerceScalar converts a single value to the normalized Go representation
+// of a field type (see CoerceValue)
+func CoerceScalar(fieldType string, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %s", describeValue(value))
+		}
+		return s, nil
+
+	case "integer", "int":
+		return coerceInteger(value)
+
+	case "float":
+		return coerceFloat(value)
+
+	case "decimal":
+		return coerceDecimal(value)
+
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil && (v == "true" || v == "false") {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("expected boolean, got %s", describeValue(value))
+
+	case "timestamp", "datetime":
+		return coerceTimestamp(value)
+
+	case "date":
+		t, err := coerceTimestamp(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected date (YYYY-MM-DD), got %s", describeValue(value))
+		}
+		return t.Format("2006-01-02"), nil
+
+	case "time":
+		s, ok := value.(string)
+		if ok {
+			for _, layout := range []string{"15:04:05.999999999", "15:04"} {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t.Format("15:04:05.999999999"), nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("expected time (HH:MM[:SS]), got %s", describeValue(value))
+
+	case "uuid":
+		s, ok := value.(string)
+		if !ok || !uuidPattern.MatchString(s) {
+			return nil, fmt.Errorf("expected UUID, got %s", describeValue(value))
+		}
+		return strings.ToLower(s), nil
+
+	case "json":
+		if s, ok := value.(string); ok {
+			if !json.Valid([]byte(s)) {
+				return nil, fmt.Errorf("expected JSON document, got invalid JSON text")
+			}
+			return s, nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected JSON document: %v", err)
+		}
+		return string(data), nil
+
+	default:
+		return value, nil
+	}
+}
+
+func coerceInteger(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), nil
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("expected integer, got %s", describeValue(value))
+}
+
+func coerceFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f, nil
+		}
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("expected number, got %s", describeValue(value))
+}
+
+func coerceDecimal(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			break
+		}
+		return v, nil
+	case json.Number:
+		if decimalPattern.MatchString(v.String()) {
+			return v.String(), nil
+		}
+	case string:
+		if decimalPattern.MatchString(v) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("expected decimal number, got %s", describeValue(value))
+}
+
+func coerceTimestamp(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected ISO 8601 timestamp, got %s", describeValue(value))
+}
+
+// toSlice returns the elements of an array value
+func toSlice(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return nil, false
+	}
+	if _, isBytes := value.([]byte); isBytes {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+// describeValue renders a value for error messages
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case float64, float32, int, int64, int32, json.Number:
+		return fmt.Sprintf("number %v", v)
+	default:
+		if _, ok := toSlice(value); ok {
+			return "array"
+		}
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,103 @@
+package dsl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldType string
+		op        FilterOperator
+		value     interface{}
+		want      interface{}
+	}{
+		{"integer from JSON number", "integer", OpEqual, float64(42), int64(42)},
+		{"integer from string", "int", OpGT, "-7", int64(-7)},
+		{"float", "float", OpLT, float64(1.5), 1.5},
+		{"decimal number", "decimal", OpGTE, 19.99, 19.99},
+		{"decimal integer", "decimal", OpGTE, 20, int64(20)},
+		{"decimal string keeps precision", "decimal", OpEqual, "12345678901234567890.123456789", "12345678901234567890.123456789"},
+		{"boolean", "boolean", OpEqual, true, true},
+		{"boolean string", "boolean", OpEqual, "false", false},
+		{"timestamp RFC 3339", "timestamp", OpAfter, "2024-03-01T10:30:00+02:00", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{"timestamp date only", "datetime", OpBefore, "2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"date", "date", OpEqual, "2024-03-01", "2024-03-01"},
+		{"time", "time", OpEqual, "09:15", "09:15:00"},
+		{"uuid is lowercased", "uuid", OpEqual, "6F9619FF-8B86-D011-B42D-00C04FC964FF", "6f9619ff-8b86-d011-b42d-00c04fc964ff"},
+		{"json object", "json", OpEqual, map[string]interface{}{"a": float64(1)}, `{"a":1}`},
+		{"like keeps pattern", "string", OpLike, "A%", "A%"},
+		{"in list", "integer", OpIn, []interface{}{float64(1), "2"}, []interface{}{int64(1), int64(2)}},
+		{"not_in typed slice", "string", OpNotIn, []string{"a", "b"}, []interface{}{"a", "b"}},
+		{"between", "decimal", OpBetween, []interface{}{float64(10), "20.50"}, []interface{}{float64(10), "20.50"}},
+		{"gte_lt", "integer", OpGTELT, []interface{}{float64(1), "10"}, []interface{}{int64(1), int64(10)}},
+		{"open lower bound", "date", OpBetween, []interface{}{nil, "2024-03-31"}, []interface{}{nil, "2024-03-31"}},
+		{"open upper bound", "integer", OpGTLTE, []interface{}{float64(5), nil}, []interface{}{int64(5), nil}},
+		{"has_key", "json", OpHasKey, "gift", "gift"},
+		{"json_contains object", "json", OpJSONContains, map[string]interface{}{"vip": true}, `{"vip":true}`},
+		{"is_null ignores value", "integer", OpIsNull, "anything", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CoerceValue(tt.fieldType, tt.op, tt.value)
+			if err != nil {
+				t.Fatalf("CoerceValue() error = %v", err)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				if gotTime, ok := got.(time.Time); !ok || !gotTime.Equal(want) {
+					t.Errorf("CoerceValue() = %v, want %v", got, want)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CoerceValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceValue_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldType string
+		op        FilterOperator
+		value     interface{}
+		errMsg    string
+	}{
+		{"fractional integer", "integer", OpEqual, 1.5, "expected integer, got number 1.5"},
+		{"string for integer", "integer", OpEqual, "abc", `expected integer, got "abc"`},
+		{"bad decimal", "decimal", OpEqual, "1,000", "expected decimal number"},
+		{"bad boolean", "boolean", OpEqual, "yes", "expected boolean"},
+		{"bad timestamp", "timestamp", OpAfter, "last tuesday", "expected ISO 8601 timestamp"},
+		{"bad date", "date", OpEqual, "03/01/2024", "expected date"},
+		{"bad uuid", "uuid", OpEqual, "not-a-uuid", "expected UUID"},
+		{"number for string", "string", OpEqual, float64(3), "expected string, got number 3"},
+		{"pattern must be string", "string", OpStartsWith, float64(3), "starts_with requires a string value"},
+		{"in scalar", "string", OpIn, "PAID", "in requires an array of values"},
+		{"in empty", "string", OpIn, []interface{}{}, "in requires at least one value"},
+		{"in bad element", "integer", OpIn, []interface{}{float64(1), "x"}, "value[1]: expected integer"},
+		{"in null element", "integer", OpNotIn, []interface{}{nil}, "value[0] cannot be null"},
+		{"between scalar", "integer", OpBetween, float64(1), "between requires an array of 2 values"},
+		{"between arity", "integer", OpBetween, []interface{}{float64(1), float64(2), float64(3)}, "between requires exactly 2 values, got 3"},
+		{"range without bounds", "date", OpGTELT, []interface{}{nil, nil}, "gte_lt requires at least one non-null bound"},
+		{"range bad bound", "date", OpBetween, []interface{}{"2024-01-01", "soon"}, "value[1]: expected date"},
+		{"missing value", "integer", OpEqual, nil, "value is required for operator ="},
+		{"invalid json text", "json", OpEqual, "{oops", "expected JSON document"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CoerceValue(tt.fieldType, tt.op, tt.value)
+			if err == nil {
+				t.Fatalf("CoerceValue() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("CoerceValue() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package dsl
+
+import (
+	"fmt"
+
+	"udv/internal/limits"
+)
+
+// WindowFunc represents a window function
+type WindowFunc string
+
+const (
+	// Ranking functions, no field
+	WinRowNumber   WindowFunc = "row_number"
+	WinRank        WindowFunc = "rank"
+	WinDenseRank   WindowFunc = "dense_rank"
+	WinPercentRank WindowFunc = "percent_rank"
+
+	// Offset functions, the field's value in another row
+	WinLag        WindowFunc = "lag"
+	WinLead       WindowFunc = "lead"
+	WinFirstValue WindowFunc = "first_value"
+
+	// Aggregates over the window; with order_by they are running values
+	WinSum   WindowFunc = "sum"
+	WinAvg   WindowFunc = "avg"
+	WinCount WindowFunc = "count"
+	WinMin   WindowFunc = "min"
+	WinMax   WindowFunc = "max"
+)
+
+// Window represents a window function computed for every result row over
+// the rows of its partition, e.g. a running total per customer:
+//
+//	{"fn": "sum", "field": "amount", "partition_by": ["user_id"],
+//	 "order_by": [{"field": "created_at"}], "alias": "running_total"}
+type Window struct {
+	Function    WindowFunc `json:"fn"`
+	Field       string     `json:"field,omitempty"`
+	PartitionBy []string   `json:"partition_by,omitempty"`
+	OrderBy     []Sort     `json:"order_by,omitempty"`
+	Alias       string     `json:"alias"`
+	Offset      *int       `json:"offset,omitempty"` // lag/lead only: rows back or ahead, default 1
+}
+
+// windowField says whether a window function takes a field
+type windowField int
+
+const (
+	windowNoField windowField = iota
+	windowFieldRequired
+	windowFieldOptional
+)
+
+// windowFuncs lists the supported window functions and their field rule
+var windowFuncs = map[WindowFunc]windowField{
+	WinRowNumber:   windowNoField,
+	WinRank:        windowNoField,
+	WinDenseRank:   windowNoField,
+	WinPercentRank: windowNoField,
+	WinLag:         windowFieldRequired,
+	WinLead:        windowFieldRequired,
+	WinFirstValue:  windowFieldRequired,
+	WinSum:         windowFieldRequired,
+	WinAvg:         windowFieldRequired,
+	WinCount:       windowFieldOptional,
+	WinMin:         windowFieldRequired,
+	WinMax:         windowFieldRequired,
+}
+
+// windowNeedsOrder lists the functions that are only meaningful over an
+// ordered window
+var windowNeedsOrder = map[WindowFunc]bool{
+	WinRowNumber:   true,
+	WinRank:        true,
+	WinDenseRank:   true,
+	WinPercentRank: true,
+	WinLag:         true,
+	WinLead:        true,
+	WinFirstValue:  true,
+}
+
+// findWindow returns the window with the given alias, or nil
+func findWindow(windows []Window, alias string) *Window {
+	for i := range windows {
+		if windows[i].Alias == alias {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// validateWindows checks window functions against the registry. Windows
+// apply to plain row queries; they cannot be combined with grouping.
+func (v *Validator) validateWindows(q *Query) error {
+	if len(q.Windows) == 0 {
+		return nil
+	}
+	if len(q.GroupBy) > 0 || len(q.Aggregates) > 0 {
+		return fmt.Errorf("windows cannot be combined with group_by or aggregates")
+	}
+
+	aliases := make(map[string]bool, len(q.Windows))
+	for i, w := range q.Windows {
+		if w.Alias == "" {
+			return fmt.Errorf("window[%d] alias is required", i)
+		}
+		if aliases[w.Alias] {
+			return fmt.Errorf("window[%d] duplicate alias: %s", i, w.Alias)
+		}
+		aliases[w.Alias] = true
+		// Rows carry model columns and windows side by side
+		if _, err := v.registry.GetField(q.Model, w.Alias); err == nil {
+			return fmt.Errorf("window[%d] alias %s clashes with a field of model %s", i, w.Alias, q.Model)
+		}
+
+		rule, ok := windowFuncs[w.Function]
+		if !ok {
+			return fmt.Errorf("window[%d] unknown function: %s", i, w.Function)
+		}
+
+		switch {
+		case w.Field == "" && rule == windowFieldRequired:
+			return fmt.Errorf("window[%d] field is required for function %s", i, w.Function)
+		case w.Field != "" && rule == windowNoField:
+			return fmt.Errorf("window[%d] function %s takes no field", i, w.Function)
+		}
+
+		if w.Field != "" {
+			f, err := v.resolveField(q.Model, w.Field)
+			if err != nil {
+				return fmt.Errorf("window[%d] invalid field: %v", i, err)
+			}
+			if w.Function != WinLag && w.Function != WinLead && w.Function != WinFirstValue {
+				if !f.Aggregatable {
+					return fmt.Errorf("window[%d] field is not aggregatable: %s", i, w.Field)
+				}
+				if err := v.validateAggregateForType(AggregateFunc(w.Function), f.Type); err != nil {
+					return fmt.Errorf("window[%d] invalid for field %s: %v", i, w.Field, err)
+				}
+			}
+		}
+
+		if w.Offset != nil {
+			if w.Function != WinLag && w.Function != WinLead {
+				return fmt.Errorf("window[%d] offset is only valid for functions %s and %s", i, WinLag, WinLead)
+			}
+			if *w.Offset < 1 || *w.Offset > limits.MaxWindowOffset {
+				return fmt.Errorf("window[%d] offset must be between 1 and %d", i, limits.MaxWindowOffset)
+			}
+		}
+
+		for _, field := range w.PartitionBy {
+			f, err := v.resolveField(q.Model, field)
+			if err != nil {
+				return fmt.Errorf("window[%d] invalid partition_by field: %v", i, err)
+			}
+			if !f.Groupable {
+				return fmt.Errorf("window[%d] partition_by field is not groupable: %s", i, field)
+			}
+		}
+
+		if len(w.OrderBy) == 0 && windowNeedsOrder[w.Function] {
+			return fmt.Errorf("window[%d] function %s requires order_by", i, w.Function)
+		}
+		for _, s := range w.OrderBy {
+			if _, err := v.resolveField(q.Model, s.Field); err != nil {
+				return fmt.Errorf("window[%d] invalid order_by field: %v", i, err)
+			}
+			if s.Direction != "" && s.Direction != SortAsc && s.Direction != SortDesc {
+				return fmt.Errorf("window[%d] invalid order_by direction: %s", i, s.Direction)
+			}
+		}
+	}
+
+	return nil
+}
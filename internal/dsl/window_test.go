@@ -0,0 +1,92 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateQuery_Windows(t *testing.T) {
+	reg := setupTestRegistry()
+	v := NewValidator(reg)
+
+	byUser := []string{"user_id"}
+	byDate := []Sort{{Field: "created_at"}}
+	offset := 2
+	query := &Query{
+		Model: "orders",
+		Windows: []Window{
+			{Function: WinRowNumber, PartitionBy: byUser, OrderBy: byDate, Alias: "order_no"},
+			{Function: WinSum, Field: "amount", PartitionBy: byUser, OrderBy: byDate, Alias: "running_total"},
+			{Function: WinLag, Field: "amount", PartitionBy: byUser, OrderBy: byDate, Offset: &offset, Alias: "prev_amount"},
+			{Function: WinCount, PartitionBy: byUser, Alias: "user_orders"},
+		},
+		Sort: []Sort{{Field: "running_total", Direction: SortDesc}},
+	}
+	if err := v.ValidateQuery(query); err != nil {
+		t.Errorf("ValidateQuery() error = %v, want nil", err)
+	}
+
+	zero := 0
+	tests := []struct {
+		name   string
+		window Window
+		errMsg string
+	}{
+		{"missing alias", Window{Function: WinCount}, "window[0] alias is required"},
+		{"alias clashes with field", Window{Function: WinCount, Alias: "amount"}, "alias amount clashes with a field of model orders"},
+		{"unknown function", Window{Function: "ntile", Alias: "w"}, "unknown function: ntile"},
+		{"ranking with field", Window{Function: WinRank, Field: "amount", OrderBy: byDate, Alias: "w"}, "function rank takes no field"},
+		{"lag without field", Window{Function: WinLag, OrderBy: byDate, Alias: "w"}, "field is required for function lag"},
+		{"ranking without order", Window{Function: WinRowNumber, PartitionBy: byUser, Alias: "w"}, "function row_number requires order_by"},
+		{"sum on string", Window{Function: WinSum, Field: "status", Alias: "w"}, "function sum requires numeric field, got string"},
+		{"offset on sum", Window{Function: WinSum, Field: "amount", Offset: &offset, Alias: "w"}, "offset is only valid for functions lag and lead"},
+		{"zero offset", Window{Function: WinLead, Field: "amount", OrderBy: byDate, Offset: &zero, Alias: "w"}, "offset must be between 1 and 1000"},
+		{"unknown partition field", Window{Function: WinCount, PartitionBy: []string{"region"}, Alias: "w"}, "invalid partition_by field"},
+		{"bad order direction", Window{Function: WinRank, OrderBy: []Sort{{Field: "amount", Direction: "up"}}, Alias: "w"}, "invalid order_by direction: up"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(&Query{Model: "orders", Windows: []Window{tt.window}})
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	count := Window{Function: WinCount, Alias: "n"}
+	queries := []struct {
+		name   string
+		query  *Query
+		errMsg string
+	}{
+		{
+			"duplicate alias",
+			&Query{Model: "orders", Windows: []Window{count, count}},
+			"window[1] duplicate alias: n",
+		},
+		{
+			"with group_by",
+			&Query{Model: "orders", GroupBy: []GroupBy{{Field: "status"}}, Windows: []Window{count}},
+			"windows cannot be combined with group_by or aggregates",
+		},
+		{
+			"with cursor pagination",
+			&Query{Model: "orders", Windows: []Window{count}, Pagination: &Pagination{Limit: 10, Keyset: true}},
+			"cursor pagination cannot be combined with windows",
+		},
+	}
+	for _, tt := range queries {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateQuery(tt.query)
+			if err == nil {
+				t.Fatalf("ValidateQuery() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("ValidateQuery() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
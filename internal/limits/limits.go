@@ -1,3 +1,27 @@
 package limits
 
 // Package limits enforces system-wide constraints
+
+// DefaultMaxFilterDepth is the default maximum nesting depth of and/or/not
+// groups in a filter expression. A bare comparison has depth 0.
+const DefaultMaxFilterDepth = 8
+
+// MaxRelationHops is the maximum number of relations a dotted field path
+// may traverse (and therefore the number of joins a single path can add)
+const MaxRelationHops = 4
+
+// MaxJSONPathDepth is the maximum number of keys a JSON path below a json
+// field may have
+const MaxJSONPathDepth = 8
+
+// MaxTimeSeriesBuckets is the maximum number of buckets a time series
+// request may generate
+const MaxTimeSeriesBuckets = 10000
+
+// MaxFieldValues is the maximum number of distinct values a field values
+// (facet) request may return
+const MaxFieldValues = 1000
+
+// MaxWindowOffset is the maximum number of rows a lag/lead window function
+// may look back or ahead
+const MaxWindowOffset = 1000
@@ -0,0 +1,103 @@
+package planner
+
+import (
+	"fmt"
+
+	"udv/internal/dsl"
+)
+
+// Keyset holds the keyset (cursor) pagination state of a plan. Its keys are
+// the full ordering of the query, the primary key last; After is the
+// position of the previous page's last row, or nil on the first page.
+// The predicate it implies is kept out of Filters so counts and other
+// derived queries see every page.
+type Keyset struct {
+	Keys  []KeysetKey
+	After []interface{} // one value per key; a nil element is SQL NULL
+	sorts []dsl.Sort
+}
+
+// KeysetKey is one column of a keyset ordering
+type KeysetKey struct {
+	Field     string // field name, also the key of the column in result rows
+	Column    ColumnRef
+	Direction string // "ASC", "DESC"
+	Nullable  bool
+}
+
+// NextCursor returns the cursor continuing after row, the last row of a
+// page
+func (k *Keyset) NextCursor(row map[string]interface{}) (string, error) {
+	values := make([]interface{}, len(k.Keys))
+	for i, key := range k.Keys {
+		value, ok := row[key.Field]
+		if !ok {
+			return "", fmt.Errorf("result row has no value for cursor key %s", key.Field)
+		}
+		values[i] = value
+	}
+	return dsl.EncodeCursor(k.sorts, values)
+}
+
+// planKeyset sets up keyset pagination: the ordering is completed with the
+// primary key and a cursor becomes the values to continue after. Key
+// columns missing from an explicit field list are selected too, so the next
+// cursor can be read from the last row.
+func (p *Planner) planKeyset(scope *planScope, q *dsl.Query) (*Keyset, error) {
+	model := scope.model
+	sorts := dsl.KeysetSort(q, model.PrimaryKey)
+	keyset := &Keyset{sorts: sorts}
+
+	for _, s := range sorts {
+		field, err := p.registry.GetField(model.Name, s.Field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor sort field %s: %w", s.Field, err)
+		}
+		direction := "ASC"
+		if s.Direction == dsl.SortDesc {
+			direction = "DESC"
+		}
+		keyset.Keys = append(keyset.Keys, KeysetKey{
+			Field:     s.Field,
+			Column:    p.schemaFieldToColumnRef(model.Name, s.Field, scope.plan.RootModel.Alias),
+			Direction: direction,
+			Nullable:  field.Nullable,
+		})
+	}
+
+	if q.Pagination.Cursor != "" {
+		values, err := dsl.DecodeCursor(q.Pagination.Cursor, sorts)
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			if value == nil {
+				continue
+			}
+			values[i], err = dsl.CoerceScalar(string(keyset.Keys[i].Column.DataType), value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor value for %s: %w", keyset.Keys[i].Field, err)
+			}
+		}
+		keyset.After = values
+	}
+
+	if len(scope.plan.Select) > 0 {
+		for _, key := range keyset.Keys {
+			if !selectsField(scope.plan.Select, key.Field) {
+				scope.plan.Select = append(scope.plan.Select, SelectExpr{Column: key.Column, Alias: key.Field})
+			}
+		}
+	}
+
+	return keyset, nil
+}
+
+func selectsField(selects []SelectExpr, field string) bool {
+	for _, s := range selects {
+		if s.Alias == field {
+			return true
+		}
+	}
+	return false
+}
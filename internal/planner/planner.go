@@ -2,6 +2,7 @@ package planner
 
 import (
 	"fmt"
+	"time"
 
 	"udv/internal/dsl"
 	"udv/internal/schema"
@@ -41,9 +42,11 @@ const (
 type ColumnRef struct {
 	TableAlias string
 	ColumnName string
-	DataType   FieldType
+	DataType   FieldType // For JSON paths, the type of the extracted value
+	JSONPath   []string  // Keys below a json column; empty for plain columns
 }
 
+
 // SelectExpr represents a column in the SELECT clause
 type SelectExpr struct {
 	Column      ColumnRef
@@ -79,11 +82,23 @@ type FilterExpr interface {
 	isFilterExpr()
 }
 
-// ComparisonFilterIR represents an atomic filter in IR
+// ComparisonFilterIR represents an atomic filter in IR. In a HAVING
+// filter, Aggregate is set and is compared instead of the Left column.
 type ComparisonFilterIR struct {
-	Left     ColumnRef
-	Operator dsl.FilterOperator
-	Value    *ValueExpr
+	Left      ColumnRef
+	Aggregate *AggregateExpr
+	Operator  dsl.FilterOperator
+	Value     *ValueExpr
+	Search    *SearchDoc // search operator only: the document matched
+	Threshold float64    // similar operator only: minimum similarity
+}
+
+// SearchDoc is the text a search filter matches: a precomputed tsvector
+// column, or the concatenation of string columns parsed with Language
+type SearchDoc struct {
+	Columns  []ColumnRef
+	Vector   *ColumnRef
+	Language string
 }
 
 func (c *ComparisonFilterIR) isFilterExpr() {}
@@ -104,7 +119,10 @@ type ValueExpr struct {
 
 // GroupExpr represents a GROUP BY expression
 type GroupExpr struct {
-	Column ColumnRef
+	Column      ColumnRef
+	Alias       string // Output name, e.g. "status", "user.country" or "created_at_day"
+	Granularity string // Time bucket ("day", "month", ...); empty for a plain column
+	Timezone    string // IANA timezone for bucketing; empty for the session timezone
 }
 
 // AggregateFn represents an aggregate function
@@ -116,21 +134,37 @@ const (
 	AggAvgFn   AggregateFn = "AVG"
 	AggMinFn   AggregateFn = "MIN"
 	AggMaxFn   AggregateFn = "MAX"
+
+	AggCountDistinctFn AggregateFn = "COUNT_DISTINCT"
+	AggMedianFn        AggregateFn = "MEDIAN"
+	AggPercentileFn    AggregateFn = "PERCENTILE"
+	AggStddevFn        AggregateFn = "STDDEV"
+	AggVarianceFn      AggregateFn = "VARIANCE"
+	AggStringAggFn     AggregateFn = "STRING_AGG"
+	AggArrayAggFn      AggregateFn = "ARRAY_AGG"
+	AggBoolAndFn       AggregateFn = "BOOL_AND"
+	AggBoolOrFn        AggregateFn = "BOOL_OR"
 )
 
 // AggregateExpr represents an aggregate function in IR
 type AggregateExpr struct {
-	Function AggregateFn
-	Column   *ColumnRef
-	Alias    string
+	Function  AggregateFn
+	Column    *ColumnRef
+	Alias     string
+	Fraction  float64    // PERCENTILE only
+	Separator string     // STRING_AGG only
+	Filter    FilterExpr // Optional per-aggregate row filter
 }
 
 // SortTarget represents what we're sorting by
 type SortTarget string
 
 const (
-	SortColumn    SortTarget = "COLUMN"
-	SortAggregate SortTarget = "AGGREGATE"
+	SortColumn     SortTarget = "COLUMN"
+	SortAggregate  SortTarget = "AGGREGATE"
+	SortRank       SortTarget = "RANK"
+	SortSimilarity SortTarget = "SIMILARITY"
+	SortWindow     SortTarget = "WINDOW"
 )
 
 // SortExpr represents a sort specification
@@ -138,7 +172,9 @@ type SortExpr struct {
 	Target    SortTarget
 	Column    *ColumnRef
 	Aggregate *AggregateExpr
-	Direction string // "ASC", "DESC"
+	Search    *ComparisonFilterIR // SortRank, SortSimilarity: the filter scored against
+	Window    *WindowExpr         // SortWindow
+	Direction string              // "ASC", "DESC"
 }
 
 // Pagination represents pagination parameters
@@ -155,8 +191,21 @@ type QueryPlan struct {
 	Filters    FilterExpr
 	GroupBy    []GroupExpr
 	Aggregates []AggregateExpr
+	Having     FilterExpr
+	Windows    []WindowExpr
 	Sort       []SortExpr
 	Pagination Pagination
+	Keyset     *Keyset         // keyset pagination; nil for LIMIT/OFFSET
+	Resolved   []ResolvedValue // relative date filter values and what they resolved to
+}
+
+// ResolvedValue records a relative date expression in a filter and the
+// absolute value it was planned with
+type ResolvedValue struct {
+	Field      string
+	Operator   dsl.FilterOperator
+	Expression interface{}
+	Value      interface{}
 }
 
 // ModelRef represents a model in the query plan
@@ -167,14 +216,30 @@ type ModelRef struct {
 	PrimaryKey ColumnRef
 }
 
+// planScope tracks per-query state while a plan is built: the root model
+// and the table alias joined for each relation path
+type planScope struct {
+	model   *schema.Model
+	plan    *QueryPlan
+	aliases map[string]string // relation path (e.g. "order.customer") -> table alias
+	now     time.Time         // instant relative dates resolve against, in the request timezone
+}
+
 // Planner converts DSL queries into execution plans
 type Planner struct {
 	registry *schema.Registry
+	clock    func() time.Time
 }
 
 // NewPlanner creates a new query planner
 func NewPlanner(reg *schema.Registry) *Planner {
-	return &Planner{registry: reg}
+	return &Planner{registry: reg, clock: time.Now}
+}
+
+// SetClock replaces the clock relative date filter values are resolved
+// against (time.Now by default)
+func (p *Planner) SetClock(clock func() time.Time) {
+	p.clock = clock
 }
 
 // PlanQuery converts a validated DSL query into a QueryPlan IR
@@ -204,10 +269,23 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 		PrimaryKey: rootPrimaryKey,
 	}
 
+	loc, err := q.Location()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", q.Timezone)
+	}
+
+	// Every relative date in the query resolves against the same instant
+	scope := &planScope{
+		model:   model,
+		plan:    plan,
+		aliases: make(map[string]string),
+		now:     p.clock().In(loc),
+	}
+
 	// 2. Process SELECT clause
 	if len(q.Fields) > 0 {
 		for _, field := range q.Fields {
-			colRef := p.schemaFieldToColumnRef(model.Name, field, "t0")
+			colRef := p.resolveColumn(scope, field)
 			plan.Select = append(plan.Select, SelectExpr{
 				Column:      colRef,
 				Alias:       field,
@@ -218,7 +296,7 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 
 	// 3. Process WHERE filters
 	if q.Filters != nil {
-		filterIR, err := p.convertFilterExpr(model.Name, "t0", q.Filters)
+		filterIR, err := p.convertFilterExpr(scope, q.Filters)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert filters: %w", err)
 		}
@@ -227,9 +305,14 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 
 	// 4. Process GROUP BY
 	if len(q.GroupBy) > 0 {
-		for _, field := range q.GroupBy {
-			colRef := p.schemaFieldToColumnRef(model.Name, field, "t0")
-			plan.GroupBy = append(plan.GroupBy, GroupExpr{Column: colRef})
+		for _, g := range q.GroupBy {
+			colRef := p.resolveColumn(scope, g.Field)
+			plan.GroupBy = append(plan.GroupBy, GroupExpr{
+				Column:      colRef,
+				Alias:       g.Name(),
+				Granularity: string(g.Granularity),
+				Timezone:    g.Timezone,
+			})
 		}
 	}
 
@@ -238,20 +321,52 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 		for _, agg := range q.Aggregates {
 			var colRef *ColumnRef
 			if agg.Field != "" {
-				ref := p.schemaFieldToColumnRef(model.Name, agg.Field, "t0")
+				ref := p.resolveColumn(scope, agg.Field)
 				colRef = &ref
 			}
 
-			aggFn := p.dslAggToIRAgg(agg.Function)
-			plan.Aggregates = append(plan.Aggregates, AggregateExpr{
-				Function: aggFn,
+			aggExpr := AggregateExpr{
+				Function: p.dslAggToIRAgg(agg.Function),
 				Column:   colRef,
 				Alias:    agg.Alias,
-			})
+			}
+			if agg.Fraction != nil {
+				aggExpr.Fraction = *agg.Fraction
+			}
+			if agg.Function == dsl.AggStringAgg {
+				aggExpr.Separator = dsl.DefaultStringAggSeparator
+				if agg.Separator != nil {
+					aggExpr.Separator = *agg.Separator
+				}
+			}
+			if agg.Filter != nil {
+				filterIR, err := p.convertFilterExpr(scope, agg.Filter)
+				if err != nil {
+					return nil, fmt.Errorf("failed to convert filter of aggregate %s: %w", agg.Alias, err)
+				}
+				aggExpr.Filter = filterIR
+			}
+			plan.Aggregates = append(plan.Aggregates, aggExpr)
 		}
 	}
 
-	// 6. Process SORT
+	// 6. Process HAVING
+	if q.Having != nil {
+		havingIR, err := p.convertFilterTree(q.Having, func(f *dsl.ComparisonFilter) (FilterExpr, error) {
+			return p.convertHavingFilter(plan, f)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert having: %w", err)
+		}
+		plan.Having = havingIR
+	}
+
+	// Windows are computed per row, after filtering
+	if len(q.Windows) > 0 {
+		plan.Windows = p.convertWindows(scope, q.Windows)
+	}
+
+	// 7. Process SORT
 	if len(q.Sort) > 0 {
 		for _, sort := range q.Sort {
 			direction := "ASC"
@@ -259,7 +374,45 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 				direction = "DESC"
 			}
 
-			colRef := p.schemaFieldToColumnRef(model.Name, sort.Field, "t0")
+			if sort.Field == dsl.SearchRankField || sort.Field == dsl.SimilarityField {
+				target, search := SortRank, dsl.FindSearchFilter(q.Filters)
+				if sort.Field == dsl.SimilarityField {
+					target, search = SortSimilarity, dsl.FindSimilarFilter(q.Filters)
+				}
+				if search == nil {
+					return nil, fmt.Errorf("sort by %s requires a matching filter", sort.Field)
+				}
+				searchIR, err := p.convertComparisonFilter(scope, search)
+				if err != nil {
+					return nil, err
+				}
+				plan.Sort = append(plan.Sort, SortExpr{
+					Target:    target,
+					Search:    searchIR,
+					Direction: direction,
+				})
+				continue
+			}
+
+			if window := findWindow(plan, sort.Field); window != nil {
+				plan.Sort = append(plan.Sort, SortExpr{
+					Target:    SortWindow,
+					Window:    window,
+					Direction: direction,
+				})
+				continue
+			}
+
+			if agg := findAggregate(plan, sort.Field); agg != nil {
+				plan.Sort = append(plan.Sort, SortExpr{
+					Target:    SortAggregate,
+					Aggregate: agg,
+					Direction: direction,
+				})
+				continue
+			}
+
+			colRef := p.resolveColumn(scope, sort.Field)
 			plan.Sort = append(plan.Sort, SortExpr{
 				Target:    SortColumn,
 				Column:    &colRef,
@@ -268,7 +421,7 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 		}
 	}
 
-	// 7. Process PAGINATION
+	// 8. Process PAGINATION
 	if q.Pagination != nil {
 		plan.Pagination = Pagination{
 			Limit:  q.Pagination.Limit,
@@ -282,17 +435,39 @@ func (p *Planner) PlanQuery(q *dsl.Query) (*QueryPlan, error) {
 		}
 	}
 
+	// Keyset pagination orders by the full keyset, primary key included
+	if q.Pagination.IsKeyset() {
+		keyset, err := p.planKeyset(scope, q)
+		if err != nil {
+			return nil, err
+		}
+		plan.Keyset = keyset
+		plan.Pagination.Offset = 0
+		if len(keyset.Keys) > len(plan.Sort) {
+			pk := keyset.Keys[len(keyset.Keys)-1]
+			plan.Sort = append(plan.Sort, SortExpr{Target: SortColumn, Column: &pk.Column, Direction: pk.Direction})
+		}
+	}
+
 	return plan, nil
 }
 
 // convertFilterExpr recursively converts a DSL filter to IR format
-func (p *Planner) convertFilterExpr(modelName, tableAlias string, expr dsl.FilterExpr) (FilterExpr, error) {
+func (p *Planner) convertFilterExpr(scope *planScope, expr dsl.FilterExpr) (FilterExpr, error) {
+	return p.convertFilterTree(expr, func(f *dsl.ComparisonFilter) (FilterExpr, error) {
+		return p.convertComparisonFilter(scope, f)
+	})
+}
+
+// convertFilterTree converts the and/or/not structure of a DSL filter and
+// hands every comparison to convertLeaf
+func (p *Planner) convertFilterTree(expr dsl.FilterExpr, convertLeaf func(*dsl.ComparisonFilter) (FilterExpr, error)) (FilterExpr, error) {
 	switch e := expr.(type) {
 	case *dsl.ComparisonFilter:
-		return p.convertComparisonFilter(modelName, tableAlias, e)
+		return convertLeaf(e)
 
 	case *dsl.LogicalFilter:
-		return p.convertLogicalFilter(modelName, tableAlias, e)
+		return p.convertLogicalFilter(e, convertLeaf)
 
 	default:
 		return nil, fmt.Errorf("unknown filter expression type")
@@ -300,51 +475,166 @@ func (p *Planner) convertFilterExpr(modelName, tableAlias string, expr dsl.Filte
 }
 
 // convertComparisonFilter converts a DSL comparison filter to IR
-func (p *Planner) convertComparisonFilter(modelName, tableAlias string, f *dsl.ComparisonFilter) (*ComparisonFilterIR, error) {
-	colRef := p.schemaFieldToColumnRef(modelName, f.Field, tableAlias)
+func (p *Planner) convertComparisonFilter(scope *planScope, f *dsl.ComparisonFilter) (*ComparisonFilterIR, error) {
+	if f.Op == dsl.OpSearch {
+		return p.convertSearchFilter(scope, f)
+	}
+
+	colRef := p.resolveColumn(scope, f.Field)
 
 	var valueExpr *ValueExpr
 	if f.Op != dsl.OpIsNull && f.Op != dsl.OpNotNull {
+		raw, relative, err := dsl.ResolveRelativeValue(string(colRef.DataType), f.Op, f.Value, scope.now, scope.now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for field %s: %w", f.Field, err)
+		}
+		value, err := dsl.CoerceValue(string(colRef.DataType), f.Op, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for field %s: %w", f.Field, err)
+		}
+		if relative {
+			scope.plan.Resolved = append(scope.plan.Resolved, ResolvedValue{
+				Field:      f.Field,
+				Operator:   f.Op,
+				Expression: f.Value,
+				Value:      value,
+			})
+		}
 		valueExpr = &ValueExpr{
-			Value: f.Value,
+			Value: value,
 			Type:  colRef.DataType,
 		}
 	}
 
-	return &ComparisonFilterIR{
+	filter := &ComparisonFilterIR{
 		Left:     colRef,
 		Operator: f.Op,
 		Value:    valueExpr,
+	}
+	if f.Op == dsl.OpSimilar {
+		filter.Threshold = dsl.DefaultSimilarityThreshold
+		if f.Threshold != nil {
+			filter.Threshold = *f.Threshold
+		}
+	}
+	return filter, nil
+}
+
+// convertSearchFilter converts a search filter. Without a field it matches
+// the root model's search document; with one, that field alone.
+func (p *Planner) convertSearchFilter(scope *planScope, f *dsl.ComparisonFilter) (*ComparisonFilterIR, error) {
+	value, err := dsl.CoerceValue(string(TypeString), f.Op, f.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search value: %w", err)
+	}
+
+	model := scope.model
+	doc := &SearchDoc{Language: model.SearchLanguage}
+	switch {
+	case f.Field != "":
+		doc.Columns = []ColumnRef{p.resolveColumn(scope, f.Field)}
+	case model.SearchVector != "":
+		doc.Vector = &ColumnRef{TableAlias: scope.plan.RootModel.Alias, ColumnName: model.SearchVector}
+	case len(model.SearchFields) > 0:
+		for _, name := range model.SearchFields {
+			doc.Columns = append(doc.Columns, p.schemaFieldToColumnRef(model.Name, name, scope.plan.RootModel.Alias))
+		}
+	default:
+		return nil, fmt.Errorf("model %s has no searchFields or searchVector configured", model.Name)
+	}
+
+	return &ComparisonFilterIR{
+		Operator: f.Op,
+		Value:    &ValueExpr{Value: value, Type: TypeString},
+		Search:   doc,
 	}, nil
 }
 
-// convertLogicalFilter converts a DSL logical filter to IR
-func (p *Planner) convertLogicalFilter(modelName, tableAlias string, f *dsl.LogicalFilter) (*LogicalFilterIR, error) {
+// findAggregate returns a copy of the planned aggregate with the given alias,
+// or nil if there is none
+func findAggregate(plan *QueryPlan, alias string) *AggregateExpr {
+	for i := range plan.Aggregates {
+		if plan.Aggregates[i].Alias == alias {
+			agg := plan.Aggregates[i]
+			return &agg
+		}
+	}
+	return nil
+}
+
+// convertHavingFilter converts a HAVING comparison on an aggregate alias
+func (p *Planner) convertHavingFilter(plan *QueryPlan, f *dsl.ComparisonFilter) (*ComparisonFilterIR, error) {
+	agg := findAggregate(plan, f.Field)
+	if agg == nil {
+		return nil, fmt.Errorf("having field is not an aggregate alias: %s", f.Field)
+	}
+
+	resultType := aggregateResultType(*agg)
+
+	var valueExpr *ValueExpr
+	if f.Op != dsl.OpIsNull && f.Op != dsl.OpNotNull {
+		value, err := dsl.CoerceValue(string(resultType), f.Op, f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid having value for %s: %w", f.Field, err)
+		}
+		valueExpr = &ValueExpr{
+			Value: value,
+			Type:  resultType,
+		}
+	}
+
+	return &ComparisonFilterIR{
+		Left:      ColumnRef{DataType: resultType},
+		Aggregate: agg,
+		Operator:  f.Op,
+		Value:     valueExpr,
+	}, nil
+}
+
+// aggregateResultType returns the type of the value an aggregate produces
+func aggregateResultType(agg AggregateExpr) FieldType {
+	switch agg.Function {
+	case AggCountFn, AggCountDistinctFn:
+		return TypeInteger
+	case AggSumFn, AggAvgFn, AggMedianFn, AggPercentileFn, AggStddevFn, AggVarianceFn:
+		return TypeDecimal
+	case AggStringAggFn:
+		return TypeString
+	case AggArrayAggFn:
+		return TypeJSON
+	case AggBoolAndFn, AggBoolOrFn:
+		return TypeBoolean
+	default:
+		if agg.Column != nil {
+			return agg.Column.DataType
+		}
+		return TypeDecimal
+	}
+}
+
+// convertLogicalFilter converts a DSL logical filter to IR, recursing into
+// nested groups
+func (p *Planner) convertLogicalFilter(f *dsl.LogicalFilter, convertLeaf func(*dsl.ComparisonFilter) (FilterExpr, error)) (*LogicalFilterIR, error) {
 	logicalIR := &LogicalFilterIR{
 		Nodes: []FilterExpr{},
 	}
 
+	var children []dsl.FilterExpr
 	if len(f.And) > 0 {
 		logicalIR.Op = "AND"
-		for _, cond := range f.And {
-			irCond, err := p.convertComparisonFilter(modelName, tableAlias, cond)
-			if err != nil {
-				return nil, err
-			}
-			logicalIR.Nodes = append(logicalIR.Nodes, irCond)
-		}
+		children = f.And
 	} else if len(f.Or) > 0 {
 		logicalIR.Op = "OR"
-		for _, cond := range f.Or {
-			irCond, err := p.convertComparisonFilter(modelName, tableAlias, cond)
-			if err != nil {
-				return nil, err
-			}
-			logicalIR.Nodes = append(logicalIR.Nodes, irCond)
-		}
+		children = f.Or
 	} else if f.Not != nil {
 		logicalIR.Op = "NOT"
-		irCond, err := p.convertComparisonFilter(modelName, tableAlias, f.Not)
+		children = []dsl.FilterExpr{f.Not}
+	} else {
+		return nil, fmt.Errorf("logical filter has no conditions")
+	}
+
+	for _, cond := range children {
+		irCond, err := p.convertFilterTree(cond, convertLeaf)
 		if err != nil {
 			return nil, err
 		}
@@ -354,6 +644,81 @@ func (p *Planner) convertLogicalFilter(modelName, tableAlias string, f *dsl.Logi
 	return logicalIR, nil
 }
 
+// resolveColumn converts a plain or dotted field path into a ColumnRef,
+// joining the related tables the path traverses
+func (p *Planner) resolveColumn(scope *planScope, path string) ColumnRef {
+	fp, err := p.registry.ResolveFieldPath(scope.model.Name, path)
+	if err != nil {
+		// This should not happen if validation was done correctly
+		return ColumnRef{}
+	}
+
+	alias := scope.plan.RootModel.Alias
+	modelName := scope.model.Name
+	relPath := ""
+	for _, rel := range fp.Relations {
+		if relPath == "" {
+			relPath = rel.Name
+		} else {
+			relPath += "." + rel.Name
+		}
+		alias = p.joinRelation(scope, relPath, modelName, alias, rel)
+		modelName = rel.TargetModel
+	}
+
+	return ColumnRef{
+		TableAlias: alias,
+		ColumnName: fp.Field.Name,
+		DataType:   FieldType(fp.Type()),
+		JSONPath:   fp.JSONPath,
+	}
+}
+
+// joinRelation returns the alias of the table joined for a relation path,
+// adding a LEFT JOIN the first time the path is used. Aliases are numbered
+// t1, t2, ... in order of first use, so plans are deterministic. A
+// many_to_many relation joins its link table first, then the target.
+func (p *Planner) joinRelation(scope *planScope, relPath, fromModel, fromAlias string, rel *schema.Relation) string {
+	if alias, exists := scope.aliases[relPath]; exists {
+		return alias
+	}
+
+	target := p.registry.GetModel(rel.TargetModel)
+	left := p.schemaFieldToColumnRef(fromModel, rel.ForeignKey, fromAlias)
+
+	if rel.Type == schema.ManyToMany {
+		linkAlias := fmt.Sprintf("t%d", len(scope.plan.Joins)+1)
+		scope.plan.Joins = append(scope.plan.Joins, JoinPlan{
+			Type:      JoinLeft,
+			FromAlias: fromAlias,
+			ToTable:   rel.JoinTable,
+			ToAlias:   linkAlias,
+			On: JoinCondition{
+				Left:  left,
+				Right: ColumnRef{TableAlias: linkAlias, ColumnName: rel.JoinSourceKey, DataType: left.DataType},
+			},
+		})
+
+		targetKey := p.schemaFieldToColumnRef(target.Name, rel.ReferenceKey, "")
+		fromAlias = linkAlias
+		left = ColumnRef{TableAlias: linkAlias, ColumnName: rel.JoinTargetKey, DataType: targetKey.DataType}
+	}
+
+	toAlias := fmt.Sprintf("t%d", len(scope.plan.Joins)+1)
+	scope.plan.Joins = append(scope.plan.Joins, JoinPlan{
+		Type:      JoinLeft,
+		FromAlias: fromAlias,
+		ToTable:   target.Table,
+		ToAlias:   toAlias,
+		On: JoinCondition{
+			Left:  left,
+			Right: p.schemaFieldToColumnRef(target.Name, rel.ReferenceKey, toAlias),
+		},
+	})
+	scope.aliases[relPath] = toAlias
+	return toAlias
+}
+
 // schemaFieldToColumnRef converts a schema field to a ColumnRef
 func (p *Planner) schemaFieldToColumnRef(modelName, fieldName, tableAlias string) ColumnRef {
 	field, err := p.registry.GetField(modelName, fieldName)
@@ -382,6 +747,24 @@ func (p *Planner) dslAggToIRAgg(fn dsl.AggregateFunc) AggregateFn {
 		return AggMinFn
 	case dsl.AggMax:
 		return AggMaxFn
+	case dsl.AggCountDistinct:
+		return AggCountDistinctFn
+	case dsl.AggMedian:
+		return AggMedianFn
+	case dsl.AggPercentile:
+		return AggPercentileFn
+	case dsl.AggStddev:
+		return AggStddevFn
+	case dsl.AggVariance:
+		return AggVarianceFn
+	case dsl.AggStringAgg:
+		return AggStringAggFn
+	case dsl.AggArrayAgg:
+		return AggArrayAggFn
+	case dsl.AggBoolAnd:
+		return AggBoolAndFn
+	case dsl.AggBoolOr:
+		return AggBoolOrFn
 	default:
 		return AggCountFn
 	}
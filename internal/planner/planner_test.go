@@ -1,7 +1,10 @@
 package planner
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 
 	"udv/internal/config"
 	"udv/internal/dsl"
@@ -21,6 +24,8 @@ func setupTestRegistry() *schema.Registry {
 					{Name: "status", Type: "string", Nullable: false},
 					{Name: "amount", Type: "decimal", Nullable: false},
 					{Name: "created_at", Type: "timestamp", Nullable: false},
+					{Name: "is_paid", Type: "boolean", Nullable: false},
+					{Name: "metadata", Type: "json", Nullable: true},
 				},
 			},
 			{
@@ -33,6 +38,7 @@ func setupTestRegistry() *schema.Registry {
 					{Name: "email", Type: "string", Nullable: false},
 					{Name: "age", Type: "integer", Nullable: true},
 				},
+				SearchFields: []string{"name", "email"},
 			},
 		},
 	}
@@ -152,9 +158,9 @@ func TestPlanQuery_QueryWithLogicalFilter(t *testing.T) {
 	query := &dsl.Query{
 		Model: "orders",
 		Filters: &dsl.LogicalFilter{
-			And: []*dsl.ComparisonFilter{
-				{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
-				{Field: "amount", Op: dsl.OpGT, Value: 1000},
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+				&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 1000},
 			},
 		},
 	}
@@ -201,13 +207,246 @@ func TestPlanQuery_QueryWithLogicalFilter(t *testing.T) {
 	}
 }
 
+func TestPlanQuery_NestedLogicalFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model: "orders",
+		Filters: &dsl.LogicalFilter{
+			Or: []dsl.FilterExpr{
+				&dsl.LogicalFilter{
+					And: []dsl.FilterExpr{
+						&dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "paid"},
+						&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGT, Value: 100},
+					},
+				},
+				&dsl.LogicalFilter{
+					Not: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "refunded"},
+				},
+			},
+		},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	root, ok := plan.Filters.(*LogicalFilterIR)
+	if !ok || root.Op != "OR" || len(root.Nodes) != 2 {
+		t.Fatalf("Filters = %#v, want OR with 2 nodes", plan.Filters)
+	}
+
+	and, ok := root.Nodes[0].(*LogicalFilterIR)
+	if !ok || and.Op != "AND" || len(and.Nodes) != 2 {
+		t.Fatalf("Nodes[0] = %#v, want AND with 2 nodes", root.Nodes[0])
+	}
+	if cond, ok := and.Nodes[1].(*ComparisonFilterIR); !ok || cond.Left.ColumnName != "amount" {
+		t.Errorf("Nodes[0].Nodes[1] = %#v, want amount comparison", and.Nodes[1])
+	}
+
+	not, ok := root.Nodes[1].(*LogicalFilterIR)
+	if !ok || not.Op != "NOT" || len(not.Nodes) != 1 {
+		t.Fatalf("Nodes[1] = %#v, want NOT with 1 node", root.Nodes[1])
+	}
+}
+
+func TestPlanQuery_EmptyLogicalFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	_, err := planner.PlanQuery(&dsl.Query{Model: "orders", Filters: &dsl.LogicalFilter{}})
+	if err == nil {
+		t.Errorf("PlanQuery() error = nil, want error for empty logical filter")
+	}
+}
+
+func setupRelationTestRegistry(t *testing.T) *schema.Registry {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "customer_id", Type: "integer"},
+					{Name: "amount", Type: "decimal"},
+				},
+			},
+			{
+				Name:       "customers",
+				Table:      "customers",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "string"},
+					{Name: "country_id", Type: "integer"},
+				},
+			},
+			{
+				Name:       "countries",
+				Table:      "countries",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "string"},
+				},
+			},
+		},
+	}
+
+	reg := schema.NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+	relations := []struct {
+		model string
+		rel   *schema.Relation
+	}{
+		{"orders", &schema.Relation{Name: "customer", Type: schema.ManyToOne, TargetModel: "customers", ForeignKey: "customer_id", ReferenceKey: "id"}},
+		{"customers", &schema.Relation{Name: "country", Type: schema.ManyToOne, TargetModel: "countries", ForeignKey: "country_id", ReferenceKey: "id"}},
+	}
+	for _, r := range relations {
+		if err := reg.AddRelation(r.model, r.rel); err != nil {
+			t.Fatalf("AddRelation() error = %v", err)
+		}
+	}
+	return reg
+}
+
+func TestPlanQuery_RelationJoins(t *testing.T) {
+	reg := setupRelationTestRegistry(t)
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model:   "orders",
+		Fields:  []string{"id", "customer.email", "customer.country.name"},
+		Filters: &dsl.ComparisonFilter{Field: "customer.country.name", Op: dsl.OpEqual, Value: "NL"},
+		Sort:    []dsl.Sort{{Field: "customer.email", Direction: dsl.SortAsc}},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	// Each relation path is joined once, in order of first use
+	if len(plan.Joins) != 2 {
+		t.Fatalf("Joins has %d items, want 2", len(plan.Joins))
+	}
+
+	customer := plan.Joins[0]
+	if customer.Type != JoinLeft || customer.FromAlias != "t0" || customer.ToTable != "customers" || customer.ToAlias != "t1" {
+		t.Errorf("Joins[0] = %+v", customer)
+	}
+	if customer.On.Left.ColumnName != "customer_id" || customer.On.Right.TableAlias != "t1" || customer.On.Right.ColumnName != "id" {
+		t.Errorf("Joins[0].On = %+v", customer.On)
+	}
+
+	country := plan.Joins[1]
+	if country.FromAlias != "t1" || country.ToTable != "countries" || country.ToAlias != "t2" {
+		t.Errorf("Joins[1] = %+v", country)
+	}
+	if country.On.Left.TableAlias != "t1" || country.On.Left.ColumnName != "country_id" {
+		t.Errorf("Joins[1].On.Left = %+v", country.On.Left)
+	}
+
+	if plan.Select[1].Column.TableAlias != "t1" || plan.Select[1].Column.ColumnName != "email" {
+		t.Errorf("Select[1].Column = %+v, want t1.email", plan.Select[1].Column)
+	}
+	if plan.Select[2].Column.TableAlias != "t2" || plan.Select[2].Alias != "customer.country.name" {
+		t.Errorf("Select[2] = %+v, want t2.name aliased customer.country.name", plan.Select[2])
+	}
+
+	filter, ok := plan.Filters.(*ComparisonFilterIR)
+	if !ok || filter.Left.TableAlias != "t2" {
+		t.Errorf("Filters = %#v, want comparison on t2", plan.Filters)
+	}
+	if plan.Sort[0].Column.TableAlias != "t1" {
+		t.Errorf("Sort[0].Column.TableAlias = %s, want t1", plan.Sort[0].Column.TableAlias)
+	}
+}
+
+func TestPlanQuery_ManyToManyJoin(t *testing.T) {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields:     []config.Field{{Name: "id", Type: "integer"}},
+				Relations: []config.Relation{
+					{Name: "tags", Type: "many_to_many", Model: "tags",
+						JoinTable: &config.JoinTable{Table: "order_tags", SourceKey: "order_id", TargetKey: "tag_id"}},
+				},
+			},
+			{
+				Name:       "tags",
+				Table:      "tags",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "label", Type: "string"},
+				},
+			},
+		},
+	}
+	reg := schema.NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+	planner := NewPlanner(reg)
+
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "tags.label", Op: dsl.OpEqual, Value: "gift"},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	if len(plan.Joins) != 2 {
+		t.Fatalf("Joins has %d items, want 2 (link table and target)", len(plan.Joins))
+	}
+
+	link := plan.Joins[0]
+	if link.ToTable != "order_tags" || link.ToAlias != "t1" || link.On.Left.ColumnName != "id" || link.On.Right.ColumnName != "order_id" {
+		t.Errorf("Joins[0] = %+v, want t0.id = order_tags t1.order_id", link)
+	}
+
+	target := plan.Joins[1]
+	if target.FromAlias != "t1" || target.ToTable != "tags" || target.ToAlias != "t2" || target.On.Left.ColumnName != "tag_id" || target.On.Right.ColumnName != "id" {
+		t.Errorf("Joins[1] = %+v, want t1.tag_id = tags t2.id", target)
+	}
+
+	filter, ok := plan.Filters.(*ComparisonFilterIR)
+	if !ok || filter.Left.TableAlias != "t2" || filter.Left.ColumnName != "label" {
+		t.Errorf("Filters = %#v, want comparison on t2.label", plan.Filters)
+	}
+}
+
+func TestPlanQuery_NoJoinsForLocalFields(t *testing.T) {
+	reg := setupRelationTestRegistry(t)
+	planner := NewPlanner(reg)
+
+	plan, err := planner.PlanQuery(&dsl.Query{Model: "orders", Fields: []string{"id", "amount"}})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+	if len(plan.Joins) != 0 {
+		t.Errorf("Joins has %d items, want 0", len(plan.Joins))
+	}
+}
+
 func TestPlanQuery_QueryWithGroupBy(t *testing.T) {
 	reg := setupTestRegistry()
 	planner := NewPlanner(reg)
 
 	query := &dsl.Query{
 		Model:   "orders",
-		GroupBy: []string{"status"},
+		GroupBy: []dsl.GroupBy{{Field: "status"}},
 	}
 
 	plan, err := planner.PlanQuery(query)
@@ -232,7 +471,7 @@ func TestPlanQuery_QueryWithAggregates(t *testing.T) {
 
 	query := &dsl.Query{
 		Model:   "orders",
-		GroupBy: []string{"status"},
+		GroupBy: []dsl.GroupBy{{Field: "status"}},
 		Aggregates: []dsl.Aggregate{
 			{Function: dsl.AggSum, Field: "amount", Alias: "total_amount"},
 			{Function: dsl.AggCount, Field: "", Alias: "order_count"},
@@ -275,6 +514,497 @@ func TestPlanQuery_QueryWithAggregates(t *testing.T) {
 	}
 }
 
+func TestPlanQuery_ExtendedAggregates(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	fraction := 0.9
+	query := &dsl.Query{
+		Model: "orders",
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggCountDistinct, Field: "status", Alias: "statuses"},
+			{Function: dsl.AggPercentile, Field: "amount", Alias: "p90", Fraction: &fraction},
+			{Function: dsl.AggStringAgg, Field: "status", Alias: "status_list"},
+			{Function: dsl.AggBoolAnd, Field: "is_paid", Alias: "all_paid"},
+		},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	want := []AggregateFn{AggCountDistinctFn, AggPercentileFn, AggStringAggFn, AggBoolAndFn}
+	for i, fn := range want {
+		if plan.Aggregates[i].Function != fn {
+			t.Errorf("Aggregates[%d].Function = %s, want %s", i, plan.Aggregates[i].Function, fn)
+		}
+	}
+	if plan.Aggregates[1].Fraction != 0.9 {
+		t.Errorf("Fraction = %v, want 0.9", plan.Aggregates[1].Fraction)
+	}
+	if plan.Aggregates[2].Separator != dsl.DefaultStringAggSeparator {
+		t.Errorf("Separator = %q, want default %q", plan.Aggregates[2].Separator, dsl.DefaultStringAggSeparator)
+	}
+	if got := aggregateResultType(plan.Aggregates[3]); got != TypeBoolean {
+		t.Errorf("bool_and result type = %s, want boolean", got)
+	}
+}
+
+func TestPlanQuery_AggregateFilter(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model:   "orders",
+		GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggCount, Alias: "paid", Filter: &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"}},
+			{Function: dsl.AggCount, Alias: "total"},
+		},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	filter, ok := plan.Aggregates[0].Filter.(*ComparisonFilterIR)
+	if !ok {
+		t.Fatalf("Aggregates[0].Filter = %#v, want *ComparisonFilterIR", plan.Aggregates[0].Filter)
+	}
+	if filter.Left.ColumnName != "status" || filter.Left.TableAlias != "t0" || filter.Value.Value != "PAID" {
+		t.Errorf("Aggregates[0].Filter = %+v, want t0.status = PAID", filter)
+	}
+	if plan.Aggregates[1].Filter != nil {
+		t.Errorf("Aggregates[1].Filter = %#v, want nil", plan.Aggregates[1].Filter)
+	}
+}
+
+func TestPlanQuery_NormalizesFilterValues(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model: "orders",
+		Filters: &dsl.LogicalFilter{
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "user_id", Op: dsl.OpIn, Value: []interface{}{float64(1), "2"}},
+				&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01T00:00:00Z"},
+			},
+		},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	nodes := plan.Filters.(*LogicalFilterIR).Nodes
+	in := nodes[0].(*ComparisonFilterIR).Value.Value
+	if !reflect.DeepEqual(in, []interface{}{int64(1), int64(2)}) {
+		t.Errorf("in value = %#v, want []interface{}{int64(1), int64(2)}", in)
+	}
+	if _, ok := nodes[1].(*ComparisonFilterIR).Value.Value.(time.Time); !ok {
+		t.Errorf("after value = %#v, want time.Time", nodes[1].(*ComparisonFilterIR).Value.Value)
+	}
+
+	// The planner rejects values that do not fit even without prior validation
+	_, err = planner.PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "amount", Op: dsl.OpBetween, Value: float64(5)},
+	})
+	if err == nil {
+		t.Errorf("PlanQuery() error = nil, want error for scalar between value")
+	}
+}
+
+func TestPlanQuery_RangeFilters(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	tests := []struct {
+		name  string
+		field string
+		op    dsl.FilterOperator
+		value interface{}
+		want  []interface{}
+	}{
+		{"between", "amount", dsl.OpBetween, []interface{}{float64(10), float64(20)}, []interface{}{float64(10), float64(20)}},
+		{"gte_lt timestamps", "created_at", dsl.OpGTELT, []interface{}{"2024-01-01", "2024-02-01"},
+			[]interface{}{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}},
+		{"open lower bound", "created_at", dsl.OpBetween, []interface{}{nil, "2024-02-01T12:00:00Z"},
+			[]interface{}{nil, time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)}},
+		{"open upper bound", "id", dsl.OpGTLTE, []interface{}{"100", nil}, []interface{}{int64(100), nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.PlanQuery(&dsl.Query{
+				Model:   "orders",
+				Filters: &dsl.ComparisonFilter{Field: tt.field, Op: tt.op, Value: tt.value},
+			})
+			if err != nil {
+				t.Fatalf("PlanQuery() error = %v", err)
+			}
+			f := plan.Filters.(*ComparisonFilterIR)
+			if f.Operator != tt.op {
+				t.Errorf("Operator = %s, want %s", f.Operator, tt.op)
+			}
+			if !reflect.DeepEqual(f.Value.Value, tt.want) {
+				t.Errorf("Value = %#v, want %#v", f.Value.Value, tt.want)
+			}
+		})
+	}
+
+	_, err := planner.PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Filters: &dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpGTELT, Value: []interface{}{nil, nil}},
+	})
+	if err == nil {
+		t.Errorf("PlanQuery() error = nil, want error for range without bounds")
+	}
+}
+
+func TestPlanQuery_RelativeDates(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+	planner.SetClock(func() time.Time {
+		return time.Date(2024, 3, 14, 23, 30, 0, 0, time.UTC)
+	})
+
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model: "orders",
+		Filters: &dsl.LogicalFilter{
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpGTELT, Value: []interface{}{"start_of_month", "now"}},
+				&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01"},
+			},
+		},
+		Timezone: "Asia/Tokyo",
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	// 23:30 UTC on the 14th is already the 15th in Tokyo; still March
+	wantLower := time.Date(2024, 3, 1, 0, 0, 0, 0, tokyo)
+	wantUpper := time.Date(2024, 3, 15, 8, 30, 0, 0, tokyo)
+
+	nodes := plan.Filters.(*LogicalFilterIR).Nodes
+	bounds := nodes[0].(*ComparisonFilterIR).Value.Value.([]interface{})
+	if !bounds[0].(time.Time).Equal(wantLower) || !bounds[1].(time.Time).Equal(wantUpper) {
+		t.Errorf("bounds = %v, want [%v %v]", bounds, wantLower, wantUpper)
+	}
+
+	// Only the relative filter is reported
+	if len(plan.Resolved) != 1 {
+		t.Fatalf("Resolved length = %d, want 1", len(plan.Resolved))
+	}
+	rv := plan.Resolved[0]
+	if rv.Field != "created_at" || rv.Operator != dsl.OpGTELT {
+		t.Errorf("Resolved[0] = %+v, want created_at gte_lt", rv)
+	}
+	if !reflect.DeepEqual(rv.Expression, []interface{}{"start_of_month", "now"}) {
+		t.Errorf("Resolved[0].Expression = %#v", rv.Expression)
+	}
+	if !reflect.DeepEqual(rv.Value, bounds) {
+		t.Errorf("Resolved[0].Value = %#v, want %#v", rv.Value, bounds)
+	}
+}
+
+func TestPlanQuery_JSONPaths(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model:   "orders",
+		Fields:  []string{"metadata.shipping.country"},
+		Filters: &dsl.ComparisonFilter{Field: "metadata.weight::float", Op: dsl.OpGT, Value: "2.5"},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+
+	sel := plan.Select[0]
+	if sel.Column.ColumnName != "metadata" || sel.Column.DataType != TypeString || !reflect.DeepEqual(sel.Column.JSONPath, []string{"shipping", "country"}) {
+		t.Errorf("Select[0].Column = %+v, want metadata string path [shipping country]", sel.Column)
+	}
+	if sel.Alias != "metadata.shipping.country" {
+		t.Errorf("Select[0].Alias = %s, want metadata.shipping.country", sel.Alias)
+	}
+
+	// The cast type drives value coercion
+	f := plan.Filters.(*ComparisonFilterIR)
+	if f.Left.DataType != TypeFloat || !reflect.DeepEqual(f.Left.JSONPath, []string{"weight"}) {
+		t.Errorf("Filter.Left = %+v, want float path [weight]", f.Left)
+	}
+	if f.Value.Value != 2.5 {
+		t.Errorf("Filter value = %#v, want 2.5", f.Value.Value)
+	}
+}
+
+func TestPlanQuery_Search(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model:   "users",
+		Filters: &dsl.ComparisonFilter{Op: dsl.OpSearch, Value: "jane"},
+		Sort:    []dsl.Sort{{Field: dsl.SearchRankField, Direction: dsl.SortDesc}},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+
+	f := plan.Filters.(*ComparisonFilterIR)
+	if f.Search == nil || f.Search.Language != "english" || len(f.Search.Columns) != 2 {
+		t.Fatalf("Search = %+v, want english document over 2 columns", f.Search)
+	}
+	if f.Search.Columns[0].ColumnName != "name" || f.Search.Columns[1].ColumnName != "email" {
+		t.Errorf("Search columns = %+v, want name, email", f.Search.Columns)
+	}
+
+	if len(plan.Sort) != 1 || plan.Sort[0].Target != SortRank || plan.Sort[0].Direction != "DESC" {
+		t.Fatalf("Sort = %+v, want one DESC rank sort", plan.Sort)
+	}
+	if plan.Sort[0].Search.Value.Value != "jane" {
+		t.Errorf("rank search value = %v, want jane", plan.Sort[0].Search.Value.Value)
+	}
+
+	// A field narrows the search to that column
+	plan, err = planner.PlanQuery(&dsl.Query{
+		Model:   "users",
+		Filters: &dsl.ComparisonFilter{Field: "email", Op: dsl.OpSearch, Value: "example"},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+	f = plan.Filters.(*ComparisonFilterIR)
+	if len(f.Search.Columns) != 1 || f.Search.Columns[0].ColumnName != "email" {
+		t.Errorf("Search columns = %+v, want email", f.Search.Columns)
+	}
+}
+
+func TestPlanQuery_Similar(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	threshold := 0.6
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model: "users",
+		Filters: &dsl.LogicalFilter{
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "name", Op: dsl.OpSimilar, Value: "jonh", Threshold: &threshold},
+				&dsl.ComparisonFilter{Field: "email", Op: dsl.OpSimilar, Value: "jonh@"},
+			},
+		},
+		Sort: []dsl.Sort{{Field: dsl.SimilarityField, Direction: dsl.SortDesc}},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+
+	nodes := plan.Filters.(*LogicalFilterIR).Nodes
+	if f := nodes[0].(*ComparisonFilterIR); f.Threshold != 0.6 || f.Left.ColumnName != "name" {
+		t.Errorf("filter[0] = %+v, want name with threshold 0.6", f)
+	}
+	if f := nodes[1].(*ComparisonFilterIR); f.Threshold != dsl.DefaultSimilarityThreshold {
+		t.Errorf("filter[1] threshold = %v, want default %v", f.Threshold, dsl.DefaultSimilarityThreshold)
+	}
+
+	// Similarity sorts by the first similar filter
+	if len(plan.Sort) != 1 || plan.Sort[0].Target != SortSimilarity || plan.Sort[0].Direction != "DESC" {
+		t.Fatalf("Sort = %+v, want one DESC similarity sort", plan.Sort)
+	}
+	if s := plan.Sort[0].Search; s.Left.ColumnName != "name" || s.Value.Value != "jonh" {
+		t.Errorf("similarity sort = %+v, want name against jonh", s)
+	}
+}
+
+func TestPlanQuery_Keyset(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	sort := []dsl.Sort{{Field: "age", Direction: dsl.SortDesc}}
+	cursor, err := dsl.EncodeCursor(dsl.KeysetSort(&dsl.Query{Sort: sort}, "id"), []interface{}{nil, 42})
+	if err != nil {
+		t.Fatalf("EncodeCursor() error = %v", err)
+	}
+
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model:      "users",
+		Fields:     []string{"name"},
+		Filters:    &dsl.ComparisonFilter{Field: "name", Op: dsl.OpStartsWith, Value: "J"},
+		Sort:       sort,
+		Pagination: &dsl.Pagination{Limit: 20, Cursor: cursor},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+
+	k := plan.Keyset
+	if k == nil || len(k.Keys) != 2 {
+		t.Fatalf("Keyset = %+v, want age and id keys", k)
+	}
+	if k.Keys[0].Field != "age" || !k.Keys[0].Nullable || k.Keys[1].Field != "id" || k.Keys[1].Direction != "DESC" {
+		t.Errorf("Keys = %+v, want nullable age then id DESC", k.Keys)
+	}
+	if !reflect.DeepEqual(k.After, []interface{}{nil, int64(42)}) {
+		t.Errorf("After = %#v, want [nil, 42]", k.After)
+	}
+
+	// The primary key completes the ordering and key fields are selected
+	if len(plan.Sort) != 2 || plan.Sort[1].Column.ColumnName != "id" || plan.Sort[1].Direction != "DESC" {
+		t.Errorf("Sort = %+v, want age then id DESC", plan.Sort)
+	}
+	var selected []string
+	for _, s := range plan.Select {
+		selected = append(selected, s.Alias)
+	}
+	if !reflect.DeepEqual(selected, []string{"name", "age", "id"}) {
+		t.Errorf("Select = %v, want name, age, id", selected)
+	}
+	// The position is not a filter, so derived queries see every page
+	if _, ok := plan.Filters.(*ComparisonFilterIR); !ok {
+		t.Errorf("Filters = %#v, want only the name filter", plan.Filters)
+	}
+
+	next, err := k.NextCursor(map[string]interface{}{"name": "Jo", "age": int64(30), "id": int64(7)})
+	if err != nil {
+		t.Fatalf("NextCursor() error = %v", err)
+	}
+	values, err := dsl.DecodeCursor(next, dsl.KeysetSort(&dsl.Query{Sort: sort}, "id"))
+	if err != nil || len(values) != 2 || values[0].(json.Number) != "30" || values[1].(json.Number) != "7" {
+		t.Errorf("NextCursor() decodes to %v, %v; want [30 7]", values, err)
+	}
+}
+
+func TestPlanQuery_SortByAggregateAlias(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model:      "orders",
+		GroupBy:    []dsl.GroupBy{{Field: "status"}},
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "order_count"}},
+		Sort: []dsl.Sort{
+			{Field: "order_count", Direction: dsl.SortDesc},
+			{Field: "status"},
+		},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	if len(plan.Sort) != 2 {
+		t.Fatalf("Sort length = %d, want 2", len(plan.Sort))
+	}
+
+	first := plan.Sort[0]
+	if first.Target != SortAggregate || first.Column != nil {
+		t.Errorf("Sort[0] = %+v, want aggregate target without column", first)
+	}
+	if first.Aggregate == nil || first.Aggregate.Alias != "order_count" || first.Aggregate.Function != AggCountFn {
+		t.Errorf("Sort[0].Aggregate = %+v, want COUNT order_count", first.Aggregate)
+	}
+	if first.Direction != "DESC" {
+		t.Errorf("Sort[0].Direction = %s, want DESC", first.Direction)
+	}
+
+	second := plan.Sort[1]
+	if second.Target != SortColumn || second.Column == nil || second.Column.ColumnName != "status" {
+		t.Errorf("Sort[1] = %+v, want column status", second)
+	}
+}
+
+func TestPlanQuery_TimeBucketGroupBy(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model: "orders",
+		GroupBy: []dsl.GroupBy{
+			{Field: "created_at", Granularity: dsl.GranularityDay, Timezone: "Europe/Berlin"},
+			{Field: "created_at", Granularity: dsl.GranularityMonth, Alias: "month"},
+		},
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	want := []GroupExpr{
+		{Alias: "created_at_day", Granularity: "day", Timezone: "Europe/Berlin"},
+		{Alias: "month", Granularity: "month"},
+	}
+	if len(plan.GroupBy) != len(want) {
+		t.Fatalf("GroupBy length = %d, want %d", len(plan.GroupBy), len(want))
+	}
+	for i, w := range want {
+		got := plan.GroupBy[i]
+		if got.Alias != w.Alias || got.Granularity != w.Granularity || got.Timezone != w.Timezone {
+			t.Errorf("GroupBy[%d] = %+v, want %+v", i, got, w)
+		}
+		if got.Column.ColumnName != "created_at" || got.Column.DataType != TypeTimestamp {
+			t.Errorf("GroupBy[%d].Column = %+v, want created_at timestamp", i, got.Column)
+		}
+	}
+}
+
+func TestPlanQuery_QueryWithHaving(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	query := &dsl.Query{
+		Model:   "orders",
+		GroupBy: []dsl.GroupBy{{Field: "user_id"}},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggSum, Field: "amount", Alias: "total_amount"},
+			{Function: dsl.AggCount, Alias: "order_count"},
+		},
+		Having: &dsl.ComparisonFilter{Field: "total_amount", Op: dsl.OpGT, Value: 1000},
+	}
+
+	plan, err := planner.PlanQuery(query)
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v, want nil", err)
+	}
+
+	having, ok := plan.Having.(*ComparisonFilterIR)
+	if !ok {
+		t.Fatalf("Having = %#v, want *ComparisonFilterIR", plan.Having)
+	}
+	if having.Aggregate == nil || having.Aggregate.Function != AggSumFn || having.Aggregate.Alias != "total_amount" {
+		t.Errorf("Having.Aggregate = %+v, want SUM total_amount", having.Aggregate)
+	}
+	if having.Aggregate.Column == nil || having.Aggregate.Column.ColumnName != "amount" {
+		t.Errorf("Having.Aggregate.Column = %+v, want amount", having.Aggregate.Column)
+	}
+	if having.Value == nil || having.Value.Value != int64(1000) || having.Value.Type != TypeDecimal {
+		t.Errorf("Having.Value = %+v, want 1000 decimal", having.Value)
+	}
+}
+
+func TestPlanQuery_HavingUnknownAlias(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	_, err := planner.PlanQuery(&dsl.Query{
+		Model:      "orders",
+		Aggregates: []dsl.Aggregate{{Function: dsl.AggCount, Alias: "n"}},
+		Having:     &dsl.ComparisonFilter{Field: "m", Op: dsl.OpGT, Value: 1},
+	})
+	if err == nil {
+		t.Errorf("PlanQuery() error = nil, want error for unknown having alias")
+	}
+}
+
 func TestPlanQuery_QueryWithSort(t *testing.T) {
 	reg := setupTestRegistry()
 	planner := NewPlanner(reg)
@@ -346,11 +1076,11 @@ func TestPlanQuery_ComplexQuery(t *testing.T) {
 	query := &dsl.Query{
 		Model:   "orders",
 		Fields:  []string{"status", "amount"},
-		GroupBy: []string{"status"},
+		GroupBy: []dsl.GroupBy{{Field: "status"}},
 		Filters: &dsl.LogicalFilter{
-			And: []*dsl.ComparisonFilter{
-				{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01"},
-				{Field: "amount", Op: dsl.OpGTE, Value: 100},
+			And: []dsl.FilterExpr{
+				&dsl.ComparisonFilter{Field: "created_at", Op: dsl.OpAfter, Value: "2024-01-01"},
+				&dsl.ComparisonFilter{Field: "amount", Op: dsl.OpGTE, Value: 100},
 			},
 		},
 		Aggregates: []dsl.Aggregate{
@@ -445,3 +1175,43 @@ func TestNewPlanner(t *testing.T) {
 		t.Errorf("NewPlanner() registry not set correctly")
 	}
 }
+
+func TestPlanQuery_Windows(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	offset := 2
+	plan, err := planner.PlanQuery(&dsl.Query{
+		Model: "orders",
+		Windows: []dsl.Window{
+			{Function: dsl.WinLag, Field: "amount", PartitionBy: []string{"user_id"},
+				OrderBy: []dsl.Sort{{Field: "created_at", Direction: dsl.SortDesc}}, Offset: &offset, Alias: "prev_amount"},
+			{Function: dsl.WinCount, Alias: "total_orders"},
+		},
+		Sort: []dsl.Sort{{Field: "prev_amount"}},
+	})
+	if err != nil {
+		t.Fatalf("PlanQuery() error = %v", err)
+	}
+
+	if len(plan.Windows) != 2 {
+		t.Fatalf("Windows = %+v, want 2", plan.Windows)
+	}
+	lag := plan.Windows[0]
+	if lag.Function != WinLagFn || lag.Column == nil || lag.Column.ColumnName != "amount" || lag.Offset != 2 {
+		t.Errorf("window[0] = %+v, want LAG of amount by 2", lag)
+	}
+	if len(lag.PartitionBy) != 1 || lag.PartitionBy[0].ColumnName != "user_id" {
+		t.Errorf("window[0] PartitionBy = %+v, want user_id", lag.PartitionBy)
+	}
+	if len(lag.OrderBy) != 1 || lag.OrderBy[0].Column.ColumnName != "created_at" || lag.OrderBy[0].Direction != "DESC" {
+		t.Errorf("window[0] OrderBy = %+v, want created_at DESC", lag.OrderBy)
+	}
+	if count := plan.Windows[1]; count.Function != WinCountFn || count.Column != nil {
+		t.Errorf("window[1] = %+v, want COUNT(*)", count)
+	}
+
+	if len(plan.Sort) != 1 || plan.Sort[0].Target != SortWindow || plan.Sort[0].Window.Alias != "prev_amount" {
+		t.Errorf("Sort = %+v, want window sort by prev_amount", plan.Sort)
+	}
+}
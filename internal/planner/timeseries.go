@@ -0,0 +1,80 @@
+package planner
+
+import (
+	"fmt"
+	"time"
+
+	"udv/internal/dsl"
+)
+
+// TimeSeriesPlan is an aggregate query grouped by a single time bucket,
+// together with the range whose every bucket must appear in the result
+type TimeSeriesPlan struct {
+	Query    *QueryPlan // GROUP BY Bucket, aggregates and filters; no sort or pagination
+	Bucket   GroupExpr
+	From     time.Time       // inclusive, in the request timezone
+	To       time.Time       // exclusive, in the request timezone
+	ZeroFill map[string]bool // aggregate aliases reported as 0 for empty buckets
+}
+
+// PlanTimeSeries converts a validated time series request into a plan
+func (p *Planner) PlanTimeSeries(q *dsl.TimeSeriesQuery) (*TimeSeriesPlan, error) {
+	if q == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+
+	from, to, err := q.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := q.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	timezone := q.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	queryPlan, err := p.PlanQuery(&dsl.Query{
+		Model:   q.Model,
+		Filters: q.Filters,
+		GroupBy: []dsl.GroupBy{{
+			Field:       q.Field,
+			Granularity: q.Interval,
+			Timezone:    timezone,
+			Alias:       dsl.TimeSeriesBucketColumn,
+		}},
+		Aggregates: q.Aggregates,
+		Timezone:   q.Timezone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	queryPlan.Pagination = Pagination{}
+
+	zeroFill := make(map[string]bool)
+	for _, agg := range queryPlan.Aggregates {
+		if agg.Function == AggCountFn || agg.Function == AggCountDistinctFn || (q.Fill == dsl.FillZero && isNumericType(aggregateResultType(agg))) {
+			zeroFill[agg.Alias] = true
+		}
+	}
+
+	return &TimeSeriesPlan{
+		Query:    queryPlan,
+		Bucket:   queryPlan.GroupBy[0],
+		From:     from.In(loc),
+		To:       to.In(loc),
+		ZeroFill: zeroFill,
+	}, nil
+}
+
+// isNumericType reports whether values of the type can be replaced by 0
+func isNumericType(t FieldType) bool {
+	switch t {
+	case TypeInteger, TypeInt, TypeFloat, TypeDecimal:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package planner
+
+import (
+	"testing"
+
+	"udv/internal/dsl"
+)
+
+func TestPlanTimeSeries(t *testing.T) {
+	reg := setupTestRegistry()
+	planner := NewPlanner(reg)
+
+	q := &dsl.TimeSeriesQuery{
+		Model:    "orders",
+		Field:    "created_at",
+		Interval: dsl.GranularityWeek,
+		Timezone: "Europe/Paris",
+		Range:    dsl.TimeRange{From: "2024-03-01", To: "2024-04-01"},
+		Filters:  &dsl.ComparisonFilter{Field: "status", Op: dsl.OpEqual, Value: "PAID"},
+		Aggregates: []dsl.Aggregate{
+			{Function: dsl.AggCount, Alias: "orders"},
+			{Function: dsl.AggSum, Field: "amount", Alias: "revenue"},
+			{Function: dsl.AggMax, Field: "status", Alias: "last_status"},
+		},
+	}
+
+	plan, err := planner.PlanTimeSeries(q)
+	if err != nil {
+		t.Fatalf("PlanTimeSeries() error = %v", err)
+	}
+
+	if plan.Bucket.Alias != dsl.TimeSeriesBucketColumn || plan.Bucket.Granularity != "week" || plan.Bucket.Timezone != "Europe/Paris" {
+		t.Errorf("Bucket = %+v, want week bucket in Europe/Paris", plan.Bucket)
+	}
+	if plan.Bucket.Column.ColumnName != "created_at" {
+		t.Errorf("Bucket.Column = %+v, want created_at", plan.Bucket.Column)
+	}
+	if plan.From.Format("2006-01-02 15:04 MST") != "2024-03-01 00:00 CET" {
+		t.Errorf("From = %v, want local midnight", plan.From)
+	}
+	if plan.Query.Filters == nil || len(plan.Query.Aggregates) != 3 {
+		t.Errorf("Query = %+v, want filters and 3 aggregates", plan.Query)
+	}
+	if plan.Query.Pagination.Limit != 0 {
+		t.Errorf("Pagination.Limit = %d, want 0", plan.Query.Pagination.Limit)
+	}
+
+	// Counts are always zero-filled; other aggregates only with fill=zero
+	if !plan.ZeroFill["orders"] || plan.ZeroFill["revenue"] || plan.ZeroFill["last_status"] {
+		t.Errorf("ZeroFill = %v, want only orders", plan.ZeroFill)
+	}
+
+	q.Fill = dsl.FillZero
+	plan, err = planner.PlanTimeSeries(q)
+	if err != nil {
+		t.Fatalf("PlanTimeSeries() error = %v", err)
+	}
+	if !plan.ZeroFill["orders"] || !plan.ZeroFill["revenue"] || plan.ZeroFill["last_status"] {
+		t.Errorf("ZeroFill = %v, want orders and revenue", plan.ZeroFill)
+	}
+}
@@ -0,0 +1,81 @@
+package planner
+
+import (
+	"strings"
+
+	"udv/internal/dsl"
+)
+
+// WindowFn represents a window function in IR
+type WindowFn string
+
+const (
+	WinRowNumberFn   WindowFn = "ROW_NUMBER"
+	WinRankFn        WindowFn = "RANK"
+	WinDenseRankFn   WindowFn = "DENSE_RANK"
+	WinPercentRankFn WindowFn = "PERCENT_RANK"
+	WinLagFn         WindowFn = "LAG"
+	WinLeadFn        WindowFn = "LEAD"
+	WinFirstValueFn  WindowFn = "FIRST_VALUE"
+	WinSumFn         WindowFn = "SUM"
+	WinAvgFn         WindowFn = "AVG"
+	WinCountFn       WindowFn = "COUNT"
+	WinMinFn         WindowFn = "MIN"
+	WinMaxFn         WindowFn = "MAX"
+)
+
+// WindowExpr represents a window function computed over the partition of
+// each result row
+type WindowExpr struct {
+	Function    WindowFn
+	Column      *ColumnRef // nil for ranking functions and COUNT(*)
+	Offset      int        // LAG/LEAD only; 0 means the SQL default of 1
+	PartitionBy []ColumnRef
+	OrderBy     []SortExpr // SortColumn entries only
+	Alias       string
+}
+
+// convertWindows converts the windows of a DSL query to IR
+func (p *Planner) convertWindows(scope *planScope, windows []dsl.Window) []WindowExpr {
+	exprs := make([]WindowExpr, 0, len(windows))
+	for _, w := range windows {
+		expr := WindowExpr{
+			Function: WindowFn(strings.ToUpper(string(w.Function))),
+			Alias:    w.Alias,
+		}
+		if w.Field != "" {
+			colRef := p.resolveColumn(scope, w.Field)
+			expr.Column = &colRef
+		}
+		if w.Offset != nil {
+			expr.Offset = *w.Offset
+		}
+		for _, field := range w.PartitionBy {
+			expr.PartitionBy = append(expr.PartitionBy, p.resolveColumn(scope, field))
+		}
+		for _, s := range w.OrderBy {
+			colRef := p.resolveColumn(scope, s.Field)
+			direction := "ASC"
+			if s.Direction == dsl.SortDesc {
+				direction = "DESC"
+			}
+			expr.OrderBy = append(expr.OrderBy, SortExpr{
+				Target:    SortColumn,
+				Column:    &colRef,
+				Direction: direction,
+			})
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs
+}
+
+func findWindow(plan *QueryPlan, alias string) *WindowExpr {
+	for i := range plan.Windows {
+		if plan.Windows[i].Alias == alias {
+			window := plan.Windows[i]
+			return &window
+		}
+	}
+	return nil
+}
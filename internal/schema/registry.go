@@ -2,9 +2,11 @@ package schema
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"udv/internal/config"
+	"udv/internal/limits"
 )
 
 // RelationType represents the type of relationship between models
@@ -29,20 +31,85 @@ type Field struct {
 
 // Relation represents a relationship to another model
 type Relation struct {
+	Name          string // Name used in dotted field paths, e.g. "user"
 	Type          RelationType
 	TargetModel   string // Name of the related model
 	ForeignKey    string // Local field name
 	ReferenceKey  string // Field in target model
+
+	// many_to_many only: the link table and its columns referencing the
+	// local ForeignKey and the target ReferenceKey respectively
+	JoinTable     string
+	JoinSourceKey string
+	JoinTargetKey string
 }
 
 // Model represents a data model with its fields and relationships
 type Model struct {
-	Name        string
-	Table       string
-	PrimaryKey  string
-	Fields      map[string]*Field
-	Relations   map[string]*Relation
-	FieldOrder  []string // Preserve field order
+	Name          string
+	Table         string
+	PrimaryKey    string
+	Fields        map[string]*Field
+	Relations     map[string]*Relation
+	FieldOrder    []string // Preserve field order
+	RelationOrder []string // Preserve relation declaration order
+
+	// Full-text search document: a precomputed tsvector column, or else the
+	// string fields to build one from, parsed with SearchLanguage
+	SearchFields   []string
+	SearchVector   string
+	SearchLanguage string
+}
+
+// Searchable reports whether the model has a full-text search document
+func (m *Model) Searchable() bool {
+	return m.SearchVector != "" || len(m.SearchFields) > 0
+}
+
+// FieldPath is a field reference resolved from a root model, possibly
+// through a chain of relations (e.g. "order.customer.country"). Segments
+// after a json field address keys inside the document
+// ("metadata.shipping.country"), and a "::type" suffix casts the extracted
+// value ("metadata.weight::float").
+type FieldPath struct {
+	Path      string
+	Relations []*Relation // Relations traversed, in order; empty for local fields
+	Model     string      // Model that owns Field
+	Field     *Field
+	JSONPath  []string // Keys (or array indexes) below a json Field
+	Cast      string   // Type the JSON value is cast to; empty for text
+}
+
+// jsonCastTypes are the types a JSON path value can be cast to
+var jsonCastTypes = map[string]bool{
+	"string":    true,
+	"integer":   true,
+	"float":     true,
+	"decimal":   true,
+	"boolean":   true,
+	"timestamp": true,
+	"date":      true,
+	"json":      true,
+}
+
+// Type returns the type of the value the path yields: the field type for
+// plain fields, otherwise the cast type, or string for uncast JSON paths
+// (extracted as text)
+func (fp *FieldPath) Type() string {
+	if len(fp.JSONPath) == 0 {
+		return fp.Field.Type
+	}
+	if fp.Cast != "" {
+		return fp.Cast
+	}
+	return "string"
+}
+
+// IsJSONDocument reports whether the path yields a JSON document (a json
+// field, or a JSON path that is uncast or cast to json) that the JSON
+// operators can be applied to
+func (fp *FieldPath) IsJSONDocument() bool {
+	return fp.Field.Type == "json" && (fp.Cast == "" || fp.Cast == "json")
 }
 
 // Registry is the in-memory schema registry
@@ -72,6 +139,13 @@ func (r *Registry) LoadFromConfig(cfg *config.Config) error {
 			Fields:     make(map[string]*Field),
 			Relations:  make(map[string]*Relation),
 			FieldOrder: []string{},
+
+			SearchFields:   cfgModel.SearchFields,
+			SearchVector:   cfgModel.SearchVector,
+			SearchLanguage: cfgModel.SearchLanguage,
+		}
+		if model.SearchLanguage == "" {
+			model.SearchLanguage = config.DefaultSearchLanguage
 		}
 
 		// Add fields with sensible defaults
@@ -89,9 +163,48 @@ func (r *Registry) LoadFromConfig(cfg *config.Config) error {
 			model.FieldOrder = append(model.FieldOrder, cfgField.Name)
 		}
 
+		for _, name := range model.SearchFields {
+			if f, exists := model.Fields[name]; !exists || f.Type != "string" {
+				return fmt.Errorf("invalid search field: %s.%s must be a string field", model.Name, name)
+			}
+		}
+
 		r.models[cfgModel.Name] = model
 	}
 
+	// Second pass: resolve relations now that every model exists
+	for _, cfgModel := range cfg.Models {
+		model := r.models[cfgModel.Name]
+		for _, cfgRel := range cfgModel.Relations {
+			rel := &Relation{
+				Name:         cfgRel.Name,
+				Type:         RelationType(cfgRel.Type),
+				TargetModel:  cfgRel.Model,
+				ForeignKey:   cfgRel.ForeignKey,
+				ReferenceKey: cfgRel.ReferenceKey,
+			}
+			if cfgRel.JoinTable != nil {
+				rel.JoinTable = cfgRel.JoinTable.Table
+				rel.JoinSourceKey = cfgRel.JoinTable.SourceKey
+				rel.JoinTargetKey = cfgRel.JoinTable.TargetKey
+			}
+
+			// Keys default to the primary keys on either side
+			if rel.ForeignKey == "" {
+				rel.ForeignKey = model.PrimaryKey
+			}
+			if rel.ReferenceKey == "" {
+				if target, exists := r.models[rel.TargetModel]; exists {
+					rel.ReferenceKey = target.PrimaryKey
+				}
+			}
+
+			if err := r.addRelation(model.Name, rel); err != nil {
+				return fmt.Errorf("invalid relation: %w", err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -172,3 +285,180 @@ func (r *Registry) GetModelFields(modelName string) ([]*Field, error) {
 	}
 	return fields, nil
 }
+
+// AddRelation registers a named relation on a model. The target model and
+// both key fields must already exist.
+func (r *Registry) AddRelation(modelName string, rel *Relation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.addRelation(modelName, rel)
+}
+
+// addRelation checks and registers a relation; the caller holds the lock
+func (r *Registry) addRelation(modelName string, rel *Relation) error {
+	model, exists := r.models[modelName]
+	if !exists {
+		return fmt.Errorf("model not found: %s", modelName)
+	}
+	if rel.Name == "" {
+		return fmt.Errorf("relation name is required on model %s", modelName)
+	}
+	if strings.Contains(rel.Name, ".") {
+		return fmt.Errorf("relation name must not contain '.': %s.%s", modelName, rel.Name)
+	}
+	if _, exists := model.Relations[rel.Name]; exists {
+		return fmt.Errorf("duplicate relation: %s.%s", modelName, rel.Name)
+	}
+	if _, exists := model.Fields[rel.Name]; exists {
+		return fmt.Errorf("relation name conflicts with field: %s.%s", modelName, rel.Name)
+	}
+
+	switch rel.Type {
+	case OneToOne, OneToMany, ManyToOne, ManyToMany:
+	default:
+		return fmt.Errorf("relation %s.%s: invalid type %q", modelName, rel.Name, rel.Type)
+	}
+
+	target, exists := r.models[rel.TargetModel]
+	if !exists {
+		return fmt.Errorf("relation %s.%s: target model not found: %s", modelName, rel.Name, rel.TargetModel)
+	}
+	if _, exists := model.Fields[rel.ForeignKey]; !exists {
+		return fmt.Errorf("relation %s.%s: foreign key field not found: %s.%s", modelName, rel.Name, modelName, rel.ForeignKey)
+	}
+	if _, exists := target.Fields[rel.ReferenceKey]; !exists {
+		return fmt.Errorf("relation %s.%s: reference key field not found: %s.%s", modelName, rel.Name, target.Name, rel.ReferenceKey)
+	}
+
+	if rel.Type == ManyToMany {
+		if rel.JoinTable == "" || rel.JoinSourceKey == "" || rel.JoinTargetKey == "" {
+			return fmt.Errorf("relation %s.%s: many_to_many requires a join table with source and target keys", modelName, rel.Name)
+		}
+		// The link table need not be a model, but if it is, check its columns
+		for _, m := range r.models {
+			if m.Table != rel.JoinTable {
+				continue
+			}
+			for _, key := range []string{rel.JoinSourceKey, rel.JoinTargetKey} {
+				if _, exists := m.Fields[key]; !exists {
+					return fmt.Errorf("relation %s.%s: join table key not found: %s.%s", modelName, rel.Name, rel.JoinTable, key)
+				}
+			}
+		}
+	} else if rel.JoinTable != "" {
+		return fmt.Errorf("relation %s.%s: join table is only valid for many_to_many", modelName, rel.Name)
+	}
+
+	model.Relations[rel.Name] = rel
+	model.RelationOrder = append(model.RelationOrder, rel.Name)
+	return nil
+}
+
+// GetRelation returns a named relation of a model
+func (r *Registry) GetRelation(modelName, relationName string) (*Relation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	model, exists := r.models[modelName]
+	if !exists {
+		return nil, fmt.Errorf("model not found: %s", modelName)
+	}
+
+	rel, exists := model.Relations[relationName]
+	if !exists {
+		return nil, fmt.Errorf("relation not found: %s.%s", modelName, relationName)
+	}
+
+	return rel, nil
+}
+
+// ListRelations returns all relations of a model in their declared order
+func (r *Registry) ListRelations(modelName string) ([]*Relation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	model, exists := r.models[modelName]
+	if !exists {
+		return nil, fmt.Errorf("model not found: %s", modelName)
+	}
+
+	relations := make([]*Relation, len(model.RelationOrder))
+	for i, name := range model.RelationOrder {
+		relations[i] = model.Relations[name]
+	}
+	return relations, nil
+}
+
+// ResolveFieldPath resolves a plain or dotted field path against a model.
+// Leading segments name relations and the next names a field of the model
+// reached through them. Only a json field may be followed by further
+// segments, which form its JSON path; a "::type" suffix casts that path.
+func (r *Registry) ResolveFieldPath(modelName, path string) (*FieldPath, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	model, exists := r.models[modelName]
+	if !exists {
+		return nil, fmt.Errorf("model not found: %s", modelName)
+	}
+
+	fp := &FieldPath{Path: path}
+	if i := strings.Index(path, "::"); i >= 0 {
+		fp.Cast = path[i+2:]
+		path = path[:i]
+		if !jsonCastTypes[fp.Cast] {
+			return nil, fmt.Errorf("invalid cast type in field path %s: %q", fp.Path, fp.Cast)
+		}
+	}
+
+	segments := strings.Split(path, ".")
+	for _, seg := range segments {
+		if seg == "" {
+			return nil, fmt.Errorf("invalid field path: %q", fp.Path)
+		}
+	}
+
+	i := 0
+	for ; i < len(segments)-1; i++ {
+		rel, exists := model.Relations[segments[i]]
+		if !exists {
+			break
+		}
+		if len(fp.Relations) == limits.MaxRelationHops {
+			return nil, fmt.Errorf("field path %s traverses more than %d relations", fp.Path, limits.MaxRelationHops)
+		}
+		target, exists := r.models[rel.TargetModel]
+		if !exists {
+			return nil, fmt.Errorf("model not found: %s", rel.TargetModel)
+		}
+		fp.Relations = append(fp.Relations, rel)
+		model = target
+	}
+
+	fieldName := segments[i]
+	field, exists := model.Fields[fieldName]
+	if !exists {
+		if i < len(segments)-1 {
+			return nil, fmt.Errorf("relation not found: %s.%s", model.Name, fieldName)
+		}
+		return nil, fmt.Errorf("field not found: %s.%s", model.Name, fieldName)
+	}
+
+	if rest := segments[i+1:]; len(rest) > 0 {
+		if field.Type != "json" {
+			return nil, fmt.Errorf("field %s.%s is not json and has no path %s", model.Name, fieldName, strings.Join(rest, "."))
+		}
+		if len(rest) > limits.MaxJSONPathDepth {
+			return nil, fmt.Errorf("field path %s is deeper than %d JSON keys", fp.Path, limits.MaxJSONPathDepth)
+		}
+		fp.JSONPath = rest
+	}
+	if fp.Cast != "" && len(fp.JSONPath) == 0 {
+		return nil, fmt.Errorf("cast requires a JSON path: %s", fp.Path)
+	}
+
+	fp.Model = model.Name
+	fp.Field = field
+	return fp, nil
+}
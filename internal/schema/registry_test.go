@@ -1,6 +1,8 @@
 package schema
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"udv/internal/config"
@@ -337,3 +339,318 @@ func TestAggregatableFields(t *testing.T) {
 		}
 	}
 }
+
+func setupRelationRegistry(t *testing.T) *Registry {
+	cfg := &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "countries",
+				Table:      "countries",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "code", Type: "string"},
+				},
+			},
+			{
+				Name:       "users",
+				Table:      "users",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "string"},
+					{Name: "country_id", Type: "integer"},
+					{Name: "profile", Type: "json"},
+				},
+			},
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "user_id", Type: "integer"},
+					{Name: "amount", Type: "decimal"},
+					{Name: "metadata", Type: "json"},
+				},
+			},
+		},
+	}
+
+	reg := NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+	if err := reg.AddRelation("orders", &Relation{Name: "user", Type: ManyToOne, TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}); err != nil {
+		t.Fatalf("AddRelation(orders.user) error = %v", err)
+	}
+	if err := reg.AddRelation("users", &Relation{Name: "country", Type: ManyToOne, TargetModel: "countries", ForeignKey: "country_id", ReferenceKey: "id"}); err != nil {
+		t.Fatalf("AddRelation(users.country) error = %v", err)
+	}
+	return reg
+}
+
+func TestAddRelation(t *testing.T) {
+	reg := setupRelationRegistry(t)
+
+	tests := []struct {
+		name  string
+		model string
+		rel   *Relation
+	}{
+		{"unknown model", "nonexistent", &Relation{Name: "x", TargetModel: "users", ForeignKey: "id", ReferenceKey: "id"}},
+		{"missing name", "orders", &Relation{TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}},
+		{"dotted name", "orders", &Relation{Name: "a.b", TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}},
+		{"duplicate", "orders", &Relation{Name: "user", TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}},
+		{"conflicts with field", "orders", &Relation{Name: "amount", TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "id"}},
+		{"unknown target", "orders", &Relation{Name: "buyer", TargetModel: "people", ForeignKey: "user_id", ReferenceKey: "id"}},
+		{"unknown foreign key", "orders", &Relation{Name: "buyer", TargetModel: "users", ForeignKey: "buyer_id", ReferenceKey: "id"}},
+		{"unknown reference key", "orders", &Relation{Name: "buyer", TargetModel: "users", ForeignKey: "user_id", ReferenceKey: "uid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := reg.AddRelation(tt.model, tt.rel); err == nil {
+				t.Errorf("AddRelation() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestResolveFieldPath(t *testing.T) {
+	reg := setupRelationRegistry(t)
+
+	fp, err := reg.ResolveFieldPath("orders", "amount")
+	if err != nil {
+		t.Fatalf("ResolveFieldPath(amount) error = %v", err)
+	}
+	if len(fp.Relations) != 0 || fp.Model != "orders" || fp.Field.Name != "amount" {
+		t.Errorf("ResolveFieldPath(amount) = %+v", fp)
+	}
+
+	fp, err = reg.ResolveFieldPath("orders", "user.country.code")
+	if err != nil {
+		t.Fatalf("ResolveFieldPath(user.country.code) error = %v", err)
+	}
+	if len(fp.Relations) != 2 {
+		t.Fatalf("ResolveFieldPath(user.country.code) has %d relations, want 2", len(fp.Relations))
+	}
+	if fp.Relations[0].Name != "user" || fp.Relations[1].Name != "country" {
+		t.Errorf("Relations = %s, %s; want user, country", fp.Relations[0].Name, fp.Relations[1].Name)
+	}
+	if fp.Model != "countries" || fp.Field.Name != "code" || fp.Field.Type != "string" {
+		t.Errorf("ResolveFieldPath(user.country.code) = model %s field %+v", fp.Model, fp.Field)
+	}
+
+	invalid := []string{"", "user.", "user..email", "buyer.email", "user.missing", "amount.value", "user.country.code.x"}
+	for _, path := range invalid {
+		if _, err := reg.ResolveFieldPath("orders", path); err == nil {
+			t.Errorf("ResolveFieldPath(%q) error = nil, want error", path)
+		}
+	}
+}
+
+func TestResolveFieldPath_JSON(t *testing.T) {
+	reg := setupRelationRegistry(t)
+
+	tests := []struct {
+		path     string
+		field    string
+		jsonPath []string
+		cast     string
+		typ      string
+		document bool
+	}{
+		{"metadata", "metadata", nil, "", "json", true},
+		{"metadata.shipping.country", "metadata", []string{"shipping", "country"}, "", "string", true},
+		{"metadata.weight::float", "metadata", []string{"weight"}, "float", "float", false},
+		{"metadata.items.0::json", "metadata", []string{"items", "0"}, "json", "json", true},
+		{"user.profile.tier", "profile", []string{"tier"}, "", "string", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			fp, err := reg.ResolveFieldPath("orders", tt.path)
+			if err != nil {
+				t.Fatalf("ResolveFieldPath() error = %v", err)
+			}
+			if fp.Field.Name != tt.field || !reflect.DeepEqual(fp.JSONPath, tt.jsonPath) || fp.Cast != tt.cast {
+				t.Errorf("ResolveFieldPath() = field %s path %v cast %q; want %s %v %q", fp.Field.Name, fp.JSONPath, fp.Cast, tt.field, tt.jsonPath, tt.cast)
+			}
+			if fp.Type() != tt.typ {
+				t.Errorf("Type() = %s, want %s", fp.Type(), tt.typ)
+			}
+			if fp.IsJSONDocument() != tt.document {
+				t.Errorf("IsJSONDocument() = %v, want %v", fp.IsJSONDocument(), tt.document)
+			}
+		})
+	}
+
+	invalid := []string{
+		"metadata.",
+		"metadata.weight::money",
+		"amount::integer",
+		"metadata::float",
+		"amount.cents",
+		"metadata.a.b.c.d.e.f.g.h.i",
+	}
+	for _, path := range invalid {
+		if _, err := reg.ResolveFieldPath("orders", path); err == nil {
+			t.Errorf("ResolveFieldPath(%q) error = nil, want error", path)
+		}
+	}
+}
+
+func relationConfig(orderRelations []config.Relation) *config.Config {
+	return &config.Config{
+		Models: []config.Model{
+			{
+				Name:       "orders",
+				Table:      "orders",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "user_id", Type: "integer"},
+				},
+				Relations: orderRelations,
+			},
+			{
+				Name:       "users",
+				Table:      "users",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "string"},
+				},
+				Relations: []config.Relation{
+					{Name: "orders", Type: "one_to_many", Model: "orders", ReferenceKey: "user_id"},
+				},
+			},
+			{
+				Name:       "tags",
+				Table:      "tags",
+				PrimaryKey: "id",
+				Fields: []config.Field{
+					{Name: "id", Type: "integer"},
+					{Name: "label", Type: "string"},
+				},
+			},
+			{
+				Name:       "order_tags",
+				Table:      "order_tags",
+				PrimaryKey: "order_id",
+				Fields: []config.Field{
+					{Name: "order_id", Type: "integer"},
+					{Name: "tag_id", Type: "integer"},
+				},
+			},
+		},
+	}
+}
+
+func TestLoadFromConfigRelations(t *testing.T) {
+	cfg := relationConfig([]config.Relation{
+		{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "user_id"},
+		{Name: "tags", Type: "many_to_many", Model: "tags",
+			JoinTable: &config.JoinTable{Table: "order_tags", SourceKey: "order_id", TargetKey: "tag_id"}},
+	})
+
+	reg := NewRegistry()
+	if err := reg.LoadFromConfig(cfg); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v, want nil", err)
+	}
+
+	user, err := reg.GetRelation("orders", "user")
+	if err != nil {
+		t.Fatalf("GetRelation(orders, user) error = %v", err)
+	}
+	if user.Type != ManyToOne || user.TargetModel != "users" || user.ForeignKey != "user_id" {
+		t.Errorf("GetRelation(orders, user) = %+v", user)
+	}
+	if user.ReferenceKey != "id" {
+		t.Errorf("user.ReferenceKey = %s, want target primary key id", user.ReferenceKey)
+	}
+
+	orders, err := reg.GetRelation("users", "orders")
+	if err != nil {
+		t.Fatalf("GetRelation(users, orders) error = %v", err)
+	}
+	if orders.Type != OneToMany || orders.ForeignKey != "id" || orders.ReferenceKey != "user_id" {
+		t.Errorf("GetRelation(users, orders) = %+v, want id -> orders.user_id", orders)
+	}
+
+	tags, err := reg.GetRelation("orders", "tags")
+	if err != nil {
+		t.Fatalf("GetRelation(orders, tags) error = %v", err)
+	}
+	if tags.JoinTable != "order_tags" || tags.JoinSourceKey != "order_id" || tags.JoinTargetKey != "tag_id" {
+		t.Errorf("GetRelation(orders, tags) = %+v", tags)
+	}
+
+	relations, err := reg.ListRelations("orders")
+	if err != nil {
+		t.Fatalf("ListRelations(orders) error = %v", err)
+	}
+	if len(relations) != 2 || relations[0].Name != "user" || relations[1].Name != "tags" {
+		t.Errorf("ListRelations(orders) returned %d relations in wrong order", len(relations))
+	}
+
+	if _, err := reg.GetRelation("orders", "nonexistent"); err == nil {
+		t.Errorf("GetRelation(orders, nonexistent) error = nil, want error")
+	}
+	if _, err := reg.ListRelations("nonexistent"); err == nil {
+		t.Errorf("ListRelations(nonexistent) error = nil, want error")
+	}
+
+	if _, err := reg.ResolveFieldPath("orders", "tags.label"); err != nil {
+		t.Errorf("ResolveFieldPath(orders, tags.label) error = %v", err)
+	}
+}
+
+func TestLoadFromConfigInvalidRelations(t *testing.T) {
+	tests := []struct {
+		name   string
+		rel    config.Relation
+		errMsg string
+	}{
+		{
+			name:   "unknown target model",
+			rel:    config.Relation{Name: "user", Type: "many_to_one", Model: "people", ForeignKey: "user_id"},
+			errMsg: "target model not found: people",
+		},
+		{
+			name:   "unknown foreign key",
+			rel:    config.Relation{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "buyer_id"},
+			errMsg: "foreign key field not found: orders.buyer_id",
+		},
+		{
+			name:   "unknown reference key",
+			rel:    config.Relation{Name: "user", Type: "many_to_one", Model: "users", ForeignKey: "user_id", ReferenceKey: "uid"},
+			errMsg: "reference key field not found: users.uid",
+		},
+		{
+			name: "unknown join table key",
+			rel: config.Relation{Name: "tags", Type: "many_to_many", Model: "tags",
+				JoinTable: &config.JoinTable{Table: "order_tags", SourceKey: "order_id", TargetKey: "label_id"}},
+			errMsg: "join table key not found: order_tags.label_id",
+		},
+		{
+			name:   "invalid type",
+			rel:    config.Relation{Name: "user", Type: "belongs_to", Model: "users", ForeignKey: "user_id"},
+			errMsg: "invalid type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			err := reg.LoadFromConfig(relationConfig([]config.Relation{tt.rel}))
+			if err == nil {
+				t.Fatalf("LoadFromConfig() error = nil, want error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("LoadFromConfig() error = %v, want to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -32,10 +32,28 @@ type Field struct {
 
 // Model represents a database table in the JSON config
 type Model struct {
-	Name       string  `json:"name"`
-	Table      string  `json:"table"`
-	PrimaryKey string  `json:"primaryKey"`
-	Fields     []Field `json:"fields"`
+	Name       string     `json:"name"`
+	Table      string     `json:"table"`
+	PrimaryKey string     `json:"primaryKey"`
+	Fields     []Field    `json:"fields"`
+	Relations  []Relation `json:"relations,omitempty"`
+}
+
+// Relation represents a relationship between models in the JSON config
+type Relation struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Model        string     `json:"model"`
+	ForeignKey   string     `json:"foreignKey,omitempty"`
+	ReferenceKey string     `json:"referenceKey,omitempty"`
+	JoinTable    *JoinTable `json:"joinTable,omitempty"`
+}
+
+// JoinTable describes the link table of a many_to_many relation
+type JoinTable struct {
+	Table     string `json:"table"`
+	SourceKey string `json:"sourceKey"`
+	TargetKey string `json:"targetKey"`
 }
 
 // ModelConfig represents the complete models.json structure
@@ -43,7 +61,7 @@ type ModelConfig struct {
 	Models []Model `json:"models"`
 }
 
-// ColumnInfo holds PostgreSQL column metadata
+// ColumnInfo holds column metadata
 type ColumnInfo struct {
 	ColumnName    string
 	DataType      string
@@ -52,21 +70,78 @@ type ColumnInfo struct {
 	OrdinalPos    int
 }
 
-// TableInfo holds PostgreSQL table metadata
+// TableInfo holds table metadata
 type TableInfo struct {
 	TableName string
 	Columns   []ColumnInfo
 	PrimaryKey string
 }
 
+// ForeignKeyInfo holds a single-column foreign key constraint
+type ForeignKeyInfo struct {
+	ConstraintName string
+	Table          string
+	Column         string
+	RefTable       string
+	RefColumn      string
+}
+
+// Introspector reads table metadata from one kind of database
+type Introspector interface {
+	GetAllTables() ([]string, error)
+	GetTableColumns(tableName string) ([]ColumnInfo, error)
+	GetPrimaryKey(tableName string) (string, error)
+	GetForeignKeys(tableName string) ([]ForeignKeyInfo, error)
+	// MapType maps a column's data type to a JSON model type
+	MapType(dataType string) FieldType
+}
+
 // SchemaProcessor handles database schema introspection
 type SchemaProcessor struct {
-	db *sql.DB
+	source Introspector
 }
 
-// NewSchemaProcessor creates a new schema processor
+// NewSchemaProcessor creates a new schema processor for a PostgreSQL
+// database
 func NewSchemaProcessor(db *sql.DB) *SchemaProcessor {
-	return &SchemaProcessor{db: db}
+	return &SchemaProcessor{source: &postgresIntrospector{db: db}}
+}
+
+// NewSQLiteSchemaProcessor creates a new schema processor for a SQLite
+// database
+func NewSQLiteSchemaProcessor(db *sql.DB) *SchemaProcessor {
+	return &SchemaProcessor{source: &sqliteIntrospector{db: db}}
+}
+
+// GetTableColumns fetches column information for a table
+func (sp *SchemaProcessor) GetTableColumns(tableName string) ([]ColumnInfo, error) {
+	return sp.source.GetTableColumns(tableName)
+}
+
+// GetPrimaryKey fetches the primary key for a table
+func (sp *SchemaProcessor) GetPrimaryKey(tableName string) (string, error) {
+	return sp.source.GetPrimaryKey(tableName)
+}
+
+// GetForeignKeys fetches the single-column foreign keys declared on a table
+func (sp *SchemaProcessor) GetForeignKeys(tableName string) ([]ForeignKeyInfo, error) {
+	return sp.source.GetForeignKeys(tableName)
+}
+
+// GetAllTables fetches all table names from the database
+func (sp *SchemaProcessor) GetAllTables() ([]string, error) {
+	return sp.source.GetAllTables()
+}
+
+// postgresIntrospector reads the public schema of a PostgreSQL database
+// from information_schema and the pg_catalog tables
+type postgresIntrospector struct {
+	db *sql.DB
+}
+
+// MapType implements Introspector
+func (p *postgresIntrospector) MapType(dataType string) FieldType {
+	return mapPostgreSQLTypeToJSON(dataType)
 }
 
 // mapPostgreSQLTypeToJSON maps PostgreSQL data types to JSON model types
@@ -106,7 +181,7 @@ func mapPostgreSQLTypeToJSON(pgType string) FieldType {
 }
 
 // GetTableColumns fetches column information for a table
-func (sp *SchemaProcessor) GetTableColumns(tableName string) ([]ColumnInfo, error) {
+func (p *postgresIntrospector) GetTableColumns(tableName string) ([]ColumnInfo, error) {
 	query := `
 		SELECT
 			column_name,
@@ -122,7 +197,7 @@ func (sp *SchemaProcessor) GetTableColumns(tableName string) ([]ColumnInfo, erro
 			ordinal_position ASC
 	`
 
-	rows, err := sp.db.Query(query, tableName)
+	rows, err := p.db.Query(query, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query columns: %w", err)
 	}
@@ -148,7 +223,7 @@ func (sp *SchemaProcessor) GetTableColumns(tableName string) ([]ColumnInfo, erro
 }
 
 // GetPrimaryKey fetches the primary key for a table
-func (sp *SchemaProcessor) GetPrimaryKey(tableName string) (string, error) {
+func (p *postgresIntrospector) GetPrimaryKey(tableName string) (string, error) {
 	query := `
 		SELECT a.attname
 		FROM pg_index i
@@ -159,7 +234,7 @@ func (sp *SchemaProcessor) GetPrimaryKey(tableName string) (string, error) {
 	`
 
 	var pkName string
-	err := sp.db.QueryRow(query, tableName).Scan(&pkName)
+	err := p.db.QueryRow(query, tableName).Scan(&pkName)
 	if err != nil && err != sql.ErrNoRows {
 		return "", fmt.Errorf("failed to query primary key: %w", err)
 	}
@@ -171,8 +246,55 @@ func (sp *SchemaProcessor) GetPrimaryKey(tableName string) (string, error) {
 	return pkName, nil
 }
 
+// GetForeignKeys fetches the single-column foreign keys declared on a table.
+// Composite foreign keys cannot be expressed as relations and are skipped.
+func (p *postgresIntrospector) GetForeignKeys(tableName string) ([]ForeignKeyInfo, error) {
+	query := `
+		SELECT
+			c.conname,
+			src.relname,
+			sa.attname,
+			ref.relname,
+			ra.attname
+		FROM pg_constraint c
+		JOIN pg_class src ON src.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = src.relnamespace
+		JOIN pg_class ref ON ref.oid = c.confrelid
+		JOIN pg_attribute sa ON sa.attrelid = c.conrelid AND sa.attnum = c.conkey[1]
+		JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = c.confkey[1]
+		WHERE
+			c.contype = 'f'
+			AND src.relname = $1
+			AND n.nspname = 'public'
+			AND array_length(c.conkey, 1) = 1
+		ORDER BY
+			c.conname ASC
+	`
+
+	rows, err := p.db.Query(query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var fks []ForeignKeyInfo
+	for rows.Next() {
+		var fk ForeignKeyInfo
+		if err := rows.Scan(&fk.ConstraintName, &fk.Table, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key: %w", err)
+		}
+		fks = append(fks, fk)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating foreign keys: %w", err)
+	}
+
+	return fks, nil
+}
+
 // GetAllTables fetches all table names from the database
-func (sp *SchemaProcessor) GetAllTables() ([]string, error) {
+func (p *postgresIntrospector) GetAllTables() ([]string, error) {
 	query := `
 		SELECT table_name
 		FROM information_schema.tables
@@ -180,7 +302,7 @@ func (sp *SchemaProcessor) GetAllTables() ([]string, error) {
 		ORDER BY table_name ASC
 	`
 
-	rows, err := sp.db.Query(query)
+	rows, err := p.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tables: %w", err)
 	}
@@ -202,9 +324,11 @@ func (sp *SchemaProcessor) GetAllTables() ([]string, error) {
 	return tables, nil
 }
 
-// GenerateModels creates Model objects from database schema
+// GenerateModels creates Model objects from database schema, including
+// relations derived from foreign key constraints
 func (sp *SchemaProcessor) GenerateModels(tableNames []string) ([]Model, error) {
 	var models []Model
+	var foreignKeys []ForeignKeyInfo
 
 	for _, tableName := range tableNames {
 		// Get columns
@@ -224,12 +348,19 @@ func (sp *SchemaProcessor) GenerateModels(tableNames []string) ([]Model, error)
 			return nil, fmt.Errorf("failed to get primary key for table %s: %w", tableName, err)
 		}
 
+		// Get foreign keys
+		fks, err := sp.GetForeignKeys(tableName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get foreign keys for table %s: %w", tableName, err)
+		}
+		foreignKeys = append(foreignKeys, fks...)
+
 		// Convert columns to fields
 		var fields []Field
 		for _, col := range columns {
 			field := Field{
 				Name:     col.ColumnName,
-				Type:     mapPostgreSQLTypeToJSON(col.DataType),
+				Type:     sp.source.MapType(col.DataType),
 				Nullable: col.IsNullable,
 			}
 			fields = append(fields, field)
@@ -246,9 +377,147 @@ func (sp *SchemaProcessor) GenerateModels(tableNames []string) ([]Model, error)
 		models = append(models, model)
 	}
 
+	BuildRelations(models, foreignKeys)
+
 	return models, nil
 }
 
+// BuildRelations adds relations derived from foreign keys to the models.
+// Each foreign key becomes a many_to_one relation on the referencing model
+// and an inverse one_to_many relation on the referenced model. A table that
+// holds exactly two foreign keys and nothing else but its primary key and
+// timestamps is treated as a join table, and its two ends also get
+// many_to_many relations to each other. Foreign keys pointing at tables
+// outside the generated set are ignored.
+func BuildRelations(models []Model, foreignKeys []ForeignKeyInfo) {
+	byTable := make(map[string]int, len(models))
+	for i, m := range models {
+		byTable[m.Table] = i
+	}
+
+	fksByTable := make(map[string][]ForeignKeyInfo)
+	for _, fk := range foreignKeys {
+		_, srcOK := byTable[fk.Table]
+		_, refOK := byTable[fk.RefTable]
+		if !srcOK || !refOK {
+			continue
+		}
+		fksByTable[fk.Table] = append(fksByTable[fk.Table], fk)
+	}
+
+	for _, fk := range foreignKeys {
+		srcIdx, srcOK := byTable[fk.Table]
+		refIdx, refOK := byTable[fk.RefTable]
+		if !srcOK || !refOK {
+			continue
+		}
+
+		src := &models[srcIdx]
+		name := uniqueRelationName(src, relationNameForColumn(fk.Column, fk.RefTable))
+		src.Relations = append(src.Relations, Relation{
+			Name:         name,
+			Type:         "many_to_one",
+			Model:        models[refIdx].Name,
+			ForeignKey:   fk.Column,
+			ReferenceKey: fk.RefColumn,
+		})
+
+		ref := &models[refIdx]
+		inverse := fk.Table
+		if relationTaken(ref, inverse) {
+			inverse = fk.Table + "_by_" + name
+		}
+		ref.Relations = append(ref.Relations, Relation{
+			Name:         uniqueRelationName(ref, inverse),
+			Type:         "one_to_many",
+			Model:        src.Name,
+			ForeignKey:   fk.RefColumn,
+			ReferenceKey: fk.Column,
+		})
+	}
+
+	for _, m := range models {
+		fks := fksByTable[m.Table]
+		if !isJoinTable(m, fks) {
+			continue
+		}
+
+		for _, pair := range [][2]ForeignKeyInfo{{fks[0], fks[1]}, {fks[1], fks[0]}} {
+			from, to := pair[0], pair[1]
+			owner := &models[byTable[from.RefTable]]
+			target := models[byTable[to.RefTable]]
+
+			name := to.RefTable
+			if relationTaken(owner, name) {
+				name = m.Table + "_" + relationNameForColumn(to.Column, to.RefTable)
+			}
+			owner.Relations = append(owner.Relations, Relation{
+				Name:         uniqueRelationName(owner, name),
+				Type:         "many_to_many",
+				Model:        target.Name,
+				ForeignKey:   from.RefColumn,
+				ReferenceKey: to.RefColumn,
+				JoinTable: &JoinTable{
+					Table:     m.Table,
+					SourceKey: from.Column,
+					TargetKey: to.Column,
+				},
+			})
+		}
+	}
+}
+
+// isJoinTable reports whether a model is a pure link table: exactly two
+// foreign keys, with every other column being the primary key or a timestamp
+func isJoinTable(m Model, fks []ForeignKeyInfo) bool {
+	if len(fks) != 2 || fks[0].Column == fks[1].Column {
+		return false
+	}
+
+	for _, f := range m.Fields {
+		if f.Name == fks[0].Column || f.Name == fks[1].Column || f.Name == m.PrimaryKey || f.Type == TypeTimestamp {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// relationNameForColumn derives a relation name from a foreign key column,
+// e.g. "user_id" -> "user", falling back to the referenced table name
+func relationNameForColumn(column, refTable string) string {
+	if name := strings.TrimSuffix(column, "_id"); name != column && name != "" {
+		return name
+	}
+	return refTable
+}
+
+// relationTaken reports whether a name is already used by a field or
+// relation of the model
+func relationTaken(m *Model, name string) bool {
+	for _, f := range m.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	for _, r := range m.Relations {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueRelationName returns name, or name with a numeric suffix, such that
+// it does not clash with the model's fields or existing relations
+func uniqueRelationName(m *Model, name string) string {
+	candidate := name
+	for i := 2; relationTaken(m, candidate); i++ {
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+	return candidate
+}
+
 // GenerateAndSaveModels generates models from database and saves to file
 func (sp *SchemaProcessor) GenerateAndSaveModels(outputPath string, tableNames []string) error {
 	var tables []string
@@ -97,3 +97,161 @@ func TestFieldTypeValues(t *testing.T) {
 		}
 	}
 }
+
+func findRelation(m Model, name string) *Relation {
+	for i := range m.Relations {
+		if m.Relations[i].Name == name {
+			return &m.Relations[i]
+		}
+	}
+	return nil
+}
+
+// TestBuildRelations tests relation generation from foreign keys
+func TestBuildRelations(t *testing.T) {
+	models := []Model{
+		{Name: "users", Table: "users", PrimaryKey: "id", Fields: []Field{
+			{Name: "id", Type: TypeInteger},
+			{Name: "email", Type: TypeString},
+		}},
+		{Name: "orders", Table: "orders", PrimaryKey: "id", Fields: []Field{
+			{Name: "id", Type: TypeInteger},
+			{Name: "user_id", Type: TypeInteger},
+			{Name: "approver", Type: TypeInteger},
+		}},
+		{Name: "tags", Table: "tags", PrimaryKey: "id", Fields: []Field{
+			{Name: "id", Type: TypeInteger},
+			{Name: "label", Type: TypeString},
+		}},
+		{Name: "order_tags", Table: "order_tags", PrimaryKey: "id", Fields: []Field{
+			{Name: "id", Type: TypeInteger},
+			{Name: "order_id", Type: TypeInteger},
+			{Name: "tag_id", Type: TypeInteger},
+			{Name: "created_at", Type: TypeTimestamp},
+		}},
+	}
+
+	fks := []ForeignKeyInfo{
+		{ConstraintName: "orders_user_fk", Table: "orders", Column: "user_id", RefTable: "users", RefColumn: "id"},
+		{ConstraintName: "orders_approver_fk", Table: "orders", Column: "approver", RefTable: "users", RefColumn: "id"},
+		{ConstraintName: "order_tags_order_fk", Table: "order_tags", Column: "order_id", RefTable: "orders", RefColumn: "id"},
+		{ConstraintName: "order_tags_tag_fk", Table: "order_tags", Column: "tag_id", RefTable: "tags", RefColumn: "id"},
+		{ConstraintName: "external_fk", Table: "orders", Column: "user_id", RefTable: "not_generated", RefColumn: "id"},
+	}
+
+	BuildRelations(models, fks)
+	users, orders, tags, orderTags := models[0], models[1], models[2], models[3]
+
+	// many_to_one named after the column
+	if rel := findRelation(orders, "user"); rel == nil || rel.Type != "many_to_one" || rel.Model != "users" || rel.ForeignKey != "user_id" || rel.ReferenceKey != "id" {
+		t.Errorf("orders.user = %+v, want many_to_one users via user_id", rel)
+	}
+
+	// Column without _id suffix falls back to the referenced table name
+	if rel := findRelation(orders, "users"); rel == nil || rel.ForeignKey != "approver" {
+		t.Errorf("orders.users = %+v, want many_to_one via approver", rel)
+	}
+
+	// Inverse one_to_many, disambiguated for the second FK from the same table
+	if rel := findRelation(users, "orders"); rel == nil || rel.Type != "one_to_many" || rel.ForeignKey != "id" || rel.ReferenceKey != "user_id" {
+		t.Errorf("users.orders = %+v, want one_to_many via orders.user_id", rel)
+	}
+	if rel := findRelation(users, "orders_by_users"); rel == nil || rel.ReferenceKey != "approver" {
+		t.Errorf("users.orders_by_users = %+v, want one_to_many via orders.approver", rel)
+	}
+
+	// Join table yields many_to_many on both ends
+	rel := findRelation(orders, "tags")
+	if rel == nil || rel.Type != "many_to_many" || rel.Model != "tags" || rel.JoinTable == nil {
+		t.Fatalf("orders.tags = %+v, want many_to_many", rel)
+	}
+	if rel.JoinTable.Table != "order_tags" || rel.JoinTable.SourceKey != "order_id" || rel.JoinTable.TargetKey != "tag_id" {
+		t.Errorf("orders.tags.joinTable = %+v", rel.JoinTable)
+	}
+	if rel := findRelation(tags, "orders"); rel == nil || rel.Type != "many_to_many" || rel.JoinTable.SourceKey != "tag_id" {
+		t.Errorf("tags.orders = %+v, want many_to_many via tag_id", rel)
+	}
+
+	// The join table keeps its own many_to_one relations
+	if len(orderTags.Relations) != 2 {
+		t.Errorf("order_tags has %d relations, want 2", len(orderTags.Relations))
+	}
+}
+
+// TestIsJoinTable tests join table detection
+func TestIsJoinTable(t *testing.T) {
+	twoFKs := []ForeignKeyInfo{
+		{Table: "t", Column: "a_id", RefTable: "a", RefColumn: "id"},
+		{Table: "t", Column: "b_id", RefTable: "b", RefColumn: "id"},
+	}
+
+	tests := []struct {
+		name   string
+		model  Model
+		fks    []ForeignKeyInfo
+		expect bool
+	}{
+		{
+			name: "pure link table",
+			model: Model{PrimaryKey: "a_id", Fields: []Field{
+				{Name: "a_id", Type: TypeInteger}, {Name: "b_id", Type: TypeInteger},
+			}},
+			fks:    twoFKs,
+			expect: true,
+		},
+		{
+			name: "link table with surrogate key and timestamps",
+			model: Model{PrimaryKey: "id", Fields: []Field{
+				{Name: "id", Type: TypeInteger}, {Name: "a_id", Type: TypeInteger},
+				{Name: "b_id", Type: TypeInteger}, {Name: "created_at", Type: TypeTimestamp},
+			}},
+			fks:    twoFKs,
+			expect: true,
+		},
+		{
+			name: "table with payload column",
+			model: Model{PrimaryKey: "id", Fields: []Field{
+				{Name: "id", Type: TypeInteger}, {Name: "a_id", Type: TypeInteger},
+				{Name: "b_id", Type: TypeInteger}, {Name: "quantity", Type: TypeInteger},
+			}},
+			fks:    twoFKs,
+			expect: false,
+		},
+		{
+			name: "single foreign key",
+			model: Model{PrimaryKey: "id", Fields: []Field{
+				{Name: "id", Type: TypeInteger}, {Name: "a_id", Type: TypeInteger},
+			}},
+			fks:    twoFKs[:1],
+			expect: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isJoinTable(tt.model, tt.fks); got != tt.expect {
+				t.Errorf("isJoinTable() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+// TestRelationNameForColumn tests relation naming from FK columns
+func TestRelationNameForColumn(t *testing.T) {
+	tests := []struct {
+		column   string
+		refTable string
+		expected string
+	}{
+		{"user_id", "users", "user"},
+		{"billing_address_id", "addresses", "billing_address"},
+		{"owner", "users", "users"},
+		{"_id", "things", "things"},
+	}
+
+	for _, test := range tests {
+		if got := relationNameForColumn(test.column, test.refTable); got != test.expected {
+			t.Errorf("relationNameForColumn(%q, %q) = %q, want %q", test.column, test.refTable, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package schema_processor
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// sqliteIntrospector reads the schema of a SQLite database from
+// sqlite_master and the table_info and foreign_key_list pragmas
+type sqliteIntrospector struct {
+	db *sql.DB
+}
+
+// sqliteForeignKey is one row of PRAGMA foreign_key_list: a column of
+// foreign key id. Composite keys span several rows with the same id, and To
+// is NULL when the key references the parent's primary key implicitly.
+type sqliteForeignKey struct {
+	ID       int
+	RefTable string
+	From     string
+	To       sql.NullString
+}
+
+// quoteSQLiteIdentifier quotes a table name for a pragma, which cannot take
+// it as a parameter
+func quoteSQLiteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// mapSQLiteTypeToJSON maps a SQLite declared column type to a JSON model
+// type. SQLite types are free-form, so common type names are matched first
+// and the rest follow SQLite's type affinity rules (any name containing
+// INT is an integer, and so on).
+func mapSQLiteTypeToJSON(declType string) FieldType {
+	declType = strings.ToUpper(strings.TrimSpace(declType))
+	baseType := strings.TrimSpace(strings.Split(declType, "(")[0])
+
+	switch baseType {
+	case "BOOLEAN", "BOOL":
+		return TypeBoolean
+	case "DATE", "DATETIME", "TIMESTAMP", "TIME":
+		return TypeTimestamp
+	case "JSON", "JSONB":
+		return TypeJSON
+	case "UUID":
+		return TypeUUID
+	case "NUMERIC", "DECIMAL", "MONEY":
+		return TypeDecimal
+	case "":
+		// No declared type: values of any kind, shown as text
+		return TypeString
+	}
+
+	switch {
+	case strings.Contains(baseType, "INT"):
+		return TypeInteger
+	case strings.Contains(baseType, "CHAR"), strings.Contains(baseType, "CLOB"), strings.Contains(baseType, "TEXT"):
+		return TypeString
+	case strings.Contains(baseType, "BLOB"):
+		return TypeBinary
+	case strings.Contains(baseType, "REAL"), strings.Contains(baseType, "FLOA"), strings.Contains(baseType, "DOUB"):
+		return TypeDecimal
+	default:
+		log.Printf("Warning: Unknown SQLite type '%s', defaulting to string", declType)
+		return TypeString
+	}
+}
+
+// MapType implements Introspector
+func (s *sqliteIntrospector) MapType(dataType string) FieldType {
+	return mapSQLiteTypeToJSON(dataType)
+}
+
+// GetAllTables implements Introspector, skipping SQLite's internal tables
+func (s *sqliteIntrospector) GetAllTables() ([]string, error) {
+	query := `
+		SELECT name
+		FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name ASC
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, tableName)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+
+	return tables, nil
+}
+
+// tableInfo runs PRAGMA table_info, returning the columns in declaration
+// order and, for each, its position in the primary key (0 when not part of
+// it)
+func (s *sqliteIntrospector) tableInfo(tableName string) ([]ColumnInfo, []int, error) {
+	rows, err := s.db.Query("PRAGMA table_info(" + quoteSQLiteIdentifier(tableName) + ")")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []ColumnInfo
+	var pkPositions []int
+	for rows.Next() {
+		var col ColumnInfo
+		var cid, notNull, pk int
+		if err := rows.Scan(&cid, &col.ColumnName, &col.DataType, &notNull, &col.ColumnDefault, &pk); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		col.OrdinalPos = cid + 1
+		// SQLite lets primary key columns hold NULL unless declared NOT
+		// NULL, but UDV needs them to identify rows
+		col.IsNullable = notNull == 0 && pk == 0
+		columns = append(columns, col)
+		pkPositions = append(pkPositions, pk)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating columns: %w", err)
+	}
+
+	return columns, pkPositions, nil
+}
+
+// GetTableColumns implements Introspector
+func (s *sqliteIntrospector) GetTableColumns(tableName string) ([]ColumnInfo, error) {
+	columns, _, err := s.tableInfo(tableName)
+	return columns, err
+}
+
+// GetPrimaryKey implements Introspector, returning the first column of the
+// primary key
+func (s *sqliteIntrospector) GetPrimaryKey(tableName string) (string, error) {
+	columns, pkPositions, err := s.tableInfo(tableName)
+	if err != nil {
+		return "", fmt.Errorf("failed to query primary key: %w", err)
+	}
+	for i, pos := range pkPositions {
+		if pos == 1 {
+			return columns[i].ColumnName, nil
+		}
+	}
+	// Fallback: assume "id" if no primary key found
+	return "id", nil
+}
+
+// GetForeignKeys implements Introspector using PRAGMA foreign_key_list
+func (s *sqliteIntrospector) GetForeignKeys(tableName string) ([]ForeignKeyInfo, error) {
+	rows, err := s.db.Query("PRAGMA foreign_key_list(" + quoteSQLiteIdentifier(tableName) + ")")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []sqliteForeignKey
+	for rows.Next() {
+		var fk sqliteForeignKey
+		var seq int
+		var onUpdate, onDelete, match string
+		if err := rows.Scan(&fk.ID, &seq, &fk.RefTable, &fk.From, &fk.To, &onUpdate, &onDelete, &match); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key: %w", err)
+		}
+		keys = append(keys, fk)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating foreign keys: %w", err)
+	}
+
+	return sqliteForeignKeyInfo(tableName, keys, s.GetPrimaryKey)
+}
+
+// sqliteForeignKeyInfo turns foreign_key_list rows into single-column
+// foreign keys, skipping composite ones. SQLite foreign keys are unnamed,
+// so they are named like PostgreSQL's defaults (orders_user_id_fkey).
+// Implicit references are resolved with primaryKey.
+func sqliteForeignKeyInfo(tableName string, keys []sqliteForeignKey, primaryKey func(string) (string, error)) ([]ForeignKeyInfo, error) {
+	columnsPerKey := make(map[int]int)
+	for _, k := range keys {
+		columnsPerKey[k.ID]++
+	}
+
+	var fks []ForeignKeyInfo
+	for _, k := range keys {
+		if columnsPerKey[k.ID] != 1 {
+			continue
+		}
+		refColumn := k.To.String
+		if !k.To.Valid || refColumn == "" {
+			pk, err := primaryKey(k.RefTable)
+			if err != nil {
+				return nil, err
+			}
+			refColumn = pk
+		}
+		fks = append(fks, ForeignKeyInfo{
+			ConstraintName: tableName + "_" + k.From + "_fkey",
+			Table:          tableName,
+			Column:         k.From,
+			RefTable:       k.RefTable,
+			RefColumn:      refColumn,
+		})
+	}
+
+	sort.Slice(fks, func(i, j int) bool {
+		return fks[i].ConstraintName < fks[j].ConstraintName
+	})
+	return fks, nil
+}
@@ -0,0 +1,88 @@
+package schema_processor
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+// TestMapSQLiteTypeToJSON tests the SQLite declared type mapping
+func TestMapSQLiteTypeToJSON(t *testing.T) {
+	tests := []struct {
+		declType string
+		expected FieldType
+	}{
+		// Integer affinity
+		{"INTEGER", TypeInteger},
+		{"int", TypeInteger},
+		{"BIGINT", TypeInteger},
+		{"UNSIGNED BIG INT", TypeInteger},
+
+		// Text affinity
+		{"TEXT", TypeString},
+		{"VARCHAR(255)", TypeString},
+		{"NVARCHAR(100)", TypeString},
+		{"CLOB", TypeString},
+		{"", TypeString},
+
+		// Real and numeric
+		{"REAL", TypeDecimal},
+		{"DOUBLE PRECISION", TypeDecimal},
+		{"FLOAT", TypeDecimal},
+		{"NUMERIC", TypeDecimal},
+		{"DECIMAL(10,5)", TypeDecimal},
+
+		// Common type names SQLite stores by affinity
+		{"BOOLEAN", TypeBoolean},
+		{"DATETIME", TypeTimestamp},
+		{"DATE", TypeTimestamp},
+		{"TIMESTAMP", TypeTimestamp},
+		{"JSON", TypeJSON},
+		{"UUID", TypeUUID},
+		{"BLOB", TypeBinary},
+
+		// Unknown type (should default to string)
+		{"GEOMETRY", TypeString},
+	}
+
+	for _, test := range tests {
+		t.Run(test.declType, func(t *testing.T) {
+			result := mapSQLiteTypeToJSON(test.declType)
+			if result != test.expected {
+				t.Errorf("mapSQLiteTypeToJSON(%q) = %v, want %v", test.declType, result, test.expected)
+			}
+		})
+	}
+}
+
+// TestSQLiteForeignKeyInfo tests turning PRAGMA foreign_key_list rows into
+// foreign keys
+func TestSQLiteForeignKeyInfo(t *testing.T) {
+	keys := []sqliteForeignKey{
+		// Declared last, listed first by SQLite
+		{ID: 0, RefTable: "users", From: "user_id", To: sql.NullString{String: "id", Valid: true}},
+		// Composite keys span several rows and are skipped
+		{ID: 1, RefTable: "shipments", From: "shipment_id", To: sql.NullString{String: "id", Valid: true}},
+		{ID: 1, RefTable: "shipments", From: "carrier", To: sql.NullString{String: "carrier", Valid: true}},
+		// REFERENCES coupons without a column means its primary key
+		{ID: 2, RefTable: "coupons", From: "coupon_code"},
+	}
+	primaryKey := func(table string) (string, error) {
+		if table != "coupons" {
+			t.Errorf("primary key looked up for %s", table)
+		}
+		return "code", nil
+	}
+
+	fks, err := sqliteForeignKeyInfo("orders", keys, primaryKey)
+	if err != nil {
+		t.Fatalf("sqliteForeignKeyInfo() error = %v", err)
+	}
+	want := []ForeignKeyInfo{
+		{ConstraintName: "orders_coupon_code_fkey", Table: "orders", Column: "coupon_code", RefTable: "coupons", RefColumn: "code"},
+		{ConstraintName: "orders_user_id_fkey", Table: "orders", Column: "user_id", RefTable: "users", RefColumn: "id"},
+	}
+	if !reflect.DeepEqual(fks, want) {
+		t.Errorf("sqliteForeignKeyInfo() = %+v, want %+v", fks, want)
+	}
+}